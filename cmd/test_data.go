@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
@@ -49,7 +50,7 @@ func full() {
 	}
 
 	token := "robot"
-	err = dataClient.WriteToken(token, user.Name, user.Email, "", "")
+	err = dataClient.WriteToken(token, user.Name, user.Email, "", nil)
 	if err != nil {
 		fmt.Println(err)
 	}
@@ -117,8 +118,8 @@ func extend() {
 	}
 	messagingService := messaging.GetService()
 	ticketService := ticket.GetService()
-	core.ExtendTrain(dataClient, messagingService, latestTrain, newCommits, nil)
-	core.StartTrain(dataClient, code.GetService(), messagingService, phase.GetService(), ticketService, latestTrain)
+	core.ExtendTrain(context.Background(), dataClient, messagingService, latestTrain, newCommits, nil)
+	core.StartTrain(context.Background(), dataClient, code.GetService(), messagingService, phase.GetService(), ticketService, latestTrain)
 }
 
 func create() {
@@ -159,8 +160,8 @@ func create() {
 	}
 	messagingService := messaging.GetService()
 	ticketService := ticket.GetService()
-	train := core.CreateTrain(dataClient, messagingService, "master", commits)
-	core.StartTrain(dataClient, code.GetService(), messagingService, phase.GetService(), ticketService, train)
+	train := core.CreateTrain(context.Background(), dataClient, messagingService, "master", commits)
+	core.StartTrain(context.Background(), dataClient, code.GetService(), messagingService, phase.GetService(), ticketService, train)
 }
 
 type DataKey int
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+var apiTokenNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.\-]{0,63}$`)
+
+func apiTokenEndpoints() []endpoint {
+	scopes := make([]string, len(types.Scopes))
+	for i, scope := range types.Scopes {
+		scopes[i] = scope.String()
+	}
+
+	return []endpoint{
+		newAdminEp("/api/tokens", get, fetchAPITokens).
+			describe("List API tokens, including expired and revoked ones.").
+			returns([]*types.APIToken{}),
+		newAdminEp("/api/tokens", post, createAPIToken).
+			describe("Create an API token. The token is only returned here, so store it somewhere safe.").
+			requiredForm("name", "Unique name of the token, like the automation using it.").
+			requiredForm("scopes", fmt.Sprintf(
+				"Space or comma-separated scopes: %s. train:admin includes train:write, which includes train:read. "+
+					"Tokens never get a higher role than their creator has.",
+				strings.Join(scopes, ", "))).
+			form("expires_at", "RFC3339 timestamp or date. Defaults to never expiring.").
+			returns(&newAPIToken{}),
+		newAdminEp("/api/tokens/{token_id:[0-9]+}/revoke", post, revokeAPIToken).
+			describe("Revoke an API token.").
+			returns(&types.APIToken{}),
+	}
+}
+
+// An API token, along with the token itself.
+type newAPIToken struct {
+	*types.APIToken
+	Token string `json:"token"`
+}
+
+func fetchAPITokens(_ *http.Request) response {
+	dataClient := data.NewClient()
+	tokens, err := dataClient.APITokens()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting API tokens: %v", err),
+			http.StatusInternalServerError)
+	}
+	return dataResponse(tokens)
+}
+
+func createAPIToken(r *http.Request) response {
+	err := r.ParseForm()
+	if err != nil {
+		return errorResponse("Error parsing POST form", http.StatusBadRequest)
+	}
+
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	if !apiTokenNameRegex.MatchString(name) {
+		return errorResponse(
+			"`name` must be 1-64 letters, numbers, dots, dashes or underscores",
+			http.StatusBadRequest)
+	}
+
+	scopes, err := types.ParseScopes(r.PostFormValue("scopes"))
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusBadRequest)
+	}
+	if len(scopes) == 0 {
+		return errorResponse("`scopes` must be set in POST form", http.StatusBadRequest)
+	}
+
+	var expiresAt *time.Time
+	if value := r.PostFormValue("expires_at"); value != "" {
+		expiresAt, err = parseHistoryTime(value)
+		if err != nil {
+			return errorResponse(fmt.Sprintf("Bad `expires_at` value: %v", err), http.StatusBadRequest)
+		}
+		if !expiresAt.After(time.Now()) {
+			return errorResponse("`expires_at` must be in the future", http.StatusBadRequest)
+		}
+	}
+
+	token, tokenHash, err := auth.NewAPIToken()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error generating API token: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+
+	dataClient := data.NewClient()
+	apiToken, err := dataClient.CreateAPIToken(name, tokenHash, scopes, expiresAt, authedUser)
+	if err != nil {
+		if err == data.ErrAPITokenNameTaken {
+			return errorResponse(err.Error(), http.StatusBadRequest)
+		}
+		return errorResponse(
+			fmt.Sprintf("Error creating API token: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	audit(dataClient, authedUser, types.APITokenCreateAction, "api_token", strconv.FormatUint(apiToken.ID, 10),
+		nil, apiTokenAuditState(apiToken), "")
+
+	return dataResponse(&newAPIToken{
+		APIToken: apiToken,
+		Token:    token,
+	})
+}
+
+func revokeAPIToken(r *http.Request) response {
+	tokenIDStr := mux.Vars(r)["token_id"]
+	tokenID, err := strconv.ParseUint(tokenIDStr, 10, 64)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Bad token_id value: %s", tokenIDStr),
+			http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+	apiToken, err := dataClient.APIToken(tokenID)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting API token: %v", err),
+			http.StatusInternalServerError)
+	}
+	if apiToken == nil {
+		return errorResponse("API token not found.", http.StatusNotFound)
+	}
+	if apiToken.RevokedAt.HasValue() {
+		return errorResponse("API token already revoked.", http.StatusBadRequest)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+
+	err = dataClient.RevokeAPIToken(apiToken, authedUser)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error revoking API token: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	audit(dataClient, authedUser, types.APITokenRevokeAction, "api_token", strconv.FormatUint(apiToken.ID, 10),
+		apiTokenAuditState(apiToken), nil, "")
+
+	return dataResponse(apiToken)
+}
+
+func apiTokenAuditState(apiToken *types.APIToken) map[string]string {
+	return map[string]string{
+		"name":   apiToken.Name,
+		"scopes": apiToken.Scopes,
+		"user":   apiToken.User.Email,
+	}
+}
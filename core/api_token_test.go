@@ -0,0 +1,141 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func requestWithBearer(t *testing.T, server http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest(method, path, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	return res
+}
+
+func TestAPITokens(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	// Create a token by endpoint.
+	name := fmt.Sprintf("jenkins-%d", time.Now().UnixNano())
+	form := url.Values{"name": {name}, "scopes": {"train:read jobs:write"}}
+	req, err := http.NewRequest("POST", "/api/tokens", strings.NewReader(form.Encode()))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(testData.TokenCookie)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	var created struct {
+		Result struct {
+			ID     string `json:"id"`
+			Token  string `json:"token"`
+			Scopes string `json:"scopes"`
+		} `json:"result"`
+	}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Result.Token)
+	assert.Equal(t, "train:read jobs:write", created.Result.Scopes)
+	token := created.Result.Token
+
+	// Token can read, acting as its own user.
+	res = requestWithBearer(t, server, "GET", "/api/user", token)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), fmt.Sprintf(`"name":"%s"`, name))
+
+	// But not call endpoints outside its scopes.
+	trainPath := fmt.Sprintf("/api/train/%d/block", testData.Train.ID)
+	res = requestWithBearer(t, server, "POST", trainPath, token)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Contains(t, res.Body.String(), types.TrainWrite.String())
+
+	// Or endpoints closed to tokens.
+	res = requestWithBearer(t, server, "GET", "/api/tokens", token)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	res = requestWithBearer(t, server, "GET", "/api/user", "not-a-token")
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	// Last use is recorded.
+	apiToken, err := data.NewClient().APITokenByHash(auth.HashAPIToken(token))
+	assert.NoError(t, err)
+	assert.True(t, apiToken.LastUsedAt.HasValue())
+
+	// Revoke by endpoint.
+	req, err = http.NewRequest("POST", fmt.Sprintf("/api/tokens/%s/revoke", created.Result.ID), nil)
+	assert.NoError(t, err)
+	req.AddCookie(testData.TokenCookie)
+	res = httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = requestWithBearer(t, server, "GET", "/api/user", token)
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+	assert.Contains(t, res.Body.String(), "revoked")
+}
+
+func TestCreateAPITokenValidation(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	for _, form := range []url.Values{
+		{"name": {"bad name"}, "scopes": {"train:read"}},
+		{"name": {"no-scopes"}},
+		{"name": {"bad-scope"}, "scopes": {"train:drive"}},
+		{"name": {"expired"}, "scopes": {"train:read"}, "expires_at": {"2000-01-01"}},
+	} {
+		req, err := http.NewRequest("POST", "/api/tokens", strings.NewReader(form.Encode()))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(testData.TokenCookie)
+		res := httptest.NewRecorder()
+		server.ServeHTTP(res, req)
+		assert.Equal(t, http.StatusBadRequest, res.Code, form.Encode())
+	}
+}
+
+func TestAPITokenScopesAndCreatorRole(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	token, tokenHash, err := auth.NewAPIToken()
+	assert.NoError(t, err)
+	_, err = data.NewClient().CreateAPIToken(
+		fmt.Sprintf("admin-%d", time.Now().UnixNano()), tokenHash,
+		[]types.Scope{types.TrainAdmin}, nil, testData.User)
+	assert.NoError(t, err)
+
+	// train:admin includes train:read.
+	res := requestWithBearer(t, server, "GET", "/api/options", token)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithBearer(t, server, "GET", "/api/audit", token)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	// The token loses admin along with its creator.
+	settings.CustomizeAdminUsers(nil)
+	res = requestWithBearer(t, server, "GET", "/api/audit", token)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	res = requestWithBearer(t, server, "GET", "/api/options", token)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func approvalEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/train/{train_id:[0-9]+}/approve", post, approveTrain).
+			requires(types.Committer).
+			describe("Approve the train for every approval rule it needs that you can approve for.").
+			returns(&types.Train{}),
+	}
+}
+
+func approveTrain(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	train, resp := parseTrainVars(r, dataClient, false)
+	if resp != nil {
+		return *resp
+	}
+
+	resp = validateMutableTrain(train)
+	if resp != nil {
+		return *resp
+	}
+
+	if len(train.Approvals) == 0 {
+		return errorResponse("Train doesn't need approvals.", http.StatusBadRequest)
+	}
+
+	options, err := dataClient.Options()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting options: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+
+	var approved []string
+	canApprove := false
+	for _, status := range train.Approvals {
+		rule := options.Approvals.Rule(status.Rule)
+		if !rule.CanApprove(authedUser, train.Commits) {
+			continue
+		}
+		canApprove = true
+		if status.IsComplete() || hasApproved(status, authedUser) {
+			continue
+		}
+
+		_, err := dataClient.CreateApproval(train, authedUser, status.Rule)
+		if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error approving train: %v", err),
+				http.StatusInternalServerError)
+		}
+		approved = append(approved, status.Rule)
+	}
+
+	if !canApprove {
+		return errorResponse("You can't approve this train.", http.StatusForbidden)
+	}
+	if len(approved) == 0 {
+		return errorResponse("Nothing left for you to approve on this train.", http.StatusBadRequest)
+	}
+
+	auditTrain(dataClient, authedUser, types.TrainApproveAction, train, nil, strings.Join(approved, ", "))
+
+	train, err = dataClient.Train(train.ID)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting train: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	if train.ActivePhase == types.Verification {
+		checkPhaseCompletion(
+			r.Context(), dataClient, code.GetService(), messaging.GetService(), phase.GetService(),
+			ticket.GetService(), train.ActivePhases.Verification)
+	}
+
+	clearLatestTrainCache()
+
+	return dataResponse(train)
+}
+
+func hasApproved(status *types.ApprovalStatus, user *types.User) bool {
+	for _, approval := range status.Approvals {
+		if approval.User.ID == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Records which approval rules the train needs, from the files it changes since production.
+// If the files can't be listed, the train needs every rule.
+// Errors if the rules can't be recorded, in which case the train mustn't verify.
+func requireApprovals(ctx context.Context, dataClient data.Client, codeService code.Service, train *types.Train) error {
+	options, err := dataClient.Options()
+	if err != nil {
+		return err
+	}
+	if len(options.Approvals) == 0 && train.ApprovalRules == "" {
+		return nil
+	}
+
+	var files []string
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		logger.Error("Error getting production train, so train %d needs every approval: %v", train.ID, err)
+	} else if productionTrain != nil {
+		files, err = codeService.ChangedFiles(ctx, productionTrain.HeadSHA, train.HeadSHA)
+		if err != nil {
+			logger.Error("Error getting files changed by train %d, so it needs every approval: %v", train.ID, err)
+			files = nil
+		}
+	}
+
+	rules := make([]string, 0)
+	for _, rule := range options.Approvals {
+		if rule.AppliesTo(files) {
+			rules = append(rules, rule.Name)
+		}
+	}
+	return dataClient.SetApprovalRules(train, rules)
+}
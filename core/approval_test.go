@@ -0,0 +1,96 @@
+// +build data
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestApproveTrain(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	email := fmt.Sprintf("approver-%d@example.com", time.Now().UnixNano())
+	approver, err := dataClient.ReadOrCreateUser("approver", email)
+	assert.NoError(t, err)
+	approverToken := fmt.Sprintf("approver-%d", time.Now().UnixNano())
+	err = dataClient.WriteToken(approverToken, approver.Name, approver.Email, "", nil)
+	assert.NoError(t, err)
+	approverCookie := &http.Cookie{Name: auth.GetCookieName(), Value: approverToken}
+
+	options := types.DefaultOptions
+	options.Approvals = types.ApprovalRules{
+		{Name: "payments", Required: 2, Users: []string{testData.User.Email, email}, Paths: []string{"payments/"}},
+		{Name: "mobile", Required: 1, Role: "admin", Paths: []string{"mobile/"}},
+	}
+	err = dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	path := fmt.Sprintf("/api/train/%d/approve", testData.Train.ID)
+	res := requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The train doesn't need approvals yet")
+
+	codeService := &code.CodeServiceMock{
+		ChangedFilesMock: func(ctx context.Context, oldRef, newRef string) ([]string, error) {
+			return []string{"payments/charge.go", "README.md"}, nil
+		},
+	}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = requireApprovals(context.Background(), dataClient, codeService, train)
+	assert.NoError(t, err)
+	assert.Len(t, train.Approvals, 1)
+	assert.Equal(t, "payments", train.Approvals[0].Rule)
+
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Each person approves once")
+
+	train, err = dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.Len(t, train.Approvals[0].Approvals, 1)
+	assert.False(t, train.Approvals[0].IsComplete())
+
+	res = requestWithCookie(t, server, "POST", path, nil, approverCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	train, err = dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.True(t, train.Approvals[0].IsComplete())
+	assert.Nil(t, train.PendingApproval())
+
+	// Approvals are for the train's head, so extending the train needs them again.
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{{SHA: fmt.Sprintf("appr0ve-%d", time.Now().UnixNano())}})
+	assert.NoError(t, err)
+	train, err = dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, train.PendingApproval())
+
+	// Authors can't approve their own commits.
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{
+		{SHA: fmt.Sprintf("appr0ve-%d", time.Now().UnixNano()), AuthorEmail: email}})
+	assert.NoError(t, err)
+	res = requestWithCookie(t, server, "POST", path, nil, approverCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	// Only the listed users can approve.
+	options.Approvals[0].Users = []string{email}
+	err = dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 500
+)
+
+func auditEndpoints() []endpoint {
+	actions := make([]string, len(types.AuditActions))
+	for i, action := range types.AuditActions {
+		actions[i] = action.String()
+	}
+
+	return []endpoint{
+		newAdminEp("/api/audit", get, fetchAuditEvents).
+			scoped(types.TrainAdmin).
+			describe("List changes to trains and settings, newest first, one page at a time.").
+			query("actor", fmt.Sprintf("Only changes by this user email, or by %s itself.", types.ConductorActor)).
+			query("action", fmt.Sprintf("Only this action: %s.", strings.Join(actions, ", "))).
+			query("target_type", "Only changes to this kind of target, e.g. train, mode, options or metadata.").
+			query("target_id", "Only changes to this target, e.g. a train ID or metadata namespace.").
+			query("after", "RFC3339 timestamp or date.").
+			query("before", "RFC3339 timestamp or date.").
+			query("cursor", "next_cursor of the previous page.").
+			query("limit", fmt.Sprintf("Events per page, at most %d. Defaults to %d.",
+				MaxAuditLimit, DefaultAuditLimit)).
+			returns(&auditLog{}),
+	}
+}
+
+// An audit event, with its before and after values decoded.
+type auditEventResult struct {
+	*types.AuditEvent
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+type auditLog struct {
+	Params     map[string]string   `json:"params"`
+	Events     []*auditEventResult `json:"events"`
+	NextCursor *string             `json:"next_cursor"`
+}
+
+// The parts of a train that users and Conductor change.
+type trainAuditState struct {
+	State            types.TrainState `json:"state"`
+	Closed           bool             `json:"closed"`
+	ScheduleOverride bool             `json:"schedule_override"`
+	Blocked          bool             `json:"blocked"`
+	BlockedReason    *string          `json:"blocked_reason"`
+	Engineer         *string          `json:"engineer"`
+	HeadSHA          string           `json:"head_sha"`
+}
+
+func newTrainAuditState(train *types.Train) *trainAuditState {
+	state := &trainAuditState{
+		State:            train.State(),
+		Closed:           train.Closed,
+		ScheduleOverride: train.ScheduleOverride,
+		Blocked:          train.Blocked,
+		HeadSHA:          train.HeadSHA,
+	}
+	// Copy the values, since the train is changed afterwards.
+	if train.BlockedReason != nil {
+		reason := *train.BlockedReason
+		state.BlockedReason = &reason
+	}
+	if train.Engineer != nil {
+		engineer := train.Engineer.Email
+		state.Engineer = &engineer
+	}
+	return state
+}
+
+func phaseAuditState(phase *types.Phase) map[string]string {
+	return map[string]string{
+		"phase":    phase.Type.String(),
+		"phase_id": strconv.FormatUint(phase.ID, 10),
+	}
+}
+
+// What's in production before and after a rollback.
+func rollbackAuditState(train *types.Train) map[string]string {
+	return map[string]string{
+		"train_id": strconv.FormatUint(train.ID, 10),
+		"head_sha": train.HeadSHA,
+	}
+}
+
+// The current values of the metadata keys, skipping unset ones.
+func metadataAuditState(dataClient data.Client, namespace string, keys []string) map[string]string {
+	values := make(map[string]string)
+	for _, key := range keys {
+		value, err := dataClient.MetadataGetKey(namespace, key)
+		if err != nil {
+			if err != data.ErrNoSuchNamespaceOrKey {
+				logger.Error("Error getting metadata %s/%s for audit: %v", namespace, key, err)
+			}
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
+
+// Records a change by user, or by Conductor if user is nil.
+// Before and after are encoded as JSON, and can be nil.
+// Errors are logged rather than returned, so a change isn't undone because it couldn't be recorded.
+func audit(
+	dataClient data.Client,
+	user *types.User,
+	action types.AuditAction,
+	targetType, targetID string,
+	before, after interface{},
+	reason string) {
+
+	event := &types.AuditEvent{
+		Actor:      user,
+		ActorName:  types.ConductorActor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+	}
+	if user != nil {
+		event.ActorName = user.Name
+	}
+
+	var err error
+	event.Before, err = auditValue(before)
+	if err != nil {
+		logger.Error("Error encoding audit value for %s: %v", action, err)
+	}
+	event.After, err = auditValue(after)
+	if err != nil {
+		logger.Error("Error encoding audit value for %s: %v", action, err)
+	}
+
+	err = dataClient.CreateAuditEvent(event)
+	if err != nil {
+		logger.Error("Error recording %s of %s %s: %v", action, targetType, targetID, err)
+	}
+}
+
+func auditValue(value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// Records a change to a train, given its state beforehand.
+func auditTrain(
+	dataClient data.Client,
+	user *types.User,
+	action types.AuditAction,
+	train *types.Train,
+	before *trainAuditState,
+	reason string) {
+
+	var beforeValue interface{}
+	if before != nil {
+		beforeValue = before
+	}
+	audit(dataClient, user, action, "train", strconv.FormatUint(train.ID, 10),
+		beforeValue, newTrainAuditState(train), reason)
+}
+
+// Returns filter, or a response if there was an error.
+func parseAuditFilter(params map[string]string) (*types.AuditFilter, *response) {
+	filter := &types.AuditFilter{
+		Actor:      params["actor"],
+		TargetType: params["target_type"],
+		TargetID:   params["target_id"],
+		Limit:      DefaultAuditLimit,
+	}
+
+	if action, ok := params["action"]; ok {
+		auditAction, err := types.AuditActionFromString(action)
+		if err != nil {
+			resp := errorResponse(err.Error(), http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.Action = &auditAction
+	}
+
+	timeFilters := map[string]**time.Time{
+		"after":  &filter.After,
+		"before": &filter.Before,
+	}
+	for key, target := range timeFilters {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+		parsed, err := parseHistoryTime(value)
+		if err != nil {
+			resp := errorResponse(
+				fmt.Sprintf("Bad `%s` value: %v", key, err),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		*target = parsed
+	}
+
+	if cursor, ok := params["cursor"]; ok {
+		cursorID, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			resp := errorResponse(
+				fmt.Sprintf("Bad cursor value: %s", cursor),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.Cursor = cursorID
+	}
+
+	if limit, ok := params["limit"]; ok {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt < 1 || limitInt > MaxAuditLimit {
+			resp := errorResponse(
+				fmt.Sprintf("Bad limit value: %s. Must be between 1 and %d.", limit, MaxAuditLimit),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.Limit = limitInt
+	}
+
+	return filter, nil
+}
+
+func fetchAuditEvents(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	params := make(map[string]string)
+	for key, values := range r.URL.Query() {
+		params[key] = values[0]
+	}
+
+	filter, resp := parseAuditFilter(params)
+	if resp != nil {
+		return *resp
+	}
+
+	// Fetch one extra event to know if there's another page.
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+
+	events, err := dataClient.AuditEvents(filter)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting audit events: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	var nextCursor *string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		cursor := strconv.FormatUint(events[pageSize-1].ID, 10)
+		nextCursor = &cursor
+	}
+
+	results := make([]*auditEventResult, len(events))
+	for i, event := range events {
+		results[i] = &auditEventResult{AuditEvent: event}
+		if event.Before != "" {
+			results[i].Before = json.RawMessage(event.Before)
+		}
+		if event.After != "" {
+			results[i].After = json.RawMessage(event.After)
+		}
+	}
+
+	return dataResponse(&auditLog{
+		Params:     params,
+		Events:     results,
+		NextCursor: nextCursor,
+	})
+}
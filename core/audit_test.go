@@ -0,0 +1,79 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+type auditLogResponse struct {
+	Result struct {
+		Events []struct {
+			ActorName  string                 `json:"actor_name"`
+			Action     string                 `json:"action"`
+			TargetType string                 `json:"target_type"`
+			TargetID   string                 `json:"target_id"`
+			Before     map[string]interface{} `json:"before"`
+			After      map[string]interface{} `json:"after"`
+			Reason     string                 `json:"reason"`
+		} `json:"events"`
+		NextCursor *string `json:"next_cursor"`
+	} `json:"result"`
+}
+
+func TestAudit(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	trainID := fmt.Sprintf("%d", testData.Train.ID)
+	res := requestWithCookie(t, server, "POST", fmt.Sprintf("/api/train/%s/close", trainID), nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "POST", fmt.Sprintf("/api/train/%s/block", trainID),
+		url.Values{"reason": {"Bad migration"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	query := url.Values{"target_type": {"train"}, "target_id": {trainID}, "limit": {"1"}}
+	res = requestWithCookie(t, server, "GET", "/api/audit?"+query.Encode(), nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	var auditLog auditLogResponse
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &auditLog))
+	assert.Len(t, auditLog.Result.Events, 1)
+	assert.NotNil(t, auditLog.Result.NextCursor)
+	event := auditLog.Result.Events[0]
+	assert.Equal(t, types.TrainBlockAction.String(), event.Action)
+	assert.Equal(t, testData.User.Name, event.ActorName)
+	assert.Equal(t, false, event.Before["blocked"])
+	assert.Equal(t, true, event.After["blocked"])
+	assert.Equal(t, "Bad migration", event.Reason)
+	assert.Equal(t, "Bad migration", event.After["blocked_reason"])
+
+	query.Set("action", types.TrainCloseAction.String())
+	query.Set("actor", testData.User.Email)
+	res = requestWithCookie(t, server, "GET", "/api/audit?"+query.Encode(), nil, testData.TokenCookie)
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &auditLog))
+	assert.Len(t, auditLog.Result.Events, 1)
+	assert.Equal(t, "open", auditLog.Result.Events[0].Before["state"])
+	assert.Equal(t, "closed", auditLog.Result.Events[0].After["state"])
+
+	query.Set("actor", types.ConductorActor)
+	res = requestWithCookie(t, server, "GET", "/api/audit?"+query.Encode(), nil, testData.TokenCookie)
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &auditLog))
+	assert.Len(t, auditLog.Result.Events, 0)
+
+	res = requestWithCookie(t, server, "GET", "/api/audit?action=train.explode", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	settings.CustomizeAdminUsers([]string{})
+	res = requestWithCookie(t, server, "GET", "/api/audit", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
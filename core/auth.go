@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 
@@ -24,30 +25,24 @@ const AdminPermissionMessage = "Only admins can call this endpoint."
 
 func (_ authMiddleware) Wrap(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if handler.(endpoint).needsAuth {
-			cookie, err := r.Cookie(auth.GetCookieName())
-			// Note: Only possible error is ErrNoCookie.
-			if err == http.ErrNoCookie {
-				errorResponse("Unauthorized", http.StatusUnauthorized).Write(w, r)
-				return
+		ep := handler.(endpoint)
+		if ep.needsAuth {
+			var user *types.User
+			var resp *response
+			if token := auth.BearerToken(r); token != "" {
+				user, resp = apiTokenUser(r, ep, token)
+			} else {
+				user, resp = cookieUser(w, r)
 			}
-			token := cookie.Value
-
-			dataClient := data.NewClient()
-			user, err := dataClient.UserByToken(token)
-			if err != nil {
-				logger.Error("Error getting user by token (%s): %v", token, err)
-				errorResponse("Unauthorized", http.StatusUnauthorized).Write(w, r)
+			if resp != nil {
+				resp.Write(w, r)
 				return
 			}
-			user.IsAdmin = settings.IsAdminUser(user.Email)
-
-			// Check admin access restrictions.
-			if handler.(endpoint).needsAdmin && !user.IsAdmin {
-				errorResponse(
-					fmt.Sprintf("%s You are logged in as %s.",
-						AdminPermissionMessage, user.Name),
-					http.StatusForbidden).Write(w, r)
+
+			// Check role restrictions.
+			resp = checkRole(r, ep, user)
+			if resp != nil {
+				resp.Write(w, r)
 				return
 			}
 
@@ -59,11 +54,200 @@ func (_ authMiddleware) Wrap(handler http.Handler) http.Handler {
 	})
 }
 
+// How often to record when a session was last used, which extends it.
+const SessionLastUsedPrecision = time.Minute
+
+// Returns the user for the auth cookie, or a response if not logged in.
+// Using a session extends it, so the cookie is refreshed with the new expiry.
+func cookieUser(w http.ResponseWriter, r *http.Request) (*types.User, *response) {
+	cookie, err := r.Cookie(auth.GetCookieName())
+	// Note: Only possible error is ErrNoCookie.
+	if err == http.ErrNoCookie {
+		resp := errorResponse("Unauthorized", http.StatusUnauthorized)
+		return nil, &resp
+	}
+	token := cookie.Value
+
+	dataClient := data.NewClient()
+	session, err := dataClient.Session(token)
+	if err != nil {
+		logger.Error("Error getting session (%s): %v", token, err)
+		resp := errorResponse("Unauthorized", http.StatusUnauthorized)
+		return nil, &resp
+	}
+
+	now := time.Now()
+	if !now.Before(sessionExpiresAt(session)) {
+		resp := errorResponse("Session expired", http.StatusUnauthorized)
+		resp.Cookies = []*http.Cookie{auth.EmptyCookie()}
+		return nil, &resp
+	}
+	if !session.LastUsedAt.HasValue() ||
+		now.Sub(session.LastUsedAt.Value) > SessionLastUsedPrecision {
+		err = dataClient.TouchSession(session)
+		if err != nil {
+			logger.Error("Error recording use of session %s: %v", session.SessionID(), err)
+		} else {
+			http.SetCookie(w, sessionCookie(session))
+		}
+	}
+
+	user := session.User
+	user.Role, err = userRole(dataClient, user)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error getting role: %v", err),
+			http.StatusInternalServerError)
+		return nil, &resp
+	}
+	user.IsAdmin = user.Role == types.Admin
+	return user, nil
+}
+
+func sessionExpiresAt(session *types.Auth) time.Time {
+	return session.ExpiresAt(auth.SessionIdleTimeout(), auth.SessionMaxLifetime())
+}
+
+// The auth cookie for a session, expiring along with it.
+func sessionCookie(session *types.Auth) *http.Cookie {
+	return auth.NewCookie(session.Token, sessionExpiresAt(session))
+}
+
+// Returns admin for admin users and members of admin groups,
+// otherwise the role assigned to the user, or the default role.
+func userRole(dataClient data.Client, user *types.User) (types.Role, error) {
+	if settings.IsAdminUser(user.Email) || settings.IsAdminGroupMember(user.Groups) {
+		return types.Admin, nil
+	}
+	assigned, err := dataClient.UserRole(user)
+	if err != nil {
+		return types.Viewer, err
+	}
+	if assigned != nil {
+		return assigned.Role, nil
+	}
+	return defaultRole(), nil
+}
+
+func defaultRole() types.Role {
+	role, err := types.RoleFromString(settings.GetDefaultRole())
+	if err != nil {
+		logger.Error("Bad default role, using %s: %v", types.Viewer, err)
+		return types.Viewer
+	}
+	return role
+}
+
+// Returns a response if the user can't call the endpoint.
+func checkRole(r *http.Request, ep endpoint, user *types.User) *response {
+	if user.Role.AtLeast(ep.role) {
+		return nil
+	}
+	if ep.allowsTrainEngineer {
+		train, resp := parseTrainVars(r, data.NewClient(), false)
+		if resp != nil {
+			return resp
+		}
+		if train.Engineer != nil && train.Engineer.ID == user.ID {
+			return nil
+		}
+	}
+	resp := errorResponse(
+		fmt.Sprintf("%s You are logged in as %s, with the %s role.",
+			rolePermissionMessage(ep), user.Name, user.Role),
+		http.StatusForbidden)
+	return &resp
+}
+
+func rolePermissionMessage(ep endpoint) string {
+	switch {
+	case ep.role == types.Admin:
+		return AdminPermissionMessage
+	case ep.allowsTrainEngineer:
+		return fmt.Sprintf(
+			"Only the train's engineer and users with the %s role or above can call this endpoint.", ep.role)
+	default:
+		return fmt.Sprintf("Only users with the %s role or above can call this endpoint.", ep.role)
+	}
+}
+
+// How often to record when an API token was last used.
+const APITokenLastUsedPrecision = time.Minute
+
+// Returns the user an API token acts as, or a response if the token can't call the endpoint.
+func apiTokenUser(r *http.Request, ep endpoint, token string) (*types.User, *response) {
+	dataClient := data.NewClient()
+	apiToken, err := dataClient.APITokenByHash(auth.HashAPIToken(token))
+	if err != nil {
+		// Don't log the token, since it might be valid for another environment.
+		logger.Error("Error getting API token: %v", err)
+		resp := errorResponse("Unauthorized", http.StatusUnauthorized)
+		return nil, &resp
+	}
+
+	err = apiToken.Validate(time.Now())
+	if err != nil {
+		resp := errorResponse(err.Error(), http.StatusUnauthorized)
+		return nil, &resp
+	}
+
+	if ep.scope == nil {
+		resp := errorResponse("API tokens can't call this endpoint.", http.StatusForbidden)
+		return nil, &resp
+	}
+	if !apiToken.HasScope(*ep.scope) {
+		resp := errorResponse(
+			fmt.Sprintf("Token %s needs the %s scope to call this endpoint.", apiToken.Name, ep.scope),
+			http.StatusForbidden)
+		return nil, &resp
+	}
+
+	if !apiToken.LastUsedAt.HasValue() ||
+		time.Since(apiToken.LastUsedAt.Value) > APITokenLastUsedPrecision {
+		err = dataClient.TouchAPIToken(apiToken)
+		if err != nil {
+			logger.Error("Error recording use of API token %s: %v", apiToken.Name, err)
+		}
+	}
+	logger.Info("API token %s: %s %s", apiToken.Name, r.Method, r.URL.Path)
+
+	// Tokens are limited by their scopes instead of roles,
+	// but never get more than whoever created them has now.
+	user := apiToken.User
+	user.Role = types.ReleaseManager
+	if apiToken.HasScope(types.TrainAdmin) {
+		user.Role = types.Admin
+	}
+	creatorRole, err := userRole(dataClient, apiToken.CreatedBy)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error getting role: %v", err),
+			http.StatusInternalServerError)
+		return nil, &resp
+	}
+	if !creatorRole.AtLeast(user.Role) {
+		user.Role = creatorRole
+	}
+	user.IsAdmin = user.Role == types.Admin
+	return user, nil
+}
+
 func authEndpoints() []endpoint {
 	return []endpoint{
-		newOpenEp("/api/auth/info", get, authInfo),
-		newOpenEp("/api/auth/login", get, authLogin),
-		newEp("/api/auth/logout", post, authLogout),
+		newOpenEp("/api/auth/info", get, authInfo).
+			describe("Get the URL and provider to log in with.").
+			returns(&authInfoResult{}),
+		newOpenEp("/api/auth/start", get, authStart).
+			describe("Start logging in; redirects to the auth provider.").
+			redirects(),
+		newOpenEp("/api/auth/login", get, authLogin).
+			describe("Finish logging in; redirects to the home page with an auth cookie set.").
+			requiredQuery("code", "OAuth code from the auth provider.").
+			query("state", "State from /api/auth/start, passed back by the auth provider.").
+			redirects(),
+		newEp("/api/auth/logout", post, authLogout).
+			describe("Log out, revoking the auth token and clearing the auth cookie.").
+			returns(nil),
 	}
 }
 
@@ -71,17 +255,37 @@ func authEndpoints() []endpoint {
 // This endpoint is currently unused, but we might want it in the future (cli?).
 func authInfo(_ *http.Request) response {
 	authService := auth.GetService()
-	authURL := authService.AuthURL(settings.GetHostname())
 	authProvider := authService.AuthProvider()
-	return dataResponse(struct {
-		URL      string `json:"url"`
-		Provider string `json:"provider"`
-	}{
-		authURL,
-		authProvider,
+	return dataResponse(&authInfoResult{
+		URL:      "/api/auth/start",
+		Provider: authProvider,
 	})
 }
 
+// Redirects to the auth provider, remembering the login's state and PKCE verifier in a cookie.
+func authStart(_ *http.Request) response {
+	state, verifier, err := auth.NewLoginState()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error starting login: %v", err),
+			http.StatusInternalServerError)
+	}
+	authURL := auth.GetService().AuthURL(settings.GetHostname(), state, verifier)
+	if authURL == "" {
+		return errorResponse("Auth provider is unavailable.", http.StatusServiceUnavailable)
+	}
+	return response{
+		Code:         http.StatusFound,
+		Cookies:      []*http.Cookie{auth.NewLoginCookie(state, verifier)},
+		RedirectPath: authURL,
+	}
+}
+
+type authInfoResult struct {
+	URL      string `json:"url"`
+	Provider string `json:"provider"`
+}
+
 func authLogin(r *http.Request) response {
 	authService := auth.GetService()
 	dataClient := data.NewClient()
@@ -93,17 +297,23 @@ func authLogin(r *http.Request) response {
 		return errorResponse(fmt.Sprintf("'code' in post form had %d elements; 1 expected.", len(code)),
 			http.StatusBadRequest)
 	}
-	name, email, avatar, codeToken, err := authService.Login(code[0])
+
+	verifier, resp := loginVerifier(r, authService.RequiresLoginState())
+	if resp != nil {
+		return *resp
+	}
+
+	identity, err := authService.Login(r.Context(), settings.GetHostname(), code[0], verifier)
 	if err != nil {
 		return errorResponse(err.Error(), http.StatusInternalServerError)
 	}
-	if name == "" || email == "" {
+	if identity.Name == "" || identity.Email == "" {
 		return errorResponse(
 			fmt.Sprintf("Name, email, and avatar must be set, were %s, %s, and %s respectively.",
-				name, email, avatar), http.StatusInternalServerError)
+				identity.Name, identity.Email, identity.AvatarURL), http.StatusInternalServerError)
 	}
 	token := uuid.NewV4().String() // TODO: Read from env for robot user.
-	err = dataClient.WriteToken(token, name, email, avatar, codeToken)
+	err = dataClient.WriteToken(token, identity.Name, identity.Email, identity.AvatarURL, identity.Groups)
 	if err != nil {
 		return errorResponse(err.Error(), http.StatusInternalServerError)
 	}
@@ -111,6 +321,24 @@ func authLogin(r *http.Request) response {
 	return loginResponse(token)
 }
 
+// Returns the PKCE verifier of a login started at /api/auth/start, which must come back with the same state.
+// If requireState is false, logins can also start at the auth provider, and have no verifier.
+func loginVerifier(r *http.Request, requireState bool) (string, *response) {
+	state, verifier, ok := auth.ParseLoginCookie(r)
+	if !ok {
+		if requireState {
+			resp := errorResponse("Login wasn't started here; try logging in again.", http.StatusBadRequest)
+			return "", &resp
+		}
+		return "", nil
+	}
+	if r.URL.Query().Get("state") != state {
+		resp := errorResponse("Login state doesn't match; try logging in again.", http.StatusBadRequest)
+		return "", &resp
+	}
+	return verifier, nil
+}
+
 func authLogout(r *http.Request) response {
 	dataClient := data.NewClient()
 	authedUser := r.Context().Value("user").(*types.User)
@@ -122,16 +350,17 @@ func authLogout(r *http.Request) response {
 }
 
 func loginResponse(token string) response {
+	session := &types.Auth{Token: token, CreatedAt: types.Time{Value: time.Now()}}
 	return response{
 		Code:         http.StatusFound,
-		Cookie:       auth.NewCookie(token),
+		Cookies:      []*http.Cookie{sessionCookie(session), auth.EmptyLoginCookie()},
 		RedirectPath: "/",
 	}
 }
 
 func logoutResponse() response {
 	return response{
-		Code:   http.StatusOK,
-		Cookie: auth.EmptyCookie(),
+		Code:    http.StatusOK,
+		Cookies: []*http.Cookie{auth.EmptyCookie()},
 	}
 }
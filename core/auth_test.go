@@ -0,0 +1,39 @@
+package core
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+)
+
+func TestLoginVerifier(t *testing.T) {
+	request := func(state string, cookie *http.Cookie) *http.Request {
+		req, err := http.NewRequest("GET", "/api/auth/login?code=c0de&state="+state, nil)
+		assert.NoError(t, err)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		return req
+	}
+	cookie := auth.NewLoginCookie("st4te", "ver1fier")
+
+	verifier, resp := loginVerifier(request("st4te", cookie), true)
+	assert.Nil(t, resp)
+	assert.Equal(t, "ver1fier", verifier)
+
+	_, resp = loginVerifier(request("other", cookie), false)
+	assert.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// Without the cookie, only providers that can start logins themselves are let through.
+	verifier, resp = loginVerifier(request("", nil), false)
+	assert.Nil(t, resp)
+	assert.Empty(t, verifier)
+
+	_, resp = loginVerifier(request("st4te", nil), true)
+	assert.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
@@ -1,20 +1,25 @@
 package core
 
 import (
+	"context"
 	"time"
 
 	"github.com/Nextdoor/conductor/services/code"
 	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/health"
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
 	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/tracing"
 )
 
 const SyncTicketsInterval = time.Second * 10
 const CheckJobsInterval = time.Second * 5
 const CheckTrainLockInterval = time.Second * 5
+const CheckSoakingPhasesInterval = time.Second * 30
+const DeleteExpiredSessionsInterval = time.Hour
 
 // How long to wait until starting background tasks after boot, in seconds.
 // This is useful when upgrading Conductor, to avoid race conditions when two instances are polling at once.
@@ -29,15 +34,17 @@ func backgroundTaskLoop() {
 	killed := make(chan bool)
 	for {
 		go func() {
-			dataClient := data.NewClient()
 			codeService := code.GetService()
 			messagingService := messaging.GetService()
 			phaseService := phase.GetService()
 			ticketService := ticket.GetService()
+			healthService := health.GetService()
 
 			syncTicketsTicker := time.NewTicker(SyncTicketsInterval)
 			checkJobsTicker := time.NewTicker(CheckJobsInterval)
 			checkTrainLockTicker := time.NewTicker(CheckTrainLockInterval)
+			checkSoakingPhasesTicker := time.NewTicker(CheckSoakingPhasesInterval)
+			deleteExpiredSessionsTicker := time.NewTicker(DeleteExpiredSessionsInterval)
 			defer func() {
 				err, stack := parsePanic(recover())
 				if err != nil {
@@ -46,14 +53,31 @@ func backgroundTaskLoop() {
 				killed <- true
 			}()
 
+			// Each tick gets its own data client, which reads the options once.
 			for {
 				select {
 				case <-syncTicketsTicker.C:
-					syncTickets(dataClient, codeService, messagingService, phaseService, ticketService)
+					ctx, span := tracing.Start(context.Background(), "background.syncTickets")
+					syncTickets(ctx, data.NewClient(), codeService, messagingService, phaseService, ticketService)
+					span.End()
 				case <-checkJobsTicker.C:
-					checkJobs(dataClient)
+					ctx, span := tracing.Start(context.Background(), "background.checkJobs")
+					checkJobs(ctx, data.NewClient())
+					span.End()
 				case <-checkTrainLockTicker.C:
-					checkTrainLock(dataClient, codeService, messagingService, phaseService, ticketService)
+					ctx, span := tracing.Start(context.Background(), "background.checkTrainLock")
+					dataClient := data.NewClient()
+					checkTrainLock(ctx, dataClient, codeService, messagingService, phaseService, ticketService)
+					deployHeldTrain(ctx, dataClient, messagingService)
+					span.End()
+				case <-checkSoakingPhasesTicker.C:
+					ctx, span := tracing.Start(context.Background(), "background.checkSoakingPhases")
+					checkSoakingPhases(ctx, data.NewClient(), codeService, messagingService, phaseService, ticketService, healthService)
+					span.End()
+				case <-deleteExpiredSessionsTicker.C:
+					ctx, span := tracing.Start(context.Background(), "background.deleteExpiredSessions")
+					deleteExpiredSessions(ctx, data.NewClient())
+					span.End()
 				}
 			}
 		}()
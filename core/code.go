@@ -9,11 +9,15 @@ import (
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/tracing"
 )
 
 func codeEndpoints() []endpoint {
 	return []endpoint{
-		newOpenEp("/api/code/webhook", post, codeWebhook),
+		newOpenEp("/api/code/webhook", post, codeWebhook).
+			describe("Receive push events from the code service, to check the pushed branch for new commits.").
+			jsonBody("Webhook payload from the code service.").
+			returns(nil),
 	}
 }
 
@@ -30,7 +34,7 @@ func codeWebhook(r *http.Request) response {
 	if branch != "" {
 		logger.Info("There was a push event to branch %s", branch)
 		go checkBranch(
-			data.NewClient(), codeService, messagingService, phaseService, ticketService,
+			tracing.Detach(r.Context()), data.NewClient(), codeService, messagingService, phaseService, ticketService,
 			branch, nil)
 	}
 
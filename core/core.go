@@ -67,12 +67,31 @@ func healthz(_ *http.Request) response {
 
 func coreEndpoints() []endpoint {
 	return []endpoint{
-		newOpenEp("/healthz", get, healthz),
-		newEp("/api/config", get, fetchConfig),
-		newEp("/api/mode", get, fetchMode),
-		newAdminEp("/api/mode", post, setMode),
-		newEp("/api/options", get, fetchOptions),
-		newAdminEp("/api/options", post, setOptions),
+		newOpenEp("/healthz", get, healthz).
+			describe("Health check.").
+			returns(nil),
+		newEp("/api/config", get, fetchConfig).
+			scoped(types.TrainRead).
+			describe("Get the mode and options.").
+			returns(&types.Config{}),
+		newEp("/api/mode", get, fetchMode).
+			scoped(types.TrainRead).
+			describe("Get the mode: schedule or manual.").
+			returns(""),
+		newAdminEp("/api/mode", post, setMode).
+			scoped(types.TrainAdmin).
+			describe("Set the mode.").
+			requiredForm("mode", "schedule or manual.").
+			returns(""),
+		newEp("/api/options", get, fetchOptions).
+			scoped(types.TrainRead).
+			describe("Get the options.").
+			returns(&types.Options{}),
+		newAdminEp("/api/options", post, setOptions).
+			scoped(types.TrainAdmin).
+			describe("Set the options.").
+			requiredForm("options", "Options as JSON.").
+			returns(&types.Options{}),
 	}
 }
 
@@ -105,6 +124,11 @@ func setMode(r *http.Request) response {
 	}
 
 	dataClient := data.NewClient()
+	before, err := dataClient.Mode()
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusInternalServerError)
+	}
+
 	err = dataClient.SetMode(mode)
 	if err != nil {
 		return errorResponse(
@@ -114,6 +138,9 @@ func setMode(r *http.Request) response {
 			http.StatusInternalServerError)
 	}
 
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.ModeSetAction, "mode", "", before.String(), mode.String(), "")
+
 	return dataResponse(mode.String())
 }
 
@@ -139,6 +166,11 @@ func setOptions(r *http.Request) response {
 	}
 
 	dataClient := data.NewClient()
+	before, err := dataClient.Options()
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusInternalServerError)
+	}
+
 	err = dataClient.SetOptions(options)
 	if err != nil {
 		return errorResponse(
@@ -148,5 +180,8 @@ func setOptions(r *http.Request) response {
 			http.StatusInternalServerError)
 	}
 
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.OptionsSetAction, "options", "", before, options, "")
+
 	return dataResponse(options)
 }
@@ -5,21 +5,37 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"reflect"
 
 	"github.com/gorilla/mux"
 
 	"github.com/Nextdoor/conductor/shared/datadog"
+	"github.com/Nextdoor/conductor/shared/types"
 )
 
 func Endpoints() []endpoint {
 	var endpoints []endpoint
 	endpoints = append(endpoints, authEndpoints()...)
+	endpoints = append(endpoints, apiTokenEndpoints()...)
+	endpoints = append(endpoints, approvalEndpoints()...)
+	endpoints = append(endpoints, auditEndpoints()...)
 	endpoints = append(endpoints, codeEndpoints()...)
 	endpoints = append(endpoints, searchEndpoints()...)
+	endpoints = append(endpoints, sessionEndpoints()...)
+	endpoints = append(endpoints, splitEndpoints()...)
 	endpoints = append(endpoints, coreEndpoints()...)
+	endpoints = append(endpoints, excludeEndpoints()...)
+	endpoints = append(endpoints, expediteEndpoints()...)
+	endpoints = append(endpoints, freezeEndpoints()...)
+	endpoints = append(endpoints, historyEndpoints()...)
+	endpoints = append(endpoints, holdEndpoints()...)
 	endpoints = append(endpoints, jobEndpoints()...)
 	endpoints = append(endpoints, metadataEndpoints()...)
+	endpoints = append(endpoints, openAPIEndpoints()...)
 	endpoints = append(endpoints, phaseEndpoints()...)
+	endpoints = append(endpoints, roleEndpoints()...)
+	endpoints = append(endpoints, rollbackEndpoints()...)
+	endpoints = append(endpoints, statsEndpoints()...)
 	endpoints = append(endpoints, ticketEndpoints()...)
 	endpoints = append(endpoints, trainEndpoints()...)
 	endpoints = append(endpoints, userEndpoints()...)
@@ -43,7 +59,7 @@ func (method httpMethod) String() string {
 	case del:
 		return "DELETE"
 	default:
-		panic(fmt.Errorf("Unknown httpMethod %s", string(method)))
+		panic(fmt.Errorf("Unknown httpMethod %d", method))
 	}
 }
 
@@ -54,14 +70,15 @@ type middleware interface {
 type handlerFunc func(*http.Request) response
 
 // Creates an endpoint that requires authentication.
+// Any logged in user can call it, unless it requires a role.
 func newEp(path string, method httpMethod,
 	handler handlerFunc) endpoint {
 	return endpoint{
-		uri:        path,
-		method:     method,
-		needsAuth:  true,
-		needsAdmin: false,
-		handler:    handler,
+		uri:       path,
+		method:    method,
+		needsAuth: true,
+		role:      types.Viewer,
+		handler:   handler,
 	}
 }
 
@@ -69,11 +86,11 @@ func newEp(path string, method httpMethod,
 func newAdminEp(path string, method httpMethod,
 	handler handlerFunc) endpoint {
 	return endpoint{
-		uri:        path,
-		method:     method,
-		needsAuth:  true,
-		needsAdmin: true,
-		handler:    handler,
+		uri:       path,
+		method:    method,
+		needsAuth: true,
+		role:      types.Admin,
+		handler:   handler,
 	}
 }
 
@@ -81,22 +98,133 @@ func newAdminEp(path string, method httpMethod,
 func newOpenEp(path string, method httpMethod,
 	handler handlerFunc) endpoint {
 	return endpoint{
-		uri:        path,
-		method:     method,
-		needsAuth:  false,
-		needsAdmin: false,
-		handler:    handler,
+		uri:       path,
+		method:    method,
+		needsAuth: false,
+		handler:   handler,
 	}
 }
 
 type endpoint struct {
 	http.Handler
 
-	uri        string
-	method     httpMethod
-	needsAuth  bool
-	needsAdmin bool
-	handler    handlerFunc
+	uri       string
+	method    httpMethod
+	needsAuth bool
+	handler   handlerFunc
+	// Role users need to call the endpoint.
+	role types.Role
+	// Whether the engineer of the train in the path can call the endpoint, whatever their role.
+	allowsTrainEngineer bool
+	// Scope API tokens need to call the endpoint; nil if they can't.
+	scope *types.Scope
+	doc   endpointDoc
+}
+
+// Describes an endpoint for the generated OpenAPI spec.
+// Set with the chainable methods below, e.g.
+// newEp(...).describe("Get a train.").returns(&types.Train{}).
+type endpointDoc struct {
+	summary string
+	params  []endpointParam
+	anyForm string // Description of free-form POST form fields, if accepted.
+	body    string // Description of the JSON request body, if any.
+
+	result   reflect.Type // Type of the response result; nil for empty responses.
+	raw      bool         // Whether the result is the whole response body.
+	redirect bool
+	responds bool // Whether the response was documented, with returns or redirects.
+}
+
+type endpointParam struct {
+	name        string
+	in          string // query or form
+	description string
+	required    bool
+}
+
+// Restricts the endpoint to users with at least role.
+func (h endpoint) requires(role types.Role) endpoint {
+	h.role = role
+	return h
+}
+
+// Also allows the engineer of the train in the path to call the endpoint.
+func (h endpoint) orTrainEngineer() endpoint {
+	h.allowsTrainEngineer = true
+	return h
+}
+
+// Allows API tokens with scope to call the endpoint.
+func (h endpoint) scoped(scope types.Scope) endpoint {
+	h.scope = &scope
+	return h
+}
+
+// Sets the summary of the endpoint.
+func (h endpoint) describe(summary string) endpoint {
+	h.doc.summary = summary
+	return h
+}
+
+// Documents an optional query param.
+func (h endpoint) query(name, description string) endpoint {
+	return h.param(endpointParam{name: name, in: "query", description: description})
+}
+
+// Documents a required query param.
+func (h endpoint) requiredQuery(name, description string) endpoint {
+	return h.param(endpointParam{name: name, in: "query", description: description, required: true})
+}
+
+// Documents an optional POST form field.
+func (h endpoint) form(name, description string) endpoint {
+	return h.param(endpointParam{name: name, in: "form", description: description})
+}
+
+// Documents a required POST form field.
+func (h endpoint) requiredForm(name, description string) endpoint {
+	return h.param(endpointParam{name: name, in: "form", description: description, required: true})
+}
+
+func (h endpoint) param(param endpointParam) endpoint {
+	params := make([]endpointParam, len(h.doc.params), len(h.doc.params)+1)
+	copy(params, h.doc.params)
+	h.doc.params = append(params, param)
+	return h
+}
+
+// Documents that any POST form field is accepted, like metadata keys.
+func (h endpoint) anyForm(description string) endpoint {
+	h.doc.anyForm = description
+	return h
+}
+
+// Documents a free-form request body, like a webhook payload.
+func (h endpoint) jsonBody(description string) endpoint {
+	h.doc.body = description
+	return h
+}
+
+// Documents the response result by example value, e.g. &types.Train{} or []string{}.
+// Use nil for endpoints that respond with an empty result.
+func (h endpoint) returns(result interface{}) endpoint {
+	h.doc.result = reflect.TypeOf(result)
+	h.doc.responds = true
+	return h
+}
+
+// Like returns, for endpoints that respond with a rawResponse.
+func (h endpoint) returnsRaw(result interface{}) endpoint {
+	h.doc.raw = true
+	return h.returns(result)
+}
+
+// Documents that the endpoint responds with a redirect.
+func (h endpoint) redirects() endpoint {
+	h.doc.redirect = true
+	h.doc.responds = true
+	return h
 }
 
 func (h endpoint) Route(r *mux.Router, handler http.Handler) {
@@ -112,16 +240,17 @@ func (h endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 type response struct {
-	Result       interface{}  `json:"result,omitempty"`
-	Error        interface{}  `json:"error,omitempty"`
-	Code         int          `json:"-"`
-	Cookie       *http.Cookie `json:"-"`
-	RedirectPath string       `json:"-"`
+	Result       interface{}    `json:"result,omitempty"`
+	Error        interface{}    `json:"error,omitempty"`
+	Code         int            `json:"-"`
+	Cookies      []*http.Cookie `json:"-"`
+	RedirectPath string         `json:"-"` // A path or absolute URL.
+	Raw          interface{}    `json:"-"` // Written as the whole body, instead of Result.
 }
 
 func (resp response) Write(w http.ResponseWriter, r *http.Request) {
-	if resp.Cookie != nil {
-		http.SetCookie(w, resp.Cookie)
+	for _, cookie := range resp.Cookies {
+		http.SetCookie(w, cookie)
 	}
 
 	if resp.RedirectPath != "" {
@@ -149,7 +278,11 @@ func (resp response) Write(w http.ResponseWriter, r *http.Request) {
 	buffer := new(bytes.Buffer)
 	encoder := json.NewEncoder(buffer)
 	encoder.SetIndent("", indent)
-	err := encoder.Encode(resp)
+	var body interface{} = resp
+	if resp.Raw != nil {
+		body = resp.Raw
+	}
+	err := encoder.Encode(body)
 	if err != nil {
 		logMsg := fmt.Sprintf("Could not marshal response (%+v): %v", r, err)
 		datadog.Error("%s", logMsg)
@@ -195,6 +328,13 @@ func dataResponse(result interface{}) response {
 	return resultResponse(result, http.StatusOK)
 }
 
+func rawResponse(body interface{}) response {
+	return response{
+		Raw:  body,
+		Code: http.StatusOK,
+	}
+}
+
 func emptyResponse() response {
 	return dataResponse(nil)
 }
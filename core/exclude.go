@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/tracing"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func excludeEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/train/{train_id:[0-9]+}/commit/{sha:[0-9a-fA-F]+}/exclude", post, excludeCommit).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Take a bad commit off the train without cancelling it. " +
+				"The commit is reverted, or the train is delivered again without it, depending on the options.").
+			returns(&types.Train{}),
+	}
+}
+
+func excludeCommit(r *http.Request) response {
+	trainCloseModificationLock.Lock()
+	defer trainCloseModificationLock.Unlock()
+
+	dataClient := data.NewClient()
+
+	train, resp := parseTrainVars(r, dataClient, false)
+	if resp != nil {
+		return *resp
+	}
+
+	resp = validateMutableTrain(train)
+	if resp != nil {
+		return *resp
+	}
+
+	sha := mux.Vars(r)["sha"]
+	var commit *types.Commit
+	for _, trainCommit := range train.Commits {
+		if trainCommit.SHA == sha {
+			commit = trainCommit
+			break
+		}
+	}
+	if commit == nil {
+		return errorResponse(
+			fmt.Sprintf("Commit %s is not on train %d.", sha, train.ID),
+			http.StatusBadRequest)
+	}
+	if len(train.Commits) == 1 {
+		return errorResponse(
+			"Can't take the only commit off the train. Cancel the train instead.",
+			http.StatusBadRequest)
+	}
+
+	options, err := dataClient.Options()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting options: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	return takeCommitOff(
+		r.Context(), dataClient, code.GetService(), messaging.GetService(), phase.GetService(),
+		ticket.GetService(), options, train, commit, authedUser)
+}
+
+// Takes the commit off the train, by reverting it or delivering the train again without it.
+func takeCommitOff(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	options *types.Options,
+	train *types.Train,
+	commit *types.Commit,
+	authedUser *types.User) response {
+
+	// Revert first, so nothing changes if it fails.
+	if !options.ExcludesByRedelivery() {
+		err := codeService.Revert(ctx, commit.SHA, train.Branch)
+		if err == code.ErrRevertUnsupported {
+			return errorResponse(
+				fmt.Sprintf("%v. Set exclude_commits to %s in the options to deliver the train again instead.",
+					err, types.ExcludeByRedelivery),
+				http.StatusNotImplemented)
+		} else if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error reverting commit %s: %v", commit.SHA, err),
+				http.StatusInternalServerError)
+		}
+	}
+
+	before := newTrainAuditState(train)
+	err := dataClient.ExcludeCommit(train, commit)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error taking commit off train: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	auditTrain(dataClient, authedUser, types.TrainExcludeCommitAction, train, before, commit.SHA)
+	metrics.Incr("train.exclude_commit", train.DatadogTags())
+	messagingService.CommitExcluded(ctx, train, commit, authedUser)
+
+	err = deleteCommitTicket(ctx, dataClient, ticketService, train, commit)
+	if err != nil {
+		// The ticket can still be closed by hand.
+		logger.Error("Error deleting ticket for commit %s on train %d: %v", commit.SHA, train.ID, err)
+	}
+
+	// Verification starts over either way, with a ticket for the author's other commits.
+	if options.ExcludesByRedelivery() {
+		err = dataClient.RedeliverTrain(train, generatedBranch(train))
+		if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error redelivering train: %v", err),
+				http.StatusInternalServerError)
+		}
+		go StartTrain(tracing.Detach(ctx), data.NewClient(), codeService, messagingService, phaseService,
+			ticketService, train)
+	} else {
+		err = extendWithRevert(ctx, dataClient, codeService, messagingService, phaseService, ticketService,
+			train, authedUser)
+		if err != nil {
+			return errorResponse(err.Error(), http.StatusInternalServerError)
+		}
+	}
+
+	clearLatestTrainCache()
+
+	train, err = dataClient.Train(train.ID)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting train: %v", err),
+			http.StatusInternalServerError)
+	}
+	return dataResponse(train)
+}
+
+// Redelivered trains are built from their head without the excluded commits, on this branch.
+func generatedBranch(train *types.Train) string {
+	return fmt.Sprintf("conductor/train-%d", train.ID)
+}
+
+// Deletes the ticket for the commit's author, which covers the commit.
+func deleteCommitTicket(
+	ctx context.Context,
+	dataClient data.Client,
+	ticketService ticket.Service,
+	train *types.Train,
+	commit *types.Commit) error {
+
+	ticketModificationLock.Lock()
+	defer ticketModificationLock.Unlock()
+
+	for _, trainTicket := range train.Tickets {
+		for _, ticketCommit := range trainTicket.Commits {
+			if ticketCommit.SHA != commit.SHA {
+				continue
+			}
+			err := ticketService.DeleteTicket(ctx, trainTicket)
+			if err != nil {
+				return err
+			}
+			return dataClient.DeleteTicket(trainTicket)
+		}
+	}
+	return nil
+}
+
+// Picks up the revert from the branch, which delivers the train again.
+func extendWithRevert(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	train *types.Train,
+	user *types.User) error {
+
+	if train.Closed {
+		return extendClosedTrain(ctx, dataClient, train, user)
+	}
+	checkBranch(ctx, dataClient, codeService, messagingService, phaseService, ticketService, train.Branch, user)
+	return nil
+}
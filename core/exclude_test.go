@@ -0,0 +1,115 @@
+// +build data
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestExcludeCommit(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	options := types.DefaultOptions
+	options.ExcludeCommits = types.ExcludeByRedelivery
+	err := dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	bad := &types.Commit{SHA: fmt.Sprintf("bad%x", time.Now().UnixNano()), AuthorEmail: "bad@example.com"}
+	good := &types.Commit{SHA: fmt.Sprintf("900d%x", time.Now().UnixNano())}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{bad, good})
+	assert.NoError(t, err)
+	phaseGroupID := train.ActivePhases.ID
+
+	path := fmt.Sprintf("/api/train/%d/commit/%s/exclude", train.ID, "abc123")
+	res := requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The commit isn't on the train")
+
+	path = fmt.Sprintf("/api/train/%d/commit/%s/exclude", train.ID, bad.SHA)
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Len(t, train.Commits, 2)
+	for _, commit := range train.Commits {
+		assert.NotEqual(t, bad.SHA, commit.SHA)
+	}
+	assert.Len(t, train.ExcludedCommits, 1)
+	assert.Equal(t, bad.SHA, train.ExcludedCommits[0].SHA)
+	assert.Equal(t, good.SHA, train.HeadSHA)
+
+	// Delivered again without the commit.
+	assert.NotEqual(t, phaseGroupID, train.ActivePhases.ID)
+	assert.Equal(t, generatedBranch(train), train.BuildBranch())
+
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The commit is already off the train")
+}
+
+func TestExcludeCommitByRevert(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+
+	bad := &types.Commit{SHA: fmt.Sprintf("bad%x", time.Now().UnixNano()), AuthorEmail: "bad@example.com"}
+	good := &types.Commit{SHA: fmt.Sprintf("900d%x", time.Now().UnixNano())}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{bad, good})
+	assert.NoError(t, err)
+
+	options := types.DefaultOptions
+	options.ExcludeCommits = types.ExcludeByRevert
+	var revertedSHA, revertedBranch string
+	codeService := &code.CodeServiceMock{
+		RevertMock: func(ctx context.Context, sha1, branch string) error {
+			revertedSHA, revertedBranch = sha1, branch
+			return nil
+		},
+	}
+	messagingService := messaging.MessagingServiceMock{}
+	phaseService := &phase.PhaseServiceMock{}
+	ticketService := &ticket.TicketServiceMock{}
+
+	// Code hosts that can't revert leave the train alone.
+	unsupported := &code.CodeServiceMock{
+		RevertMock: func(ctx context.Context, sha1, branch string) error {
+			return code.ErrRevertUnsupported
+		},
+	}
+	res := takeCommitOff(
+		context.Background(), dataClient, unsupported, messagingService, phaseService, ticketService,
+		&options, train, bad, testData.User)
+	assert.Equal(t, http.StatusNotImplemented, res.Code)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, train.ExcludedCommits)
+
+	res = takeCommitOff(
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		&options, train, bad, testData.User)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, bad.SHA, revertedSHA)
+	assert.Equal(t, train.Branch, revertedBranch)
+
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Len(t, train.ExcludedCommits, 1)
+	assert.Nil(t, train.GeneratedBranch, "Reverted trains build from their branch")
+}
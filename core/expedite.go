@@ -0,0 +1,317 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/tracing"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func expediteEndpoints() []endpoint {
+	return []endpoint{
+		newAdminEp("/api/expedite", post, expediteTrain).
+			scoped(types.TrainAdmin).
+			describe("Ship a hotfix ahead of the current train. "+
+				"The current train is blocked, and goes back in line once the hotfix is deployed or cancelled.").
+			requiredForm("reason", "Why the fix can't wait for the current train.").
+			form("sha", "Ship the hotfix branch up to this commit. Defaults to the head of the branch.").
+			form("commits", "Comma-separated SHAs to ship instead, like cherry-picks. "+
+				"They must be the only commits on the hotfix branch since production.").
+			form("branch", "The hotfix branch. Defaults to the expedite branch in the options.").
+			returns(&types.Train{}),
+	}
+}
+
+func expediteTrain(r *http.Request) response {
+	err := r.ParseForm()
+	if err != nil {
+		return errorResponse("Error parsing POST form", http.StatusBadRequest)
+	}
+
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		return errorResponse("`reason` must be set in POST form", http.StatusBadRequest)
+	}
+
+	sha := strings.TrimSpace(r.PostFormValue("sha"))
+	var shas []string
+	for _, commit := range strings.Split(r.PostFormValue("commits"), ",") {
+		commit = strings.TrimSpace(commit)
+		if commit != "" {
+			shas = append(shas, commit)
+		}
+	}
+	if sha != "" && len(shas) > 0 {
+		return errorResponse("Only one of `sha` and `commits` can be set", http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+
+	options, err := dataClient.Options()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting options: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	branch := strings.TrimSpace(r.PostFormValue("branch"))
+	if branch == "" {
+		branch = options.Expedite.BranchName()
+	}
+
+	// Don't lose the current train.
+	var preempted *types.Train
+	latestTrain, err := dataClient.LatestTrain()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting latest train: %v", err),
+			http.StatusInternalServerError)
+	}
+	if latestTrain != nil && !latestTrain.Done {
+		if latestTrain.IsExpedited() {
+			return errorResponse(
+				fmt.Sprintf("Train %d is already expedited.", latestTrain.ID),
+				http.StatusBadRequest)
+		}
+		if latestTrain.IsDeploying() {
+			return errorResponse(
+				fmt.Sprintf("Train %d is deploying. Wait for it to finish.", latestTrain.ID),
+				http.StatusBadRequest)
+		}
+		if latestTrain.Branch == branch {
+			return errorResponse(
+				fmt.Sprintf("Train %d is already on branch %s. Hotfixes need their own branch.", latestTrain.ID, branch),
+				http.StatusBadRequest)
+		}
+		preempted = latestTrain
+	}
+
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting the train in production: %v", err),
+			http.StatusInternalServerError)
+	}
+	if productionTrain == nil {
+		return errorResponse("Nothing has been deployed yet.", http.StatusBadRequest)
+	}
+
+	head := sha
+	if head == "" {
+		head = branch
+	}
+	commits, err := code.GetService().CompareRefs(r.Context(), productionTrain.HeadSHA, head)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting commits since production: %v", err),
+			http.StatusInternalServerError)
+	}
+	if len(shas) > 0 {
+		var resp *response
+		commits, resp = onlyCommits(commits, shas, branch)
+		if resp != nil {
+			return *resp
+		}
+	}
+	if len(commits) == 0 {
+		return errorResponse(
+			fmt.Sprintf("There's nothing new on %s since production.", head),
+			http.StatusBadRequest)
+	}
+	for _, commit := range commits {
+		commit.Branch = branch
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	messagingService := messaging.GetService()
+
+	train := ExpediteTrain(r.Context(), dataClient, messagingService, branch, authedUser, commits, reason, preempted)
+	if train == nil {
+		return errorResponse("Error creating expedited train", http.StatusInternalServerError)
+	}
+
+	if preempted != nil && !preempted.Blocked {
+		before := newTrainAuditState(preempted)
+		blockedReason := fmt.Sprintf("hotfix train %d", train.ID)
+		err = dataClient.BlockTrain(preempted, &blockedReason)
+		if err != nil {
+			logger.Error("Error blocking preempted train: %v", err)
+		} else {
+			auditTrain(dataClient, authedUser, types.TrainBlockAction, preempted, before, reason)
+			messagingService.TrainBlocked(r.Context(), preempted, authedUser)
+		}
+	}
+
+	go StartTrain(tracing.Detach(r.Context()), data.NewClient(), code.GetService(), messagingService,
+		phase.GetService(), ticket.GetService(), train)
+
+	return dataResponse(train)
+}
+
+// Returns the commits up to the last of shas, which must be exactly shas.
+// Otherwise, returns a response saying what's missing or extra.
+func onlyCommits(commits []*types.Commit, shas []string, branch string) ([]*types.Commit, *response) {
+	wanted := make(map[string]bool)
+	for _, sha := range shas {
+		wanted[sha] = true
+	}
+
+	last := -1
+	found := 0
+	for i, commit := range commits {
+		if wanted[commit.SHA] {
+			last = i
+			found++
+		}
+	}
+	if found < len(wanted) {
+		resp := errorResponse(
+			fmt.Sprintf("Not all of the commits are on %s since production.", branch),
+			http.StatusBadRequest)
+		return nil, &resp
+	}
+
+	commits = commits[:last+1]
+	for _, commit := range commits {
+		if !wanted[commit.SHA] {
+			resp := errorResponse(
+				fmt.Sprintf("Commit %s on %s isn't in `commits`, and would ship too.", commit.SHA, branch),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+	}
+	return commits, nil
+}
+
+func ExpediteTrain(
+	ctx context.Context,
+	dataClient data.Client,
+	messagingService messaging.Service,
+	branch string,
+	engineer *types.User,
+	commits []*types.Commit,
+	reason string,
+	preempted *types.Train) *types.Train {
+
+	train, err := dataClient.CreateExpeditedTrain(branch, engineer, commits, reason, preempted)
+	if err != nil {
+		logger.Error("Error creating expedited train: %v", err)
+		return nil
+	}
+
+	train.SendCommitCountMetrics()
+
+	auditTrain(dataClient, engineer, types.TrainExpediteAction, train, nil, reason)
+
+	metrics.Incr("train.expedite", train.DatadogTags())
+	messagingService.TrainCreation(ctx, train, commits)
+
+	clearLatestTrainCache()
+
+	return train
+}
+
+// Whether the train skips verification jobs and tickets, as an expedited train.
+func skipsVerification(dataClient data.Client, train *types.Train) bool {
+	if !train.IsExpedited() {
+		return false
+	}
+	options, err := dataClient.Options()
+	if err != nil {
+		logger.Error("Error getting options: %v", err)
+		return false
+	}
+	return options.SkipsVerification(train)
+}
+
+// Puts the train that an expedited train preempted back in line, now that the expedited train is done.
+// See handleNewCommitsForBranch.
+func resumePreemptedTrain(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	train *types.Train) {
+
+	if train.PreemptedID == nil {
+		return
+	}
+
+	preempted, err := dataClient.Train(*train.PreemptedID)
+	if err != nil {
+		logger.Error("Error getting preempted train: %v", err)
+		return
+	}
+	if preempted == nil {
+		return
+	}
+
+	checkBranch(
+		ctx, dataClient, codeService, messagingService, phaseService, ticketService,
+		preempted.Branch, nil)
+}
+
+// Duplicates the preempted train after the expedited train, with any commits that came in meanwhile.
+// If production has hotfix commits that the new train doesn't, they're merged into its branch
+// and the train is extended with the merge, so they aren't undone.
+// If they don't merge cleanly, the train is blocked until someone merges them.
+func rebasePreemptedTrain(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	expedited *types.Train,
+	preempted *types.Train,
+	newCommits []*types.Commit) {
+
+	// Clean up old train.
+	err := ticketService.CloseTrainTickets(ctx, preempted)
+	if err != nil {
+		logger.Error("Error closing old train tickets: %v", err)
+	}
+
+	train := DuplicateTrain(ctx, dataClient, messagingService, preempted, newCommits)
+	if train == nil {
+		return
+	}
+
+	if expedited.IsDeployed() {
+		missing, err := codeService.CompareRefs(ctx, train.HeadSHA, expedited.HeadSHA)
+		if err != nil {
+			logger.Error("Error comparing train %d to hotfix train %d: %v", train.ID, expedited.ID, err)
+		} else if len(missing) > 0 {
+			merge, err := codeService.Merge(ctx, expedited.HeadSHA, train.Branch)
+			if err != nil {
+				logger.Error("Error merging hotfix train %d into %s: %v", expedited.ID, train.Branch, err)
+				before := newTrainAuditState(train)
+				reason := fmt.Sprintf("missing %d commits from hotfix train %d. Merge %s into %s",
+					len(missing), expedited.ID, expedited.Branch, train.Branch)
+				err = dataClient.BlockTrain(train, &reason)
+				if err != nil {
+					logger.Error("Error blocking train: %v", err)
+				} else {
+					auditTrain(dataClient, nil, types.TrainBlockAction, train, before, reason)
+					messagingService.TrainBlocked(ctx, train, nil)
+				}
+			} else if merge != nil {
+				ExtendTrain(ctx, dataClient, messagingService, train, []*types.Commit{merge}, nil)
+			}
+		}
+	}
+
+	go StartTrain(tracing.Detach(ctx), data.NewClient(), codeService, messagingService, phaseService, ticketService, train)
+}
@@ -0,0 +1,145 @@
+// +build data
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestOnlyCommits(t *testing.T) {
+	commits := []*types.Commit{{SHA: "a"}, {SHA: "b"}, {SHA: "c"}}
+
+	result, resp := onlyCommits(commits, []string{"b", "a"}, "hotfix")
+	assert.Nil(t, resp)
+	assert.Len(t, result, 2)
+
+	_, resp = onlyCommits(commits, []string{"c"}, "hotfix")
+	assert.NotNil(t, resp)
+
+	_, resp = onlyCommits(commits, []string{"a", "d"}, "hotfix")
+	assert.NotNil(t, resp)
+}
+
+// An expedited train holds commits for the train it preempted, which goes back in line once it's done.
+func TestExpeditedTrainPreempts(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+	hotfixCommit := &types.Commit{
+		Message:     "Hotfix commit",
+		AuthorName:  "Author Name",
+		AuthorEmail: "author@email.com",
+		URL:         "https://github.com",
+		SHA:         "h0tf1x",
+	}
+
+	expedited, err := dataClient.CreateExpeditedTrain(
+		"hotfix", testData.User, []*types.Commit{hotfixCommit}, "outage", testData.Train)
+	assert.NoError(t, err)
+	assert.True(t, expedited.IsExpedited())
+	assert.True(t, expedited.Closed)
+	assert.Equal(t, testData.Train.ID, *expedited.PreemptedID)
+	assert.True(t, expedited.PreviousTrainDone)
+
+	codeService := &code.CodeServiceMock{
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
+			return []*types.Commit{newCommit}, nil
+		},
+	}
+	messagingService := messaging.MessagingServiceMock{}
+	phaseService := &phase.PhaseServiceMock{}
+	ticketService := &ticket.TicketServiceMock{}
+
+	// New commits wait for the hotfix.
+	checkBranch(
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		testData.Train.Branch, testData.User)
+	train, err := dataClient.LatestTrain()
+	assert.NoError(t, err)
+	assert.Equal(t, expedited.ID, train.ID)
+
+	err = dataClient.CancelTrain(expedited)
+	assert.NoError(t, err)
+	resumePreemptedTrain(
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		expedited)
+
+	train, err = dataClient.LatestTrain()
+	assert.NoError(t, err)
+	assert.NotEqual(t, expedited.ID, train.ID)
+	assert.Equal(t, testData.Train.Branch, train.Branch)
+	assert.Equal(t, testData.Train.TailSHA, train.TailSHA)
+	assert.Equal(t, newCommitSHA, train.HeadSHA)
+	assert.False(t, train.Blocked)
+}
+
+func TestRebasePreemptedTrain(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+	hotfixCommit := &types.Commit{
+		Message:     "Hotfix commit",
+		AuthorName:  "Author Name",
+		AuthorEmail: "author@email.com",
+		URL:         "https://github.com",
+		SHA:         "h0tf1x",
+	}
+	expedited, err := dataClient.CreateExpeditedTrain(
+		"hotfix", testData.User, []*types.Commit{hotfixCommit}, "outage", testData.Train)
+	assert.NoError(t, err)
+	expedited.DeployedAt = types.Time{Value: time.Now()}
+
+	mergeCommit := &types.Commit{
+		Message:     "Merge h0tf1x into " + testData.Train.Branch,
+		AuthorName:  "Conductor",
+		AuthorEmail: "conductor@email.com",
+		URL:         "https://github.com",
+		SHA:         "m3rge",
+	}
+	var mergedHead, mergedBranch string
+	codeService := &code.CodeServiceMock{
+		CompareRefsMock: func(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
+			return []*types.Commit{hotfixCommit}, nil
+		},
+		MergeMock: func(ctx context.Context, head, branch string) (*types.Commit, error) {
+			mergedHead, mergedBranch = head, branch
+			return mergeCommit, nil
+		},
+	}
+	messagingService := messaging.MessagingServiceMock{}
+	phaseService := &phase.PhaseServiceMock{}
+	ticketService := &ticket.TicketServiceMock{}
+
+	// The hotfix is merged into the branch, and the new train includes the merge.
+	rebasePreemptedTrain(
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		expedited, testData.Train, []*types.Commit{newCommit})
+	assert.Equal(t, expedited.HeadSHA, mergedHead)
+	assert.Equal(t, testData.Train.Branch, mergedBranch)
+	train, err := dataClient.LatestTrain()
+	assert.NoError(t, err)
+	assert.Equal(t, mergeCommit.SHA, train.HeadSHA)
+	assert.False(t, train.Blocked)
+
+	// If it doesn't merge, the new train waits for someone to merge it.
+	codeService.MergeMock = func(ctx context.Context, head, branch string) (*types.Commit, error) {
+		return nil, fmt.Errorf("%s doesn't merge cleanly into %s", head, branch)
+	}
+	rebasePreemptedTrain(
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		expedited, train, nil)
+	train, err = dataClient.LatestTrain()
+	assert.NoError(t, err)
+	assert.True(t, train.Blocked)
+	assert.Contains(t, *train.BlockedReason, "hotfix train")
+}
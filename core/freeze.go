@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func freezeEndpoints() []endpoint {
+	return []endpoint{
+		newAdminEp("/api/train/{train_id:[0-9]+}/overrideFreeze", post, overrideFreeze).
+			scoped(types.TrainAdmin).
+			describe("Let the train deploy even though deploys are frozen.").
+			requiredForm("reason", "Why the train can't wait for the freeze to end.").
+			returns(&types.Train{}),
+	}
+}
+
+func overrideFreeze(r *http.Request) response {
+	err := r.ParseForm()
+	if err != nil {
+		return errorResponse("Error parsing POST form", http.StatusBadRequest)
+	}
+
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason == "" {
+		return errorResponse("`reason` must be set in POST form", http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+
+	train, resp := parseTrainVars(r, dataClient, false)
+	if resp != nil {
+		return *resp
+	}
+
+	resp = validateMutableTrain(train)
+	if resp != nil {
+		return *resp
+	}
+
+	if train.Freeze == nil {
+		return errorResponse("Deploys aren't frozen.", http.StatusBadRequest)
+	}
+	if train.FreezeOverrideReason != nil {
+		return errorResponse("The freeze is already overridden for this train.", http.StatusBadRequest)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+
+	before := newTrainAuditState(train)
+	err = dataClient.OverrideFreeze(train, authedUser, reason)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error overriding freeze: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	auditTrain(dataClient, authedUser, types.TrainFreezeOverrideAction, train, before, reason)
+
+	deployIfReady(r.Context(), dataClient, messaging.GetService(), train)
+
+	clearLatestTrainCache()
+
+	return dataResponse(train)
+}
@@ -0,0 +1,55 @@
+// +build data
+
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestOverrideFreeze(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	path := fmt.Sprintf("/api/train/%d/overrideFreeze", testData.Train.ID)
+	res := requestWithCookie(t, server, "POST", path, url.Values{"reason": {"security fix"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Deploys aren't frozen yet")
+
+	dataClient := data.NewClient()
+	start := time.Now().Add(-time.Hour)
+	end := time.Now().Add(time.Hour)
+	options := types.DefaultOptions
+	options.Freezes = types.FreezeWindows{{Reason: "the holidays", Start: &start, End: &end}}
+	err := dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	res = requestWithCookie(t, server, "GET", fmt.Sprintf("/api/train/%d", testData.Train.ID), nil, testData.TokenCookie)
+	assert.Contains(t, res.Body.String(), `"reason":"the holidays"`)
+
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Overrides need a reason")
+
+	res = requestWithCookie(t, server, "POST", path, url.Values{"reason": {"security fix"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.Contains(t, res.Body.String(), `"freeze_override_reason":"security fix"`)
+
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.False(t, train.IsFrozen())
+	assert.Equal(t, testData.User.Email, train.FreezeOverrideBy.Email)
+
+	settings.CustomizeAdminUsers([]string{})
+	res = requestWithCookie(t, server, "POST", path, url.Values{"reason": {"security fix"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+}
@@ -0,0 +1,153 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const (
+	DefaultTrainHistoryLimit = 25
+	MaxTrainHistoryLimit     = 100
+)
+
+func historyEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/trains", get, fetchTrains).
+			scoped(types.TrainRead).
+			describe("List past trains, newest first, one page at a time.").
+			query("branch", "Only trains on this branch.").
+			query("engineer", "Only trains with this engineer email.").
+			query("author", "Only trains with a commit by this author email.").
+			query("state", "Only trains in this state: open, closed, blocked, deploying, deployed or cancelled.").
+			query("created_after", "RFC3339 timestamp or date.").
+			query("created_before", "RFC3339 timestamp or date.").
+			query("deployed_after", "RFC3339 timestamp or date.").
+			query("deployed_before", "RFC3339 timestamp or date.").
+			query("cursor", "next_cursor of the previous page.").
+			query("limit", fmt.Sprintf("Trains per page, at most %d. Defaults to %d.",
+				MaxTrainHistoryLimit, DefaultTrainHistoryLimit)).
+			returns(&types.TrainHistory{}),
+	}
+}
+
+// Dates can be given as RFC3339 timestamps or as plain dates.
+// Plain dates are interpreted in the Conductor timezone.
+func parseHistoryTime(value string) (*time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return &parsed, nil
+	}
+	parsed, err = time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("Bad date value: %s", value)
+	}
+	return &parsed, nil
+}
+
+// Returns filter, or a response if there was an error.
+func parseTrainFilter(params map[string]string) (*types.TrainFilter, *response) {
+	filter := &types.TrainFilter{
+		Branch:        params["branch"],
+		EngineerEmail: params["engineer"],
+		AuthorEmail:   params["author"],
+		Limit:         DefaultTrainHistoryLimit,
+	}
+
+	if state, ok := params["state"]; ok {
+		trainState, err := types.TrainStateFromString(state)
+		if err != nil {
+			resp := errorResponse(err.Error(), http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.State = &trainState
+	}
+
+	timeFilters := map[string]**time.Time{
+		"created_after":   &filter.CreatedAfter,
+		"created_before":  &filter.CreatedBefore,
+		"deployed_after":  &filter.DeployedAfter,
+		"deployed_before": &filter.DeployedBefore,
+	}
+	for key, target := range timeFilters {
+		value, ok := params[key]
+		if !ok {
+			continue
+		}
+		parsed, err := parseHistoryTime(value)
+		if err != nil {
+			resp := errorResponse(
+				fmt.Sprintf("Bad `%s` value: %v", key, err),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		*target = parsed
+	}
+
+	if cursor, ok := params["cursor"]; ok {
+		cursorID, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			resp := errorResponse(
+				fmt.Sprintf("Bad cursor value: %s", cursor),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.Cursor = cursorID
+	}
+
+	if limit, ok := params["limit"]; ok {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt < 1 || limitInt > MaxTrainHistoryLimit {
+			resp := errorResponse(
+				fmt.Sprintf("Bad limit value: %s. Must be between 1 and %d.", limit, MaxTrainHistoryLimit),
+				http.StatusBadRequest)
+			return nil, &resp
+		}
+		filter.Limit = limitInt
+	}
+
+	return filter, nil
+}
+
+func fetchTrains(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	query := r.URL.Query()
+	params := make(map[string]string)
+	for key, values := range query {
+		params[key] = values[0]
+	}
+
+	filter, resp := parseTrainFilter(params)
+	if resp != nil {
+		return *resp
+	}
+
+	// Fetch one extra train to know if there's another page.
+	pageSize := filter.Limit
+	filter.Limit = pageSize + 1
+
+	trains, err := dataClient.Trains(filter)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting trains: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	var nextCursor *string
+	if len(trains) > pageSize {
+		trains = trains[:pageSize]
+		cursor := strconv.FormatUint(trains[pageSize-1].ID, 10)
+		nextCursor = &cursor
+	}
+
+	return dataResponse(&types.TrainHistory{
+		Params:     params,
+		Trains:     trains,
+		NextCursor: nextCursor,
+	})
+}
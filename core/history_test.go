@@ -0,0 +1,106 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+type trainHistoryResponse struct {
+	Result types.TrainHistory `json:"result"`
+	Error  string             `json:"error"`
+}
+
+func fetchTrainHistory(t *testing.T, server *mux.Router, testData *TestData, query string) (int, trainHistoryResponse) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/trains?%s", query), nil)
+	assert.NoError(t, err)
+	req.AddCookie(testData.TokenCookie)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	var history trainHistoryResponse
+	err = json.Unmarshal(res.Body.Bytes(), &history)
+	assert.NoError(t, err)
+	return res.Code, history
+}
+
+func TestFetchTrainsPagination(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	branch := "history_test_branch"
+	var trainIDs []uint64
+	for i := 0; i < 3; i++ {
+		commit := &types.Commit{SHA: fmt.Sprintf("history_test_sha_%d", i)}
+		train, err := dataClient.CreateTrain(branch, testData.User, []*types.Commit{commit})
+		assert.NoError(t, err)
+		trainIDs = append(trainIDs, train.ID)
+	}
+
+	code, history := fetchTrainHistory(t, server, testData, fmt.Sprintf("branch=%s&limit=2", branch))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 2)
+	assert.Equal(t, trainIDs[2], history.Result.Trains[0].ID)
+	assert.Equal(t, trainIDs[1], history.Result.Trains[1].ID)
+	assert.Equal(t, int64(1), history.Result.Trains[0].CommitCount)
+	assert.NotNil(t, history.Result.NextCursor)
+
+	code, history = fetchTrainHistory(t, server, testData,
+		fmt.Sprintf("branch=%s&limit=2&cursor=%s", branch, *history.Result.NextCursor))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 1)
+	assert.Equal(t, trainIDs[0], history.Result.Trains[0].ID)
+	assert.Nil(t, history.Result.NextCursor)
+}
+
+func TestFetchTrainsFilters(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	branch := "history_filter_branch"
+	commit := &types.Commit{SHA: "history_filter_sha", AuthorEmail: "history-author@example.com"}
+	train, err := dataClient.CreateTrain(branch, testData.User, []*types.Commit{commit})
+	assert.NoError(t, err)
+
+	code, history := fetchTrainHistory(t, server, testData,
+		fmt.Sprintf("branch=%s&author=%s&engineer=%s&state=open", branch, commit.AuthorEmail, testData.User.Email))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 1)
+	assert.Equal(t, train.ID, history.Result.Trains[0].ID)
+	assert.Equal(t, types.Open, history.Result.Trains[0].State)
+
+	err = dataClient.CancelTrain(train)
+	assert.NoError(t, err)
+
+	code, history = fetchTrainHistory(t, server, testData, fmt.Sprintf("branch=%s&state=open", branch))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 0)
+
+	code, history = fetchTrainHistory(t, server, testData, fmt.Sprintf("branch=%s&state=cancelled", branch))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 1)
+
+	code, history = fetchTrainHistory(t, server, testData,
+		fmt.Sprintf("branch=%s&created_after=2100-01-01", branch))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, history.Result.Trains, 0)
+
+	code, _ = fetchTrainHistory(t, server, testData, "state=derailed")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = fetchTrainHistory(t, server, testData, "created_after=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = fetchTrainHistory(t, server, testData, "limit=1000")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func holdEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/train/{train_id:[0-9]+}/commit/{sha:[0-9a-fA-F]+}/release", post, releaseCommit).
+			requires(types.Committer).
+			scoped(types.TrainWrite).
+			describe("Let the train deploy with a commit marked [hold] or [deploy-after: time]. " +
+				"Only the commit's author and the train's engineer can release it.").
+			returns(&types.Train{}),
+	}
+}
+
+func releaseCommit(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	train, resp := parseTrainVars(r, dataClient, false)
+	if resp != nil {
+		return *resp
+	}
+
+	// Trains left behind by a split still deploy, so only deploying and deployed trains are done with holds.
+	if train.IsDeployed() {
+		return errorResponse("Train already deployed.", http.StatusBadRequest)
+	}
+	if train.IsDeploying() {
+		return errorResponse("Train is deploying.", http.StatusBadRequest)
+	}
+
+	sha := mux.Vars(r)["sha"]
+	var hold *types.CommitHold
+	for _, trainHold := range train.Holds {
+		if trainHold.Commit.SHA == sha {
+			hold = trainHold
+			break
+		}
+	}
+	if hold == nil {
+		return errorResponse(
+			fmt.Sprintf("Commit %s is not holding train %d.", sha, train.ID),
+			http.StatusBadRequest)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	isEngineer := train.Engineer != nil && train.Engineer.ID == authedUser.ID
+	if !isEngineer && hold.Commit.AuthorEmail != authedUser.Email {
+		return errorResponse(
+			fmt.Sprintf("Only the author of %s and the train's engineer can release it.", hold.Commit.ShortSHA()),
+			http.StatusForbidden)
+	}
+
+	before := newTrainAuditState(train)
+	err := dataClient.ReleaseCommit(train, hold.Commit)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error releasing commit: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	auditTrain(dataClient, authedUser, types.TrainReleaseCommitAction, train, before, hold.Commit.SHA)
+	metrics.Incr("train.release_commit", train.DatadogTags())
+
+	messagingService := messaging.GetService()
+	messagingService.CommitReleased(r.Context(), train, hold.Commit, authedUser)
+
+	deployIfReady(r.Context(), dataClient, messagingService, train)
+
+	clearLatestTrainCache()
+
+	return dataResponse(train)
+}
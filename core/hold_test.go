@@ -0,0 +1,99 @@
+// +build data
+
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestReleaseCommit(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	cookieFor := func(name string) (*types.User, *http.Cookie) {
+		email := fmt.Sprintf("%s-%d@example.com", name, time.Now().UnixNano())
+		user, err := dataClient.ReadOrCreateUser(name, email)
+		assert.NoError(t, err)
+		token := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+		err = dataClient.WriteToken(token, user.Name, user.Email, "", nil)
+		assert.NoError(t, err)
+		return user, &http.Cookie{Name: auth.GetCookieName(), Value: token}
+	}
+	author, authorCookie := cookieFor("author")
+	_, bystanderCookie := cookieFor("bystander")
+
+	held := &types.Commit{
+		SHA:         fmt.Sprintf("a%x", time.Now().UnixNano()),
+		Message:     "Launch the banner [hold: press release]",
+		AuthorEmail: author.Email,
+	}
+	later := &types.Commit{
+		SHA:     fmt.Sprintf("b%x", time.Now().UnixNano()),
+		Message: fmt.Sprintf("Turn on the flag [deploy-after: %s]", time.Now().AddDate(0, 0, 1).Format(types.DeployAfterLayout)),
+	}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{held, later})
+	assert.NoError(t, err)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Len(t, train.Holds, 2)
+	assert.False(t, train.IsDeployable())
+
+	path := fmt.Sprintf("/api/train/%d/commit/%s/release", train.ID, "sha1")
+	res := requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The commit isn't held")
+
+	path = fmt.Sprintf("/api/train/%d/commit/%s/release", train.ID, held.SHA)
+	res = requestWithCookie(t, server, "POST", path, nil, bystanderCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code, "Neither the author nor the engineer")
+
+	res = requestWithCookie(t, server, "POST", path, nil, authorCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	path = fmt.Sprintf("/api/train/%d/commit/%s/release", train.ID, later.SHA)
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, train.Holds)
+	assert.ElementsMatch(t, []string{held.SHA, later.SHA}, train.ReleasedSHAList())
+}
+
+func TestReleaseCommitOnEarlierTrain(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	held := &types.Commit{SHA: fmt.Sprintf("a%x", time.Now().UnixNano()), Message: "Launch the banner [hold]"}
+	alsoHeld := &types.Commit{SHA: fmt.Sprintf("b%x", time.Now().UnixNano()), Message: "Turn on the flag [hold]"}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{held, alsoHeld})
+	assert.NoError(t, err)
+
+	// Like a train left behind by a split, it isn't the latest but still deploys.
+	setup(t)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, train.NextID)
+
+	path := fmt.Sprintf("/api/train/%d/commit/%s/release", train.ID, held.SHA)
+	res := requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	err = dataClient.DeployTrain(train)
+	assert.NoError(t, err)
+	path = fmt.Sprintf("/api/train/%d/commit/%s/release", train.ID, alsoHeld.SHA)
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Deployed trains are done with holds")
+}
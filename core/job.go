@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,16 +14,31 @@ import (
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
-	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/settings"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 func jobEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job", get, fetchJobs),
-		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job", post, startJob),
-		newEp(`/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job/{job_name:[a-zA-Z0-9_\-]+}`, post, completeJob),
+		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job", get, fetchJobs).
+			scoped(types.TrainRead).
+			describe("Get the jobs of a phase.").
+			returns(types.Jobs{}),
+		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job", post, startJob).
+			requires(types.Committer).
+			scoped(types.JobsWrite).
+			describe("Start a job of a phase, or restart it if already started.").
+			requiredForm("name", "Job name, one of the jobs expected for the phase.").
+			requiredForm("url", "Link to the job.").
+			returns(&types.Job{}),
+		newEp(`/api/train/{train_id:[0-9]+}/phase/{phase_id:[0-9]+}/job/{job_name:[a-zA-Z0-9_\-]+}`, post, completeJob).
+			requires(types.Committer).
+			scoped(types.JobsWrite).
+			describe("Complete a started job.").
+			requiredForm("result", "0 for ok, 1 for error.").
+			returns(nil),
 	}
 }
 
@@ -84,14 +100,18 @@ func fetchJobs(r *http.Request) response {
 	return dataResponse(targetPhase.Jobs)
 }
 
-func isValidJobName(jobName string, phaseType types.PhaseType) bool {
-	possibleJobNames := types.JobsForPhase(phaseType)
+func isValidJobName(dataClient data.Client, jobName string, phase *types.Phase) (bool, error) {
+	options, err := dataClient.Options()
+	if err != nil {
+		return false, err
+	}
+	possibleJobNames := options.TrainJobsForPhase(phase)
 	for _, possibleJobName := range possibleJobNames {
 		if possibleJobName == jobName {
-			return true
+			return true, nil
 		}
 	}
-	return false
+	return false, nil
 }
 
 func jobByName(jobName string, jobs []*types.Job) *types.Job {
@@ -131,19 +151,25 @@ func startJob(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	if !isValidJobName(jobName, targetPhase.Type) {
+	validJobName, err := isValidJobName(dataClient, jobName, targetPhase)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting expected jobs: %v", err),
+			http.StatusInternalServerError)
+	}
+	if !validJobName {
 		return errorResponse(
 			fmt.Sprintf("Job with name %s not expected for %s phase",
-				jobName, targetPhase.Type.String()),
+				jobName, targetPhase.Name),
 			http.StatusBadRequest)
 	}
 
-	activePhaseType := targetPhase.Train.ActivePhase
-	if targetPhase.Before(activePhaseType) {
+	activePhase := targetPhase.Train.CurrentPhase()
+	if targetPhase.Type != types.RollbackPhase && targetPhase.Before(activePhase) {
 		return errorResponse(
 			fmt.Sprintf(
 				"Cannot start a job on a previous phase. Active phase is %s, target phase is %s.",
-				activePhaseType.String(), targetPhase.Type.String()),
+				activePhase.Name, targetPhase.Name),
 			http.StatusBadRequest)
 	}
 
@@ -172,14 +198,14 @@ func startJob(r *http.Request) response {
 		logger.Error("Warning: Job with name %s has already been started for Train %d, Phase %d",
 			jobName, targetPhase.Train.ID, targetPhase.ID)
 
-		datadog.Incr("job.start", job.DatadogTags())
-		datadog.Incr("job.restart", job.DatadogTags())
+		metrics.Incr("job.start", job.DatadogTags())
+		metrics.Incr("job.restart", job.DatadogTags())
 		err = dataClient.RestartJob(job, url)
 		if err != nil {
 			return errorResponse("Error restarting job", http.StatusInternalServerError)
 		}
 	} else {
-		datadog.Incr("job.start", job.DatadogTags())
+		metrics.Incr("job.start", job.DatadogTags())
 		err = dataClient.StartJob(job, url)
 		if err != nil {
 			return errorResponse("Error starting job", http.StatusInternalServerError)
@@ -190,9 +216,18 @@ func startJob(r *http.Request) response {
 	// If the job completes before the phase starts, we give a duration of 0.
 	if job.Phase.StartedAt.HasValue() {
 		timeSincePhaseStart := job.StartedAt.Value.Sub(job.Phase.StartedAt.Value)
-		datadog.Gauge("job.start.time_since_phase_start", timeSincePhaseStart.Seconds(), job.DatadogTags())
+		metrics.Histogram("job.start.time_since_phase_start", timeSincePhaseStart.Seconds(), job.DatadogTags())
 	} else {
-		datadog.Gauge("job.start.time_since_phase_start", 0, job.DatadogTags())
+		metrics.Histogram("job.start.time_since_phase_start", 0, job.DatadogTags())
+	}
+
+	if targetPhase.Type == types.RollbackPhase {
+		err = restartRollback(dataClient, targetPhase)
+		if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error restarting rollback: %v", err),
+				http.StatusInternalServerError)
+		}
 	}
 
 	return dataResponse(job)
@@ -221,10 +256,16 @@ func completeJob(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	if !isValidJobName(jobName, targetPhase.Type) {
+	validJobName, err := isValidJobName(dataClient, jobName, targetPhase)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting expected jobs: %v", err),
+			http.StatusInternalServerError)
+	}
+	if !validJobName {
 		return errorResponse(
 			fmt.Sprintf("Job with name %s not expected for %s phase",
-				jobName, targetPhase.Type.String()),
+				jobName, targetPhase.Name),
 			http.StatusBadRequest)
 	}
 
@@ -259,34 +300,98 @@ func completeJob(r *http.Request) response {
 
 	messagingService := messaging.GetService()
 
-	datadog.Incr("job.complete", job.DatadogTags())
+	metrics.Incr("job.complete", job.DatadogTags())
 	if jobResult == types.Ok {
-		datadog.Incr("job.success", job.DatadogTags())
+		metrics.Incr("job.success", job.DatadogTags())
 	} else {
-		datadog.Incr("job.failure", job.DatadogTags())
-		messagingService.JobFailed(job)
+		metrics.Incr("job.failure", job.DatadogTags())
+		if targetPhase.Type != types.RollbackPhase {
+			messagingService.JobFailed(r.Context(), job)
+		}
+		if targetPhase.Type == types.Deploy {
+			rollbackFailedDeploy(r.Context(), dataClient, messagingService, job)
+		}
 	}
 
 	duration := job.CompletedAt.Value.Sub(job.StartedAt.Value)
-	datadog.Gauge("job.duration", duration.Seconds(), job.DatadogTags())
+	metrics.Histogram("job.duration", duration.Seconds(), job.DatadogTags())
 
 	// Measure how long after the phase started did the job complete.
 	// If the job completes before the phase starts, we give a duration of 0.
 	if job.Phase.StartedAt.HasValue() {
 		timeSincePhaseStart := job.CompletedAt.Value.Sub(job.Phase.StartedAt.Value)
-		datadog.Gauge("job.complete.time_since_phase_start", timeSincePhaseStart.Seconds(), job.DatadogTags())
+		metrics.Histogram("job.complete.time_since_phase_start", timeSincePhaseStart.Seconds(), job.DatadogTags())
 	} else {
-		datadog.Gauge("job.complete.time_since_phase_start", 0, job.DatadogTags())
+		metrics.Histogram("job.complete.time_since_phase_start", 0, job.DatadogTags())
+	}
+
+	if targetPhase.Type == types.RollbackPhase {
+		err = completeRollback(r.Context(), dataClient, messagingService, job, "")
+		if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error completing rollback: %v", err),
+				http.StatusInternalServerError)
+		}
+		return emptyResponse()
 	}
 
 	codeService := code.GetService()
 	phaseService := phase.GetService()
 	ticketService := ticket.GetService()
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService, targetPhase)
+	checkPhaseCompletion(r.Context(), dataClient, codeService, messagingService, phaseService, ticketService, targetPhase)
 
 	return emptyResponse()
 }
 
-func checkJobs(dataClient data.Client) {
+// Rolls production back when a deploy job fails, if the options ask for it,
+// so a train isn't left half deployed until someone notices.
+func rollbackFailedDeploy(
+	ctx context.Context,
+	dataClient data.Client,
+	messagingService messaging.Service,
+	job *types.Job) {
+
+	train := job.Phase.Train
+	if train.Done || !job.Phase.IsInActivePhaseGroup() || !job.Phase.StartedAt.HasValue() {
+		return
+	}
+
+	options, err := dataClient.Options()
+	if err != nil {
+		logger.Error("Error getting options: %v", err)
+		return
+	}
+	if !options.Rollback.OnDeployFailure {
+		return
+	}
+	if settings.GetJenkinsRollbackJob() == "" {
+		logger.Error("Deploy of train %d failed, but no rollback job is configured", train.ID)
+		return
+	}
+
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		logger.Error("Error getting production train: %v", err)
+		return
+	}
+	if productionTrain == nil {
+		logger.Error("Deploy of train %d failed, but there's no train to roll back to", train.ID)
+		return
+	}
+
+	metrics.Incr("train.rollback.automatic", train.DatadogTags())
+	logger.Info("Job %s failed to deploy train %d. Rolling back to train %d.",
+		job.Name, train.ID, productionTrain.ID)
+
+	_, err = rollbackToTrain(ctx, dataClient, messagingService, productionTrain, nil)
+	if err != nil {
+		logger.Error("Error rolling back to train %d: %v", productionTrain.ID, err)
+		return
+	}
+
+	messagingService.DeployRolledBack(ctx, job, productionTrain)
+}
+
+func checkJobs(ctx context.Context, dataClient data.Client) {
 	// TODO
 }
@@ -3,6 +3,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/shared/types"
 )
@@ -116,6 +118,31 @@ func TestJobCreate(t *testing.T) {
 	assert.Equal(t, jobs[0].Name, jobName)
 }
 
+func TestJobCreateBranchJobs(t *testing.T) {
+	server, testData := setup(t)
+
+	dataClient := data.NewClient()
+
+	types.CustomizeJobs(types.Delivery, []string{"test_job_1"})
+	options := types.DefaultOptions
+	options.Branches = map[string]types.BranchOptions{
+		testData.Train.Branch: {Jobs: map[string][]string{"delivery": {"branch_job_1"}}},
+	}
+	err := dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+
+	targetPhase := testData.Train.ActivePhases.Delivery
+	path := fmt.Sprintf("/api/train/%d/phase/%d/job", testData.Train.ID, targetPhase.ID)
+
+	form := url.Values{"name": {"test_job_1"}, "url": {"http://example.com/1"}}
+	res := requestWithCookie(t, server, "POST", path, form, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, res.Body.String())
+
+	form.Set("name", "branch_job_1")
+	res = requestWithCookie(t, server, "POST", path, form, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+}
+
 func TestNoDeployWhenBlocked(t *testing.T) {
 	server, testData := setup(t)
 
@@ -257,3 +284,42 @@ func TestJobComplete(t *testing.T) {
 	assert.Equal(t, jobs[0].Result, types.JobResult(1))
 	assert.NotNil(t, jobs[0].CompletedAt.Get())
 }
+
+func TestRollbackFailedDeployNeedsPolicyAndJob(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+
+	rolledBack := false
+	messagingService := messaging.MessagingServiceMock{
+		DeployRolledBackMock: func(ctx context.Context, job *types.Job, rolledBackTo *types.Train) {
+			rolledBack = true
+		},
+	}
+
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	for _, phase := range train.ActivePhases.Phases() {
+		assert.NoError(t, dataClient.StartPhase(phase))
+	}
+	job, err := dataClient.CreateJob(train.ActivePhases.Deploy, "deploy")
+	assert.NoError(t, err)
+	job.Phase = train.ActivePhases.Deploy
+
+	// Off by default.
+	rollbackFailedDeploy(context.Background(), dataClient, messagingService, job)
+	assert.False(t, rolledBack)
+
+	// Without a rollback job, the train is left for people to handle.
+	options := types.DefaultOptions
+	options.Rollback.OnDeployFailure = true
+	err = dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	rollbackFailedDeploy(context.Background(), dataClient, messagingService, job)
+	assert.False(t, rolledBack)
+
+	train, err = dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.False(t, train.CancelledAt.HasValue())
+}
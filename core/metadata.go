@@ -7,16 +7,36 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
 )
 
 func metadataEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/metadata", get, metadataListNamespaces),
-		newEp("/api/metadata/{namespace:[^/]+?}", get, metadataListKeys),
-		newEp("/api/metadata/{namespace:[^/]+?}/{key:[^/]+?}", get, metadataGetKey),
-		newAdminEp("/api/metadata/{namespace:[^/]+?}", post, metadataSet),
-		newAdminEp("/api/metadata/{namespace:[^/]+?}", del, metadataDeleteNamespace),
-		newAdminEp("/api/metadata/{namespace:[^/]+?}/{key:[^/]+?}", del, metadataDeleteKey),
+		newEp("/api/metadata", get, metadataListNamespaces).
+			scoped(types.TrainRead).
+			describe("List metadata namespaces.").
+			returns([]string{}),
+		newEp("/api/metadata/{namespace:[^/]+?}", get, metadataListKeys).
+			scoped(types.TrainRead).
+			describe("List the keys in a metadata namespace.").
+			returns([]string{}),
+		newEp("/api/metadata/{namespace:[^/]+?}/{key:[^/]+?}", get, metadataGetKey).
+			scoped(types.TrainRead).
+			describe("Get the value of a metadata key.").
+			returns(""),
+		newAdminEp("/api/metadata/{namespace:[^/]+?}", post, metadataSet).
+			scoped(types.TrainAdmin).
+			describe("Set metadata keys in a namespace.").
+			anyForm("Each field sets the key of the same name.").
+			returns(nil),
+		newAdminEp("/api/metadata/{namespace:[^/]+?}", del, metadataDeleteNamespace).
+			scoped(types.TrainAdmin).
+			describe("Delete a metadata namespace and all its keys.").
+			returns(nil),
+		newAdminEp("/api/metadata/{namespace:[^/]+?}/{key:[^/]+?}", del, metadataDeleteKey).
+			scoped(types.TrainAdmin).
+			describe("Delete a metadata key.").
+			returns(nil),
 	}
 }
 
@@ -86,12 +106,21 @@ func metadataSet(r *http.Request) response {
 
 	dataClient := data.NewClient()
 
+	keys := make([]string, 0, len(newData))
+	for key := range newData {
+		keys = append(keys, key)
+	}
+	before := metadataAuditState(dataClient, namespace, keys)
+
 	err = dataClient.MetadataSet(namespace, newData)
 	if err != nil {
 		return errorResponse(
 			err.Error(),
 			http.StatusInternalServerError)
 	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.MetadataSetAction, "metadata", namespace, before, newData, "")
 	return emptyResponse()
 }
 
@@ -100,12 +129,23 @@ func metadataDeleteNamespace(r *http.Request) response {
 	namespace := vars["namespace"]
 
 	dataClient := data.NewClient()
-	err := dataClient.MetadataDeleteNamespace(namespace)
+	keys, err := dataClient.MetadataListKeys(namespace)
 	if err != nil {
 		return errorResponse(
 			err.Error(),
 			http.StatusInternalServerError)
 	}
+	before := metadataAuditState(dataClient, namespace, keys)
+
+	err = dataClient.MetadataDeleteNamespace(namespace)
+	if err != nil {
+		return errorResponse(
+			err.Error(),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.MetadataDeleteAction, "metadata", namespace, before, nil, "")
 	return emptyResponse()
 }
 
@@ -115,11 +155,16 @@ func metadataDeleteKey(r *http.Request) response {
 	key := vars["key"]
 
 	dataClient := data.NewClient()
+	before := metadataAuditState(dataClient, namespace, []string{key})
+
 	err := dataClient.MetadataDeleteKey(namespace, key)
 	if err != nil {
 		return errorResponse(
 			err.Error(),
 			http.StatusInternalServerError)
 	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.MetadataDeleteAction, "metadata", namespace, before, nil, "")
 	return emptyResponse()
 }
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+var trainStates = []types.TrainState{
+	types.Open, types.Closed, types.Blocked, types.Deploying, types.Deployed, types.Cancelled}
+
+// Sets gauges describing the latest train.
+// These only go to Prometheus, which reads them on every scrape.
+func updateTrainStateMetrics(dataClient data.Client) {
+	train, err := getCacheBackedLatestTrain(dataClient, true)
+	if err != nil {
+		logger.Error("Error getting latest train for metrics: %v", err)
+		return
+	}
+	if train == nil {
+		return
+	}
+
+	prometheus := metrics.Prometheus()
+	state := train.State()
+	for _, trainState := range trainStates {
+		value := 0.0
+		if trainState == state {
+			value = 1
+		}
+		prometheus.Gauge("train.state", value, []string{fmt.Sprintf("state:%s", trainState)})
+	}
+
+	tags := []string{fmt.Sprintf("branch:%s", train.Branch)}
+	prometheus.Gauge("train.id", float64(train.ID), tags)
+	prometheus.Gauge("train.commits", float64(len(train.Commits)), tags)
+	prometheus.Gauge("train.age", time.Since(train.CreatedAt.Value).Seconds(), tags)
+}
+
+// Serves Prometheus metrics. Not authenticated, so scrapers don't need a token.
+func newMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		updateTrainStateMetrics(data.NewClient())
+		metrics.Prometheus().ServeHTTP(w, r)
+	})
+}
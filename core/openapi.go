@@ -0,0 +1,422 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const openAPIVersion = "3.0.3"
+
+func openAPIEndpoints() []endpoint {
+	return []endpoint{
+		newOpenEp("/api/openapi", get, fetchOpenAPI).
+			describe("Get the OpenAPI spec of this API.").
+			returnsRaw(map[string]interface{}{}),
+	}
+}
+
+func fetchOpenAPI(_ *http.Request) response {
+	return rawResponse(newOpenAPISpec(Endpoints()))
+}
+
+type openAPISpec struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]*openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIOperation struct {
+	Summary     string                      `json:"summary"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags"`
+	Parameters  []*openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+	// Whether the endpoint is restricted to admin users.
+	Admin bool `json:"x-admin"`
+	// Role users need to call the endpoint.
+	Role string `json:"x-role,omitempty"`
+	// Scope API tokens need to call the endpoint, if they can.
+	Scope string `json:"x-scope,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required"`
+	Content     map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	AllOf                []*openAPISchema          `json:"allOf,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+const (
+	cookieAuthScheme = "cookieAuth"
+	bearerAuthScheme = "bearerAuth"
+	errorSchemaRef   = "#/components/schemas/Error"
+	jsonContentType  = "application/json"
+	formContentType  = "application/x-www-form-urlencoded"
+)
+
+// Matches mux path variables, like {train_id:[0-9]+}.
+var pathVariableRegex = regexp.MustCompile(`\{([^}:]+)(?::([^}]*))?\}`)
+
+// Builds the OpenAPI spec for endpoints from their docs.
+func newOpenAPISpec(endpoints []endpoint) *openAPISpec {
+	generator := &schemaGenerator{schemas: map[string]*openAPISchema{
+		"Error": {
+			Type: "object",
+			Properties: map[string]*openAPISchema{
+				"error": {Type: "string"},
+			},
+		},
+	}}
+
+	spec := &openAPISpec{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title: "Conductor API",
+			Description: "Continuous Deployment Train Management API.\n\n" +
+				"Responses are JSON, with the response data in `result` or an error message in `error`. " +
+				"Paths also accept a `.json` or `.pretty` suffix; `.pretty` indents the response.",
+			Version: "1.0.0",
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: generator.schemas,
+			SecuritySchemes: map[string]*openAPISecurityScheme{
+				cookieAuthScheme: {
+					Type:        "apiKey",
+					In:          "cookie",
+					Name:        auth.GetCookieName(),
+					Description: "Set by logging in through /api/auth/login.",
+				},
+				bearerAuthScheme: {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "API token created through /api/tokens. Endpoints list the scope they need in x-scope.",
+				},
+			},
+		},
+	}
+
+	for _, ep := range endpoints {
+		path := pathVariableRegex.ReplaceAllString(ep.uri, "{$1}")
+		operations, ok := spec.Paths[path]
+		if !ok {
+			operations = make(map[string]*openAPIOperation)
+			spec.Paths[path] = operations
+		}
+		operations[strings.ToLower(ep.method.String())] = generator.operation(ep)
+	}
+	return spec
+}
+
+func (g *schemaGenerator) operation(ep endpoint) *openAPIOperation {
+	operation := &openAPIOperation{
+		Summary:   ep.doc.summary,
+		Tags:      []string{endpointTag(ep.uri)},
+		Responses: make(map[string]*openAPIResponse),
+		Admin:     ep.needsAuth && ep.role == types.Admin,
+	}
+
+	for _, match := range pathVariableRegex.FindAllStringSubmatch(ep.uri, -1) {
+		schema := &openAPISchema{Type: "string"}
+		if match[2] != "" {
+			schema.Pattern = fmt.Sprintf("^(?:%s)$", match[2])
+		}
+		operation.Parameters = append(operation.Parameters, &openAPIParameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   schema,
+		})
+	}
+
+	form := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	for _, param := range ep.doc.params {
+		if param.in == "form" {
+			form.Properties[param.name] = &openAPISchema{Type: "string", Description: param.description}
+			if param.required {
+				form.Required = append(form.Required, param.name)
+			}
+			continue
+		}
+		operation.Parameters = append(operation.Parameters, &openAPIParameter{
+			Name:        param.name,
+			In:          param.in,
+			Description: param.description,
+			Required:    param.required,
+			Schema:      &openAPISchema{Type: "string"},
+		})
+	}
+	if ep.doc.anyForm != "" {
+		form.Description = ep.doc.anyForm
+		form.AdditionalProperties = &openAPISchema{Type: "string"}
+	}
+	if len(form.Properties) > 0 || form.AdditionalProperties != nil {
+		operation.RequestBody = &openAPIRequestBody{
+			Required: len(form.Required) > 0,
+			Content:  map[string]openAPIMediaType{formContentType: {Schema: form}},
+		}
+	} else if ep.doc.body != "" {
+		operation.RequestBody = &openAPIRequestBody{
+			Description: ep.doc.body,
+			Required:    true,
+			Content:     map[string]openAPIMediaType{jsonContentType: {Schema: &openAPISchema{}}},
+		}
+	}
+
+	if ep.doc.redirect {
+		operation.Responses["302"] = &openAPIResponse{Description: "Redirect."}
+	} else {
+		var schema *openAPISchema
+		switch {
+		case ep.doc.raw:
+			schema = g.schema(ep.doc.result)
+		case ep.doc.result == nil:
+			schema = &openAPISchema{Type: "object"}
+		default:
+			schema = &openAPISchema{
+				Type:       "object",
+				Properties: map[string]*openAPISchema{"result": g.schema(ep.doc.result)},
+			}
+		}
+		operation.Responses["200"] = &openAPIResponse{
+			Description: "Success.",
+			Content:     map[string]openAPIMediaType{jsonContentType: {Schema: schema}},
+		}
+	}
+	errorContent := map[string]openAPIMediaType{jsonContentType: {Schema: &openAPISchema{Ref: errorSchemaRef}}}
+	operation.Responses["default"] = &openAPIResponse{Description: "Error.", Content: errorContent}
+
+	var requirements []string
+	if ep.needsAuth {
+		operation.Security = []map[string][]string{{cookieAuthScheme: {}}}
+		operation.Responses["401"] = &openAPIResponse{Description: "Not logged in.", Content: errorContent}
+	}
+	if ep.needsAuth && ep.scope != nil {
+		operation.Scope = ep.scope.String()
+		operation.Security = append(operation.Security, map[string][]string{bearerAuthScheme: {}})
+		operation.Responses["403"] = &openAPIResponse{Description: "Missing permission.", Content: errorContent}
+		requirements = append(requirements, fmt.Sprintf("API tokens need the %s scope.", ep.scope))
+	}
+	if ep.needsAuth && ep.role != types.Viewer {
+		operation.Role = ep.role.String()
+		operation.Responses["403"] = &openAPIResponse{Description: "Missing permission.", Content: errorContent}
+		requirement := fmt.Sprintf("Requires the %s role or above.", ep.role)
+		if ep.allowsTrainEngineer {
+			requirement = fmt.Sprintf("Requires being the train's engineer, or the %s role or above.", ep.role)
+		}
+		requirements = append([]string{requirement}, requirements...)
+	}
+	operation.Description = strings.Join(requirements, " ")
+	return operation
+}
+
+// Groups endpoints by the first path segment after /api.
+func endpointTag(uri string) string {
+	segments := strings.Split(strings.Trim(uri, "/"), "/")
+	if len(segments) > 1 && segments[0] == "api" {
+		return segments[1]
+	}
+	return segments[0]
+}
+
+// Generates schemas from the JSON encoding of Go types.
+// Named structs are added to schemas and referenced, which also handles recursive types.
+type schemaGenerator struct {
+	schemas map[string]*openAPISchema
+}
+
+// Types with custom JSON encodings, or enums.
+var knownSchemas = map[reflect.Type]openAPISchema{
+	reflect.TypeOf(types.Time{}): {Type: "string", Format: "date-time", Nullable: true},
+	reflect.TypeOf(time.Time{}):  {Type: "string", Format: "date-time"},
+	reflect.TypeOf(types.TrainState(0)): stringEnumSchema(
+		types.Open, types.Closed, types.Blocked, types.Deploying, types.Deployed, types.Cancelled),
+	reflect.TypeOf(types.Role(0)): stringEnumSchema(
+		types.Viewer, types.Committer, types.Engineer, types.ReleaseManager, types.Admin),
+	reflect.TypeOf(types.AuditAction(0)): stringEnumSchema(auditActionValues()...),
+	// Any JSON value.
+	reflect.TypeOf(json.RawMessage{}):  {},
+	reflect.TypeOf(types.Mode(0)):      intEnumSchema(types.Schedule, types.Manual),
+	reflect.TypeOf(types.PhaseType(0)): intEnumSchema(types.Delivery, types.Verification, types.Deploy),
+	reflect.TypeOf(types.JobResult(0)): intEnumSchema(types.Ok, types.Error),
+	reflect.TypeOf(time.Weekday(0)): intEnumSchema(
+		time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday),
+}
+
+func auditActionValues() []fmt.Stringer {
+	values := make([]fmt.Stringer, len(types.AuditActions))
+	for i, action := range types.AuditActions {
+		values[i] = action
+	}
+	return values
+}
+
+func stringEnumSchema(values ...fmt.Stringer) openAPISchema {
+	schema := openAPISchema{Type: "string"}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value.String())
+	}
+	return schema
+}
+
+// Int enums are described with their names, e.g. "0: schedule, 1: manual".
+func intEnumSchema(values ...fmt.Stringer) openAPISchema {
+	schema := openAPISchema{Type: "integer"}
+	names := make([]string, len(values))
+	for i, value := range values {
+		number := reflect.ValueOf(value).Int()
+		schema.Enum = append(schema.Enum, number)
+		names[i] = fmt.Sprintf("%d: %s", number, value.String())
+	}
+	schema.Description = strings.Join(names, ", ")
+	return schema
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) *openAPISchema {
+	if t == nil {
+		return &openAPISchema{}
+	}
+	if known, ok := knownSchemas[t]; ok {
+		return &known
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := g.schema(t.Elem())
+		if schema.Ref != "" {
+			// Siblings of $ref are ignored, so wrap it to make it nullable.
+			return &openAPISchema{AllOf: []*openAPISchema{schema}, Nullable: true}
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Slice, reflect.Array:
+		return &openAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := g.schemas[name]; !ok {
+			// Add a placeholder first, in case the struct references itself.
+			g.schemas[name] = &openAPISchema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + name}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &openAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	default:
+		// Interfaces can hold anything.
+		return &openAPISchema{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *openAPISchema {
+	schema := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")
+		if tag[0] == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		// Embedded structs without a name have their fields promoted.
+		if field.Anonymous && tag[0] == "" && fieldType.Kind() == reflect.Struct {
+			for name, property := range g.structSchema(fieldType).Properties {
+				schema.Properties[name] = property
+			}
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		// The string option encodes numbers and bools as strings.
+		if len(tag) > 1 && tag[1] == "string" {
+			schema.Properties[name] = &openAPISchema{
+				Type:     "string",
+				Nullable: field.Type.Kind() == reflect.Ptr,
+			}
+			continue
+		}
+		schema.Properties[name] = g.schema(field.Type)
+	}
+	return schema
+}
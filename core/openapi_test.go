@@ -0,0 +1,99 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test that every route is documented, so the OpenAPI spec stays accurate.
+func TestEndpointsDocumented(t *testing.T) {
+	for _, ep := range Endpoints() {
+		route := ep.method.String() + " " + ep.uri
+		assert.NotEmpty(t, ep.doc.summary, "%s has no summary; add one with describe", route)
+		assert.True(t, ep.doc.responds, "%s has no documented response; add one with returns or redirects", route)
+	}
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	endpoints := Endpoints()
+	spec := newOpenAPISpec(endpoints)
+
+	count := 0
+	for _, operations := range spec.Paths {
+		count += len(operations)
+	}
+	assert.Equal(t, len(endpoints), count, "Routes should not share a path and method")
+
+	train := spec.Paths["/api/train/{train_id}"]["get"]
+	assert.NotNil(t, train)
+	assert.Equal(t, "path", train.Parameters[0].In)
+	assert.Equal(t, "train_id", train.Parameters[0].Name)
+	assert.Equal(t, "^(?:[0-9]+)$", train.Parameters[0].Schema.Pattern)
+	assert.Equal(t, []map[string][]string{{cookieAuthScheme: {}}, {bearerAuthScheme: {}}}, train.Security)
+	assert.Equal(t, "train:read", train.Scope)
+	assert.False(t, train.Admin)
+	result := train.Responses["200"].Content[jsonContentType].Schema.Properties["result"]
+	assert.Equal(t, "#/components/schemas/Train", result.AllOf[0].Ref)
+
+	setMode := spec.Paths["/api/mode"]["post"]
+	assert.True(t, setMode.Admin)
+	assert.Equal(t, "train:admin", setMode.Scope)
+	assert.NotNil(t, setMode.Responses["403"])
+	form := setMode.RequestBody.Content[formContentType].Schema
+	assert.Equal(t, []string{"mode"}, form.Required)
+
+	cancel := spec.Paths["/api/train/{train_id}/cancel"]["post"]
+	assert.Equal(t, "release-manager", cancel.Role)
+	assert.Contains(t, cancel.Description, "train's engineer")
+	assert.Empty(t, train.Role)
+
+	logout := spec.Paths["/api/auth/logout"]["post"]
+	assert.Equal(t, []map[string][]string{{cookieAuthScheme: {}}}, logout.Security)
+	assert.Empty(t, logout.Scope)
+
+	healthz := spec.Paths["/healthz"]["get"]
+	assert.Empty(t, healthz.Security)
+	assert.Nil(t, healthz.Responses["401"])
+
+	trainSchema := spec.Components.Schemas["Train"]
+	assert.Equal(t, "string", trainSchema.Properties["id"].Type)
+	assert.Equal(t, "date-time", trainSchema.Properties["created_at"].Format)
+	assert.True(t, trainSchema.Properties["next_id"].Nullable)
+	assert.NotContains(t, spec.Components.Schemas["User"].Properties, "Token")
+	state := spec.Components.Schemas["TrainSummary"].Properties["state"]
+	assert.Contains(t, state.Enum, "deployed")
+	commitMatch := spec.Components.Schemas["CommitMatch"]
+	assert.NotNil(t, commitMatch.Properties["sha"], "Embedded fields should be promoted")
+
+	// Every reference should resolve.
+	body, err := json.Marshal(spec)
+	assert.NoError(t, err)
+	refs := regexp.MustCompile(`"\$ref":"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(string(body), -1)
+	assert.NotEmpty(t, refs)
+	for _, ref := range refs {
+		assert.Contains(t, spec.Components.Schemas, ref[1])
+	}
+}
+
+// Test that the spec is served as the whole response body.
+func TestOpenAPIEndpoint(t *testing.T) {
+	server := NewServer(openAPIEndpoints())
+
+	req, err := http.NewRequest("GET", "/api/openapi.json", nil)
+	assert.NoError(t, err)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	var spec map[string]interface{}
+	assert.NoError(t, json.NewDecoder(strings.NewReader(res.Body.String())).Decode(&spec))
+	assert.Equal(t, openAPIVersion, spec["openapi"])
+	assert.Contains(t, spec["paths"], "/api/openapi")
+	assert.NotContains(t, spec, "result")
+}
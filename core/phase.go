@@ -1,28 +1,35 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/Nextdoor/conductor/services/code"
 	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/health"
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
-	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
 	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/tracing"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 func phaseEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_type:delivery|verification|deploy}/restart", post, triggerPhaseRestart),
+		newEp("/api/train/{train_id:[0-9]+}/phase/{phase_name:[a-z0-9_-]+}/restart", post, triggerPhaseRestart).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Restart an incomplete phase of the latest or previous train.").
+			returns(nil),
 	}
 }
 
@@ -37,14 +44,7 @@ func triggerPhaseRestart(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	phaseTypeStr := vars["phase_type"]
-	phaseType, err := types.PhaseTypeFromString(
-		strings.ToLower(phaseTypeStr))
-	if err != nil {
-		return errorResponse(
-			fmt.Sprintf("Bad `phase_type` value: %v", err),
-			http.StatusBadRequest)
-	}
+	phaseName := vars["phase_name"]
 
 	latestTrain, err := dataClient.LatestTrain()
 	if err != nil {
@@ -57,7 +57,7 @@ func triggerPhaseRestart(r *http.Request) response {
 		return errorResponse(
 			fmt.Sprintf("Cannot restart phase %s on train %d - the active train is %d. "+
 				"Phases can only be restarted on the latest train or the previous train.",
-				phaseType, trainID, latestTrain.ID),
+				phaseName, trainID, latestTrain.ID),
 			http.StatusBadRequest)
 	}
 
@@ -68,7 +68,12 @@ func triggerPhaseRestart(r *http.Request) response {
 			http.StatusInternalServerError)
 	}
 
-	phaseToRestart := targetTrain.Phase(phaseType)
+	phaseToRestart := targetTrain.PhaseNamed(phaseName)
+	if phaseToRestart == nil {
+		return errorResponse(
+			fmt.Sprintf("Train %d has no phase %s.", trainID, phaseName),
+			http.StatusNotFound)
+	}
 	if phaseToRestart.IsComplete() {
 		return errorResponse(
 			"This phase has already completed.",
@@ -83,17 +88,20 @@ func triggerPhaseRestart(r *http.Request) response {
 	}
 
 	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.PhaseRestartAction, "train", strconv.FormatUint(targetTrain.ID, 10),
+		phaseAuditState(phaseToRestart), phaseAuditState(replacedPhase), "")
 
 	codeService := code.GetService()
 	messagingService := messaging.GetService()
 	phaseService := phase.GetService()
 	ticketService := ticket.GetService()
-	go startPhase(data.NewClient(), codeService, messagingService, phaseService, ticketService, replacedPhase, authedUser)
+	go startPhase(tracing.Detach(r.Context()), data.NewClient(), codeService, messagingService, phaseService, ticketService, replacedPhase, authedUser)
 
 	return emptyResponse()
 }
 
 func startPhase(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -102,15 +110,21 @@ func startPhase(
 	phaseToStart *types.Phase,
 	user *types.User) {
 
+	ctx, span := tracing.Start(ctx, "startPhase")
+	defer span.End()
+	span.SetAttribute("train_id", strconv.FormatUint(phaseToStart.Train.ID, 10))
+	span.SetAttribute("phase", phaseToStart.Name)
+
 	logger.Info("Starting phase %s for train %v (%s).\n\n%+v",
-		phaseToStart.Type, phaseToStart.Train.ID, phaseToStart.Train.HeadSHA, phaseToStart.Train)
+		phaseToStart.Name, phaseToStart.Train.ID, phaseToStart.Train.HeadSHA, phaseToStart.Train)
+
+	phaseGroup := phaseToStart.PhaseGroup
 
 	// Pre-phase actions
-	switch phaseToStart.Type {
-	case types.Verification:
+	if phaseToStart == phaseGroup.FirstPhase(types.Verification) {
 		logger.Info("Handling notification and ticket creation for Phase %v", phaseToStart.ID)
 		err := phaseGroupDelivered(
-			dataClient, messagingService, ticketService, phaseToStart.Train, phaseToStart.PhaseGroup)
+			ctx, dataClient, messagingService, ticketService, phaseToStart.Train, phaseGroup)
 		if err != nil {
 			logger.Error("ErrorPhase: %v", err)
 			err = dataClient.ErrorPhase(phaseToStart, err)
@@ -118,6 +132,18 @@ func startPhase(
 				logger.Error("%v", err)
 			}
 		}
+
+		// Don't start verification without knowing which approvals it needs;
+		// the phase can be restarted once the approvals can be recorded.
+		err = requireApprovals(ctx, dataClient, codeService, phaseToStart.Train)
+		if err != nil {
+			logger.Error("Error requiring approvals: %v", err)
+			err = dataClient.ErrorPhase(phaseToStart, fmt.Errorf("Error requiring approvals: %v", err))
+			if err != nil {
+				logger.Error("%v", err)
+			}
+			return
+		}
 	}
 
 	err := dataClient.StartPhase(phaseToStart)
@@ -126,39 +152,48 @@ func startPhase(
 		return
 	}
 
-	datadog.Incr("phase.start", phaseToStart.DatadogTags())
+	metrics.Incr("phase.start", phaseToStart.DatadogTags())
 
-	switch phaseToStart.Type {
-	case types.Deploy:
-		// Check for any commits waiting for a train.
+	if phaseToStart == phaseGroup.Deploy {
+		// The train is deploying, so check for any commits waiting for a train.
 		checkBranch(
-			dataClient, codeService, messagingService, phaseService, ticketService,
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 			phaseToStart.Train.Branch, nil)
+		startSplitTrain(
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
+			phaseToStart.Train)
 	}
 
-	err = phaseService.Start(phaseToStart.Type,
-		phaseToStart.Train.ID,
-		phaseToStart.PhaseGroup.Delivery.ID,
-		phaseToStart.PhaseGroup.Verification.ID,
-		phaseToStart.PhaseGroup.Deploy.ID,
-		phaseToStart.Train.Branch, phaseToStart.PhaseGroup.HeadSHA,
-		user)
-	if err != nil {
-		logger.Error("ErrorPhase: %v", err)
-		err = dataClient.ErrorPhase(phaseToStart, err)
+	if phaseToStart.Type == types.Verification && skipsVerification(dataClient, phaseToStart.Train) {
+		logger.Info("Skipping verification for expedited train %v", phaseToStart.Train.ID)
+	} else {
+		err = phaseService.Start(ctx, phaseToStart.Type,
+			phaseToStart.Name,
+			phaseToStart.ID,
+			phaseToStart.Train.ID,
+			phaseGroup.Delivery.ID,
+			phaseGroup.Verification.ID,
+			phaseGroup.Deploy.ID,
+			phaseToStart.Train.BuildBranch(), phaseGroup.HeadSHA, phaseToStart.Train.ExcludedSHAList(),
+			user)
 		if err != nil {
-			logger.Error("%v", err)
+			logger.Error("ErrorPhase: %v", err)
+			err = dataClient.ErrorPhase(phaseToStart, err)
+			if err != nil {
+				logger.Error("%v", err)
+			}
 		}
 	}
 
 	clearLatestTrainCache()
 
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService, phaseToStart)
+	checkPhaseCompletion(ctx, dataClient, codeService, messagingService, phaseService, ticketService, phaseToStart)
 }
 
 // The phase group was delivered to staging.
 // Handle notification and ticket creation for these commits.
 func phaseGroupDelivered(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	ticketService ticket.Service,
@@ -169,11 +204,14 @@ func phaseGroupDelivered(
 	defer ticketModificationLock.Unlock()
 
 	newCommitsNeedingTickets := train.NewCommitsNeedingTickets(phaseGroup.HeadSHA, settings.NoStagingVerification)
+	if skipsVerification(dataClient, train) {
+		newCommitsNeedingTickets = nil
+	}
 	var tickets []*types.Ticket
 	var err error
 	logger.Info("There are %v commits that need tickets", len(newCommitsNeedingTickets))
 	if len(newCommitsNeedingTickets) > 0 {
-		tickets, err = ticketService.CreateTickets(train, newCommitsNeedingTickets)
+		tickets, err = ticketService.CreateTickets(ctx, train, newCommitsNeedingTickets)
 		if err != nil {
 			return err
 		}
@@ -184,7 +222,7 @@ func phaseGroupDelivered(
 			return err
 		}
 
-		datadog.Count("ticket.count", len(tickets), train.DatadogTags())
+		metrics.Count("ticket.count", len(tickets), train.DatadogTags())
 
 		// Add these tickets to the train for anything that'll immediate check them.
 		// There might be existing tickets, so append first.
@@ -202,7 +240,7 @@ func phaseGroupDelivered(
 	} else {
 		newCommits = train.CommitsBetween(phaseGroup.HeadSHA, *train.LastDeliveredSHA)
 	}
-	messagingService.TrainDelivered(train, newCommits, tickets)
+	messagingService.TrainDelivered(ctx, train, newCommits, tickets)
 
 	return nil
 }
@@ -210,6 +248,7 @@ func phaseGroupDelivered(
 var phaseCompletionLock sync.Mutex
 
 func checkPhaseCompletion(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -217,26 +256,31 @@ func checkPhaseCompletion(
 	ticketService ticket.Service,
 	targetPhase *types.Phase) {
 
+	ctx, span := tracing.Start(ctx, "checkPhaseCompletion")
+	defer span.End()
+	span.SetAttribute("train_id", strconv.FormatUint(targetPhase.Train.ID, 10))
+	span.SetAttribute("phase", targetPhase.Name)
+
 	phaseCompletionLock.Lock()
 	defer phaseCompletionLock.Unlock()
 
 	train := targetPhase.Train
 
-	var extraChecks []phase.Completeable
-	switch targetPhase.Type {
-	case types.Verification:
-		for i := range train.Tickets {
-			extraChecks = append(extraChecks, train.Tickets[i])
-		}
+	options, err := dataClient.Options()
+	if err != nil {
+		logger.Error("Error getting options: %v", err)
+		return
 	}
+	expectedJobs := options.TrainJobsForPhase(targetPhase)
+	extraChecks := phaseGates(ctx, options, health.GetService(), targetPhase)
 
 	phaseCompletedPreviously := targetPhase.IsComplete()
 	phaseCurrentlyCompleted := phase.IsComplete(
-		targetPhase.Type, targetPhase.Jobs.CompletedNames(), extraChecks...)
+		expectedJobs, targetPhase.Jobs.CompletedNames(), extraChecks...)
 
 	logger.Info("Checking phase completion for phase %v, train %v (%v). "+
 		"It has %d tickets, which will trigger %d extra completion checks.\n\nTrain: %+v",
-		targetPhase.Type, train.ID, train.HeadSHA, len(train.Tickets), len(extraChecks), train)
+		targetPhase.Name, train.ID, train.HeadSHA, len(train.Tickets), len(extraChecks), train)
 
 	if phaseCompletedPreviously && phaseCurrentlyCompleted {
 		// Completion already handled.
@@ -245,13 +289,13 @@ func checkPhaseCompletion(
 
 	if phaseCompletedPreviously && !phaseCurrentlyCompleted {
 		// Phase is no longer completed - uncomplete it.
-		datadog.Incr("phase.uncomplete", targetPhase.DatadogTags())
+		metrics.Incr("phase.uncomplete", targetPhase.DatadogTags())
 		err := dataClient.UncompletePhase(targetPhase)
 		if err != nil {
 			logger.Error("Error uncompleting phase: %v", err)
 		} else {
-			if targetPhase.Type == types.Verification {
-				messagingService.TrainUnverified(train)
+			if targetPhase == targetPhase.PhaseGroup.Verification {
+				messagingService.TrainUnverified(ctx, train)
 			}
 		}
 		return
@@ -272,42 +316,47 @@ func checkPhaseCompletion(
 		return
 	}
 
-	err := dataClient.CompletePhase(targetPhase)
+	err = dataClient.CompletePhase(targetPhase)
 	if err != nil {
 		logger.Error("Error completing phase: %v", err)
 		return
 	}
 
-	datadog.Incr("phase.complete", targetPhase.DatadogTags())
+	metrics.Incr("phase.complete", targetPhase.DatadogTags())
 	duration := targetPhase.CompletedAt.Value.Sub(targetPhase.StartedAt.Value)
-	datadog.Gauge("phase.duration", duration.Seconds(), targetPhase.DatadogTags())
+	metrics.Histogram("phase.duration", duration.Seconds(), targetPhase.DatadogTags())
 
 	logger.Info("Phase %s was completed for train %v (%s). "+
 		"It had %d tickets causing %d extra checks.\n\n%+v",
-		targetPhase.Type, train.ID, train.HeadSHA, len(train.Tickets), len(extraChecks), train)
+		targetPhase.Name, train.ID, train.HeadSHA, len(train.Tickets), len(extraChecks), train)
 
 	// Post-phase actions
-	switch targetPhase.Type {
-	case types.Delivery:
-		go startPhase(
-			data.NewClient(), codeService, messagingService, phaseService, ticketService,
-			targetPhase.PhaseGroup.Verification, nil)
-	case types.Verification:
+	nextPhase := targetPhase.Next()
+	switch {
+	case nextPhase == targetPhase.PhaseGroup.Deploy:
 		if targetPhase.IsInActivePhaseGroup() {
 			// We only send this message if this is the most recent verification phase.
 			// Otherwise, the train isn't fully verified yet.
-			messagingService.TrainVerified(train)
+			messagingService.TrainVerified(ctx, train)
 		}
-		go deployIfReady(data.NewClient(), messagingService, train)
-	case types.Deploy:
+		go deployIfReady(tracing.Detach(ctx), data.NewClient(), messagingService, train)
+	case nextPhase != nil:
+		go startPhase(
+			tracing.Detach(ctx), data.NewClient(), codeService, messagingService, phaseService, ticketService,
+			nextPhase, nil)
+	default:
+		// The whole pipeline is done.
+		before := newTrainAuditState(train)
 		err = dataClient.DeployTrain(train)
 		if err != nil {
 			logger.Error("Error deploying train: %v", err)
 			return
 		}
 
+		auditTrain(dataClient, nil, types.TrainDeployAction, train, before, "")
+
 		duration := train.DeployedAt.Value.Sub(train.CreatedAt.Value)
-		datadog.Gauge("train.deploy.lifetime.all_hours", duration.Seconds(), train.DatadogTags())
+		metrics.Histogram("train.deploy.lifetime.all_hours", duration.Seconds(), train.DatadogTags())
 
 		options, err := dataClient.Options()
 		if err != nil {
@@ -316,16 +365,18 @@ func checkPhaseCompletion(
 			regularHoursDuration := options.CloseTimeOverlap(train.CreatedAt.Value, train.DeployedAt.Value)
 			afterHoursDuration := duration - regularHoursDuration
 
-			datadog.Gauge("train.deploy.lifetime.regular_hours", regularHoursDuration.Seconds(), train.DatadogTags())
-			datadog.Gauge("train.deploy.lifetime.after_hours", afterHoursDuration.Seconds(), train.DatadogTags())
+			metrics.Histogram("train.deploy.lifetime.regular_hours", regularHoursDuration.Seconds(), train.DatadogTags())
+			metrics.Histogram("train.deploy.lifetime.after_hours", afterHoursDuration.Seconds(), train.DatadogTags())
 		}
 
-		messagingService.TrainDeployed(train)
+		messagingService.TrainDeployed(ctx, train)
 
 		checkBranch(
-			dataClient, codeService, messagingService, phaseService, ticketService,
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 			targetPhase.Train.Branch, nil)
 
+		resumePreemptedTrain(ctx, dataClient, codeService, messagingService, phaseService, ticketService, train)
+
 		if train.NextID != nil {
 			latestTrain, err := dataClient.LatestTrain()
 			if err != nil {
@@ -335,7 +386,186 @@ func checkPhaseCompletion(
 
 			// Now that this train's deploy is finished,
 			// check if the latest train can be deployed.
-			go deployIfReady(data.NewClient(), messagingService, latestTrain)
+			go deployIfReady(tracing.Detach(ctx), data.NewClient(), messagingService, latestTrain)
+		}
+	}
+}
+
+// Checks besides the jobs that must pass for the phase to complete.
+func phaseGates(
+	ctx context.Context,
+	options *types.Options,
+	healthService health.Service,
+	targetPhase *types.Phase) []phase.Completeable {
+
+	var gates []phase.Completeable
+	if targetPhase == targetPhase.PhaseGroup.Verification {
+		// Tickets and approvals hold the train until the end of verification.
+		for i := range targetPhase.Train.Tickets {
+			gates = append(gates, targetPhase.Train.Tickets[i])
+		}
+		for _, status := range targetPhase.Train.Approvals {
+			gates = append(gates, status)
 		}
 	}
+	if soak := options.PhaseSoak(targetPhase.Name); soak > 0 {
+		gates = append(gates, soakGate{phase: targetPhase, soak: soak})
+	}
+	if checks := options.PhaseChecks(targetPhase.Name); len(checks) > 0 {
+		gates = append(gates, healthGate{ctx: ctx, service: healthService, checks: checks})
+	}
+	return gates
+}
+
+// Holds a phase until it has run for a while, e.g. to watch a canary.
+type soakGate struct {
+	phase *types.Phase
+	soak  time.Duration
+}
+
+func (g soakGate) IsComplete() bool {
+	return g.phase.StartedAt.HasValue() && time.Since(g.phase.StartedAt.Value) >= g.soak
+}
+
+// Holds a phase while any of its health checks fail or can't be run.
+type healthGate struct {
+	ctx     context.Context
+	service health.Service
+	checks  []types.HealthCheck
+}
+
+func (g healthGate) IsComplete() bool {
+	breach, err := healthBreach(g.ctx, g.service, g.checks)
+	return err == nil && breach == ""
+}
+
+// Returns a description of the first failing check, or "" if they all pass.
+func healthBreach(ctx context.Context, healthService health.Service, checks []types.HealthCheck) (string, error) {
+	for _, check := range checks {
+		result, err := healthService.Check(ctx, check)
+		if err != nil {
+			return "", fmt.Errorf("Error running health check %s: %v", check.Name, err)
+		}
+		if !result.Healthy {
+			return result.Breach(check), nil
+		}
+	}
+	return "", nil
+}
+
+// Soaking phases have nothing left to report, so they're checked for completion here.
+// Phases with health checks are polled here too, and stopped as soon as a check fails.
+func checkSoakingPhases(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	healthService health.Service) {
+
+	options, err := dataClient.Options()
+	if err != nil {
+		logger.Error("Error getting options: %v", err)
+		return
+	}
+
+	latestTrain, err := dataClient.LatestTrain()
+	if err != nil {
+		logger.Error("Error getting latest train: %v", err)
+		return
+	}
+	if latestTrain == nil {
+		return
+	}
+
+	// The previous train can still be deploying, e.g. soaking in a canary.
+	trains := []*types.Train{latestTrain}
+	if latestTrain.PreviousID != nil {
+		previousTrain, err := dataClient.Train(*latestTrain.PreviousID)
+		if err != nil {
+			logger.Error("Error getting previous train: %v", err)
+		} else if previousTrain != nil && previousTrain.IsDeploying() {
+			trains = append(trains, previousTrain)
+		}
+	}
+
+	for _, train := range trains {
+		if train.Done || train.Blocked {
+			continue
+		}
+		currentPhase := train.CurrentPhase()
+		checks := options.PhaseChecks(currentPhase.Name)
+		if !currentPhase.StartedAt.HasValue() || currentPhase.IsComplete() ||
+			(options.PhaseSoak(currentPhase.Name) == 0 && len(checks) == 0) {
+			continue
+		}
+
+		breach, err := healthBreach(ctx, healthService, checks)
+		if err != nil {
+			// Not a breach, but the phase can't complete until the checks run.
+			logger.Error("%v", err)
+			continue
+		}
+		if breach != "" {
+			stopUnhealthyPhase(ctx, dataClient, messagingService, currentPhase, breach)
+			continue
+		}
+
+		checkPhaseCompletion(
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService, currentPhase)
+	}
+}
+
+// Blocks the train, or if it's deploying, cancels it and rolls production back.
+func stopUnhealthyPhase(
+	ctx context.Context,
+	dataClient data.Client,
+	messagingService messaging.Service,
+	targetPhase *types.Phase,
+	breach string) {
+
+	train := targetPhase.Train
+	metrics.Incr("phase.health_check_failed", targetPhase.DatadogTags())
+	logger.Info("Phase %s of train %d failed a health check: %s", targetPhase.Name, train.ID, breach)
+
+	err := dataClient.ErrorPhase(targetPhase, fmt.Errorf("%s", breach))
+	if err != nil {
+		logger.Error("Error recording health check failure: %v", err)
+	}
+	messagingService.HealthCheckFailed(ctx, targetPhase, breach)
+
+	if targetPhase.Type != types.Deploy {
+		before := newTrainAuditState(train)
+		err = dataClient.BlockTrain(train, &breach)
+		if err != nil {
+			logger.Error("Error blocking train: %v", err)
+			return
+		}
+		auditTrain(dataClient, nil, types.TrainBlockAction, train, before, breach)
+		messagingService.TrainBlocked(ctx, train, nil)
+		return
+	}
+
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		logger.Error("Error getting production train: %v", err)
+	} else if productionTrain != nil && settings.GetJenkinsRollbackJob() != "" {
+		// Rolling back also cancels this train.
+		_, err = rollbackToTrain(ctx, dataClient, messagingService, productionTrain, nil)
+		if err == nil {
+			return
+		}
+		logger.Error("Error rolling back to train %d: %v", productionTrain.ID, err)
+	}
+
+	// Nothing to roll back to, so at least keep the train from going any further.
+	before := newTrainAuditState(train)
+	err = dataClient.CancelTrain(train)
+	if err != nil {
+		logger.Error("Error cancelling train: %v", err)
+		return
+	}
+	auditTrain(dataClient, nil, types.TrainCancelAction, train, before, breach)
+	messagingService.TrainCancelled(ctx, train, nil)
 }
@@ -12,6 +12,7 @@
 package core
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,12 +27,14 @@ func TestJenkinsPhase(t *testing.T) {
 
 	phaseService := phase.GetService()
 
-	err := phaseService.Start(types.Delivery,
+	err := phaseService.Start(context.Background(), types.Delivery,
+		testData.Train.ActivePhases.Delivery.Name,
+		testData.Train.ActivePhases.Delivery.ID,
 		testData.Train.ID,
 		testData.Train.ActivePhases.Delivery.ID,
 		testData.Train.ActivePhases.Verification.ID,
 		testData.Train.ActivePhases.Deploy.ID,
-		"branch", "sha", nil)
+		"branch", "sha", nil, nil)
 	assert.NoError(t, err)
 
 	// TODO: Test the job API calls.
@@ -3,6 +3,7 @@
 package core
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/Nextdoor/conductor/services/code"
 	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/health"
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
@@ -40,7 +42,7 @@ func TestStartPhaseVerification(t *testing.T) {
 	codeService := &code.CodeServiceMock{}
 	var trainDeliveredCalls []TrainDeliveredCall
 	messagingService := messaging.MessagingServiceMock{
-		TrainDeliveredMock: func(train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
+		TrainDeliveredMock: func(ctx context.Context, train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
 			trainDeliveredCalls = append(
 				trainDeliveredCalls, TrainDeliveredCall{train, commits, tickets})
 		},
@@ -49,7 +51,7 @@ func TestStartPhaseVerification(t *testing.T) {
 	ticketService := &ticket.TicketServiceMock{}
 	oldVerificationPhaseStartTime := testData.Train.ActivePhases.Verification.StartedAt
 	startPhase(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.ActivePhases.Verification, testData.User)
 	train, err := dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -64,6 +66,40 @@ func TestStartPhaseVerification(t *testing.T) {
 		trainDeliveredCalls)
 }
 
+func TestStartPhaseRedelivered(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	excluded := train.Commits[0]
+	err = dataClient.ExcludeCommit(train, excluded)
+	assert.NoError(t, err)
+	err = dataClient.RedeliverTrain(train, generatedBranch(train))
+	assert.NoError(t, err)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+
+	var startedBranch string
+	var startedExcludedSHAs []string
+	phaseService := &phase.PhaseServiceMock{
+		StartMock: func(
+			ctx context.Context, phaseType types.PhaseType, phaseName string, phaseID, trainID,
+			deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string, excludedSHAs []string,
+			buildUser *types.User) error {
+			startedBranch, startedExcludedSHAs = branch, excludedSHAs
+			return nil
+		},
+	}
+	startPhase(
+		context.Background(), dataClient, &code.CodeServiceMock{}, messaging.MessagingServiceMock{},
+		phaseService, &ticket.TicketServiceMock{}, train.ActivePhases.Delivery, testData.User)
+
+	// The jobs build the train's head without the excluded commit.
+	assert.Equal(t, generatedBranch(train), startedBranch)
+	assert.Equal(t, []string{excluded.SHA}, startedExcludedSHAs)
+}
+
 func TestCompletePhaseOutOfOrder(t *testing.T) {
 	_, testData := setup(t)
 	dataClient := data.NewClient()
@@ -74,7 +110,7 @@ func TestCompletePhaseOutOfOrder(t *testing.T) {
 	err := dataClient.StartPhase(testData.Train.ActivePhases.Verification)
 	assert.NoError(t, err)
 	// Complete verification phase. Should not be complete afterwards unless delivery is started and completed.
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.ActivePhases.Verification)
 	train, err := dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -83,7 +119,7 @@ func TestCompletePhaseOutOfOrder(t *testing.T) {
 	// Complete delivery phase, then try again. Should successfully complete this time.
 	err = dataClient.CompletePhase(train.ActivePhases.Delivery)
 	assert.NoError(t, err)
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Verification)
 	train, err = dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -98,7 +134,7 @@ func TestCompletePhaseBeforeStart(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	ticketService := &ticket.TicketServiceMock{}
 	// Try to complete delivery phase. Should not be complete afterwards because delivery hasn't been started yet.
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.ActivePhases.Delivery)
 	train, err := dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -107,7 +143,7 @@ func TestCompletePhaseBeforeStart(t *testing.T) {
 	// Start delivery phase, then try again. Should successfully complete this time.
 	err = dataClient.StartPhase(train.ActivePhases.Delivery)
 	assert.NoError(t, err)
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Delivery)
 	train, err = dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -122,10 +158,10 @@ func TestUnverifiedPhaseUncomplete(t *testing.T) {
 	trainVerifiedCalled := false
 	trainUnverifiedCalled := false
 	messagingService := messaging.MessagingServiceMock{
-		TrainVerifiedMock: func(*types.Train) {
+		TrainVerifiedMock: func(context.Context, *types.Train) {
 			trainVerifiedCalled = true
 		},
-		TrainUnverifiedMock: func(*types.Train) {
+		TrainUnverifiedMock: func(context.Context, *types.Train) {
 			trainUnverifiedCalled = true
 		},
 	}
@@ -152,7 +188,7 @@ func TestUnverifiedPhaseUncomplete(t *testing.T) {
 	testData.Train.Tickets = newTickets
 
 	// Check verification phase completion. Should uncomplete verification phase and call messaging.TrainUnverified.
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.ActivePhases.Verification)
 	train, err := dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -173,7 +209,7 @@ func TestUnverifiedPhaseUncomplete(t *testing.T) {
 	train.Tickets = newTickets
 
 	// Check verification phase completion. Should now complete verification phase and call messaging.TrainVerified.
-	checkPhaseCompletion(dataClient, codeService, messagingService, phaseService, ticketService,
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Verification)
 	train, err = dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -229,10 +265,10 @@ func TestDeliveryFinishedMessaging(t *testing.T) {
 	var sendDirectCalls []SendDirectCall
 	messagingService := &messaging.Messenger{
 		Engine: &messaging.EngineMock{
-			SendMock: func(text string) {
+			SendMock: func(ctx context.Context, text string) {
 				sendCalls = append(sendCalls, SendCall{text})
 			},
-			SendDirectMock: func(name string, email string, text string) {
+			SendDirectMock: func(ctx context.Context, name string, email string, text string) {
 				sendDirectCalls = append(sendDirectCalls, SendDirectCall{name, email, text})
 			},
 		},
@@ -251,7 +287,7 @@ func TestDeliveryFinishedMessaging(t *testing.T) {
 
 	var createTicketsCalls []CreateTicketsCall
 	ticketService := &ticket.TicketServiceMock{
-		CreateTicketsMock: func(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+		CreateTicketsMock: func(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 			createTicketsCalls = append(
 				createTicketsCalls, CreateTicketsCall{train, commits})
 			return []*types.Ticket{vanillaCommitTicket}, nil
@@ -261,7 +297,7 @@ func TestDeliveryFinishedMessaging(t *testing.T) {
 	codeService := &code.CodeServiceMock{}
 	assert.Equal(t, 2, len(train.Tickets))
 	startPhase(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Verification, user)
 	assert.Equal(t, 1, len(createTicketsCalls))
 	assert.Equal(t, createTicketsCalls[0].train, train)
@@ -340,10 +376,10 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationUser(t *testing.T) {
 	var sendDirectCalls []SendDirectCall
 	messagingService := &messaging.Messenger{
 		Engine: &messaging.EngineMock{
-			SendMock: func(text string) {
+			SendMock: func(ctx context.Context, text string) {
 				sendCalls = append(sendCalls, SendCall{text})
 			},
-			SendDirectMock: func(name string, email string, text string) {
+			SendDirectMock: func(ctx context.Context, name string, email string, text string) {
 				sendDirectCalls = append(sendDirectCalls, SendDirectCall{name, email, text})
 			},
 		},
@@ -358,7 +394,7 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationUser(t *testing.T) {
 
 	var createTicketsCalls []CreateTicketsCall
 	ticketService := &ticket.TicketServiceMock{
-		CreateTicketsMock: func(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+		CreateTicketsMock: func(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 			createTicketsCalls = append(
 				createTicketsCalls, CreateTicketsCall{train, commits})
 			return []*types.Ticket{needsStagingOverrideCommitTicket}, nil
@@ -367,7 +403,7 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationUser(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	codeService := &code.CodeServiceMock{}
 	startPhase(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Verification, user)
 
 	// People on the no-staging whitelist don't get tickets unless their commit is marked [needs-staging].
@@ -440,10 +476,10 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationByDefault(t *testing.T
 	var sendDirectCalls []SendDirectCall
 	messagingService := &messaging.Messenger{
 		Engine: &messaging.EngineMock{
-			SendMock: func(text string) {
+			SendMock: func(ctx context.Context, text string) {
 				sendCalls = append(sendCalls, SendCall{text})
 			},
-			SendDirectMock: func(name string, email string, text string) {
+			SendDirectMock: func(ctx context.Context, name string, email string, text string) {
 				sendDirectCalls = append(sendDirectCalls, SendDirectCall{name, email, text})
 			},
 		},
@@ -458,7 +494,7 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationByDefault(t *testing.T
 
 	var createTicketsCalls []CreateTicketsCall
 	ticketService := &ticket.TicketServiceMock{
-		CreateTicketsMock: func(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+		CreateTicketsMock: func(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 			createTicketsCalls = append(
 				createTicketsCalls, CreateTicketsCall{train, commits})
 			return []*types.Ticket{needsStagingOverrideCommitTicket}, nil
@@ -467,7 +503,7 @@ func TestDeliveryFinishedMessagingForNoStagingVerificationByDefault(t *testing.T
 	phaseService := &phase.PhaseServiceMock{}
 	codeService := &code.CodeServiceMock{}
 	startPhase(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Verification, user)
 
 	// People don't get tickets unless their commit is marked [needs-staging].
@@ -518,7 +554,7 @@ func TestDeployableAfterVerification(t *testing.T) {
 	err = dataClient.CompletePhase(testData.Train.ActivePhases.Delivery)
 	assert.NoError(t, err)
 
-	startPhase(dataClient, codeService, messagingService, phaseService, ticketService,
+	startPhase(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.ActivePhases.Verification, nil)
 
 	// Train should be deployable.
@@ -529,3 +565,113 @@ func TestDeployableAfterVerification(t *testing.T) {
 // TODO: TestRestartPhase
 // TODO: TestStartPhase
 // TODO: TestHandlePhaseCompletion
+
+func TestPipelineSoak(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+	codeService := &code.CodeServiceMock{}
+	messagingService := messaging.MessagingServiceMock{}
+	phaseService := &phase.PhaseServiceMock{}
+	ticketService := &ticket.TicketServiceMock{}
+
+	options := types.DefaultOptions
+	options.Pipeline = types.Pipeline{
+		{Name: "delivery", Type: "delivery"},
+		{Name: "verification", Type: "verification"},
+		{Name: "canary", Type: "deploy", SoakMinutes: 30},
+		{Name: "deploy", Type: "deploy"},
+	}
+	err := dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	train, err := dataClient.CreateTrain("pipeline_train", testData.User, []*types.Commit{{SHA: "p1pe"}})
+	assert.NoError(t, err)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+
+	phases := train.ActivePhases.Phases()
+	assert.Len(t, phases, 4)
+	canary := train.PhaseNamed("canary")
+	assert.Equal(t, canary, train.ActivePhases.Deploy)
+	assert.Equal(t, "deploy", canary.Next().Name)
+
+	for _, phase := range phases[:2] {
+		assert.NoError(t, dataClient.StartPhase(phase))
+		assert.NoError(t, dataClient.CompletePhase(phase))
+	}
+	assert.NoError(t, dataClient.StartPhase(canary))
+
+	// The canary has no jobs, but has to soak before the deploy goes on.
+	checkPhaseCompletion(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		canary)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.False(t, train.PhaseNamed("canary").IsComplete())
+	assert.True(t, train.IsDeploying())
+	assert.Equal(t, "canary", train.ActivePhaseName)
+}
+
+func TestHealthCheckBreach(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+	codeService := &code.CodeServiceMock{}
+	phaseService := &phase.PhaseServiceMock{}
+	ticketService := &ticket.TicketServiceMock{}
+
+	var breaches []string
+	messagingService := messaging.MessagingServiceMock{
+		HealthCheckFailedMock: func(ctx context.Context, phase *types.Phase, breach string) {
+			breaches = append(breaches, breach)
+		},
+	}
+
+	errorRate := 0.01
+	healthService := &health.HealthServiceMock{
+		CheckMock: func(ctx context.Context, check types.HealthCheck) (*health.Result, error) {
+			return &health.Result{Healthy: errorRate <= check.Max, Detail: "error rate"}, nil
+		},
+	}
+
+	options := types.DefaultOptions
+	options.Pipeline = types.Pipeline{
+		{Name: "delivery", Type: "delivery"},
+		{Name: "verification", Type: "verification"},
+		{Name: "canary", Type: "deploy", SoakMinutes: 30,
+			Checks: []types.HealthCheck{{Name: "errors", Query: "error_rate", Max: 0.05}}},
+		{Name: "deploy", Type: "deploy"},
+	}
+	err := dataClient.SetOptions(&options)
+	assert.NoError(t, err)
+	defer dataClient.SetOptions(&types.DefaultOptions)
+
+	train, err := dataClient.CreateTrain("health_train", testData.User, []*types.Commit{{SHA: "he4lth"}})
+	assert.NoError(t, err)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+
+	for _, phase := range train.ActivePhases.Phases()[:2] {
+		assert.NoError(t, dataClient.StartPhase(phase))
+		assert.NoError(t, dataClient.CompletePhase(phase))
+	}
+	assert.NoError(t, dataClient.StartPhase(train.PhaseNamed("canary")))
+
+	// Healthy, but still soaking.
+	checkSoakingPhases(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		healthService)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.True(t, train.IsDeploying())
+	assert.Empty(t, breaches)
+
+	// Without a rollback job, the breaching train is cancelled.
+	errorRate = 0.2
+	checkSoakingPhases(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
+		healthService)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.True(t, train.CancelledAt.HasValue())
+	assert.False(t, train.PhaseNamed("canary").IsComplete())
+	assert.Equal(t, "Health check errors failed: error rate is over 0.05", train.PhaseNamed("canary").Error)
+	assert.Len(t, breaches, 1)
+}
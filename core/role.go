@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func roleEndpoints() []endpoint {
+	roles := make([]string, len(types.Roles))
+	for i, role := range types.Roles {
+		roles[i] = role.String()
+	}
+
+	return []endpoint{
+		newAdminEp("/api/roles", get, fetchRoles).
+			describe("List the roles assigned to users, and the role of everyone else.").
+			returns(&rolesResult{}),
+		newAdminEp("/api/roles", post, assignRole).
+			describe("Assign a role to a user, replacing their current one.").
+			requiredForm("email", "Email of the user. They don't need to have logged in yet.").
+			requiredForm("role", fmt.Sprintf("One of: %s.", strings.Join(roles, ", "))).
+			returns(&types.UserRole{}),
+		newAdminEp("/api/roles/{user_id:[0-9]+}", del, unassignRole).
+			describe("Remove the role assigned to a user, so they have the default role.").
+			returns(nil),
+	}
+}
+
+type rolesResult struct {
+	Default     types.Role        `json:"default"`
+	AdminUsers  []string          `json:"admin_users"`  // Admins from settings, whatever their assigned role.
+	AdminGroups []string          `json:"admin_groups"` // Auth provider groups whose members are admins.
+	Assigned    []*types.UserRole `json:"assigned"`
+}
+
+func fetchRoles(_ *http.Request) response {
+	dataClient := data.NewClient()
+	roles, err := dataClient.UserRoles()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting roles: %v", err),
+			http.StatusInternalServerError)
+	}
+	return dataResponse(&rolesResult{
+		Default:     defaultRole(),
+		AdminUsers:  settings.AdminUsers,
+		AdminGroups: settings.AdminGroups,
+		Assigned:    roles,
+	})
+}
+
+func assignRole(r *http.Request) response {
+	err := r.ParseForm()
+	if err != nil {
+		return errorResponse("Error parsing POST form", http.StatusBadRequest)
+	}
+
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	if email == "" {
+		return errorResponse("`email` must be set in POST form", http.StatusBadRequest)
+	}
+
+	role, err := types.RoleFromString(r.PostFormValue("role"))
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+	user, err := dataClient.ReadOrCreateUser(email, email)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting user: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+
+	var before interface{}
+	previous, err := dataClient.UserRole(user)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting role: %v", err),
+			http.StatusInternalServerError)
+	}
+	if previous != nil {
+		before = previous.Role
+	}
+
+	userRole, err := dataClient.SetUserRole(user, role, authedUser)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error assigning role: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	audit(dataClient, authedUser, types.RoleAssignAction, "user", strconv.FormatUint(user.ID, 10), before, role, "")
+	return dataResponse(userRole)
+}
+
+func unassignRole(r *http.Request) response {
+	userIDStr := mux.Vars(r)["user_id"]
+	userID, err := strconv.ParseUint(userIDStr, 10, 64)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Bad user_id value: %s", userIDStr),
+			http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+	userRole, err := dataClient.UserRole(&types.User{ID: userID})
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting role: %v", err),
+			http.StatusInternalServerError)
+	}
+	if userRole == nil {
+		return errorResponse("User has no assigned role.", http.StatusNotFound)
+	}
+
+	err = dataClient.DeleteUserRole(userRole)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error removing role: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	audit(dataClient, authedUser, types.RoleRemoveAction, "user", userIDStr, userRole.Role, nil, "")
+	return emptyResponse()
+}
@@ -0,0 +1,120 @@
+// +build data
+
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func requestWithCookie(t *testing.T, server http.Handler, method, path string, form url.Values,
+	cookie *http.Cookie) *httptest.ResponseRecorder {
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, path, body)
+	assert.NoError(t, err)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.AddCookie(cookie)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+	return res
+}
+
+func TestRoles(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	// Another user, with the default role.
+	dataClient := data.NewClient()
+	email := fmt.Sprintf("other-%d@example.com", time.Now().UnixNano())
+	other, err := dataClient.ReadOrCreateUser("other", email)
+	assert.NoError(t, err)
+	otherToken := fmt.Sprintf("other-%d", time.Now().UnixNano())
+	err = dataClient.WriteToken(otherToken, other.Name, other.Email, "", nil)
+	assert.NoError(t, err)
+	otherCookie := &http.Cookie{Name: auth.GetCookieName(), Value: otherToken}
+
+	res := requestWithCookie(t, server, "GET", "/api/user", nil, otherCookie)
+	assert.Contains(t, res.Body.String(), `"role":"engineer"`)
+
+	// Only the train's engineer or a release manager can cancel.
+	cancelPath := fmt.Sprintf("/api/train/%d/cancel", testData.Train.ID)
+	res = requestWithCookie(t, server, "POST", cancelPath, nil, otherCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+	assert.Contains(t, res.Body.String(), "release-manager")
+
+	// Viewers can't block.
+	res = requestWithCookie(t, server, "POST", "/api/roles",
+		url.Values{"email": {email}, "role": {"viewer"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	blockPath := fmt.Sprintf("/api/train/%d/block", testData.Train.ID)
+	res = requestWithCookie(t, server, "POST", blockPath, nil, otherCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	// Release managers can cancel.
+	res = requestWithCookie(t, server, "POST", "/api/roles",
+		url.Values{"email": {email}, "role": {"release-manager"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "POST", cancelPath, nil, otherCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	// Non-admins can't manage roles.
+	res = requestWithCookie(t, server, "GET", "/api/roles", nil, otherCookie)
+	assert.Equal(t, http.StatusForbidden, res.Code)
+
+	res = requestWithCookie(t, server, "GET", "/api/roles", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), `"default":"engineer"`)
+	assert.Contains(t, res.Body.String(), email)
+
+	// Removing the role reverts to the default role.
+	res = requestWithCookie(t, server, "DELETE", fmt.Sprintf("/api/roles/%d", other.ID), nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "GET", "/api/user", nil, otherCookie)
+	assert.Contains(t, res.Body.String(), `"role":"engineer"`)
+
+	res = requestWithCookie(t, server, "POST", "/api/roles",
+		url.Values{"email": {email}, "role": {"superuser"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+// Test that the train's engineer can cancel it without a role.
+func TestCancelByEngineer(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{})
+	defer settings.CustomizeAdminUsers(nil)
+
+	res := requestWithCookie(t, server, "POST", fmt.Sprintf("/api/train/%d/cancel", testData.Train.ID),
+		nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+}
+
+// Test that only read endpoints, and logging out, are open to every role.
+func TestEndpointsRequireRole(t *testing.T) {
+	for _, ep := range Endpoints() {
+		if !ep.needsAuth || ep.method == get || ep.uri == "/api/auth/logout" {
+			continue
+		}
+		assert.NotEqual(t, types.Viewer, ep.role,
+			"%s %s can be called by viewers; add a role with requires", ep.method, ep.uri)
+	}
+}
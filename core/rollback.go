@@ -0,0 +1,315 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const (
+	DefaultRollbackLimit = 10
+	MaxRollbackLimit     = 50
+)
+
+func rollbackEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/rollback/targets", get, fetchRollbackTargets).
+			scoped(types.TrainRead).
+			describe("List the trains production can be rolled back to, newest first.").
+			query("limit", fmt.Sprintf("Trains to list, at most %d. Defaults to %d.",
+				MaxRollbackLimit, DefaultRollbackLimit)).
+			returns([]*types.RollbackTarget{}),
+		newEp("/api/rollback", get, fetchRollbacks).
+			scoped(types.TrainRead).
+			describe("List recent rollbacks, newest first.").
+			query("limit", fmt.Sprintf("Rollbacks to list, at most %d. Defaults to %d.",
+				MaxRollbackLimit, DefaultRollbackLimit)).
+			returns([]*types.Rollback{}),
+		newEp("/api/rollback/{rollback_id:[0-9]+}", get, fetchRollback).
+			scoped(types.TrainRead).
+			describe("Get a rollback.").
+			returns(&types.Rollback{}),
+		newEp("/api/rollback/{rollback_id:[0-9]+}", post, reportRollback).
+			requires(types.Committer).
+			scoped(types.JobsWrite).
+			describe("Deprecated: complete the rollback job with the job endpoints instead. "+
+				"Report the result of the rollback job.").
+			requiredForm("status", "succeeded or failed.").
+			form("error", "What went wrong, if the rollback failed.").
+			returns(&types.Rollback{}),
+	}
+}
+
+// Returns limit, or a response if there was an error.
+func parseRollbackLimit(r *http.Request) (int, *response) {
+	limit := r.URL.Query().Get("limit")
+	if limit == "" {
+		return DefaultRollbackLimit, nil
+	}
+	limitInt, err := strconv.Atoi(limit)
+	if err != nil || limitInt < 1 || limitInt > MaxRollbackLimit {
+		resp := errorResponse(
+			fmt.Sprintf("Bad limit value: %s. Must be between 1 and %d.", limit, MaxRollbackLimit),
+			http.StatusBadRequest)
+		return 0, &resp
+	}
+	return limitInt, nil
+}
+
+// Returns rollback, or a response if there was an error.
+func parseRollbackVars(r *http.Request, dataClient data.Client) (*types.Rollback, *response) {
+	rollbackIDStr := mux.Vars(r)["rollback_id"]
+	rollbackID, err := strconv.ParseUint(rollbackIDStr, 10, 64)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Rollback ID must be an integer: %s", rollbackIDStr),
+			http.StatusBadRequest)
+		return nil, &resp
+	}
+
+	rollback, err := dataClient.Rollback(rollbackID)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error getting rollback: %v", err),
+			http.StatusInternalServerError)
+		return nil, &resp
+	}
+	if rollback == nil {
+		resp := errorResponse(
+			fmt.Sprintf("Rollback %d not found", rollbackID),
+			http.StatusNotFound)
+		return nil, &resp
+	}
+	return rollback, nil
+}
+
+// Production can only go back to a train it was built on,
+// otherwise the rollback would deploy commits that were never in production.
+// Returns a response if train isn't a valid target.
+func validateRollbackTarget(
+	ctx context.Context, codeService code.Service, train, productionTrain *types.Train) *response {
+	if train.ID == productionTrain.ID {
+		resp := errorResponse(
+			fmt.Sprintf("Train %d is already in production", train.ID),
+			http.StatusBadRequest)
+		return &resp
+	}
+
+	// Anything the train has that production doesn't means it isn't an ancestor.
+	commits, err := codeService.CompareRefs(ctx, productionTrain.HeadSHA, train.HeadSHA)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error comparing train %d to production: %v", train.ID, err),
+			http.StatusInternalServerError)
+		return &resp
+	}
+	if len(commits) > 0 {
+		resp := errorResponse(
+			fmt.Sprintf("Train %d head %s is not an ancestor of production head %s",
+				train.ID, train.HeadSHA, productionTrain.HeadSHA),
+			http.StatusBadRequest)
+		return &resp
+	}
+	return nil
+}
+
+func fetchRollbackTargets(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	limit, resp := parseRollbackLimit(r)
+	if resp != nil {
+		return *resp
+	}
+
+	trains, err := dataClient.DeployedTrains(limit)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting deployed trains: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting production train: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	targets := make([]*types.RollbackTarget, 0, len(trains))
+	for _, train := range trains {
+		targets = append(targets, &types.RollbackTarget{
+			TrainID:    train.ID,
+			Branch:     train.Branch,
+			HeadSHA:    train.HeadSHA,
+			DeployedAt: train.DeployedAt,
+			Production: productionTrain != nil && train.ID == productionTrain.ID,
+		})
+	}
+	return dataResponse(targets)
+}
+
+func fetchRollbacks(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	limit, resp := parseRollbackLimit(r)
+	if resp != nil {
+		return *resp
+	}
+
+	rollbacks, err := dataClient.LatestRollbacks(limit)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting rollbacks: %v", err),
+			http.StatusInternalServerError)
+	}
+	return dataResponse(rollbacks)
+}
+
+func fetchRollback(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	rollback, resp := parseRollbackVars(r, dataClient)
+	if resp != nil {
+		return *resp
+	}
+	return dataResponse(rollback)
+}
+
+// Called when the rollback job starts, so a retried rollback counts as running again.
+func restartRollback(dataClient data.Client, phase *types.Phase) error {
+	rollback, err := dataClient.RollbackForPhase(phase)
+	if err != nil {
+		return err
+	}
+	if rollback == nil || rollback.Status == types.RollbackStarted {
+		return nil
+	}
+
+	err = dataClient.UncompletePhase(phase)
+	if err != nil {
+		return err
+	}
+	err = dataClient.SetRollbackStatus(rollback, types.RollbackStarted, "")
+	if err != nil {
+		return err
+	}
+	clearLatestTrainCache()
+	return nil
+}
+
+// Completes the rollback's job for jobs that still report with POST /api/rollback/{rollback_id}.
+func reportRollback(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	rollback, resp := parseRollbackVars(r, dataClient)
+	if resp != nil {
+		return *resp
+	}
+
+	status, err := types.RollbackStatusFromString(r.PostFormValue("status"))
+	if err != nil || status == types.RollbackStarted {
+		return errorResponse(
+			fmt.Sprintf("Bad status value: %s. Must be succeeded or failed.", r.PostFormValue("status")),
+			http.StatusBadRequest)
+	}
+
+	if rollback.Status != types.RollbackStarted {
+		return errorResponse(
+			fmt.Sprintf("Rollback %d already %s", rollback.ID, rollback.Status),
+			http.StatusBadRequest)
+	}
+
+	var job *types.Job
+	if rollback.Phase != nil {
+		job = jobByName(types.RollbackJobName, rollback.Phase.Jobs)
+	}
+	if job == nil {
+		return errorResponse(
+			fmt.Sprintf("Rollback %d has no %s job", rollback.ID, types.RollbackJobName),
+			http.StatusInternalServerError)
+	}
+	job.Phase.Train = rollback.Train
+
+	result := types.Ok
+	if status == types.RollbackFailed {
+		result = types.Error
+	}
+	rollbackErr := strings.TrimSpace(r.PostFormValue("error"))
+	err = dataClient.CompleteJob(job, result, rollbackErr)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error completing rollback job: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	err = completeRollback(r.Context(), dataClient, messaging.GetService(), job, rollbackErr)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error completing rollback: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	rollback, err = dataClient.Rollback(rollback.ID)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting rollback: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	return dataResponse(rollback)
+}
+
+// Records the result of the rollback job, and tells the channel whether production got there.
+// rollbackErr says what went wrong if the job failed, when the job reported it.
+func completeRollback(
+	ctx context.Context,
+	dataClient data.Client,
+	messagingService messaging.Service,
+	job *types.Job,
+	rollbackErr string) error {
+
+	rollback, err := dataClient.RollbackForPhase(job.Phase)
+	if err != nil {
+		return err
+	}
+	if rollback == nil {
+		return fmt.Errorf("No rollback found for phase %d", job.Phase.ID)
+	}
+
+	if job.Result == types.Ok {
+		metrics.Incr("train.rollback.success", job.Phase.Train.DatadogTags())
+		err = dataClient.CompletePhase(job.Phase)
+		if err != nil {
+			return err
+		}
+		err = dataClient.SetRollbackStatus(rollback, types.RollbackSucceeded, "")
+		if err != nil {
+			return err
+		}
+		messagingService.RollbackCompleted(ctx, rollback)
+	} else {
+		metrics.Incr("train.rollback.failure", job.Phase.Train.DatadogTags())
+		if rollbackErr == "" {
+			rollbackErr = fmt.Sprintf("%s job failed", job.Name)
+		}
+		err = dataClient.SetRollbackStatus(rollback, types.RollbackFailed, rollbackErr)
+		if err != nil {
+			return err
+		}
+		messagingService.RollbackFailed(ctx, rollback, job)
+	}
+
+	// Production is on a different train if the rollback failed.
+	clearLatestTrainCache()
+	return nil
+}
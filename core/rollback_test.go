@@ -0,0 +1,158 @@
+// +build data
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestValidateRollbackTarget(t *testing.T) {
+	production := &types.Train{ID: 2, HeadSHA: "production"}
+	target := &types.Train{ID: 1, HeadSHA: "target"}
+
+	var compared []string
+	codeService := &code.CodeServiceMock{
+		CompareRefsMock: func(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
+			compared = []string{oldRef, newRef}
+			return nil, nil
+		},
+	}
+	assert.Nil(t, validateRollbackTarget(context.Background(), codeService, target, production))
+	assert.Equal(t, []string{"production", "target"}, compared)
+
+	resp := validateRollbackTarget(context.Background(), codeService, production, production)
+	assert.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+	// The target has a commit production doesn't, e.g. it's from a branch that was reverted.
+	codeService.CompareRefsMock = func(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
+		return []*types.Commit{{SHA: "target"}}, nil
+	}
+	resp = validateRollbackTarget(context.Background(), codeService, target, production)
+	assert.NotNil(t, resp)
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestRollbackTargets(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	err := dataClient.DeployTrain(testData.Train)
+	assert.NoError(t, err)
+
+	res := requestWithCookie(t, server, "GET", "/api/rollback/targets?limit=5", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	var body struct {
+		Result []*types.RollbackTarget `json:"result"`
+	}
+	err = json.Unmarshal(res.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.True(t, len(body.Result) > 0 && len(body.Result) <= 5)
+	assert.Equal(t, testData.Train.ID, body.Result[0].TrainID)
+	assert.True(t, body.Result[0].Production)
+	for _, target := range body.Result[1:] {
+		assert.False(t, target.Production)
+	}
+
+	res = requestWithCookie(t, server, "GET", "/api/rollback/targets?limit=0", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	// Production is already there.
+	path := fmt.Sprintf("/api/train/%d/rollback", testData.Train.ID)
+	res = requestWithCookie(t, server, "POST", path, nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+}
+
+func TestRollbackJob(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	err := dataClient.DeployTrain(testData.Train)
+	assert.NoError(t, err)
+	rollback, err := dataClient.CreateRollback(testData.Train, testData.User)
+	assert.NoError(t, err)
+	assert.Equal(t, types.RollbackStarted, rollback.Status)
+	assert.Equal(t, types.RollbackPhase, rollback.Phase.Type)
+
+	jobPath := fmt.Sprintf("/api/train/%d/phase/%d/job", testData.Train.ID, rollback.Phase.ID)
+	completePath := fmt.Sprintf("%s/%s", jobPath, types.RollbackJobName)
+	startForm := url.Values{"name": {types.RollbackJobName}, "url": {"https://jenkins/rollback/1"}}
+
+	res := requestWithCookie(t, server, "POST", jobPath, url.Values{"name": {"deploy"}, "url": {"x"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Rollback phases only have the rollback job")
+
+	res = requestWithCookie(t, server, "POST", jobPath, startForm, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "POST", completePath, url.Values{"result": {"1"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, rollback.ID, train.Rollback.ID)
+	assert.Equal(t, types.RollbackFailed, train.Rollback.Status)
+	assert.Equal(t, "rollback job failed", train.Rollback.Error)
+	assert.False(t, train.Rollback.Phase.IsComplete())
+
+	// Retrying the job puts the rollback back in progress.
+	res = requestWithCookie(t, server, "POST", jobPath, startForm, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	rollback, err = dataClient.Rollback(rollback.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.RollbackStarted, rollback.Status)
+	assert.False(t, rollback.CompletedAt.HasValue())
+
+	res = requestWithCookie(t, server, "POST", completePath, url.Values{"result": {"0"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	rollback, err = dataClient.Rollback(rollback.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.RollbackSucceeded, rollback.Status)
+	assert.Empty(t, rollback.Error)
+	assert.True(t, rollback.CompletedAt.HasValue())
+	assert.True(t, rollback.Phase.IsComplete())
+
+	res = requestWithCookie(t, server, "GET", fmt.Sprintf("/api/rollback/%d", rollback.ID), nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	res = requestWithCookie(t, server, "GET", "/api/rollback/0", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestReportRollback(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	rollback, err := dataClient.CreateRollback(testData.Train, testData.User)
+	assert.NoError(t, err)
+
+	path := fmt.Sprintf("/api/rollback/%d", rollback.ID)
+	res := requestWithCookie(t, server, "POST", path, url.Values{"status": {"started"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+
+	form := url.Values{"status": {"failed"}, "error": {"Deploy timed out"}}
+	res = requestWithCookie(t, server, "POST", path, form, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	rollback, err = dataClient.Rollback(rollback.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.RollbackFailed, rollback.Status)
+	assert.Equal(t, "Deploy timed out", rollback.Error)
+	assert.True(t, rollback.CompletedAt.HasValue())
+	assert.True(t, rollback.Phase.Jobs[0].CompletedAt.HasValue())
+	assert.Equal(t, types.Error, rollback.Phase.Jobs[0].Result)
+
+	res = requestWithCookie(t, server, "POST", path, url.Values{"status": {"succeeded"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Rollbacks complete once")
+}
@@ -3,47 +3,81 @@ package core
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/Nextdoor/conductor/services/data"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
+const MaxSearchLimit = 100
+
 func searchEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/search", get, search),
+		newEp("/api/search", get, search).
+			scoped(types.TrainRead).
+			describe("Search trains and commits. At least one of the search params is required.").
+			query("q", "Matches any of the fields below.").
+			query("commit", "Commit SHA or SHA prefix.").
+			query("message", "Text in commit messages.").
+			query("author", "Commit author name or email.").
+			query("ticket", "Ticket key.").
+			query("engineer", "Train engineer name or email.").
+			query("limit", fmt.Sprintf("Results of each kind, at most %d.", MaxSearchLimit)).
+			returns(&types.Search{}),
 	}
 }
 
+// Query params that select what to search for.
+var searchParams = []string{"q", "commit", "message", "author", "ticket", "engineer"}
+
 func search(r *http.Request) response {
 	dataClient := data.NewClient()
 
 	query := r.URL.Query()
 	params := make(map[string]string)
 	for key, values := range query {
-		params[key] = values[0]
+		params[key] = strings.TrimSpace(values[0])
+	}
+
+	searchQuery := &types.SearchQuery{
+		Text:      params["q"],
+		SHA:       params["commit"],
+		Message:   params["message"],
+		Author:    params["author"],
+		TicketKey: params["ticket"],
+		Engineer:  params["engineer"],
 	}
-	sha, ok := params["commit"]
-	if !ok {
+	if !searchQuery.HasCommitCriteria() && searchQuery.Engineer == "" {
 		return errorResponse(
-			"Search only supports commit",
+			fmt.Sprintf("Search requires at least one of: %s", strings.Join(searchParams, ", ")),
 			http.StatusBadRequest)
 	}
-	commit := &types.Commit{SHA: sha}
 
-	trains, err := dataClient.TrainsByCommit(commit)
+	if limit, ok := params["limit"]; ok {
+		limitInt, err := strconv.Atoi(limit)
+		if err != nil || limitInt < 1 || limitInt > MaxSearchLimit {
+			return errorResponse(
+				fmt.Sprintf("Bad limit value: %s. Must be between 1 and %d.", limit, MaxSearchLimit),
+				http.StatusBadRequest)
+		}
+		searchQuery.Limit = limitInt
+	}
+
+	results, err := dataClient.Search(searchQuery)
 	if err != nil {
 		return errorResponse(
-			fmt.Sprintf("Error getting trains for commit: %v", err),
+			fmt.Sprintf("Error searching: %v", err),
 			http.StatusInternalServerError)
 	}
-	if len(trains) == 0 {
+	if len(results.Trains) == 0 && len(results.Commits) == 0 {
 		return errorResponse(
-			fmt.Sprintf("Could not find any trains for commit %s", sha),
+			"Could not find any trains or commits matching the search",
 			http.StatusNotFound)
 	}
 
 	return dataResponse(&types.Search{
 		Params:  params,
-		Results: trains,
+		Results: results,
 	})
 }
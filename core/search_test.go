@@ -0,0 +1,105 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+type searchResponse struct {
+	Result struct {
+		Params  map[string]string   `json:"params"`
+		Results types.SearchResults `json:"results"`
+	} `json:"result"`
+	Error string `json:"error"`
+}
+
+func fetchSearch(t *testing.T, server *mux.Router, testData *TestData, query url.Values) (int, searchResponse) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/search?%s", query.Encode()), nil)
+	assert.NoError(t, err)
+	req.AddCookie(testData.TokenCookie)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	var results searchResponse
+	err = json.Unmarshal(res.Body.Bytes(), &results)
+	assert.NoError(t, err)
+	return res.Code, results
+}
+
+func TestSearch(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	commits := []*types.Commit{
+		{
+			SHA:         "searchtestsha0001",
+			Message:     "Fix the flux capacitor overload",
+			AuthorName:  "Search Author",
+			AuthorEmail: "search-author@example.com",
+		},
+		{
+			SHA:         "searchtestsha0002",
+			Message:     "Update dependencies",
+			AuthorName:  "Other Author",
+			AuthorEmail: "other-author@example.com",
+		},
+	}
+	train, err := dataClient.CreateTrain("search_test_branch", testData.User, commits)
+	assert.NoError(t, err)
+	trainID := fmt.Sprint(train.ID)
+
+	tickets := []*types.Ticket{
+		{Key: "SEARCH-1", Train: train, Commits: commits[1:]},
+	}
+	err = dataClient.WriteTickets(tickets)
+	assert.NoError(t, err)
+
+	code, results := fetchSearch(t, server, testData, url.Values{"commit": {"searchtestsha000"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, results.Result.Results.Commits, 2)
+	assert.Equal(t, train.ID, results.Result.Results.Trains[0].ID)
+
+	code, results = fetchSearch(t, server, testData, url.Values{"message": {"flux capacitor"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, results.Result.Results.Commits, 1)
+	assert.Equal(t, commits[0].SHA, results.Result.Results.Commits[0].SHA)
+	assert.Contains(t, results.Result.Results.Commits[0].TrainIDs, trainID)
+
+	code, results = fetchSearch(t, server, testData, url.Values{"author": {"other-author@example.com"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, commits[1].SHA, results.Result.Results.Commits[0].SHA)
+
+	code, results = fetchSearch(t, server, testData, url.Values{"ticket": {"search-1"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, results.Result.Results.Commits, 1)
+	assert.Equal(t, commits[1].SHA, results.Result.Results.Commits[0].SHA)
+
+	code, results = fetchSearch(t, server, testData, url.Values{"q": {"flux"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, commits[0].SHA, results.Result.Results.Commits[0].SHA)
+
+	code, results = fetchSearch(t, server, testData, url.Values{"engineer": {testData.User.Email}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, results.Result.Results.Trains)
+
+	code, _ = fetchSearch(t, server, testData, url.Values{"message": {"no commit says this"}})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = fetchSearch(t, server, testData, url.Values{})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = fetchSearch(t, server, testData, url.Values{"q": {"flux"}, "limit": {"0"}})
+	assert.Equal(t, http.StatusBadRequest, code)
+}
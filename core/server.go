@@ -7,12 +7,15 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/shared/metrics"
 )
 
 func NewServer(endpoints []endpoint) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
 	middlewares := []middleware{
+		newTracingMiddleware(),
 		newPanicRecoveryMiddleware(),
 		newAuthMiddleware(),
 	}
@@ -25,5 +28,11 @@ func NewServer(endpoints []endpoint) *mux.Router {
 		ep.Route(router, handler)
 	}
 
+	if metrics.PrometheusEnabled() {
+		router.Path("/metrics").
+			Methods("GET").
+			Handler(newPanicRecoveryMiddleware().Wrap(newMetricsHandler()))
+	}
+
 	return router
 }
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/tracing"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func sessionEndpoints() []endpoint {
+	return []endpoint{
+		newAdminEp("/api/sessions", get, fetchSessions).
+			describe("List the active sessions of a user.").
+			requiredQuery("email", "Email of the user.").
+			returns([]*sessionResult{}),
+		newAdminEp("/api/sessions/revoke", post, revokeSessions).
+			describe("Revoke a session of a user, or all of them, logging them out.").
+			requiredForm("email", "Email of the user.").
+			form("session_id", "Session to revoke. Defaults to all of the user's sessions.").
+			returns([]*sessionResult{}),
+	}
+}
+
+// A session, without its token.
+type sessionResult struct {
+	ID         string     `json:"id"`
+	CreatedAt  types.Time `json:"created_at"`
+	LastUsedAt types.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+}
+
+func newSessionResult(session *types.Auth) *sessionResult {
+	return &sessionResult{
+		ID:         session.SessionID(),
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  sessionExpiresAt(session),
+	}
+}
+
+// Returns the user's unexpired sessions, or a response if there was an error.
+func activeSessions(dataClient data.Client, email string) ([]*types.Auth, *response) {
+	if email == "" {
+		resp := errorResponse("`email` must be set", http.StatusBadRequest)
+		return nil, &resp
+	}
+	user, err := dataClient.UserByEmail(email)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error getting user: %v", err),
+			http.StatusInternalServerError)
+		return nil, &resp
+	}
+	if user == nil {
+		resp := errorResponse("User not found.", http.StatusNotFound)
+		return nil, &resp
+	}
+
+	sessions, err := dataClient.Sessions(user)
+	if err != nil {
+		resp := errorResponse(
+			fmt.Sprintf("Error getting sessions: %v", err),
+			http.StatusInternalServerError)
+		return nil, &resp
+	}
+	now := time.Now()
+	active := make([]*types.Auth, 0, len(sessions))
+	for _, session := range sessions {
+		if now.Before(sessionExpiresAt(session)) {
+			active = append(active, session)
+		}
+	}
+	return active, nil
+}
+
+func fetchSessions(r *http.Request) response {
+	dataClient := data.NewClient()
+	sessions, resp := activeSessions(dataClient, strings.TrimSpace(r.URL.Query().Get("email")))
+	if resp != nil {
+		return *resp
+	}
+	results := make([]*sessionResult, len(sessions))
+	for i, session := range sessions {
+		results[i] = newSessionResult(session)
+	}
+	return dataResponse(results)
+}
+
+// Responds with the revoked sessions.
+func revokeSessions(r *http.Request) response {
+	err := r.ParseForm()
+	if err != nil {
+		return errorResponse("Error parsing POST form", http.StatusBadRequest)
+	}
+
+	dataClient := data.NewClient()
+	sessions, resp := activeSessions(dataClient, strings.TrimSpace(r.PostFormValue("email")))
+	if resp != nil {
+		return *resp
+	}
+
+	sessionID := r.PostFormValue("session_id")
+	revoked := make([]*sessionResult, 0)
+	for _, session := range sessions {
+		if sessionID != "" && session.SessionID() != sessionID {
+			continue
+		}
+		err = dataClient.RevokeSession(session)
+		if err != nil {
+			return errorResponse(
+				fmt.Sprintf("Error revoking session: %v", err),
+				http.StatusInternalServerError)
+		}
+		revoked = append(revoked, newSessionResult(session))
+	}
+	if sessionID != "" && len(revoked) == 0 {
+		return errorResponse("Session not found.", http.StatusNotFound)
+	}
+
+	if len(revoked) > 0 {
+		revokedIDs := make([]string, len(revoked))
+		for i, session := range revoked {
+			revokedIDs[i] = session.ID
+		}
+		authedUser := r.Context().Value("user").(*types.User)
+		audit(dataClient, authedUser, types.SessionRevokeAction, "user", strconv.FormatUint(sessions[0].User.ID, 10),
+			revokedIDs, nil, "")
+	}
+	return dataResponse(revoked)
+}
+
+func deleteExpiredSessions(ctx context.Context, dataClient data.Client) {
+	deleted, err := dataClient.DeleteExpiredSessions(auth.SessionIdleTimeout(), auth.SessionMaxLifetime())
+	if err != nil {
+		logger.Error("Error deleting expired sessions: %v", err)
+		tracing.FromContext(ctx).SetError(err)
+		return
+	}
+	tracing.FromContext(ctx).SetAttribute("deleted", strconv.FormatInt(deleted, 10))
+}
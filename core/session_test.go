@@ -0,0 +1,75 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/auth"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/settings"
+)
+
+func TestSessions(t *testing.T) {
+	server, testData := setup(t)
+	settings.CustomizeAdminUsers([]string{testData.User.Email})
+	defer settings.CustomizeAdminUsers(nil)
+
+	// Another user, logged in twice.
+	dataClient := data.NewClient()
+	email := fmt.Sprintf("sessions-%d@example.com", time.Now().UnixNano())
+	user, err := dataClient.ReadOrCreateUser("sessions", email)
+	assert.NoError(t, err)
+	var cookies []*http.Cookie
+	for i := 0; i < 2; i++ {
+		token := fmt.Sprintf("sessions-%d-%d", time.Now().UnixNano(), i)
+		err = dataClient.WriteToken(token, user.Name, user.Email, "", nil)
+		assert.NoError(t, err)
+		cookies = append(cookies, &http.Cookie{Name: auth.GetCookieName(), Value: token})
+	}
+
+	// Using a session refreshes the cookie.
+	res := requestWithCookie(t, server, "GET", "/api/user", nil, cookies[0])
+	assert.Equal(t, http.StatusOK, res.Code)
+	refreshed := res.Result().Cookies()
+	assert.Len(t, refreshed, 1)
+	assert.True(t, refreshed[0].HttpOnly)
+	assert.True(t, refreshed[0].MaxAge > 0)
+
+	res = requestWithCookie(t, server, "GET", "/api/sessions?email="+url.QueryEscape(email), nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	assert.NotContains(t, res.Body.String(), cookies[0].Value, "Tokens should not be listed")
+	var sessions struct {
+		Result []struct {
+			ID string `json:"id"`
+		} `json:"result"`
+	}
+	assert.NoError(t, json.Unmarshal(res.Body.Bytes(), &sessions))
+	assert.Len(t, sessions.Result, 2)
+
+	// Revoke one session.
+	res = requestWithCookie(t, server, "POST", "/api/sessions/revoke",
+		url.Values{"email": {email}, "session_id": {sessions.Result[0].ID}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "GET", "/api/user", nil, cookies[1])
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "Newest session should be revoked")
+	res = requestWithCookie(t, server, "GET", "/api/user", nil, cookies[0])
+	assert.Equal(t, http.StatusOK, res.Code)
+
+	// Revoke the rest.
+	res = requestWithCookie(t, server, "POST", "/api/sessions/revoke",
+		url.Values{"email": {email}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+	res = requestWithCookie(t, server, "GET", "/api/user", nil, cookies[0])
+	assert.Equal(t, http.StatusUnauthorized, res.Code)
+
+	res = requestWithCookie(t, server, "GET", "/api/sessions?email=nobody@example.com", nil, testData.TokenCookie)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
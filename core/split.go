@@ -0,0 +1,208 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
+	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/tracing"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func splitEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/train/{train_id:[0-9]+}/split", post, splitTrain).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Deploy the train up to a verified commit, and move the commits after it to a new train.").
+			requiredForm("sha", "The last commit to deploy. It and every commit before it must be verified.").
+			returns(&types.Train{}),
+	}
+}
+
+func splitTrain(r *http.Request) response {
+	trainCloseModificationLock.Lock()
+	defer trainCloseModificationLock.Unlock()
+
+	dataClient := data.NewClient()
+
+	train, resp := parseTrainVars(r, dataClient, false)
+	if resp != nil {
+		return *resp
+	}
+
+	resp = validateMutableTrain(train)
+	if resp != nil {
+		return *resp
+	}
+
+	if train.NextIsSplitOff {
+		return errorResponse(
+			fmt.Sprintf("Train %d was already split.", train.ID),
+			http.StatusBadRequest)
+	}
+
+	if train.GeneratedBranch != nil {
+		return errorResponse(
+			"Can't split a train that's delivered without its excluded commits.",
+			http.StatusBadRequest)
+	}
+
+	sha := strings.TrimSpace(r.PostFormValue("sha"))
+	splitIndex := -1
+	for i, commit := range train.Commits {
+		if commit.SHA == sha {
+			splitIndex = i
+			break
+		}
+	}
+	if splitIndex == -1 {
+		return errorResponse(
+			fmt.Sprintf("Commit %s is not on train %d.", sha, train.ID),
+			http.StatusBadRequest)
+	}
+	if splitIndex == len(train.Commits)-1 {
+		return errorResponse(
+			fmt.Sprintf("Train %d already ends at %s.", train.ID, sha),
+			http.StatusBadRequest)
+	}
+
+	// The train would still wait for these after the split.
+	keptCommits := make(map[string]bool)
+	for _, commit := range train.CommitsSince(sha) {
+		keptCommits[commit.SHA] = true
+	}
+	var holds []string
+	for _, hold := range train.Holds {
+		if keptCommits[hold.Commit.SHA] {
+			holds = append(holds, hold.String())
+		}
+	}
+	if len(holds) > 0 {
+		return errorResponse(
+			fmt.Sprintf("Commits up to %s are held. Release them, or split before them. %s",
+				sha, strings.Join(holds, " ")),
+			http.StatusBadRequest)
+	}
+
+	unverified := train.UnverifiedCommits(sha, settings.NoStagingVerification)
+	if len(unverified) > 0 {
+		shas := make([]string, len(unverified))
+		for i, commit := range unverified {
+			shas[i] = commit.ShortSHA()
+		}
+		return errorResponse(
+			fmt.Sprintf("Commits up to %s aren't all verified: %s", sha, strings.Join(shas, ", ")),
+			http.StatusBadRequest)
+	}
+
+	movedCommits := train.Commits[splitIndex+1:]
+	engineer, err := chooseEngineer(dataClient, movedCommits)
+	if err != nil {
+		logger.Error("Error choosing engineer: %v", err)
+	}
+
+	before := newTrainAuditState(train)
+	newTrain, err := dataClient.SplitTrain(train, sha, engineer)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error splitting train: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainSplitAction, train, before,
+		fmt.Sprintf("%d commits moved to train %d", len(movedCommits), newTrain.ID))
+	auditTrain(dataClient, authedUser, types.TrainCreateAction, newTrain, nil,
+		fmt.Sprintf("Split from train %d", train.ID))
+	metrics.Incr("train.split", train.DatadogTags())
+
+	ticketService := ticket.GetService()
+	err = deleteOpenTickets(r.Context(), dataClient, ticketService, train, movedCommits)
+	if err != nil {
+		logger.Error("Error deleting tickets moved off train %d: %v", train.ID, err)
+	}
+
+	messagingService := messaging.GetService()
+	messagingService.TrainSplit(r.Context(), train, newTrain, movedCommits, authedUser)
+
+	// The new train goes to staging once this one is out of the way, see startSplitTrain.
+	go StartTrain(tracing.Detach(r.Context()), data.NewClient(), code.GetService(), messagingService,
+		phase.GetService(), ticketService, train)
+
+	clearLatestTrainCache()
+
+	return dataResponse(train)
+}
+
+// Deletes the open tickets for commits that moved off the train.
+// The new train makes its own when it's delivered.
+func deleteOpenTickets(
+	ctx context.Context,
+	dataClient data.Client,
+	ticketService ticket.Service,
+	train *types.Train,
+	commits []*types.Commit) error {
+
+	ticketModificationLock.Lock()
+	defer ticketModificationLock.Unlock()
+
+	moved := make(map[string]bool)
+	for _, commit := range commits {
+		moved[commit.SHA] = true
+	}
+	for _, trainTicket := range train.Tickets {
+		if trainTicket.IsComplete() {
+			continue
+		}
+		for _, commit := range trainTicket.Commits {
+			if !moved[commit.SHA] {
+				continue
+			}
+			err := ticketService.DeleteTicket(ctx, trainTicket)
+			if err != nil {
+				return err
+			}
+			err = dataClient.DeleteTicket(trainTicket)
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Starts the train after this one if it was split off and is still waiting for staging.
+func startSplitTrain(
+	ctx context.Context,
+	dataClient data.Client,
+	codeService code.Service,
+	messagingService messaging.Service,
+	phaseService phase.Service,
+	ticketService ticket.Service,
+	train *types.Train) {
+
+	if !train.NextIsSplitOff {
+		return
+	}
+	nextTrain, err := dataClient.Train(*train.NextID)
+	if err != nil {
+		logger.Error("Error getting train after train %d: %v", train.ID, err)
+		return
+	}
+	if nextTrain.Done || nextTrain.ActivePhases.Phases()[0].StartedAt.HasValue() {
+		return
+	}
+	go StartTrain(tracing.Detach(ctx), data.NewClient(), codeService, messagingService, phaseService,
+		ticketService, nextTrain)
+}
@@ -0,0 +1,183 @@
+// +build data
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/code"
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/services/messaging"
+	"github.com/Nextdoor/conductor/services/phase"
+	"github.com/Nextdoor/conductor/services/ticket"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestSplitTrain(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	open := &types.Commit{SHA: fmt.Sprintf("a%x", time.Now().UnixNano()), AuthorEmail: "open@example.com"}
+	last := &types.Commit{SHA: fmt.Sprintf("b%x", time.Now().UnixNano()), AuthorEmail: "last@example.com"}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{open, last})
+	assert.NoError(t, err)
+	phaseGroupID := train.ActivePhases.ID
+
+	tickets := []*types.Ticket{
+		{Key: "SPLIT-1", Train: train, Commits: train.Commits[:1], ClosedAt: types.Time{Value: time.Now()}},
+		{Key: "SPLIT-2", Train: train, Commits: train.Commits[1:2]},
+	}
+	err = dataClient.WriteTickets(tickets)
+	assert.NoError(t, err)
+
+	path := fmt.Sprintf("/api/train/%d/split", train.ID)
+	res := requestWithCookie(t, server, "POST", path, url.Values{"sha": {"abc123"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The commit isn't on the train")
+
+	res = requestWithCookie(t, server, "POST", path, url.Values{"sha": {last.SHA}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "Nothing to move")
+
+	res = requestWithCookie(t, server, "POST", path, url.Values{"sha": {open.SHA}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code, "The commit's ticket is open")
+
+	res = requestWithCookie(t, server, "POST", path, url.Values{"sha": {"sha1"}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusOK, res.Code, res.Body.String())
+
+	var body struct {
+		Result *types.Train `json:"result"`
+	}
+	err = json.Unmarshal(res.Body.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, "sha1", body.Result.HeadSHA)
+
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	assert.Len(t, train.Commits, 1)
+	assert.Equal(t, "sha1", train.HeadSHA)
+	assert.NotEqual(t, phaseGroupID, train.ActivePhases.ID)
+	assert.NotNil(t, train.NextID)
+
+	newTrain, err := dataClient.Train(*train.NextID)
+	assert.NoError(t, err)
+	assert.Len(t, newTrain.Commits, 2)
+	assert.Equal(t, open.SHA, newTrain.TailSHA)
+	assert.Equal(t, last.SHA, newTrain.HeadSHA)
+	assert.False(t, newTrain.ActivePhases.Delivery.StartedAt.HasValue())
+
+	// The open ticket is gone, the new train makes its own.
+	assert.Len(t, train.Tickets, 1)
+	assert.Equal(t, "SPLIT-1", train.Tickets[0].Key)
+}
+
+func TestOldestUndeployedTrainAfterSplit(t *testing.T) {
+	_, deployedData := setup(t)
+	dataClient := data.NewClient()
+	err := dataClient.DeployTrain(deployedData.Train)
+	assert.NoError(t, err)
+
+	_, testData := setup(t)
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	moved := &types.Commit{SHA: fmt.Sprintf("c%x", time.Now().UnixNano())}
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{moved})
+	assert.NoError(t, err)
+	newTrain, err := dataClient.SplitTrain(train, "sha1", testData.User)
+	assert.NoError(t, err)
+
+	// The kept part deploys before the moved commits, though the new train is latest.
+	latest, err := dataClient.LatestTrain()
+	assert.NoError(t, err)
+	assert.Equal(t, newTrain.ID, latest.ID)
+	oldest, err := dataClient.OldestUndeployedTrain()
+	assert.NoError(t, err)
+	assert.Equal(t, train.ID, oldest.ID)
+}
+
+func TestSplitTrainDeploys(t *testing.T) {
+	_, testData := setup(t)
+	dataClient := data.NewClient()
+
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	moved := &types.Commit{SHA: fmt.Sprintf("c%x", time.Now().UnixNano())}
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{moved})
+	assert.NoError(t, err)
+	newTrain, err := dataClient.SplitTrain(train, "sha1", testData.User)
+	assert.NoError(t, err)
+
+	err = dataClient.CloseTrain(train, true)
+	assert.NoError(t, err)
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+	for _, phase := range []*types.Phase{train.ActivePhases.Delivery, train.ActivePhases.Verification} {
+		assert.NoError(t, dataClient.StartPhase(phase))
+		assert.NoError(t, dataClient.CompletePhase(phase))
+	}
+	train, err = dataClient.Train(train.ID)
+	assert.NoError(t, err)
+
+	// The new train doesn't keep the kept part from deploying or changing.
+	assert.Equal(t, newTrain.ID, *train.NextID)
+	assert.False(t, train.IsSuperseded())
+	assert.Nil(t, validateMutableTrain(train))
+	train.PreviousTrainDone = true
+	assert.True(t, train.IsDeployable())
+
+	startedTrainIDs := make(chan uint64, 2)
+	phaseService := &phase.PhaseServiceMock{
+		StartMock: func(
+			ctx context.Context, phaseType types.PhaseType, phaseName string, phaseID, trainID,
+			deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string, excludedSHAs []string,
+			buildUser *types.User) error {
+			startedTrainIDs <- trainID
+			return nil
+		},
+	}
+	startPhase(
+		context.Background(), dataClient, &code.CodeServiceMock{}, messaging.MessagingServiceMock{},
+		phaseService, &ticket.TicketServiceMock{}, train.ActivePhases.Deploy, testData.User)
+
+	// The new train is delivered once the kept part deploys.
+	var started []uint64
+	timeout := time.After(5 * time.Second)
+	for len(started) < 2 {
+		select {
+		case trainID := <-startedTrainIDs:
+			started = append(started, trainID)
+		case <-timeout:
+			t.Fatalf("Only started trains %v", started)
+		}
+	}
+	assert.ElementsMatch(t, []uint64{train.ID, newTrain.ID}, started)
+	newTrain, err = dataClient.Train(newTrain.ID)
+	assert.NoError(t, err)
+	assert.True(t, newTrain.ActivePhases.Delivery.StartedAt.HasValue())
+}
+
+func TestSplitTrainWithHold(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	held := &types.Commit{SHA: fmt.Sprintf("a%x", time.Now().UnixNano()), Message: "Launch the banner [hold]"}
+	moved := &types.Commit{SHA: fmt.Sprintf("b%x", time.Now().UnixNano())}
+	train, err := dataClient.Train(testData.Train.ID)
+	assert.NoError(t, err)
+	err = dataClient.ExtendTrain(train, testData.User, []*types.Commit{held, moved})
+	assert.NoError(t, err)
+
+	// The kept commits would still hold the train.
+	path := fmt.Sprintf("/api/train/%d/split", train.ID)
+	res := requestWithCookie(t, server, "POST", path, url.Values{"sha": {held.SHA}}, testData.TokenCookie)
+	assert.Equal(t, http.StatusBadRequest, res.Code)
+	assert.Contains(t, res.Body.String(), fmt.Sprintf("Commit %s is held", held.ShortSHA()))
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const (
+	DefaultStatsRange = 30 * 24 * time.Hour
+	MaxStatsRange     = 366 * 24 * time.Hour
+)
+
+func statsEndpoints() []endpoint {
+	return []endpoint{
+		newEp("/api/stats", get, fetchStats).
+			scoped(types.TrainRead).
+			describe("Get release stats, like deployment frequency, lead time and change failure rate.").
+			query("start", "RFC3339 timestamp or date. Defaults to 30 days before end.").
+			query("end", "RFC3339 timestamp or date. Defaults to now.").
+			query("branch", "Only trains on this branch.").
+			returns(&types.ReleaseStats{}),
+	}
+}
+
+// Returns start and end of the stats range, or a response if there was an error.
+// Defaults to the last 30 days.
+func parseStatsRange(params map[string]string) (time.Time, time.Time, *response) {
+	end := time.Now()
+	if value, ok := params["end"]; ok {
+		parsed, err := parseHistoryTime(value)
+		if err != nil {
+			resp := errorResponse(fmt.Sprintf("Bad `end` value: %v", err), http.StatusBadRequest)
+			return time.Time{}, time.Time{}, &resp
+		}
+		end = *parsed
+	}
+
+	start := end.Add(-DefaultStatsRange)
+	if value, ok := params["start"]; ok {
+		parsed, err := parseHistoryTime(value)
+		if err != nil {
+			resp := errorResponse(fmt.Sprintf("Bad `start` value: %v", err), http.StatusBadRequest)
+			return time.Time{}, time.Time{}, &resp
+		}
+		start = *parsed
+	}
+
+	if !start.Before(end) {
+		resp := errorResponse("Start must be before end", http.StatusBadRequest)
+		return time.Time{}, time.Time{}, &resp
+	}
+	if end.Sub(start) > MaxStatsRange {
+		resp := errorResponse(
+			fmt.Sprintf("Range can be at most %d days", int(MaxStatsRange.Hours()/24)),
+			http.StatusBadRequest)
+		return time.Time{}, time.Time{}, &resp
+	}
+	return start, end, nil
+}
+
+func fetchStats(r *http.Request) response {
+	dataClient := data.NewClient()
+
+	query := r.URL.Query()
+	params := make(map[string]string)
+	for key, values := range query {
+		params[key] = values[0]
+	}
+
+	start, end, resp := parseStatsRange(params)
+	if resp != nil {
+		return *resp
+	}
+	branch := params["branch"]
+
+	trains, err := dataClient.ReleaseTrains(branch, start, end)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting trains: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	rollbacks, err := dataClient.Rollbacks(branch, start, end)
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting rollbacks: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	options, err := dataClient.Options()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting options: %v", err),
+			http.StatusInternalServerError)
+	}
+
+	stats := types.ComputeReleaseStats(trains, rollbacks, options.CloseTime, start, end)
+	stats.Params = params
+	return dataResponse(stats)
+}
@@ -0,0 +1,66 @@
+// +build data
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/services/data"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+type statsResponse struct {
+	Result types.ReleaseStats `json:"result"`
+	Error  string             `json:"error"`
+}
+
+func fetchReleaseStats(t *testing.T, server *mux.Router, testData *TestData, query string) (int, statsResponse) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("/api/stats?%s", query), nil)
+	assert.NoError(t, err)
+	req.AddCookie(testData.TokenCookie)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	var stats statsResponse
+	err = json.Unmarshal(res.Body.Bytes(), &stats)
+	assert.NoError(t, err)
+	return res.Code, stats
+}
+
+func TestFetchStats(t *testing.T) {
+	server, testData := setup(t)
+	dataClient := data.NewClient()
+
+	branch := "stats_test_branch"
+	deployed, err := dataClient.CreateTrain(branch, testData.User,
+		[]*types.Commit{{SHA: "stats_test_sha_1"}})
+	assert.NoError(t, err)
+	assert.NoError(t, dataClient.DeployTrain(deployed))
+
+	cancelled, err := dataClient.CreateTrain(branch, testData.User,
+		[]*types.Commit{{SHA: "stats_test_sha_2"}})
+	assert.NoError(t, err)
+	assert.NoError(t, dataClient.CancelTrain(cancelled))
+
+	end := time.Now().Add(time.Minute).Format(time.RFC3339)
+	code, stats := fetchReleaseStats(t, server, testData, fmt.Sprintf("branch=%s&end=%s", branch, end))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1, stats.Result.Deployments)
+	assert.Equal(t, 1, stats.Result.Cancellations)
+	assert.Equal(t, 1, stats.Result.LeadTime.Count)
+	assert.Equal(t, 0.5, stats.Result.ChangeFailureRate)
+
+	code, _ = fetchReleaseStats(t, server, testData, "start=2020-02-01&end=2020-01-01")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = fetchReleaseStats(t, server, testData, "start=2018-01-01&end=2020-01-01")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
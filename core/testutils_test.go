@@ -35,7 +35,7 @@ func setup(t *testing.T) (*mux.Router, *TestData) {
 		user, err := dataClient.ReadOrCreateUser("robot", "robot@example.com")
 		assert.NoError(t, err)
 
-		err = dataClient.WriteToken("robot", user.Name, user.Email, "", "")
+		err = dataClient.WriteToken("robot", user.Name, user.Email, "", nil)
 		assert.NoError(t, err)
 
 		robotCreated = true
@@ -56,7 +56,7 @@ func setup(t *testing.T) (*mux.Router, *TestData) {
 	err = dataClient.SetOptions(&types.DefaultOptions)
 	assert.NoError(t, err)
 
-	err = dataClient.WriteToken(tokenVal, user.Name, user.Email, "", "")
+	err = dataClient.WriteToken(tokenVal, user.Name, user.Email, "", nil)
 	assert.NoError(t, err)
 	train, err := dataClient.CreateTrain("test_train", user, commits)
 	assert.NoError(t, err)
@@ -64,7 +64,7 @@ func setup(t *testing.T) (*mux.Router, *TestData) {
 	return conductorServer, &TestData{
 		Train:       train,
 		User:        user,
-		TokenCookie: auth.NewCookie(tokenVal),
+		TokenCookie: &http.Cookie{Name: auth.GetCookieName(), Value: tokenVal},
 	}
 }
 
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
@@ -11,8 +12,8 @@ import (
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
-	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
@@ -20,7 +21,10 @@ var ticketModificationLock sync.Mutex
 
 func ticketEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/ticket/open", get, openTicketsEndpoint),
+		newEp("/api/ticket/open", get, openTicketsEndpoint).
+			scoped(types.TrainRead).
+			describe("Get the tickets of the latest train.").
+			returns([]*types.Ticket{}),
 	}
 }
 
@@ -39,6 +43,7 @@ func openTicketsEndpoint(_ *http.Request) response {
 // Synchronize train's local ticket state
 // with remote ticket service state.
 func syncTickets(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -61,7 +66,7 @@ func syncTickets(
 		return
 	}
 
-	newTickets, updatedTickets, err := ticketService.SyncTickets(latestTrain)
+	newTickets, updatedTickets, err := ticketService.SyncTickets(ctx, latestTrain)
 	err = dataClient.WriteTickets(newTickets)
 	if err != nil {
 		logger.Error("Error writing tickets: %v", err)
@@ -74,7 +79,7 @@ func syncTickets(
 	}
 
 	if len(newTickets) > 0 {
-		datadog.Count("ticket.count", len(newTickets), latestTrain.DatadogTags())
+		metrics.Count("ticket.count", len(newTickets), latestTrain.DatadogTags())
 	}
 	for _, updatedTicket := range updatedTickets {
 		if updatedTicket.ClosedAt.HasValue() || updatedTicket.DeletedAt.HasValue() {
@@ -87,14 +92,14 @@ func syncTickets(
 			duration := finished.Sub(updatedTicket.CreatedAt.Value)
 			tags := latestTrain.DatadogTags()
 			tags = append(tags, fmt.Sprintf("ticket_user:%s", updatedTicket.AssigneeEmail))
-			datadog.Gauge("ticket.duration", duration.Seconds(), tags)
+			metrics.Histogram("ticket.duration", duration.Seconds(), tags)
 		}
 	}
 
 	switch latestTrain.ActivePhase {
 	case types.Verification:
 		checkPhaseCompletion(
-			dataClient, codeService, messagingService, phaseService, ticketService,
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 			latestTrain.ActivePhases.Verification)
 	case types.Deploy:
 		if latestTrain.ActivePhases.Deploy.StartedAt.HasValue() {
@@ -107,7 +112,7 @@ func syncTickets(
 			return
 		}
 		checkPhaseCompletion(
-			dataClient, codeService, messagingService, phaseService, ticketService,
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 			latestTrain.ActivePhases.Verification)
 	}
 }
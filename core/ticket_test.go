@@ -3,6 +3,7 @@
 package core
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,17 +48,17 @@ func TestSyncTickets(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Create some tickets in DB and ticket service.
-	newTickets, err := ticketService.CreateTickets(train, testCommits)
+	newTickets, err := ticketService.CreateTickets(context.Background(), train, testCommits)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 2)
 	dataClient.WriteTickets(newTickets)
 
 	// Close them only in the remote ticket service.
-	err = ticketService.CloseTickets(newTickets)
+	err = ticketService.CloseTickets(context.Background(), newTickets)
 	assert.NoError(t, err)
 
 	// Rely on syncTickets to update the database state from ticket service.
-	syncTickets(dataClient, codeService, messagingService, phaseService, ticketService)
+	syncTickets(context.Background(), dataClient, codeService, messagingService, phaseService, ticketService)
 
 	latestTrain, err := dataClient.LatestTrain()
 	assert.NoError(t, err)
@@ -65,6 +66,6 @@ func TestSyncTickets(t *testing.T) {
 	assert.True(t, latestTrain.Tickets[0].ClosedAt.HasValue())
 
 	// Clean up
-	err = ticketService.DeleteTickets(train)
+	err = ticketService.DeleteTickets(context.Background(), train)
 	assert.NoError(t, err)
 }
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Nextdoor/conductor/shared/tracing"
+)
+
+func newTracingMiddleware() tracingMiddleware {
+	return tracingMiddleware{}
+}
+
+type tracingMiddleware struct{}
+
+// Records the status code written by the handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Names spans by route, so requests for different trains share a name.
+func spanName(r *http.Request) string {
+	path := r.URL.Path
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			path = template
+		}
+	}
+	// Drop the response format suffix added by endpoint.Route.
+	if i := strings.Index(path, "{format:"); i >= 0 {
+		path = path[:i]
+	}
+	return fmt.Sprintf("%s %s", r.Method, path)
+}
+
+// Continues the caller's trace if it sent a traceparent header.
+// Jobs reporting back to Conductor can send the TRACEPARENT they were triggered with.
+func (_ tracingMiddleware) Wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.StartRemote(r.Context(), spanName(r), r.Header.Get(tracing.TraceParentHeader))
+		defer span.End()
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", strconv.Itoa(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("%s", http.StatusText(recorder.status)))
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/shared/tracing"
+)
+
+// Test that requests join the caller's trace.
+func TestTracingMiddleware(t *testing.T) {
+	parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	var span *tracing.Span
+	var name string
+	handler := func(r *http.Request) response {
+		span = tracing.FromContext(r.Context())
+		name = spanName(r)
+		return dataResponse("traced")
+	}
+	server := NewServer([]endpoint{newOpenEp("/test-trace/{id}", get, handler)})
+
+	req, err := http.NewRequest("GET", "/test-trace/5", nil)
+	assert.NoError(t, err)
+	req.Header.Set(tracing.TraceParentHeader, parent)
+	res := httptest.NewRecorder()
+	server.ServeHTTP(res, req)
+
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, span)
+	parentContext, err := tracing.ParseTraceParent(parent)
+	assert.NoError(t, err)
+	assert.Equal(t, parentContext.TraceID, span.Context().TraceID)
+	assert.NotEqual(t, parentContext.SpanID, span.Context().SpanID)
+	assert.Equal(t, "GET /test-trace/{id}", name)
+}
@@ -1,10 +1,12 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,15 +18,17 @@ import (
 	"github.com/Nextdoor/conductor/services/messaging"
 	"github.com/Nextdoor/conductor/services/phase"
 	"github.com/Nextdoor/conductor/services/ticket"
-	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/metrics"
 	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/tracing"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 var checkBranchLock sync.Mutex
 
 func checkBranch(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -32,6 +36,10 @@ func checkBranch(
 	ticketService ticket.Service,
 	branch string,
 	requester *types.User) {
+	ctx, span := tracing.Start(ctx, "checkBranch")
+	defer span.End()
+	span.SetAttribute("branch", branch)
+
 	checkBranchLock.Lock()
 	defer checkBranchLock.Unlock()
 
@@ -46,16 +54,17 @@ func checkBranch(
 		return
 	}
 
-	commits, err := getNewCommitsForBranch(codeService, branch, latestTrain, latestTrainForBranch)
+	commits, err := getNewCommitsForBranch(ctx, codeService, branch, latestTrain, latestTrainForBranch)
 	if err != nil {
 		return
 	}
 	handleNewCommitsForBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 		branch, latestTrain, latestTrainForBranch, commits, requester)
 }
 
 func getNewCommitsForBranch(
+	ctx context.Context,
 	codeService code.Service,
 	branch string,
 	latestTrain *types.Train,
@@ -65,20 +74,20 @@ func getNewCommitsForBranch(
 	var err error
 	if latestTrain == nil {
 		// This is the first train. Get 20 commits on the branch.
-		commits, err = codeService.CommitsOnBranch(branch, 20)
+		commits, err = codeService.CommitsOnBranch(ctx, branch, 20)
 		if err != nil {
 			logger.Error("Error getting commits on branch: %v", err)
 			return commits, err
 		}
 	} else if latestTrainForBranch == nil {
 		// Compare the latest train to the new train.
-		commits, err = codeService.CompareRefs(latestTrain.HeadSHA, branch)
+		commits, err = codeService.CompareRefs(ctx, latestTrain.HeadSHA, branch)
 		if err != nil {
 			logger.Error("Error comparing branches: %v", err)
 			return commits, err
 		}
 	} else {
-		commits, err = codeService.CommitsOnBranchAfter(branch, latestTrainForBranch.HeadSHA)
+		commits, err = codeService.CommitsOnBranchAfter(ctx, branch, latestTrainForBranch.HeadSHA)
 		if err != nil {
 			logger.Error("Error getting new commits on branch: %v", err)
 			return commits, err
@@ -88,6 +97,7 @@ func getNewCommitsForBranch(
 }
 
 func handleNewCommitsForBranch(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -99,6 +109,21 @@ func handleNewCommitsForBranch(
 	newCommits []*types.Commit,
 	requester *types.User) {
 
+	if latestTrain != nil && latestTrain.IsExpedited() && latestTrain.Branch != branch {
+		if !latestTrain.Done {
+			// Hold the commits until the hotfix is done.
+			QueueCommits(dataClient, newCommits)
+			return
+		}
+		if latestTrain.PreemptedID != nil && latestTrainForBranch != nil &&
+			latestTrainForBranch.ID == *latestTrain.PreemptedID {
+			rebasePreemptedTrain(
+				ctx, dataClient, codeService, messagingService, phaseService, ticketService,
+				latestTrain, latestTrainForBranch, newCommits)
+			return
+		}
+	}
+
 	if len(newCommits) == 0 {
 		return
 	}
@@ -106,36 +131,37 @@ func handleNewCommitsForBranch(
 	if latestTrain == nil || latestTrainForBranch == nil || latestTrain.IsDeploying() || latestTrain.Done {
 		if latestTrain != nil {
 			// Clean up old train.
-			err := ticketService.CloseTrainTickets(latestTrain)
+			err := ticketService.CloseTrainTickets(ctx, latestTrain)
 			if err != nil {
 				logger.Error("Error closing old train tickets: %v", err)
 			}
 		}
-		train = CreateTrain(dataClient, messagingService, branch, newCommits)
+		train = CreateTrain(ctx, dataClient, messagingService, branch, newCommits)
 	} else if latestTrainForBranch.ID == latestTrain.ID {
 		// The latest train is for this branch.
 		if !latestTrain.Closed {
-			ExtendTrain(dataClient, messagingService, train, newCommits, requester)
+			ExtendTrain(ctx, dataClient, messagingService, train, newCommits, requester)
 		} else {
 			QueueCommits(dataClient, newCommits)
 			return
 		}
 	} else {
 		// Clean up old train.
-		err := ticketService.CloseTrainTickets(train)
+		err := ticketService.CloseTrainTickets(ctx, train)
 		if err != nil {
 			logger.Error("Error closing old train tickets: %v", err)
 		}
 		// The latest train for the branch can be repurposed.
-		train = DuplicateTrain(dataClient, messagingService, train, newCommits)
+		train = DuplicateTrain(ctx, dataClient, messagingService, train, newCommits)
 	}
 
 	if train != nil {
-		go StartTrain(data.NewClient(), codeService, messagingService, phaseService, ticketService, train)
+		go StartTrain(tracing.Detach(ctx), data.NewClient(), codeService, messagingService, phaseService, ticketService, train)
 	}
 }
 
 func CreateTrain(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	branch string,
@@ -154,8 +180,10 @@ func CreateTrain(
 
 	train.SendCommitCountMetrics()
 
-	datadog.Incr("train.create", train.DatadogTags())
-	messagingService.TrainCreation(train, commits)
+	auditTrain(dataClient, nil, types.TrainCreateAction, train, nil, "")
+
+	metrics.Incr("train.create", train.DatadogTags())
+	messagingService.TrainCreation(ctx, train, commits)
 
 	clearLatestTrainCache()
 
@@ -163,6 +191,7 @@ func CreateTrain(
 }
 
 func ExtendTrain(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	train *types.Train,
@@ -179,21 +208,26 @@ func ExtendTrain(
 		}
 	}
 
+	before := newTrainAuditState(train)
 	err = dataClient.ExtendTrain(train, engineer, commits)
 	if err != nil {
 		logger.Error("Error extending train: %v", err)
 		return
 	}
 
-	datadog.Incr("train.extend", train.DatadogTags())
+	auditTrain(dataClient, requester, types.TrainExtendAction, train, before,
+		fmt.Sprintf("%d new commits", len(commits)))
+
+	metrics.Incr("train.extend", train.DatadogTags())
 	train.SendCommitCountMetrics()
 
-	messagingService.TrainExtension(train, commits, requester)
+	messagingService.TrainExtension(ctx, train, commits, requester)
 
 	clearLatestTrainCache()
 }
 
 func DuplicateTrain(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	oldTrain *types.Train,
@@ -205,10 +239,13 @@ func DuplicateTrain(
 		return nil
 	}
 
-	datadog.Incr("train.duplicate", train.DatadogTags())
+	auditTrain(dataClient, nil, types.TrainCreateAction, train, nil,
+		fmt.Sprintf("Duplicate of train %d", oldTrain.ID))
+
+	metrics.Incr("train.duplicate", train.DatadogTags())
 	train.SendCommitCountMetrics()
 
-	messagingService.TrainDuplication(train, oldTrain, commits)
+	messagingService.TrainDuplication(ctx, train, oldTrain, commits)
 
 	clearLatestTrainCache()
 
@@ -228,6 +265,7 @@ func QueueCommits(dataClient data.Client, commits []*types.Commit) {
 }
 
 func StartTrain(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -235,7 +273,8 @@ func StartTrain(
 	ticketService ticket.Service,
 	train *types.Train) {
 
-	startPhase(dataClient, codeService, messagingService, phaseService, ticketService, train.ActivePhases.Delivery, nil)
+	startPhase(ctx, dataClient, codeService, messagingService, phaseService, ticketService,
+		train.ActivePhases.Phases()[0], nil)
 }
 
 func chooseEngineer(dataClient data.Client, commits []*types.Commit) (*types.User, error) {
@@ -262,18 +301,20 @@ func chooseEngineer(dataClient data.Client, commits []*types.Commit) (*types.Use
 }
 
 func deployIfReady(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	train *types.Train) {
 
 	if train.IsDeployable() {
-		deployTrain(dataClient, messagingService, train)
+		deployTrain(ctx, dataClient, messagingService, train)
 	}
 }
 
 var deployTrainLock sync.Mutex
 
 func deployTrain(
+	ctx context.Context,
 	dataClient data.Client,
 	messagingService messaging.Service,
 	train *types.Train) {
@@ -294,7 +335,7 @@ func deployTrain(
 		return
 	}
 
-	datadog.Incr("train.deploy", train.DatadogTags())
+	metrics.Incr("train.deploy", train.DatadogTags())
 
 	options, err := dataClient.Options()
 	if err != nil {
@@ -307,32 +348,70 @@ func deployTrain(
 			}
 		}
 		if inRegularHours {
-			datadog.Incr("train.deploy.regular_hours", train.DatadogTags())
+			metrics.Incr("train.deploy.regular_hours", train.DatadogTags())
 		} else {
-			datadog.Incr("train.deploy.after_hours", train.DatadogTags())
+			metrics.Incr("train.deploy.after_hours", train.DatadogTags())
 		}
 	}
 
-	messagingService.TrainDeploying()
+	messagingService.TrainDeploying(ctx)
 	codeService := code.GetService()
 	phaseService := phase.GetService()
 	ticketService := ticket.GetService()
-	startPhase(dataClient, codeService, messagingService, phaseService, ticketService,
+	startPhase(ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 		train.ActivePhases.Deploy, train.Engineer)
 }
 
 func trainEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/train", get, fetchTrain),
-		newEp("/api/train/{train_id:[0-9]+}", get, fetchTrain),
-		newEp("/api/train/{train_id:[0-9]+}/changeEngineer", post, changeEngineer),
-		newEp("/api/train/{train_id:[0-9]+}/close", post, closeTrain),
-		newEp("/api/train/{train_id:[0-9]+}/open", post, openTrain),
-		newEp("/api/train/{train_id:[0-9]+}/extend", post, extendTrain),
-		newEp("/api/train/{train_id:[0-9]+}/block", post, blockTrain),
-		newEp("/api/train/{train_id:[0-9]+}/unblock", post, unblockTrain),
-		newEp("/api/train/{train_id:[0-9]+}/cancel", post, cancelTrain),
-		newEp("/api/train/{train_id:[0-9]+}/rollback", post, rollbackTrain),
+		newEp("/api/train", get, fetchTrain).
+			scoped(types.TrainRead).
+			describe("Get the latest train.").
+			returns(&types.Train{}),
+		newEp("/api/train/{train_id:[0-9]+}", get, fetchTrain).
+			scoped(types.TrainRead).
+			describe("Get a train.").
+			returns(&types.Train{}),
+		newEp("/api/train/{train_id:[0-9]+}/changeEngineer", post, changeEngineer).
+			requires(types.Engineer).
+			describe("Make the logged in user the engineer of the train.").
+			returns(&types.Train{}),
+		newEp("/api/train/{train_id:[0-9]+}/close", post, closeTrain).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Close the train to new commits, overriding the schedule.").
+			returns(&trainClosedResult{}),
+		newEp("/api/train/{train_id:[0-9]+}/open", post, openTrain).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Open the train to new commits, overriding the schedule.").
+			returns(&trainClosedResult{}),
+		newEp("/api/train/{train_id:[0-9]+}/extend", post, extendTrain).
+			requires(types.Engineer).
+			scoped(types.TrainWrite).
+			describe("Add new commits on the branch to a closed train, keeping it closed.").
+			returns(nil),
+		newEp("/api/train/{train_id:[0-9]+}/block", post, blockTrain).
+			requires(types.Committer).
+			scoped(types.TrainWrite).
+			describe("Block the train from deploying.").
+			form("reason", "Why the train is blocked, shown on the train and in the audit log.").
+			returns(nil),
+		newEp("/api/train/{train_id:[0-9]+}/unblock", post, unblockTrain).
+			requires(types.Committer).
+			scoped(types.TrainWrite).
+			describe("Unblock the train.").
+			returns(nil),
+		newEp("/api/train/{train_id:[0-9]+}/cancel", post, cancelTrain).
+			requires(types.ReleaseManager).orTrainEngineer().
+			scoped(types.TrainAdmin).
+			describe("Cancel the train.").
+			returns(nil),
+		newEp("/api/train/{train_id:[0-9]+}/rollback", post, rollbackTrain).
+			requires(types.ReleaseManager).
+			scoped(types.TrainAdmin).
+			describe("Roll production back to a deployed train that production was built on.").
+			returns(&types.Rollback{}),
 	}
 }
 
@@ -403,7 +482,7 @@ func clearLatestTrainCache() {
 }
 
 func validateMutableTrain(train *types.Train) *response {
-	if train.NextID != nil {
+	if train.IsSuperseded() {
 		resp := errorResponse(
 			fmt.Sprintf("Train %d is not the latest train.", train.ID),
 			http.StatusBadRequest)
@@ -464,6 +543,7 @@ func changeEngineer(r *http.Request) response {
 
 	loggedUser := r.Context().Value("user").(*types.User)
 
+	before := newTrainAuditState(train)
 	err := dataClient.ChangeTrainEngineer(train, loggedUser)
 	if err != nil {
 		return errorResponse(
@@ -471,12 +551,19 @@ func changeEngineer(r *http.Request) response {
 			http.StatusInternalServerError)
 	}
 
+	auditTrain(dataClient, loggedUser, types.TrainEngineerChangeAction, train, before, "")
+
 	messagingService := messaging.GetService()
-	messagingService.EngineerChanged(train, loggedUser)
+	messagingService.EngineerChanged(r.Context(), train, loggedUser)
 
 	return dataResponse(train)
 }
 
+type trainClosedResult struct {
+	Closed           bool `json:"closed"`
+	ScheduleOverride bool `json:"schedule_override"`
+}
+
 func closeTrain(r *http.Request) response {
 	trainCloseModificationLock.Lock()
 	defer trainCloseModificationLock.Unlock()
@@ -497,6 +584,7 @@ func closeTrain(r *http.Request) response {
 		return errorResponse("Train already closed.", http.StatusBadRequest)
 	}
 
+	before := newTrainAuditState(train)
 	err := dataClient.CloseTrain(train, true)
 	if err != nil {
 		return errorResponse(
@@ -505,20 +593,18 @@ func closeTrain(r *http.Request) response {
 	}
 
 	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainCloseAction, train, before, "")
 
 	messagingService := messaging.GetService()
-	messagingService.TrainClosed(train, authedUser)
+	messagingService.TrainClosed(r.Context(), train, authedUser)
 
-	deployIfReady(dataClient, messagingService, train)
+	deployIfReady(r.Context(), dataClient, messagingService, train)
 
 	clearLatestTrainCache()
 
-	return dataResponse(struct {
-		Closed           bool `json:"closed"`
-		ScheduleOverride bool `json:"schedule_override"`
-	}{
-		true,
-		true,
+	return dataResponse(&trainClosedResult{
+		Closed:           true,
+		ScheduleOverride: true,
 	})
 }
 
@@ -542,6 +628,7 @@ func openTrain(r *http.Request) response {
 		return errorResponse("Train already opened.", http.StatusBadRequest)
 	}
 
+	before := newTrainAuditState(train)
 	err := dataClient.OpenTrain(train, true)
 	if err != nil {
 		return errorResponse(
@@ -550,9 +637,10 @@ func openTrain(r *http.Request) response {
 	}
 
 	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainOpenAction, train, before, "")
 
 	messagingService := messaging.GetService()
-	messagingService.TrainOpened(train, authedUser)
+	messagingService.TrainOpened(r.Context(), train, authedUser)
 
 	codeService := code.GetService()
 	phaseService := phase.GetService()
@@ -560,17 +648,14 @@ func openTrain(r *http.Request) response {
 	// Check the branch for any new commits, but don't pass requester
 	// because that information is contained in the opened message.
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		r.Context(), dataClient, codeService, messagingService, phaseService, ticketService,
 		train.Branch, nil)
 
 	clearLatestTrainCache()
 
-	return dataResponse(struct {
-		Closed           bool `json:"closed"`
-		ScheduleOverride bool `json:"schedule_override"`
-	}{
-		false,
-		true,
+	return dataResponse(&trainClosedResult{
+		Closed:           false,
+		ScheduleOverride: true,
 	})
 }
 
@@ -590,34 +675,35 @@ func extendTrain(r *http.Request) response {
 		return *resp
 	}
 
+	authedUser := r.Context().Value("user").(*types.User)
+
+	err := extendClosedTrain(r.Context(), dataClient, train, authedUser)
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusInternalServerError)
+	}
+
+	clearLatestTrainCache()
+
+	return emptyResponse()
+}
+
+// Adds the new commits on the branch to the train, and leaves it closed.
+func extendClosedTrain(ctx context.Context, dataClient data.Client, train *types.Train, user *types.User) error {
 	scheduleOverride := train.ScheduleOverride
 	err := dataClient.OpenTrain(train, scheduleOverride)
 	if err != nil {
-		return errorResponse(
-			fmt.Sprintf("Error opening train: %v", err),
-			http.StatusInternalServerError)
+		return fmt.Errorf("Error opening train: %v", err)
 	}
 
-	authedUser := r.Context().Value("user").(*types.User)
-
-	codeService := code.GetService()
-	messagingService := messaging.GetService()
-	phaseService := phase.GetService()
-	ticketService := ticket.GetService()
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
-		train.Branch, authedUser)
+		ctx, dataClient, code.GetService(), messaging.GetService(), phase.GetService(), ticket.GetService(),
+		train.Branch, user)
 
 	err = dataClient.CloseTrain(train, scheduleOverride)
 	if err != nil {
-		return errorResponse(
-			fmt.Sprintf("Error closing train: %v", err),
-			http.StatusInternalServerError)
+		return fmt.Errorf("Error closing train: %v", err)
 	}
-
-	clearLatestTrainCache()
-
-	return emptyResponse()
+	return nil
 }
 
 func blockTrain(r *http.Request) response {
@@ -639,19 +725,27 @@ func blockTrain(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	err := dataClient.BlockTrain(train, nil)
+	var blockedReason *string
+	reason := strings.TrimSpace(r.PostFormValue("reason"))
+	if reason != "" {
+		blockedReason = &reason
+	}
+
+	before := newTrainAuditState(train)
+	err := dataClient.BlockTrain(train, blockedReason)
 	if err != nil {
 		return errorResponse(
 			fmt.Sprintf("Error blocking train: %v", err),
 			http.StatusInternalServerError)
 	}
 
-	datadog.Incr("train.block", train.DatadogTags())
+	metrics.Incr("train.block", train.DatadogTags())
 
 	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainBlockAction, train, before, reason)
 
 	messagingService := messaging.GetService()
-	messagingService.TrainBlocked(train, authedUser)
+	messagingService.TrainBlocked(r.Context(), train, authedUser)
 
 	clearLatestTrainCache()
 
@@ -677,8 +771,9 @@ func unblockTrain(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	datadog.Incr("train.unblock", train.DatadogTags())
+	metrics.Incr("train.unblock", train.DatadogTags())
 
+	before := newTrainAuditState(train)
 	err := dataClient.UnblockTrain(train)
 	if err != nil {
 		return errorResponse(
@@ -687,11 +782,12 @@ func unblockTrain(r *http.Request) response {
 	}
 
 	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainUnblockAction, train, before, "")
 
 	messagingService := messaging.GetService()
-	messagingService.TrainUnblocked(train, authedUser)
+	messagingService.TrainUnblocked(r.Context(), train, authedUser)
 
-	deployIfReady(dataClient, messagingService, train)
+	deployIfReady(r.Context(), dataClient, messagingService, train)
 
 	clearLatestTrainCache()
 
@@ -712,6 +808,7 @@ func cancelTrain(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
+	before := newTrainAuditState(train)
 	err := dataClient.CancelTrain(train)
 	if err != nil {
 		return errorResponse(
@@ -719,10 +816,13 @@ func cancelTrain(r *http.Request) response {
 			http.StatusInternalServerError)
 	}
 
-	datadog.Incr("train.cancel", train.DatadogTags())
+	authedUser := r.Context().Value("user").(*types.User)
+	auditTrain(dataClient, authedUser, types.TrainCancelAction, train, before, "")
+
+	metrics.Incr("train.cancel", train.DatadogTags())
 
 	duration := train.CancelledAt.Value.Sub(train.CreatedAt.Value)
-	datadog.Gauge("train.cancel.lifetime.all_hours", duration.Seconds(), train.DatadogTags())
+	metrics.Histogram("train.cancel.lifetime.all_hours", duration.Seconds(), train.DatadogTags())
 
 	options, err := dataClient.Options()
 	if err != nil {
@@ -731,14 +831,20 @@ func cancelTrain(r *http.Request) response {
 		regularHoursDuration := options.CloseTimeOverlap(train.CreatedAt.Value, train.DeployedAt.Value)
 		afterHoursDuration := duration - regularHoursDuration
 
-		datadog.Gauge("train.cancel.lifetime.regular_hours", regularHoursDuration.Seconds(), train.DatadogTags())
-		datadog.Gauge("train.cancel.lifetime.after_hours", afterHoursDuration.Seconds(), train.DatadogTags())
+		metrics.Histogram("train.cancel.lifetime.regular_hours", regularHoursDuration.Seconds(), train.DatadogTags())
+		metrics.Histogram("train.cancel.lifetime.after_hours", afterHoursDuration.Seconds(), train.DatadogTags())
 	}
 
-	authedUser := r.Context().Value("user").(*types.User)
-
 	messagingService := messaging.GetService()
-	messagingService.TrainCancelled(train, authedUser)
+	messagingService.TrainCancelled(r.Context(), train, authedUser)
+
+	if train.PreemptedID != nil {
+		go resumePreemptedTrain(tracing.Detach(r.Context()), data.NewClient(), code.GetService(), messagingService,
+			phase.GetService(), ticket.GetService(), train)
+	}
+	// A train split off this one would otherwise wait for it to deploy.
+	startSplitTrain(r.Context(), dataClient, code.GetService(), messagingService,
+		phase.GetService(), ticket.GetService(), train)
 
 	params := make(map[string]string)
 	params["TRAIN_ID"] = strconv.FormatUint(train.ID, 10)
@@ -748,9 +854,14 @@ func cancelTrain(r *http.Request) response {
 	params["BUILD_USER"] = authedUser.Name
 
 	// close all active deploy jobs in jenkins if train has been cancelled
-	for _, job := range train.ActivePhases.Deploy.Jobs {
-		if job.URL != nil {
-			build.Jenkins().CancelJob(job.Name, *job.URL, params)
+	for _, deployPhase := range train.ActivePhases.Phases() {
+		if deployPhase.Type != types.Deploy {
+			continue
+		}
+		for _, job := range deployPhase.Jobs {
+			if job.URL != nil {
+				build.Jenkins().CancelJob(r.Context(), job.Name, *job.URL, params)
+			}
 		}
 	}
 
@@ -764,7 +875,7 @@ func cancelTrain(r *http.Request) response {
 
 		// Now that this train is cancelled,
 		// check if the latest train can be deployed.
-		go deployIfReady(data.NewClient(), messagingService, latestTrain)
+		go deployIfReady(tracing.Detach(r.Context()), data.NewClient(), messagingService, latestTrain)
 	}
 
 	clearLatestTrainCache()
@@ -792,82 +903,151 @@ func rollbackTrain(r *http.Request) response {
 			http.StatusBadRequest)
 	}
 
-	datadog.Incr("train.rollback", train.DatadogTags())
+	productionTrain, err := dataClient.ProductionTrain()
+	if err != nil {
+		return errorResponse(
+			fmt.Sprintf("Error getting production train: %v", err),
+			http.StatusInternalServerError)
+	}
+	if productionTrain != nil {
+		resp = validateRollbackTarget(r.Context(), code.GetService(), train, productionTrain)
+		if resp != nil {
+			return *resp
+		}
+	}
 
 	authedUser := r.Context().Value("user").(*types.User)
 
-	messagingService := messaging.GetService()
-	messagingService.RollbackInitiated(train, authedUser)
+	rollback, err := rollbackToTrain(r.Context(), dataClient, messaging.GetService(), train, authedUser)
+	if err != nil {
+		return errorResponse(err.Error(), http.StatusInternalServerError)
+	}
+
+	return dataResponse(rollback)
+}
+
+// Triggers the rollback job to put train back in production,
+// and stops the trains after it so they don't deploy over it.
+// The user is nil for automatic rollbacks.
+func rollbackToTrain(
+	ctx context.Context,
+	dataClient data.Client,
+	messagingService messaging.Service,
+	train *types.Train,
+	user *types.User) (*types.Rollback, error) {
+
+	metrics.Incr("train.rollback", train.DatadogTags())
+
+	messagingService.RollbackInitiated(ctx, train, user)
+
+	buildUser := "Conductor"
+	blockedReason := "rollback"
+	if user != nil {
+		buildUser = user.Name
+		blockedReason = fmt.Sprintf("rollback by %s", user.Name)
+	}
 
 	params := make(map[string]string)
 	params["TRAIN_ID"] = strconv.FormatUint(train.ID, 10)
 	params["BRANCH"] = train.Branch
 	params["SHA"] = train.HeadSHA
 	params["CONDUCTOR_HOSTNAME"] = settings.GetHostname()
-	params["BUILD_USER"] = authedUser.Name
+	params["BUILD_USER"] = buildUser
 
 	latestTrain, err := dataClient.LatestTrain()
 	if err != nil {
-		return errorResponse(
-			fmt.Sprintf("Error getting latest train: %v", err),
-			http.StatusInternalServerError)
+		return nil, fmt.Errorf("Error getting latest train: %v", err)
 	}
 
+	rollbackReason := fmt.Sprintf("Rollback to train %d", train.ID)
+
 	// Cancel a deploying train, and block the latest non-deploying train.
 	if !latestTrain.Done {
+		before := newTrainAuditState(latestTrain)
 		if latestTrain.IsDeploying() {
 			err = dataClient.CancelTrain(latestTrain)
 			if err != nil {
-				return errorResponse(
-					fmt.Sprintf("Error cancelling latest train: %v", err),
-					http.StatusInternalServerError)
+				return nil, fmt.Errorf("Error cancelling latest train: %v", err)
 			}
+			auditTrain(dataClient, user, types.TrainCancelAction, latestTrain, before, rollbackReason)
 		} else if !latestTrain.Blocked {
-			blockedReason := fmt.Sprintf("rollback by %s", authedUser.Name)
 			err := dataClient.BlockTrain(latestTrain, &blockedReason)
 			if err != nil {
-				return errorResponse(
-					fmt.Sprintf("Error blocking latest train: %v", err),
-					http.StatusInternalServerError)
+				return nil, fmt.Errorf("Error blocking latest train: %v", err)
 			}
+			auditTrain(dataClient, user, types.TrainBlockAction, latestTrain, before, rollbackReason)
 
-			messagingService.TrainBlocked(latestTrain, nil)
+			messagingService.TrainBlocked(ctx, latestTrain, nil)
 		}
 	}
 
 	if !latestTrain.PreviousTrainDone {
 		previousTrain, err := dataClient.Train(*latestTrain.PreviousID)
 		if err != nil {
-			return errorResponse(
-				fmt.Sprintf("Error getting previous train: %v", err),
-				http.StatusInternalServerError)
+			return nil, fmt.Errorf("Error getting previous train: %v", err)
 		}
 
+		before := newTrainAuditState(previousTrain)
 		err = dataClient.CancelTrain(previousTrain)
 		if err != nil {
-			return errorResponse(
-				fmt.Sprintf("Error cancelling previous train: %v", err),
-				http.StatusInternalServerError)
+			return nil, fmt.Errorf("Error cancelling previous train: %v", err)
 		}
+		auditTrain(dataClient, user, types.TrainCancelAction, previousTrain, before, rollbackReason)
 
-		messagingService.TrainCancelled(previousTrain, nil)
+		messagingService.TrainCancelled(ctx, previousTrain, nil)
 	}
 
-	messagingService.RollbackInfo(authedUser)
+	messagingService.RollbackInfo(ctx, user)
 
-	err = build.Jenkins().TriggerJob(settings.GetJenkinsRollbackJob(), params)
+	rollback, err := dataClient.CreateRollback(train, user)
 	if err != nil {
-		return errorResponse(
-			fmt.Sprintf("Error triggering rollback job: %v", err),
-			http.StatusInternalServerError)
+		return nil, fmt.Errorf("Error recording rollback: %v", err)
 	}
+	params["ROLLBACK_ID"] = strconv.FormatUint(rollback.ID, 10)
+	// The job starts and completes the rollback job in this phase, like phase jobs do.
+	params["PHASE_ID"] = strconv.FormatUint(rollback.Phase.ID, 10)
+
+	err = build.Jenkins().TriggerJob(ctx, settings.GetJenkinsRollbackJob(), params)
+	if err != nil {
+		statusErr := dataClient.SetRollbackStatus(rollback, types.RollbackFailed, err.Error())
+		if statusErr != nil {
+			logger.Error("Error failing rollback: %v", statusErr)
+		}
+		return nil, fmt.Errorf("Error triggering rollback job: %v", err)
+	}
+
+	// Production goes from the last deployed train to this one.
+	var before interface{}
+	if rollback.FromTrain != nil {
+		before = rollbackAuditState(rollback.FromTrain)
+	}
+	audit(dataClient, user, types.TrainRollbackAction, "train", strconv.FormatUint(train.ID, 10),
+		before, rollbackAuditState(train), "")
 
 	clearLatestTrainCache()
 
-	return emptyResponse()
+	return rollback, nil
+}
+
+// Deploys the next train to deploy if it was only waiting for a freeze to end, for a deploy slot,
+// or for a commit's deploy-after time to pass.
+// That's the oldest undeployed train, which isn't the latest train after a split.
+func deployHeldTrain(ctx context.Context, dataClient data.Client, messagingService messaging.Service) {
+	train, err := dataClient.OldestUndeployedTrain()
+	if err != nil {
+		logger.Error("Error getting oldest undeployed train: %v", err)
+		return
+	}
+
+	if train == nil {
+		return
+	}
+
+	deployIfReady(ctx, dataClient, messagingService, train)
 }
 
 func checkTrainLock(
+	ctx context.Context,
 	dataClient data.Client,
 	codeService code.Service,
 	messagingService messaging.Service,
@@ -893,12 +1073,12 @@ func checkTrainLock(
 		return
 	}
 
-	mode, err := dataClient.Mode()
+	config, err := dataClient.Config()
 	if err != nil {
-		logger.Error("Error getting mode: %v", err)
+		logger.Error("Error getting config: %v", err)
 		return
 	}
-	if mode.IsManualMode() {
+	if config.Options.BranchMode(latestTrain.Branch, config.Mode).IsManualMode() {
 		return
 	}
 
@@ -907,6 +1087,7 @@ func checkTrainLock(
 		logger.Error("Error getting IsTrainCloseable: %v", err)
 		return
 	}
+	before := newTrainAuditState(latestTrain)
 	if closeable && !latestTrain.Closed {
 		err = dataClient.CloseTrain(latestTrain, false)
 		if err != nil {
@@ -914,9 +1095,11 @@ func checkTrainLock(
 			return
 		}
 
-		deployIfReady(dataClient, messagingService, latestTrain)
+		auditTrain(dataClient, nil, types.TrainCloseAction, latestTrain, before, "Schedule")
+
+		deployIfReady(ctx, dataClient, messagingService, latestTrain)
 
-		messagingService.TrainClosed(latestTrain, nil)
+		messagingService.TrainClosed(ctx, latestTrain, nil)
 
 		clearLatestTrainCache()
 	} else if !closeable && latestTrain.Closed {
@@ -926,10 +1109,12 @@ func checkTrainLock(
 			return
 		}
 
-		messagingService.TrainOpened(latestTrain, nil)
+		auditTrain(dataClient, nil, types.TrainOpenAction, latestTrain, before, "Schedule")
+
+		messagingService.TrainOpened(ctx, latestTrain, nil)
 
 		checkBranch(
-			dataClient, codeService, messagingService, phaseService, ticketService,
+			ctx, dataClient, codeService, messagingService, phaseService, ticketService,
 			latestTrain.Branch, nil)
 
 		clearLatestTrainCache()
@@ -3,6 +3,7 @@
 package core
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -31,7 +32,7 @@ func TestCheckBranchNoNewCommits(t *testing.T) {
 	_, testData := setup(t)
 	initialHeadSHA := testData.Train.HeadSHA
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{}, nil
 		},
 	}
@@ -40,7 +41,7 @@ func TestCheckBranchNoNewCommits(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	ticketService := &ticket.TicketServiceMock{}
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 	train, _ := dataClient.Train(testData.Train.ID)
 	assert.Equal(t, initialHeadSHA, train.HeadSHA)
@@ -50,7 +51,7 @@ func TestCheckBranchNoNewCommits(t *testing.T) {
 func TestCheckBranchExtend(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -59,7 +60,7 @@ func TestCheckBranchExtend(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	ticketService := &ticket.TicketServiceMock{}
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 	train, _ := dataClient.Train(testData.Train.ID)
 	assert.Equal(t, newCommitSHA, train.HeadSHA)
@@ -68,7 +69,7 @@ func TestCheckBranchExtend(t *testing.T) {
 // Case when there's never been a train before.
 func TestCheckBranchFirstTrain(t *testing.T) {
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchMock: func(branch string, max int) ([]*types.Commit, error) {
+		CommitsOnBranchMock: func(ctx context.Context, branch string, max int) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -79,7 +80,7 @@ func TestCheckBranchFirstTrain(t *testing.T) {
 	user, err := dataClient.ReadOrCreateUser("test_user", "test_email")
 	assert.NoError(t, err)
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		"master", user)
 	train, _ := dataClient.LatestTrain()
 	assert.Equal(t, newCommitSHA, train.HeadSHA)
@@ -89,7 +90,7 @@ func TestCheckBranchFirstTrain(t *testing.T) {
 func TestCheckBranchFirstTrainOnBranch(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CompareRefsMock: func(headSHA string, branch string) ([]*types.Commit, error) {
+		CompareRefsMock: func(ctx context.Context, headSHA string, branch string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -98,7 +99,7 @@ func TestCheckBranchFirstTrainOnBranch(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	ticketService := &ticket.TicketServiceMock{}
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		"first_train_branch", testData.User)
 	train, _ := dataClient.LatestTrain()
 	// the commit on the new branch becomes a new train.
@@ -110,7 +111,7 @@ func TestCheckBranchFirstTrainOnBranch(t *testing.T) {
 func TestCheckBranchLatestTrainDeploying(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -121,7 +122,7 @@ func TestCheckBranchLatestTrainDeploying(t *testing.T) {
 	dataClient := data.NewClient()
 	dataClient.DeployTrain(testData.Train)
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 	train, _ := dataClient.LatestTrain()
 	assert.Equal(t, newCommitSHA, train.HeadSHA)
@@ -132,7 +133,7 @@ func TestCheckBranchLatestTrainDeploying(t *testing.T) {
 func TestCheckBranchLatestTrainDeployed(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -144,14 +145,14 @@ func TestCheckBranchLatestTrainDeployed(t *testing.T) {
 
 	closeTrainTicketsCalled := false
 	ticketService := &ticket.TicketServiceMock{
-		CloseTrainTicketsMock: func(train *types.Train) error {
+		CloseTrainTicketsMock: func(ctx context.Context, train *types.Train) error {
 			closeTrainTicketsCalled = true
 			return nil
 		},
 	}
 
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 	assert.Equal(t, true, closeTrainTicketsCalled)
 
@@ -164,7 +165,7 @@ func TestCheckBranchLatestTrainDeployed(t *testing.T) {
 func TestCheckBranchQueueCommits(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -174,7 +175,7 @@ func TestCheckBranchQueueCommits(t *testing.T) {
 	phaseService := &phase.PhaseServiceMock{}
 	ticketService := &ticket.TicketServiceMock{}
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 
 	// Current train head should be unchanged.
@@ -186,7 +187,7 @@ func TestCheckBranchQueueCommits(t *testing.T) {
 func TestCheckBranchDuplicateTrain(t *testing.T) {
 	_, testData := setup(t)
 	codeService := &code.CodeServiceMock{
-		CommitsOnBranchAfterMock: func(branch string, head string) ([]*types.Commit, error) {
+		CommitsOnBranchAfterMock: func(ctx context.Context, branch string, head string) ([]*types.Commit, error) {
 			return []*types.Commit{newCommit}, nil
 		},
 	}
@@ -205,14 +206,14 @@ func TestCheckBranchDuplicateTrain(t *testing.T) {
 
 	closeTrainTicketsCalled := false
 	ticketService := &ticket.TicketServiceMock{
-		CloseTrainTicketsMock: func(train *types.Train) error {
+		CloseTrainTicketsMock: func(ctx context.Context, train *types.Train) error {
 			closeTrainTicketsCalled = true
 			return nil
 		},
 	}
 
 	checkBranch(
-		dataClient, codeService, messagingService, phaseService, ticketService,
+		context.Background(), dataClient, codeService, messagingService, phaseService, ticketService,
 		testData.Train.Branch, testData.User)
 	assert.Equal(t, true, closeTrainTicketsCalled)
 
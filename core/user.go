@@ -1,13 +1,30 @@
 package core
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
 
 func userEndpoints() []endpoint {
 	return []endpoint{
-		newEp("/api/user", get, currentUser),
+		newEp("/api/user", get, currentUser).
+			scoped(types.TrainRead).
+			describe("Get the logged in user.").
+			returns(&currentUserResult{}),
 	}
 }
 
+// The logged in user, along with their role.
+type currentUserResult struct {
+	*types.User
+	Role types.Role `json:"role"`
+}
+
 func currentUser(r *http.Request) response {
-	return dataResponse(r.Context().Value("user"))
+	user := r.Context().Value("user").(*types.User)
+	return dataResponse(&currentUserResult{
+		User: user,
+		Role: user.Role,
+	})
 }
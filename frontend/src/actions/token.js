@@ -1,10 +1,6 @@
-import cookie from 'react-cookie';
-
 import Actions from 'types/actions';
 import API from 'api';
 
-const authCookieName = process.env.AUTH_COOKIE_NAME;
-
 const set = (token) => {
   return {
     type: Actions.SetToken,
@@ -18,13 +14,10 @@ const promptLogin = () => {
   };
 };
 
+// The auth cookie is HttpOnly, so it can't be read here.
+// Assume there's a session; unauthorized API responses prompt for login.
 const get = () => (dispatch) => {
-  const token = cookie.load(authCookieName);
-  if (token === undefined) {
-    return dispatch(promptLogin());
-  } else {
-    return dispatch(set(token));
-  }
+  return dispatch(set(true));
 };
 
 const del = () => {
@@ -65,7 +65,7 @@ class Search extends React.Component {
   getComponent() {
     const {details, params} = this.props;
     const trains = [];
-    details.results.forEach(function(train) {
+    details.results.trains.forEach(function(train) {
       trains.push(<TrainLink key={train.id} id={train.id}/>);
     });
 
@@ -120,5 +120,8 @@ export const requestProps = PropTypes.shape({
 
 export const searchProps = PropTypes.shape({
   params: PropTypes.shape().isRequired,
-  results: PropTypes.arrayOf(PropTypes.shape()).isRequired,
+  results: PropTypes.shape({
+    trains: PropTypes.arrayOf(PropTypes.shape()).isRequired,
+    commits: PropTypes.arrayOf(PropTypes.shape()).isRequired,
+  }).isRequired,
 });
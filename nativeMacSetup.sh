@@ -54,9 +54,8 @@ function deploy_frontend {
     make prod-compile -C frontend
     cp -R resources/ $HOME/app
 
-    echo -e "${PINK} Generating index.html from swagger specs..${NC}"
+    echo -e "${PINK} Copying swagger docs..${NC}"
     cp -R swagger/ $HOME/app/swagger
-    pretty-swag -c $HOME/app/swagger/config.json
 
 }
 
@@ -79,13 +78,12 @@ function deploy_backend {
 
 }
 
-echo -e "${PINK} Checking install of yarn, node, nginx server and swagger..${NC}"
+echo -e "${PINK} Checking install of yarn, node and nginx server..${NC}"
 node -v || echo -e "${RED}ERROR: Please install node using installer: https://nodejs.org/en/download/ ${NC}"
 npm -v || echo -e "${RED}ERROR: Please install node using installer: https://nodejs.org/en/download/ ${NC}"
 nginx -v || echo -e "${PINK}INFO: Intalling nginx ${NC}"
 nginx -v || brew install nginx
 yarn -v || npm install -g yarn;
-npm install -g pretty-swag@0.1.144;
 
 if [ "$1" == "--frontend" ] ; then
     # run only frontend deployment related scripts, assuming we already once had a full local mac install
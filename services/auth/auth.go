@@ -2,6 +2,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
@@ -16,12 +17,36 @@ var (
 
 	// This cookie name has to match the cookie name clients expect.
 	authCookieName = flags.EnvString("AUTH_COOKIE_NAME", "conductor-auth")
+
+	// Only send the auth cookie over HTTPS. Disable for local development over HTTP.
+	authCookieSecure = flags.EnvBool("AUTH_COOKIE_SECURE", true)
+
+	// Sessions expire after this many hours without a request, or this many hours after logging in.
+	sessionIdleHours = flags.EnvInt("SESSION_IDLE_HOURS", 7*24)
+	sessionMaxHours  = flags.EnvInt("SESSION_MAX_HOURS", 30*24)
 )
 
 type Service interface {
 	AuthProvider() string
-	AuthURL(hostname string) string
-	Login(code string) (name, email, avatar, codeToken string, err error)
+	// URL to send the browser to, to log in.
+	// state is passed back to the login endpoint, and verifier is the PKCE code verifier for the login.
+	AuthURL(hostname, state, verifier string) string
+	// Whether logins always start at AuthURL. If so, logins without its state are rejected,
+	// so nobody can be logged in with a code from someone else's login.
+	RequiresLoginState() bool
+	// Exchanges the code from the auth provider for the logged in user.
+	// verifier is empty if the login didn't start at the AuthURL.
+	// ctx carries the trace to continue in calls to the provider.
+	Login(ctx context.Context, hostname, code, verifier string) (*Identity, error)
+}
+
+// A logged in user, according to the auth provider.
+type Identity struct {
+	Name      string
+	Email     string
+	AvatarURL string
+	// Groups the user is a member of, if the provider has groups.
+	Groups []string
 }
 
 type auth struct{}
@@ -30,12 +55,43 @@ func GetCookieName() string {
 	return authCookieName
 }
 
-func NewCookie(token string) *http.Cookie {
-	return &http.Cookie{Name: GetCookieName(), Value: token, Path: "/"}
+func SessionIdleTimeout() time.Duration {
+	return time.Duration(sessionIdleHours) * time.Hour
+}
+
+func SessionMaxLifetime() time.Duration {
+	return time.Duration(sessionMaxHours) * time.Hour
+}
+
+// Creates the auth cookie for a session that expires at expiresAt.
+func NewCookie(token string, expiresAt time.Time) *http.Cookie {
+	maxAge := int(time.Until(expiresAt).Seconds())
+	if maxAge <= 0 {
+		// A MaxAge of 0 would mean a browser session cookie.
+		maxAge = -1
+	}
+	return &http.Cookie{
+		Name:     GetCookieName(),
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   authCookieSecure,
+		HttpOnly: true,
+		// Lax, so the cookie is sent when following links to Conductor.
+		SameSite: http.SameSiteLaxMode,
+	}
 }
 
 func EmptyCookie() *http.Cookie {
-	return &http.Cookie{Name: GetCookieName(), Value: "", Path: "/", Expires: time.Time{}}
+	return &http.Cookie{
+		Name:     GetCookieName(),
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   authCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
 }
 
 var (
@@ -58,6 +114,8 @@ func newService() Service {
 		service = newFake()
 	case "github":
 		service = newGithubAuth()
+	case "oidc":
+		service = newOIDCAuth()
 	default:
 		panic(fmt.Errorf("Unknown Auth Implementation: %s", implementationFlag))
 	}
@@ -78,13 +136,17 @@ func (a *fake) AuthProvider() string {
 	return ""
 }
 
-func (a *fake) AuthURL(hostname string) string {
+func (a *fake) AuthURL(hostname, state, verifier string) string {
 	return ""
 }
 
-func (a *fake) Login(code string) (name, email, avatar, codeToken string, err error) {
+func (a *fake) RequiresLoginState() bool {
+	return false
+}
+
+func (a *fake) Login(ctx context.Context, hostname, code, verifier string) (*Identity, error) {
 	// If a developer doesn't choose to do github setup in envfile,
 	// this should still allow going past the login page, without fetching
 	// github profile details and avatar
-	return "dev", "dev@conductor.com", "", "", nil
+	return &Identity{Name: "dev", Email: "dev@conductor.com"}, nil
 }
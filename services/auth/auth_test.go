@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCookie(t *testing.T) {
+	cookie := NewCookie("token", time.Now().Add(time.Hour))
+	assert.Equal(t, "token", cookie.Value)
+	assert.InDelta(t, 3600, cookie.MaxAge, 5)
+	assert.True(t, cookie.Secure)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	// An expired session clears the cookie, rather than making it last the browser session.
+	cookie = NewCookie("token", time.Now().Add(-time.Hour))
+	assert.Equal(t, -1, cookie.MaxAge)
+
+	cookie = EmptyCookie()
+	assert.Equal(t, "", cookie.Value)
+	assert.Equal(t, -1, cookie.MaxAge)
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -33,27 +34,37 @@ func (a *githubAuth) AuthProvider() string {
 	return "Github"
 }
 
-func (a *githubAuth) AuthURL(hostname string) string {
+func (a *githubAuth) AuthURL(hostname, state, verifier string) string {
 	req, _ := http.NewRequest("GET", a.authClient.AuthorizeURL(), nil)
 
 	q := req.URL.Query()
 	q.Add("client_id", githubAuthClientID)
 	q.Add("redirect_uri", redirectEndpoint(hostname))
 	q.Add("scope", "user repo")
+	q.Add("state", state)
 	req.URL.RawQuery = q.Encode()
 
 	return req.URL.String()
 }
 
-func (a *githubAuth) Login(code string) (string, string, string, string, error) {
-	accessToken, err := a.authClient.AccessToken(code)
+// Logins can start at GitHub, from the frontend's OAUTH_ENDPOINT.
+func (a *githubAuth) RequiresLoginState() bool {
+	return false
+}
+
+func (a *githubAuth) Login(ctx context.Context, hostname, code, verifier string) (*Identity, error) {
+	accessToken, err := a.authClient.AccessToken(ctx, code)
 	if err != nil {
-		return "", "", "", "", err
+		return nil, err
 	}
 
-	name, email, avatar, err := a.authClient.UserInfo(accessToken)
+	name, email, avatar, err := a.authClient.UserInfo(ctx, accessToken)
 	if err != nil {
-		return "", "", "", "", err
+		return nil, err
 	}
-	return name, email, avatar, accessToken, err
+	return &Identity{
+		Name:      name,
+		Email:     email,
+		AvatarURL: avatar,
+	}, nil
 }
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// How long a login has to finish, in seconds.
+const loginMaxAge = 10 * 60
+
+// Generates the state and PKCE code verifier for a new login.
+func NewLoginState() (state, verifier string, err error) {
+	state, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	return state, verifier, nil
+}
+
+func randomString() (string, error) {
+	bytes := make([]byte, 32)
+	_, err := rand.Read(bytes)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func loginCookieName() string {
+	return GetCookieName() + "-login"
+}
+
+// Keeps the state and verifier of a login in the browser, until the auth provider redirects back.
+func NewLoginCookie(state, verifier string) *http.Cookie {
+	return &http.Cookie{
+		Name:     loginCookieName(),
+		Value:    state + "." + verifier,
+		Path:     "/api/auth",
+		MaxAge:   loginMaxAge,
+		Secure:   authCookieSecure,
+		HttpOnly: true,
+		// Lax, so it's sent when the auth provider redirects back.
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func EmptyLoginCookie() *http.Cookie {
+	return &http.Cookie{Name: loginCookieName(), Value: "", Path: "/api/auth", MaxAge: -1}
+}
+
+// Returns the state and verifier from the login cookie, if there is one.
+func ParseLoginCookie(r *http.Request) (state, verifier string, ok bool) {
+	cookie, err := r.Cookie(loginCookieName())
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// The PKCE S256 code challenge for a verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
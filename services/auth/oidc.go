@@ -0,0 +1,534 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/tracing"
+)
+
+var (
+	// Issuer URL of the OpenID Connect provider, e.g. https://example.okta.com/oauth2/default.
+	oidcIssuer       = flags.EnvString("OIDC_ISSUER", "")
+	oidcClientID     = flags.EnvString("OIDC_CLIENT_ID", "")
+	oidcClientSecret = flags.EnvString("OIDC_CLIENT_SECRET", "")
+	// Defaults to https://$HOSTNAME/api/auth/login.
+	oidcRedirectURL = flags.EnvString("OIDC_REDIRECT_URL", "")
+	// Shown on the login button.
+	oidcProviderName = flags.EnvString("OIDC_PROVIDER_NAME", "SSO")
+	oidcScopes       = flags.EnvString("OIDC_SCOPES", "openid profile email")
+
+	// Claims to read the user from, from the ID token or the userinfo endpoint.
+	oidcNameClaim   = flags.EnvString("OIDC_NAME_CLAIM", "name")
+	oidcEmailClaim  = flags.EnvString("OIDC_EMAIL_CLAIM", "email")
+	oidcAvatarClaim = flags.EnvString("OIDC_AVATAR_CLAIM", "picture")
+	oidcGroupsClaim = flags.EnvString("OIDC_GROUPS_CLAIM", "groups")
+)
+
+const (
+	// Allowed clock difference with the provider when checking token expiry.
+	oidcClockSkew = time.Minute
+	// Minimum time between fetching signing keys, when a token has an unknown key.
+	oidcKeysRefreshInterval = time.Minute
+)
+
+type oidcAuth struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	providerName string
+	scopes       string
+
+	nameClaim   string
+	emailClaim  string
+	avatarClaim string
+	groupsClaim string
+
+	client *http.Client
+
+	// Fetched on first use, so the provider doesn't need to be up to start Conductor.
+	mutex         sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func newOIDCAuth() *oidcAuth {
+	if oidcIssuer == "" {
+		panic(errors.New("oidc_issuer flag must be set."))
+	}
+	if oidcClientID == "" {
+		panic(errors.New("oidc_client_id flag must be set."))
+	}
+	return newOIDC(oidcIssuer, oidcClientID, oidcClientSecret)
+}
+
+func newOIDC(issuer, clientID, clientSecret string) *oidcAuth {
+	return &oidcAuth{
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		redirectURL:  oidcRedirectURL,
+		providerName: oidcProviderName,
+		scopes:       oidcScopes,
+		nameClaim:    oidcNameClaim,
+		emailClaim:   oidcEmailClaim,
+		avatarClaim:  oidcAvatarClaim,
+		groupsClaim:  oidcGroupsClaim,
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: tracing.Transport(nil),
+		},
+	}
+}
+
+func (a *oidcAuth) AuthProvider() string {
+	return a.providerName
+}
+
+func (a *oidcAuth) loginURL(hostname string) string {
+	if a.redirectURL != "" {
+		return a.redirectURL
+	}
+	return fmt.Sprintf("https://%s/api/auth/login", hostname)
+}
+
+// The nonce is derived from the verifier, so it's tied to the same login.
+func oidcNonce(verifier string) string {
+	sum := sha256.Sum256([]byte("nonce:" + verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (a *oidcAuth) AuthURL(hostname, state, verifier string) string {
+	discovery, err := a.getDiscovery()
+	if err != nil {
+		logger.Error("Error discovering OIDC provider: %v", err)
+		return ""
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.clientID},
+		"redirect_uri":          {a.loginURL(hostname)},
+		"scope":                 {a.scopes},
+		"state":                 {state},
+		"nonce":                 {oidcNonce(verifier)},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// The token request needs the PKCE verifier from the login cookie.
+func (a *oidcAuth) RequiresLoginState() bool {
+	return true
+}
+
+func (a *oidcAuth) Login(ctx context.Context, hostname, code, verifier string) (*Identity, error) {
+	if verifier == "" {
+		return nil, errors.New("Login must start at /api/auth/start")
+	}
+	discovery, err := a.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {a.loginURL(hostname)},
+		"code_verifier": {verifier},
+	}
+	if a.clientSecret == "" {
+		form.Set("client_id", a.clientID)
+	}
+	req, err := http.NewRequest("POST", discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if a.clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.clientID), url.QueryEscape(a.clientSecret))
+	}
+	var tokens struct {
+		IDToken     string `json:"id_token"`
+		AccessToken string `json:"access_token"`
+	}
+	err = a.doJSON(req, &tokens)
+	if err != nil {
+		return nil, fmt.Errorf("Error exchanging code: %v", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("No ID token in token response")
+	}
+
+	claims, err := a.verifyIDToken(tokens.IDToken, oidcNonce(verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	// Providers can leave claims out of the ID token, and only return them from userinfo.
+	missing := claims[a.emailClaim] == nil || claims[a.nameClaim] == nil || claims[a.groupsClaim] == nil
+	if missing && discovery.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		err = a.mergeUserinfo(ctx, discovery.UserinfoEndpoint, tokens.AccessToken, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return a.identity(claims)
+}
+
+func (a *oidcAuth) identity(claims map[string]interface{}) (*Identity, error) {
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, errors.New("Email is not verified")
+	}
+	identity := &Identity{
+		Name:      stringClaim(claims, a.nameClaim),
+		Email:     stringClaim(claims, a.emailClaim),
+		AvatarURL: stringClaim(claims, a.avatarClaim),
+	}
+	if identity.Email == "" {
+		return nil, fmt.Errorf("No %s claim for user", a.emailClaim)
+	}
+	if identity.Name == "" {
+		identity.Name = identity.Email
+	}
+
+	switch groups := claims[a.groupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+	return identity, nil
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// Fills in claims missing from the ID token from the userinfo endpoint.
+func (a *oidcAuth) mergeUserinfo(
+	ctx context.Context, endpoint, accessToken string, claims map[string]interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	userinfo := make(map[string]interface{})
+	err = a.doJSON(req, &userinfo)
+	if err != nil {
+		return fmt.Errorf("Error getting userinfo: %v", err)
+	}
+	if userinfo["sub"] != claims["sub"] {
+		return errors.New("Userinfo is for a different user than the ID token")
+	}
+	for name, value := range userinfo {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return nil
+}
+
+// Verifies the signature and claims of an ID token, returning its claims.
+func (a *oidcAuth) verifyIDToken(idToken, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed ID token header: %v", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed ID token signature: %v", err)
+	}
+
+	key, err := a.signingKey(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	err = verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed ID token claims: %v", err)
+	}
+
+	discovery, err := a.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+	if claims["iss"] != discovery.Issuer {
+		return nil, fmt.Errorf("ID token issuer is %v, expected %s", claims["iss"], discovery.Issuer)
+	}
+	if !a.hasAudience(claims) {
+		return nil, errors.New("ID token is for a different client")
+	}
+	expiry, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("ID token has no expiry")
+	}
+	if time.Now().Add(-oidcClockSkew).After(time.Unix(int64(expiry), 0)) {
+		return nil, errors.New("ID token expired")
+	}
+	if claims["nonce"] != nonce {
+		return nil, errors.New("ID token is for a different login")
+	}
+	return claims, nil
+}
+
+func (a *oidcAuth) hasAudience(claims map[string]interface{}) bool {
+	switch audience := claims["aud"].(type) {
+	case string:
+		return audience == a.clientID
+	case []interface{}:
+		if len(audience) > 1 {
+			if party, ok := claims["azp"]; ok && party != a.clientID {
+				return false
+			}
+		}
+		for _, aud := range audience {
+			if aud == a.clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	digest := sha256.Sum256([]byte(signed))
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("ID token key is not an RSA key")
+		}
+		err := rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest[:], signature)
+		if err != nil {
+			return errors.New("Invalid ID token signature")
+		}
+		return nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return errors.New("ID token key is not an EC key")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return errors.New("Invalid ID token signature")
+		}
+		return nil
+	default:
+		return fmt.Errorf("Unsupported ID token algorithm: %s", alg)
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	bytes, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bytes, v)
+}
+
+func (a *oidcAuth) getDiscovery() (*oidcDiscovery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.discovery != nil {
+		return a.discovery, nil
+	}
+
+	req, err := http.NewRequest("GET", a.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	discovery := &oidcDiscovery{}
+	err = a.doJSON(req, discovery)
+	if err != nil {
+		return nil, fmt.Errorf("Error discovering OIDC provider: %v", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != a.issuer {
+		return nil, fmt.Errorf("OIDC provider issuer is %s, expected %s", discovery.Issuer, a.issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC provider is missing endpoints")
+	}
+	a.discovery = discovery
+	return discovery, nil
+}
+
+// Returns the provider's signing key with the ID, refetching keys if it's unknown, in case they rotated.
+func (a *oidcAuth) signingKey(kid string) (crypto.PublicKey, error) {
+	discovery, err := a.getDiscovery()
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(a.keysFetchedAt) < oidcKeysRefreshInterval {
+		return nil, fmt.Errorf("Unknown ID token key: %s", kid)
+	}
+
+	req, err := http.NewRequest("GET", discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	err = a.doJSON(req, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting OIDC signing keys: %v", err)
+	}
+	a.keysFetchedAt = time.Now()
+	a.keys = make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			logger.Error("Skipping OIDC signing key %s: %v", jwk.Kid, err)
+			continue
+		}
+		a.keys[jwk.Kid] = key
+	}
+
+	key, ok := a.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown ID token key: %s", kid)
+	}
+	return key, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA keys.
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC keys.
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		bytes, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(bytes), nil
+	}
+
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, fmt.Errorf("Unsupported curve: %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("Unsupported key type: %s", jwk.Kty)
+	}
+}
+
+func (a *oidcAuth) doJSON(req *http.Request, v interface{}) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("%s: %s %s", resp.Status, oauthErr.Error, oauthErr.Description)
+		}
+		return errors.New(resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
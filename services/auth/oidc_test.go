@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testClientID = "conductor"
+
+// A local stand-in for an OpenID Connect provider.
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// Claims for the next ID token; issuer, audience, expiry and nonce are filled in if unset.
+	claims   map[string]interface{}
+	userinfo map[string]interface{}
+
+	// The last code verifier and client sent to the token endpoint.
+	verifier string
+	clientID string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	p := &testProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"userinfo_endpoint":      p.server.URL + "/userinfo",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		p.verifier = r.PostFormValue("code_verifier")
+		p.clientID = r.PostFormValue("client_id")
+		if r.PostFormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token":     p.idToken(t, r.PostFormValue("code_verifier")),
+			"access_token": "access",
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(p.userinfo)
+	})
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *testProvider) idToken(t *testing.T, verifier string) string {
+	claims := map[string]interface{}{
+		"iss":   p.server.URL,
+		"aud":   testClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"nonce": oidcNonce(verifier),
+	}
+	for name, value := range p.claims {
+		claims[name] = value
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	assert.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCAuthURL(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+	a := newOIDC(provider.server.URL, testClientID, "")
+
+	authURL, err := url.Parse(a.AuthURL("conductor.example.com", "some-state", "some-verifier"))
+	assert.NoError(t, err)
+	assert.Equal(t, provider.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	query := authURL.Query()
+	assert.Equal(t, "code", query.Get("response_type"))
+	assert.Equal(t, testClientID, query.Get("client_id"))
+	assert.Equal(t, "https://conductor.example.com/api/auth/login", query.Get("redirect_uri"))
+	assert.Equal(t, "openid profile email", query.Get("scope"))
+	assert.Equal(t, "some-state", query.Get("state"))
+	assert.Equal(t, oidcNonce("some-verifier"), query.Get("nonce"))
+	assert.Equal(t, codeChallenge("some-verifier"), query.Get("code_challenge"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+	a := newOIDC(provider.server.URL, testClientID, "")
+
+	provider.claims = map[string]interface{}{
+		"sub":            "1",
+		"name":           "Jane",
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"picture":        "https://example.com/jane.png",
+		"groups":         []string{"eng", "deployers"},
+	}
+	identity, err := a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.NoError(t, err)
+	assert.Equal(t, &Identity{
+		Name:      "Jane",
+		Email:     "jane@example.com",
+		AvatarURL: "https://example.com/jane.png",
+		Groups:    []string{"eng", "deployers"},
+	}, identity)
+	assert.Equal(t, "some-verifier", provider.verifier)
+	assert.Equal(t, testClientID, provider.clientID)
+
+	_, err = a.Login(context.Background(), "conductor.example.com", "bad-code", "some-verifier")
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "invalid_grant"))
+
+	_, err = a.Login(context.Background(), "conductor.example.com", "good-code", "")
+	assert.Error(t, err, "Logins without a verifier should be rejected")
+}
+
+func TestOIDCLoginCustomClaims(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+	a := newOIDC(provider.server.URL, testClientID, "")
+	a.emailClaim = "upn"
+	a.groupsClaim = "roles"
+
+	provider.claims = map[string]interface{}{
+		"sub":   "1",
+		"name":  "Jane",
+		"upn":   "jane@example.com",
+		"roles": "admins",
+	}
+	identity, err := a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.NoError(t, err)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.Equal(t, []string{"admins"}, identity.Groups)
+}
+
+func TestOIDCLoginUserinfo(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+	a := newOIDC(provider.server.URL, testClientID, "")
+
+	provider.claims = map[string]interface{}{"sub": "1"}
+	provider.userinfo = map[string]interface{}{
+		"sub":    "1",
+		"name":   "Jane",
+		"email":  "jane@example.com",
+		"groups": []string{"eng"},
+	}
+	identity, err := a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane", identity.Name)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.Equal(t, []string{"eng"}, identity.Groups)
+
+	provider.userinfo["sub"] = "2"
+	_, err = a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.Error(t, err, "Userinfo for another user should be rejected")
+}
+
+func TestOIDCLoginInvalidIDToken(t *testing.T) {
+	provider := newTestProvider(t)
+	defer provider.server.Close()
+	a := newOIDC(provider.server.URL, testClientID, "")
+
+	base := map[string]interface{}{"sub": "1", "name": "Jane", "email": "jane@example.com"}
+	provider.userinfo = map[string]interface{}{"sub": "1"}
+	cases := map[string]map[string]interface{}{
+		"audience":   {"aud": "someone-else"},
+		"issuer":     {"iss": "https://evil.example.com"},
+		"expiry":     {"exp": time.Now().Add(-time.Hour).Unix()},
+		"nonce":      {"nonce": "replayed"},
+		"unverified": {"email_verified": false},
+	}
+	for name, overrides := range cases {
+		provider.claims = make(map[string]interface{})
+		for claim, value := range base {
+			provider.claims[claim] = value
+		}
+		for claim, value := range overrides {
+			provider.claims[claim] = value
+		}
+		_, err := a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+		assert.Error(t, err, name)
+	}
+
+	// A token signed by another key.
+	provider.claims = base
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	signingKey := provider.key
+	provider.key = otherKey
+	_, err = a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.Error(t, err)
+	provider.key = signingKey
+	_, err = a.Login(context.Background(), "conductor.example.com", "good-code", "some-verifier")
+	assert.NoError(t, err)
+}
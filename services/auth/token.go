@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const apiTokenBytes = 32
+
+// Generates a new API token, returning it and the hash to store.
+func NewAPIToken() (token, hash string, err error) {
+	bytes := make([]byte, apiTokenBytes)
+	_, err = rand.Read(bytes)
+	if err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(bytes)
+	return token, HashAPIToken(token), nil
+}
+
+// Hashes an API token for storage and lookup.
+// Tokens are random, so they don't need a salt or a slow hash.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the token from the `Authorization: Bearer` header, or "" if there isn't one.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || strings.ToLower(header[:len(prefix)]) != prefix {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
@@ -1,7 +1,11 @@
 /* Handles building jobs remotely (like Jenkins). */
 package build
 
+import (
+	"context"
+)
+
 type Service interface {
-	CancelJob(jobName string, jobURL string, params map[string]string) error
-	TriggerJob(jobName string, params map[string]string) error
+	CancelJob(ctx context.Context, jobName string, jobURL string, params map[string]string) error
+	TriggerJob(ctx context.Context, jobName string, params map[string]string) error
 }
@@ -1,6 +1,7 @@
 package build
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/Nextdoor/conductor/shared/datadog"
 	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/tracing"
 )
 
 var (
@@ -74,7 +76,7 @@ func (j jenkins) TestAuth() error {
 	return nil
 }
 
-func (j jenkins) CancelJob(jobName string, jobURL string, params map[string]string) error {
+func (j jenkins) CancelJob(ctx context.Context, jobName string, jobURL string, params map[string]string) error {
 
 	datadog.Info("Cancelling Jenkins Job \"%s\", Params: %s", jobName, params)
 	buildURL, err := url.Parse(fmt.Sprintf("%s/stop", jobURL))
@@ -92,6 +94,7 @@ func (j jenkins) CancelJob(jobName string, jobURL string, params map[string]stri
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(tracing.Detach(ctx))
 	req.SetBasicAuth(j.Username, j.Password)
 
 	resp, err := j.Do(req)
@@ -106,7 +109,7 @@ func (j jenkins) CancelJob(jobName string, jobURL string, params map[string]stri
 	return nil
 }
 
-func (j jenkins) TriggerJob(jobName string, params map[string]string) error {
+func (j jenkins) TriggerJob(ctx context.Context, jobName string, params map[string]string) error {
 	datadog.Info("Triggering Jenkins Job \"%s\", Params: %s", jobName, params)
 	buildUrl, err := url.Parse(fmt.Sprintf("%s/job/%s/buildWithParameters", j.URL, jobName))
 	if err != nil {
@@ -123,6 +126,7 @@ func (j jenkins) TriggerJob(jobName string, params map[string]string) error {
 	if err != nil {
 		return err
 	}
+	req = req.WithContext(tracing.Detach(ctx))
 	req.SetBasicAuth(j.Username, j.Password)
 
 	resp, err := j.Do(req)
@@ -138,7 +142,8 @@ func (j jenkins) TriggerJob(jobName string, params map[string]string) error {
 
 func (j jenkins) Do(req *http.Request) (*http.Response, error) {
 	client := &http.Client{
-		Timeout: time.Second * 15,
+		Timeout:   time.Second * 15,
+		Transport: tracing.Transport(nil),
 	}
 	return client.Do(req)
 }
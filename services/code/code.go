@@ -2,6 +2,8 @@
 package code
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -20,11 +22,20 @@ var (
 
 var branchRegex *regexp.Regexp
 
+// Returned by Revert when the code host can't revert commits.
+var ErrRevertUnsupported = errors.New("The code service can't revert commits")
+
 type Service interface {
-	CommitsOnBranch(string, int) ([]*types.Commit, error)
-	CommitsOnBranchAfter(string, string) ([]*types.Commit, error)
-	CompareRefs(string, string) ([]*types.Commit, error)
-	Revert(sha1, branch string) error
+	// ctx carries the trace to continue in calls to the code host.
+	CommitsOnBranch(context.Context, string, int) ([]*types.Commit, error)
+	CommitsOnBranchAfter(context.Context, string, string) ([]*types.Commit, error)
+	CompareRefs(context.Context, string, string) ([]*types.Commit, error)
+	// Returns nil when the files can't be known, like in the fake.
+	ChangedFiles(context.Context, string, string) ([]string, error)
+	// Reverts the commit on the branch. Returns ErrRevertUnsupported if the code host can't.
+	Revert(ctx context.Context, sha1, branch string) error
+	// Merges head into branch, returning the merge commit, or nil if branch already has head.
+	Merge(ctx context.Context, head, branch string) (*types.Commit, error)
 	ParseWebhookForBranch(r *http.Request) (string, error)
 }
 
@@ -62,22 +73,30 @@ func newFake() *fake {
 	return &fake{}
 }
 
-func (c *fake) CommitsOnBranch(branch string, max int) ([]*types.Commit, error) {
+func (c *fake) CommitsOnBranch(ctx context.Context, branch string, max int) ([]*types.Commit, error) {
+	return nil, nil
+}
+
+func (c *fake) CommitsOnBranchAfter(ctx context.Context, branch string, sha string) ([]*types.Commit, error) {
 	return nil, nil
 }
 
-func (c *fake) CommitsOnBranchAfter(branch string, sha string) ([]*types.Commit, error) {
+func (c *fake) CompareRefs(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
 	return nil, nil
 }
 
-func (c *fake) CompareRefs(oldRef, newRef string) ([]*types.Commit, error) {
+func (c *fake) ChangedFiles(ctx context.Context, oldRef, newRef string) ([]string, error) {
 	return nil, nil
 }
 
-func (c *fake) Revert(sha1, branch string) error {
+func (c *fake) Revert(ctx context.Context, sha1, branch string) error {
 	return nil
 }
 
+func (c *fake) Merge(ctx context.Context, head, branch string) (*types.Commit, error) {
+	return nil, nil
+}
+
 func (c *fake) ParseWebhookForBranch(r *http.Request) (string, error) {
 	return "", nil
 }
@@ -1,45 +1,62 @@
 package code
 
 import (
+	"context"
 	"net/http"
 
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 type CodeServiceMock struct {
-	CommitsOnBranchMock       func(string, int) ([]*types.Commit, error)
-	CommitsOnBranchAfterMock  func(string, string) ([]*types.Commit, error)
-	CompareRefsMock           func(string, string) ([]*types.Commit, error)
-	RevertMock                func(sha1, branch string) error
+	CommitsOnBranchMock       func(context.Context, string, int) ([]*types.Commit, error)
+	CommitsOnBranchAfterMock  func(context.Context, string, string) ([]*types.Commit, error)
+	CompareRefsMock           func(context.Context, string, string) ([]*types.Commit, error)
+	ChangedFilesMock          func(context.Context, string, string) ([]string, error)
+	RevertMock                func(ctx context.Context, sha1, branch string) error
+	MergeMock                 func(ctx context.Context, head, branch string) (*types.Commit, error)
 	ParseWebhookForBranchMock func(r *http.Request) (string, error)
 }
 
-func (m *CodeServiceMock) CommitsOnBranch(branch string, max int) ([]*types.Commit, error) {
+func (m *CodeServiceMock) CommitsOnBranch(ctx context.Context, branch string, max int) ([]*types.Commit, error) {
 	if m.CommitsOnBranchMock == nil {
 		return nil, nil
 	}
-	return m.CommitsOnBranchMock(branch, max)
+	return m.CommitsOnBranchMock(ctx, branch, max)
 }
 
-func (m *CodeServiceMock) CommitsOnBranchAfter(branch string, sha string) ([]*types.Commit, error) {
+func (m *CodeServiceMock) CommitsOnBranchAfter(ctx context.Context, branch string, sha string) ([]*types.Commit, error) {
 	if m.CommitsOnBranchAfterMock == nil {
 		return nil, nil
 	}
-	return m.CommitsOnBranchAfterMock(branch, sha)
+	return m.CommitsOnBranchAfterMock(ctx, branch, sha)
 }
 
-func (m *CodeServiceMock) CompareRefs(oldRef, newRef string) ([]*types.Commit, error) {
+func (m *CodeServiceMock) CompareRefs(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
 	if m.CompareRefsMock == nil {
 		return nil, nil
 	}
-	return m.CompareRefsMock(oldRef, newRef)
+	return m.CompareRefsMock(ctx, oldRef, newRef)
 }
 
-func (m *CodeServiceMock) Revert(sha1, branch string) error {
+func (m *CodeServiceMock) ChangedFiles(ctx context.Context, oldRef, newRef string) ([]string, error) {
+	if m.ChangedFilesMock == nil {
+		return nil, nil
+	}
+	return m.ChangedFilesMock(ctx, oldRef, newRef)
+}
+
+func (m *CodeServiceMock) Revert(ctx context.Context, sha1, branch string) error {
 	if m.RevertMock == nil {
 		return nil
 	}
-	return m.RevertMock(sha1, branch)
+	return m.RevertMock(ctx, sha1, branch)
+}
+
+func (m *CodeServiceMock) Merge(ctx context.Context, head, branch string) (*types.Commit, error) {
+	if m.MergeMock == nil {
+		return nil, nil
+	}
+	return m.MergeMock(ctx, head, branch)
 }
 
 func (m *CodeServiceMock) ParseWebhookForBranch(r *http.Request) (string, error) {
@@ -1,6 +1,7 @@
 package code
 
 import (
+	"context"
 	"errors"
 	"net/http"
 
@@ -52,32 +53,45 @@ func newGithub() *githubCode {
 		)}
 }
 
-func (c *githubCode) CommitsOnBranch(branch string, max int) ([]*types.Commit, error) {
-	apiCommits, err := c.codeClient.CommitsOnBranch(branch, max)
+func (c *githubCode) CommitsOnBranch(ctx context.Context, branch string, max int) ([]*types.Commit, error) {
+	apiCommits, err := c.codeClient.CommitsOnBranch(ctx, branch, max)
 	if err != nil {
 		return nil, err
 	}
 	return c.convertCommits(apiCommits, branch), nil
 }
 
-func (c *githubCode) CommitsOnBranchAfter(branch string, sha string) ([]*types.Commit, error) {
-	apiCommits, err := c.codeClient.CommitsOnBranchAfter(branch, sha)
+func (c *githubCode) CommitsOnBranchAfter(ctx context.Context, branch string, sha string) ([]*types.Commit, error) {
+	apiCommits, err := c.codeClient.CommitsOnBranchAfter(ctx, branch, sha)
 	if err != nil {
 		return nil, err
 	}
 	return c.convertCommits(apiCommits, branch), nil
 }
 
-func (c *githubCode) CompareRefs(oldRef, newRef string) ([]*types.Commit, error) {
-	apiCommits, err := c.codeClient.CompareRefs(oldRef, newRef)
+func (c *githubCode) CompareRefs(ctx context.Context, oldRef, newRef string) ([]*types.Commit, error) {
+	apiCommits, err := c.codeClient.CompareRefs(ctx, oldRef, newRef)
 	if err != nil {
 		return nil, err
 	}
 	return c.convertCommits(apiCommits, newRef), nil
 }
 
-func (c *githubCode) Revert(sha1, branch string) error {
-	return c.codeClient.Revert(sha1, branch)
+func (c *githubCode) ChangedFiles(ctx context.Context, oldRef, newRef string) ([]string, error) {
+	return c.codeClient.ChangedFiles(ctx, oldRef, newRef)
+}
+
+// GitHub's API has no way to revert a commit.
+func (c *githubCode) Revert(ctx context.Context, sha1, branch string) error {
+	return ErrRevertUnsupported
+}
+
+func (c *githubCode) Merge(ctx context.Context, head, branch string) (*types.Commit, error) {
+	apiCommit, err := c.codeClient.Merge(ctx, head, branch)
+	if err != nil || apiCommit == nil {
+		return nil, err
+	}
+	return c.convertCommits([]*githubRaw.RepositoryCommit{apiCommit}, branch)[0], nil
 }
 
 func (c *githubCode) ParseWebhookForBranch(r *http.Request) (string, error) {
@@ -4,6 +4,7 @@ package data
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/Nextdoor/conductor/shared/flags"
 	"github.com/Nextdoor/conductor/shared/logger"
@@ -34,14 +35,23 @@ type Client interface {
 	Train(uint64) (*types.Train, error)
 	LatestTrain() (*types.Train, error)
 	LatestTrainForBranch(string) (*types.Train, error)
+	OldestUndeployedTrain() (*types.Train, error)
+	ProductionTrain() (*types.Train, error)
+	Trains(*types.TrainFilter) ([]*types.TrainSummary, error)
 	CreateTrain(string, *types.User, []*types.Commit) (*types.Train, error)
+	CreateExpeditedTrain(branch string, engineer *types.User, commits []*types.Commit, reason string, preempted *types.Train) (*types.Train, error)
 	ExtendTrain(*types.Train, *types.User, []*types.Commit) error
 	DuplicateTrain(*types.Train, []*types.Commit) (*types.Train, error)
+	ExcludeCommit(*types.Train, *types.Commit) error
+	RedeliverTrain(train *types.Train, branch string) error
+	SplitTrain(train *types.Train, sha string, engineer *types.User) (*types.Train, error)
+	ReleaseCommit(*types.Train, *types.Commit) error
 	ChangeTrainEngineer(*types.Train, *types.User) error
 	CloseTrain(*types.Train, bool) error
 	OpenTrain(*types.Train, bool) error
 	BlockTrain(*types.Train, *string) error
 	UnblockTrain(*types.Train) error
+	OverrideFreeze(train *types.Train, user *types.User, reason string) error
 	DeployTrain(*types.Train) error
 	CancelTrain(*types.Train) error
 	LoadLastDeliveredSHA(*types.Train) error
@@ -58,16 +68,54 @@ type Client interface {
 	CompleteJob(*types.Job, types.JobResult, string) error
 	RestartJob(*types.Job, string) error
 
+	CreateRollback(*types.Train, *types.User) (*types.Rollback, error)
+	Rollback(uint64) (*types.Rollback, error)
+	LatestRollbacks(limit int) ([]*types.Rollback, error)
+	RollbackForPhase(*types.Phase) (*types.Rollback, error)
+	SetRollbackStatus(rollback *types.Rollback, status types.RollbackStatus, rollbackErr string) error
+	DeployedTrains(limit int) ([]*types.Train, error)
+
+	SetApprovalRules(*types.Train, []string) error
+	CreateApproval(train *types.Train, user *types.User, rule string) (*types.Approval, error)
+
+	ReleaseTrains(branch string, start, end time.Time) ([]*types.Train, error)
+	Rollbacks(branch string, start, end time.Time) ([]*types.Rollback, error)
+
 	WriteCommits([]*types.Commit) ([]*types.Commit, error)
 	LatestCommitForTrain(*types.Train) (*types.Commit, error)
 	TrainsByCommit(*types.Commit) ([]*types.Train, error)
 
-	WriteToken(newToken, name, email, avatar, codeToken string) error
+	Search(*types.SearchQuery) (*types.SearchResults, error)
+
+	WriteToken(newToken, name, email, avatar string, groups []string) error
 	RevokeToken(oldToken, email string) error
 	ReadOrCreateUser(name, email string) (*types.User, error)
+	UserByEmail(email string) (*types.User, error)
 	UserByToken(token string) (*types.User, error)
+	Session(token string) (*types.Auth, error)
+	Sessions(*types.User) ([]*types.Auth, error)
+	TouchSession(*types.Auth) error
+	RevokeSession(*types.Auth) error
+	DeleteExpiredSessions(idleTimeout, maxLifetime time.Duration) (int64, error)
+
+	CreateAPIToken(name, tokenHash string, scopes []types.Scope, expiresAt *time.Time,
+		createdBy *types.User) (*types.APIToken, error)
+	APITokens() ([]*types.APIToken, error)
+	APIToken(uint64) (*types.APIToken, error)
+	APITokenByHash(string) (*types.APIToken, error)
+	TouchAPIToken(*types.APIToken) error
+	RevokeAPIToken(*types.APIToken, *types.User) error
+
+	UserRoles() ([]*types.UserRole, error)
+	UserRole(*types.User) (*types.UserRole, error)
+	SetUserRole(user *types.User, role types.Role, assignedBy *types.User) (*types.UserRole, error)
+	DeleteUserRole(*types.UserRole) error
+
+	CreateAuditEvent(*types.AuditEvent) error
+	AuditEvents(*types.AuditFilter) ([]*types.AuditEvent, error)
 
 	WriteTickets([]*types.Ticket) error
+	DeleteTicket(*types.Ticket) error
 	UpdateTickets([]*types.Ticket) error
 
 	MetadataListNamespaces() ([]string, error)
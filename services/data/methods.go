@@ -23,6 +23,10 @@ type data struct {
 
 type dataClient struct {
 	Client orm.Ormer
+
+	// The options trains are loaded with, read once per client.
+	// Clients last a request or a background tick, so changes show up in the next one.
+	trainOptions *types.Options
 }
 
 func (d *data) initialize() {
@@ -34,10 +38,15 @@ func (d *data) initialize() {
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Phase))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.PhaseGroup))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Job))
+	orm.RegisterModelWithPrefix(tablePrefix, new(types.Rollback))
+	orm.RegisterModelWithPrefix(tablePrefix, new(types.Approval))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Commit))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Ticket))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.User))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Auth))
+	orm.RegisterModelWithPrefix(tablePrefix, new(types.APIToken))
+	orm.RegisterModelWithPrefix(tablePrefix, new(types.UserRole))
+	orm.RegisterModelWithPrefix(tablePrefix, new(types.AuditEvent))
 	orm.RegisterModelWithPrefix(tablePrefix, new(types.Metadata))
 
 	err := d.RegisterDB()
@@ -53,6 +62,28 @@ func (d *data) initialize() {
 	if err != nil {
 		panic(err)
 	}
+
+	err = runMigrations(orm.NewOrm())
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Sessions used to store the GitHub token of the user, which nothing read.
+// Drop them rather than keep long-lived credentials around.
+func dropCodeTokens(client orm.Ormer) error {
+	var columns int
+	err := client.Raw(`SELECT count(*) FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = ? AND column_name = 'code_token'`,
+		tablePrefix+"auth").QueryRow(&columns)
+	if err != nil {
+		return err
+	}
+	if columns == 0 {
+		return nil
+	}
+	_, err = client.Raw(fmt.Sprintf(`ALTER TABLE %sauth DROP COLUMN code_token`, tablePrefix)).Exec()
+	return err
 }
 
 func (d *data) Client() Client {
@@ -152,9 +183,21 @@ func (d *dataClient) SetOptions(options *types.Options) error {
 	}
 	config.Options = *options
 	_, err = d.Client.Update(config, "Options")
+	d.trainOptions = nil
 	return err
 }
 
+func (d *dataClient) optionsForTrains() (*types.Options, error) {
+	if d.trainOptions == nil {
+		options, err := d.Options()
+		if err != nil {
+			return nil, err
+		}
+		d.trainOptions = options
+	}
+	return d.trainOptions, nil
+}
+
 func (d *dataClient) InCloseTime() (bool, error) {
 	config, err := d.Config()
 	if err != nil {
@@ -163,20 +206,17 @@ func (d *dataClient) InCloseTime() (bool, error) {
 	return config.Options.InCloseTime(), nil
 }
 
+// Uses the mode and close time of the train's branch.
 func (d *dataClient) IsTrainAutoCloseable(train *types.Train) (bool, error) {
-	mode, err := d.Mode()
+	config, err := d.Config()
 	if err != nil {
-		err = fmt.Errorf("Error getting Mode: %v", err)
+		err = fmt.Errorf("Error getting Config: %v", err)
 		return false, err
 	}
-	if mode == types.Manual {
+	if config.Options.BranchMode(train.Branch, config.Mode) == types.Manual {
 		return false, nil
 	}
-	inCloseTime, err := d.InCloseTime()
-	if err != nil {
-		err = fmt.Errorf("Error getting InCloseTime: %v", err)
-		return false, err
-	}
+	inCloseTime := config.Options.InBranchCloseTime(train.Branch)
 	return inCloseTime && train.Engineer != nil && !train.ScheduleOverride, nil
 }
 
@@ -264,6 +304,168 @@ func (d *dataClient) LatestTrainForBranch(branch string) (*types.Train, error) {
 	return train, nil
 }
 
+// Returns the first train after the last deployed one that isn't deployed or cancelled, or nil.
+// It's the next to deploy, which is older than the latest train when a split left it behind.
+func (d *dataClient) OldestUndeployedTrain() (*types.Train, error) {
+	query := d.Client.QueryTable(&types.Train{}).
+		Filter("deployed_at__isnull", true).
+		Filter("cancelled_at__isnull", true)
+
+	deployed := &types.Train{}
+	err := d.Client.QueryTable(deployed).Filter("deployed_at__isnull", false).OrderBy("-id").One(deployed)
+	if err == nil {
+		query = query.Filter("id__gt", deployed.ID)
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	train := &types.Train{}
+	err = query.OrderBy("id").One(train)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	err = d.loadTrainRelated(train)
+	if err != nil {
+		return nil, err
+	}
+	return train, nil
+}
+
+// Returns the train whose code is in production:
+// the last deployed train, or the train a later rollback went back to.
+func (d *dataClient) ProductionTrain() (*types.Train, error) {
+	var train *types.Train
+	deployed := &types.Train{}
+	err := d.Client.QueryTable(deployed).
+		Filter("deployed_at__isnull", false).
+		Filter("cancelled_at__isnull", true).
+		OrderBy("-deployed_at").
+		One(deployed)
+	if err == nil {
+		train = deployed
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	rollback := &types.Rollback{}
+	query := d.Client.QueryTable(rollback).RelatedSel("Train").Exclude("status", types.RollbackFailed)
+	if train != nil {
+		query = query.Filter("created_at__gt", train.DeployedAt.Value)
+	}
+	err = query.OrderBy("-id").One(rollback)
+	if err == nil {
+		train = rollback.Train
+	} else if err != orm.ErrNoRows {
+		return nil, err
+	}
+
+	if train == nil {
+		return nil, nil
+	}
+	err = d.loadTrainRelated(train)
+	if err != nil {
+		return nil, err
+	}
+	return train, nil
+}
+
+func (d *dataClient) Trains(filter *types.TrainFilter) ([]*types.TrainSummary, error) {
+	query := d.Client.QueryTable(&types.Train{})
+	if filter.Branch != "" {
+		query = query.Filter("branch", filter.Branch)
+	}
+	if filter.EngineerEmail != "" {
+		query = query.Filter("Engineer__Email", filter.EngineerEmail)
+	}
+	if filter.AuthorEmail != "" {
+		query = query.Filter("Commits__ConductorCommit__AuthorEmail", filter.AuthorEmail)
+	}
+	if filter.State != nil {
+		switch *filter.State {
+		case types.Cancelled:
+			query = query.Filter("cancelled_at__isnull", false)
+		case types.Deployed:
+			query = query.
+				Filter("cancelled_at__isnull", true).
+				Filter("deployed_at__isnull", false)
+		case types.Deploying:
+			query = query.
+				Filter("cancelled_at__isnull", true).
+				Filter("deployed_at__isnull", true).
+				Filter("ActivePhases__Deploy__StartedAt__isnull", false)
+		default:
+			query = query.
+				Filter("cancelled_at__isnull", true).
+				Filter("deployed_at__isnull", true).
+				Filter("ActivePhases__Deploy__StartedAt__isnull", true)
+			switch *filter.State {
+			case types.Blocked:
+				query = query.Filter("blocked", true)
+			case types.Closed:
+				query = query.Filter("blocked", false).Filter("closed", true)
+			case types.Open:
+				query = query.Filter("blocked", false).Filter("closed", false)
+			}
+		}
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Filter("created_at__gte", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Filter("created_at__lt", *filter.CreatedBefore)
+	}
+	if filter.DeployedAfter != nil {
+		query = query.Filter("deployed_at__gte", *filter.DeployedAfter)
+	}
+	if filter.DeployedBefore != nil {
+		query = query.Filter("deployed_at__lt", *filter.DeployedBefore)
+	}
+	if filter.Cursor != 0 {
+		query = query.Filter("id__lt", filter.Cursor)
+	}
+
+	trains := make([]*types.Train, 0)
+	_, err := query.Distinct().OrderBy("-id").Limit(filter.Limit).All(&trains)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return []*types.TrainSummary{}, nil
+		}
+		return nil, err
+	}
+
+	return d.summarizeTrains(trains)
+}
+
+// Loads just enough related data to summarize each train.
+func (d *dataClient) summarizeTrains(trains []*types.Train) ([]*types.TrainSummary, error) {
+	summaries := make([]*types.TrainSummary, len(trains))
+	for i, train := range trains {
+		if train.Engineer != nil {
+			_, err := d.Client.LoadRelated(train, "Engineer")
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err := d.Client.LoadRelated(train, "ActivePhases", 1)
+		if err != nil {
+			return nil, err
+		}
+		err = d.loadPipeline(train.ActivePhases, train)
+		if err != nil {
+			return nil, err
+		}
+		commitCount, err := d.Client.QueryM2M(train, "Commits").Count()
+		if err != nil {
+			return nil, err
+		}
+		summaries[i] = train.Summary(commitCount)
+	}
+	return summaries, nil
+}
+
 func (d *dataClient) CreateTrain(branch string, engineer *types.User, commits []*types.Commit) (*types.Train, error) {
 	if len(commits) == 0 {
 		return nil, errors.New("Cannot create a train with no commits.")
@@ -297,37 +499,60 @@ func (d *dataClient) CreateTrain(branch string, engineer *types.User, commits []
 		train.Closed = true
 	}
 
-	phaseGroup, err := d.createPhaseGroup(train)
+	err = d.insertTrain(train, commits)
 	if err != nil {
 		d.Client.Rollback()
 		return nil, err
 	}
-	train.ActivePhases = phaseGroup
 
-	_, err = d.Client.Insert(train)
+	err = d.Client.Commit()
 	if err != nil {
 		d.Client.Rollback()
 		return nil, err
 	}
+	datadog.Info("Created train (ID, Branch, HeadSHA, TailSHA) %v, %v, %v, %v", train.ID, train.Branch, train.HeadSHA, train.TailSHA)
+	return train, nil
+}
 
-	phaseGroup.Train = train
-	_, err = d.Client.Update(phaseGroup)
+// Creates a closed train that skips the queue, preempting the given train if it isn't nil.
+func (d *dataClient) CreateExpeditedTrain(
+	branch string,
+	engineer *types.User,
+	commits []*types.Commit,
+	reason string,
+	preempted *types.Train) (*types.Train, error) {
+
+	if len(commits) == 0 {
+		return nil, errors.New("Cannot create a train with no commits.")
+	}
+
+	err := d.Client.Begin()
 	if err != nil {
 		d.Client.Rollback()
 		return nil, err
 	}
 
-	m2m := d.Client.QueryM2M(train, "Commits")
-	for _, commit := range commits {
-		_, err = m2m.Add(commit)
-		if err != nil {
-			d.Client.Rollback()
-			return nil, err
-		}
+	_, err = d.WriteCommits(commits)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
 	}
 
-	// Populate train.
-	err = d.loadTrainRelated(train)
+	train := &types.Train{
+		Branch:   branch,
+		TailSHA:  commits[0].SHA,
+		HeadSHA:  commits[len(commits)-1].SHA,
+		Engineer: engineer,
+		// Closed until the hotfix ships, whatever the schedule.
+		Closed:           true,
+		ScheduleOverride: true,
+		ExpediteReason:   &reason,
+	}
+	if preempted != nil {
+		train.PreemptedID = &preempted.ID
+	}
+
+	err = d.insertTrain(train, commits)
 	if err != nil {
 		d.Client.Rollback()
 		return nil, err
@@ -338,10 +563,41 @@ func (d *dataClient) CreateTrain(branch string, engineer *types.User, commits []
 		d.Client.Rollback()
 		return nil, err
 	}
-	datadog.Info("Created train (ID, Branch, HeadSHA, TailSHA) %v, %v, %v, %v", train.ID, train.Branch, train.HeadSHA, train.TailSHA)
+	datadog.Info("Created expedited train (ID, Branch, HeadSHA, TailSHA) %v, %v, %v, %v", train.ID, train.Branch, train.HeadSHA, train.TailSHA)
 	return train, nil
 }
 
+// Inserts a new train with its phases and commits. Must be called in a transaction.
+func (d *dataClient) insertTrain(train *types.Train, commits []*types.Commit) error {
+	phaseGroup, err := d.createPhaseGroup(train)
+	if err != nil {
+		return err
+	}
+	train.ActivePhases = phaseGroup
+
+	_, err = d.Client.Insert(train)
+	if err != nil {
+		return err
+	}
+
+	phaseGroup.Train = train
+	_, err = d.Client.Update(phaseGroup)
+	if err != nil {
+		return err
+	}
+
+	m2m := d.Client.QueryM2M(train, "Commits")
+	for _, commit := range commits {
+		_, err = m2m.Add(commit)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Populate train.
+	return d.loadTrainRelated(train)
+}
+
 func (d *dataClient) ExtendTrain(train *types.Train, engineer *types.User, newCommits []*types.Commit) error {
 	if len(newCommits) == 0 {
 		return errors.New("Cannot extend a train with no new commits.")
@@ -416,115 +672,288 @@ func (d *dataClient) ExtendTrain(train *types.Train, engineer *types.User, newCo
 	return nil
 }
 
-func (d *dataClient) DuplicateTrain(oldTrain *types.Train, newCommits []*types.Commit) (*types.Train, error) {
+// Takes the commit off the train. Its SHA is kept, since the train's head still has it.
+func (d *dataClient) ExcludeCommit(train *types.Train, commit *types.Commit) error {
 	err := d.Client.Begin()
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
-	}
-
-	// Clone the old train.
-	newTrain := &types.Train{
-		Branch:   oldTrain.Branch,
-		TailSHA:  oldTrain.TailSHA,
-		HeadSHA:  oldTrain.HeadSHA,
-		Engineer: oldTrain.Engineer,
-	}
-
-	if len(newCommits) > 0 {
-		_, err = d.WriteCommits(newCommits)
-		if err != nil {
-			d.Client.Rollback()
-			return nil, err
-		}
-
-		newTrain.HeadSHA = newCommits[len(newCommits)-1].SHA
+		return err
 	}
 
-	if oldTrain.ScheduleOverride {
-		newTrain.Closed = oldTrain.Closed
-		newTrain.ScheduleOverride = true
-	} else {
-		closeable, err := d.IsTrainAutoCloseable(newTrain)
-		if err != nil {
-			d.Client.Rollback()
-			return nil, err
-		}
-		if closeable {
-			newTrain.Closed = true
-		}
+	_, err = d.Client.QueryM2M(train, "Commits").Remove(commit)
+	if err != nil {
+		d.Client.Rollback()
+		return err
 	}
 
-	phaseGroup, err := d.createPhaseGroup(newTrain)
+	train.ExcludedSHAs = strings.Join(append(train.ExcludedSHAList(), commit.SHA), ",")
+	_, err = d.Client.Update(train, "ExcludedSHAs")
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
-	newTrain.ActivePhases = phaseGroup
 
-	_, err = d.Client.Insert(newTrain)
+	err = d.Client.Commit()
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
+	datadog.Info("Excluded commit from train (ID, SHA) %v, %v", train.ID, commit.SHA)
 
-	phaseGroup.Train = newTrain
-	_, err = d.Client.Update(phaseGroup)
+	return d.loadTrainRelated(train)
+}
+
+// Starts the train over with a new phase group, built from branch.
+func (d *dataClient) RedeliverTrain(train *types.Train, branch string) error {
+	err := d.Client.Begin()
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
 
-	// Clone the old commit mappings.
-	commits := make([]*types.Commit, len(oldTrain.Commits)+len(newCommits))
-	index := 0
-	for i := range oldTrain.Commits {
-		commits[index] = oldTrain.Commits[i]
-		index += 1
-	}
-	for i := range newCommits {
-		commits[index] = newCommits[i]
-		index += 1
-	}
+	train.GeneratedBranch = &branch
 
-	m2m := d.Client.QueryM2M(newTrain, "Commits")
-	for _, commit := range commits {
-		_, err = m2m.Add(commit)
-		if err != nil {
-			d.Client.Rollback()
-			return nil, err
-		}
+	phaseGroup, err := d.createPhaseGroup(train)
+	if err != nil {
+		d.Client.Rollback()
+		return err
 	}
+	train.ActivePhases = phaseGroup
 
-	// Clone the old tickets.
-	tickets := make([]*types.Ticket, len(oldTrain.Tickets))
-	for i := range oldTrain.Tickets {
-		newTicket := oldTrain.Tickets[i]
-		newTicket.ID = 0
-		newTicket.Train = newTrain
-		tickets[i] = newTicket
+	_, err = d.Client.Update(train, "GeneratedBranch", "ActivePhases")
+	if err != nil {
+		d.Client.Rollback()
+		return err
 	}
 
-	err = d.WriteTickets(tickets)
+	phaseGroup.Train = train
+	_, err = d.Client.Update(phaseGroup)
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
 
-	// Populate train.
-	err = d.loadTrainRelated(newTrain)
+	err = d.loadTrainRelated(train)
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
 
 	err = d.Client.Commit()
 	if err != nil {
 		d.Client.Rollback()
-		return nil, err
+		return err
 	}
-	datadog.Info("Duplicated train (ID, HeadSHA, TailSHA) %v, %v, %v", newTrain.ID, newTrain.HeadSHA, newTrain.TailSHA)
-	return newTrain, nil
+	datadog.Info("Redelivering train (ID, Branch) %v, %v", train.ID, branch)
+	return nil
+}
+
+// Lets the train deploy with the commit, though it's marked [hold] or its deploy-after time hasn't passed.
+func (d *dataClient) ReleaseCommit(train *types.Train, commit *types.Commit) error {
+	train.ReleasedSHAs = strings.Join(append(train.ReleasedSHAList(), commit.SHA), ",")
+	_, err := d.Client.Update(train, "ReleasedSHAs")
+	if err != nil {
+		return err
+	}
+	datadog.Info("Released commit on train (ID, SHA) %v, %v", train.ID, commit.SHA)
+
+	return d.loadTrainRelated(train)
+}
+
+// Ends the train at sha, and moves its commits after sha to a new train behind it.
+// The train gets a new phase group, since it now deploys sha.
+func (d *dataClient) SplitTrain(train *types.Train, sha string, engineer *types.User) (*types.Train, error) {
+	splitIndex := -1
+	for i, commit := range train.Commits {
+		if commit.SHA == sha {
+			splitIndex = i
+			break
+		}
+	}
+	if splitIndex == -1 || splitIndex == len(train.Commits)-1 {
+		return nil, fmt.Errorf("Cannot split train %d at %s", train.ID, sha)
+	}
+	movedCommits := train.Commits[splitIndex+1:]
+
+	err := d.Client.Begin()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	m2m := d.Client.QueryM2M(train, "Commits")
+	for _, commit := range movedCommits {
+		_, err = m2m.Remove(commit)
+		if err != nil {
+			d.Client.Rollback()
+			return nil, err
+		}
+	}
+
+	newTrain := &types.Train{
+		Branch:       train.Branch,
+		TailSHA:      movedCommits[0].SHA,
+		HeadSHA:      train.HeadSHA,
+		Engineer:     engineer,
+		Closed:       train.Closed,
+		ReleasedSHAs: train.ReleasedSHAs,
+		SplitFromID:  &train.ID,
+	}
+	err = d.insertTrain(newTrain, movedCommits)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	train.HeadSHA = sha
+	phaseGroup, err := d.createPhaseGroup(train)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	train.ActivePhases = phaseGroup
+
+	_, err = d.Client.Update(train, "HeadSHA", "ActivePhases")
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	phaseGroup.Train = train
+	_, err = d.Client.Update(phaseGroup)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	err = d.loadTrainRelated(train)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	err = d.Client.Commit()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	datadog.Info("Split train (ID, HeadSHA, NewTrainID) %v, %v, %v", train.ID, sha, newTrain.ID)
+	return newTrain, nil
+}
+
+func (d *dataClient) DuplicateTrain(oldTrain *types.Train, newCommits []*types.Commit) (*types.Train, error) {
+	err := d.Client.Begin()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	// Clone the old train.
+	newTrain := &types.Train{
+		Branch:   oldTrain.Branch,
+		TailSHA:  oldTrain.TailSHA,
+		HeadSHA:  oldTrain.HeadSHA,
+		Engineer: oldTrain.Engineer,
+		// The head still has the excluded commits.
+		ExcludedSHAs:    oldTrain.ExcludedSHAs,
+		GeneratedBranch: oldTrain.GeneratedBranch,
+		ReleasedSHAs:    oldTrain.ReleasedSHAs,
+	}
+
+	if len(newCommits) > 0 {
+		_, err = d.WriteCommits(newCommits)
+		if err != nil {
+			d.Client.Rollback()
+			return nil, err
+		}
+
+		newTrain.HeadSHA = newCommits[len(newCommits)-1].SHA
+	}
+
+	if oldTrain.ScheduleOverride {
+		newTrain.Closed = oldTrain.Closed
+		newTrain.ScheduleOverride = true
+	} else {
+		closeable, err := d.IsTrainAutoCloseable(newTrain)
+		if err != nil {
+			d.Client.Rollback()
+			return nil, err
+		}
+		if closeable {
+			newTrain.Closed = true
+		}
+	}
+
+	phaseGroup, err := d.createPhaseGroup(newTrain)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	newTrain.ActivePhases = phaseGroup
+
+	_, err = d.Client.Insert(newTrain)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	phaseGroup.Train = newTrain
+	_, err = d.Client.Update(phaseGroup)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	// Clone the old commit mappings.
+	commits := make([]*types.Commit, len(oldTrain.Commits)+len(newCommits))
+	index := 0
+	for i := range oldTrain.Commits {
+		commits[index] = oldTrain.Commits[i]
+		index += 1
+	}
+	for i := range newCommits {
+		commits[index] = newCommits[i]
+		index += 1
+	}
+
+	m2m := d.Client.QueryM2M(newTrain, "Commits")
+	for _, commit := range commits {
+		_, err = m2m.Add(commit)
+		if err != nil {
+			d.Client.Rollback()
+			return nil, err
+		}
+	}
+
+	// Clone the old tickets.
+	tickets := make([]*types.Ticket, len(oldTrain.Tickets))
+	for i := range oldTrain.Tickets {
+		newTicket := oldTrain.Tickets[i]
+		newTicket.ID = 0
+		newTicket.Train = newTrain
+		tickets[i] = newTicket
+	}
+
+	err = d.WriteTickets(tickets)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	// Populate train.
+	err = d.loadTrainRelated(newTrain)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	err = d.Client.Commit()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	datadog.Info("Duplicated train (ID, HeadSHA, TailSHA) %v, %v, %v", newTrain.ID, newTrain.HeadSHA, newTrain.TailSHA)
+	return newTrain, nil
 }
 
 func (d *dataClient) ChangeTrainEngineer(train *types.Train, engineer *types.User) error {
@@ -575,6 +1004,16 @@ func (d *dataClient) UnblockTrain(train *types.Train) error {
 	return err
 }
 
+func (d *dataClient) OverrideFreeze(train *types.Train, user *types.User, reason string) error {
+	train.FreezeOverrideReason = &reason
+	train.FreezeOverrideBy = user
+	_, err := d.Client.Update(train, "FreezeOverrideReason", "FreezeOverrideBy")
+	if err == nil {
+		datadog.Info("Overrode freeze for train (ID, User, Reason) %v, %v, %v", train.ID, user.Email, reason)
+	}
+	return err
+}
+
 func (d *dataClient) DeployTrain(train *types.Train) error {
 	train.DeployedAt = types.Time{time.Now()}
 	_, err := d.Client.Update(train, "DeployedAt")
@@ -601,6 +1040,13 @@ func (d *dataClient) loadTrainRelated(train *types.Train) error {
 		}
 	}
 
+	if train.FreezeOverrideBy != nil {
+		_, err := d.Client.LoadRelated(train, "FreezeOverrideBy")
+		if err != nil {
+			return err
+		}
+	}
+
 	_, err := d.Client.LoadRelated(train, "Tickets")
 	if err != nil {
 		return err
@@ -626,6 +1072,11 @@ func (d *dataClient) loadTrainRelated(train *types.Train) error {
 		return err
 	}
 
+	err = d.loadPipeline(train.ActivePhases, train)
+	if err != nil {
+		return err
+	}
+
 	for _, phase := range train.ActivePhases.Phases() {
 		_, err = d.Client.LoadRelated(phase, "Jobs")
 		sort.Sort(types.JobsByID(phase.Jobs))
@@ -634,8 +1085,6 @@ func (d *dataClient) loadTrainRelated(train *types.Train) error {
 		}
 	}
 
-	train.ActivePhases.SetReferences(train)
-
 	train.SetActivePhase()
 
 	previousTrain, nextTrain, err := d.adjacentTrains(train.ID)
@@ -646,19 +1095,60 @@ func (d *dataClient) loadTrainRelated(train *types.Train) error {
 	if previousTrain != nil {
 		train.PreviousID = &previousTrain.ID
 		train.PreviousTrainDone = previousTrain.IsDone()
+		if train.PreemptedID != nil && *train.PreemptedID == previousTrain.ID {
+			// Expedited trains only wait for the train they preempted to stop deploying.
+			train.PreviousTrainDone = !previousTrain.IsDeploying()
+		}
 	} else {
 		train.PreviousTrainDone = false
 	}
 
 	if nextTrain != nil {
 		train.NextID = &nextTrain.ID
+		train.NextIsSplitOff = nextTrain.SplitFromID != nil && *nextTrain.SplitFromID == train.ID
+	}
+
+	if !train.IsDone() {
+		options, err := d.optionsForTrains()
+		if err != nil {
+			return err
+		}
+		train.Freeze = options.ActiveFreeze()
+		if !train.IsExpedited() {
+			train.NextDeploySlot = options.NextDeploySlot()
+		}
+
+		err = d.loadApprovals(train, options)
+		if err != nil {
+			return err
+		}
+
+		train.Holds = train.CommitHolds(time.Now())
+	}
+
+	if train.ExcludedSHAs != "" {
+		train.ExcludedCommits = make([]*types.Commit, 0)
+		_, err = d.Client.QueryTable(&types.Commit{}).
+			Filter("sha__in", train.ExcludedSHAList()).
+			OrderBy("id").
+			All(&train.ExcludedCommits)
+		if err != nil && err != orm.ErrNoRows {
+			return err
+		}
 	}
 
 	train.NotDeployableReason = train.GetNotDeployableReason()
 
 	train.Done = train.IsDone()
 
-	train.CanRollback = train.Done && settings.GetJenkinsRollbackJob() != ""
+	train.CanRollback = train.IsDeployed() && !train.CancelledAt.HasValue() && settings.GetJenkinsRollbackJob() != ""
+
+	if train.IsDeployed() {
+		train.Rollback, err = d.latestRollbackTo(train)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -675,6 +1165,10 @@ func (d *dataClient) loadAllTrainPhaseGroups(train *types.Train) error {
 	}
 
 	for _, phaseGroup := range train.AllPhaseGroups {
+		err = d.loadPipeline(phaseGroup, train)
+		if err != nil {
+			return err
+		}
 		for _, phase := range phaseGroup.Phases() {
 			_, err = d.Client.LoadRelated(phase, "Jobs")
 			if err != nil {
@@ -682,12 +1176,23 @@ func (d *dataClient) loadAllTrainPhaseGroups(train *types.Train) error {
 			}
 			sort.Sort(types.JobsByID(phase.Jobs))
 		}
-		phaseGroup.SetReferences(train)
 	}
 
 	return nil
 }
 
+// Loads every phase of the phase group, in order, and points them at the train.
+func (d *dataClient) loadPipeline(phaseGroup *types.PhaseGroup, train *types.Train) error {
+	_, err := d.Client.LoadRelated(phaseGroup, "Pipeline")
+	if err != nil {
+		return err
+	}
+	sort.Sort(types.PhasesByPosition(phaseGroup.Pipeline))
+
+	phaseGroup.SetReferences(train)
+	return nil
+}
+
 func (d *dataClient) LoadLastDeliveredSHA(train *types.Train) error {
 	if train.LastDeliveredSHA != nil {
 		// Already loaded.
@@ -732,17 +1237,30 @@ func (d *dataClient) Phase(phaseID uint64, train *types.Train) (*types.Phase, er
 	}
 	var phase *types.Phase
 	for _, phaseGroup := range phaseGroups {
-		if phaseGroup.Delivery.ID == phaseID {
-			phase = phaseGroup.Delivery
-			break
-		} else if phaseGroup.Verification.ID == phaseID {
-			phase = phaseGroup.Verification
-			break
-		} else if phaseGroup.Deploy.ID == phaseID {
-			phase = phaseGroup.Deploy
+		for _, groupPhase := range phaseGroup.Phases() {
+			if groupPhase.ID == phaseID {
+				phase = groupPhase
+				break
+			}
+		}
+		if phase != nil {
 			break
 		}
 	}
+	if phase == nil {
+		// Rollback phases aren't in a phase group.
+		rollback := &types.Rollback{}
+		err = d.Client.QueryTable(rollback).
+			Filter("phase_id", phaseID).
+			Filter("train_id", train.ID).
+			RelatedSel("Phase").
+			One(rollback)
+		if err == nil {
+			phase = rollback.Phase
+		} else if err != orm.ErrNoRows {
+			return nil, err
+		}
+	}
 	if phase != nil {
 		_, err := d.Client.LoadRelated(phase, "Jobs")
 		if err != nil {
@@ -795,7 +1313,19 @@ func (d *dataClient) CompletePhase(phase *types.Phase) error {
 }
 
 func (d *dataClient) ReplacePhase(phase *types.Phase) (*types.Phase, error) {
-	newPhase := phase.PhaseGroup.AddNewPhase(phase.Type, phase.Train)
+	phaseGroup := phase.PhaseGroup
+	if len(phaseGroup.Pipeline) == 0 {
+		// Phase groups from before pipelines only point to their phases, so add them to the pipeline.
+		phaseGroup.Pipeline = phaseGroup.Phases()
+		for _, groupPhase := range phaseGroup.Pipeline {
+			_, err := d.Client.Update(groupPhase, "Name", "Position", "PhaseGroup")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	newPhase := phaseGroup.ReplacePhase(phase)
 	_, err := d.Client.Insert(newPhase)
 	if err != nil {
 		return nil, err
@@ -804,7 +1334,14 @@ func (d *dataClient) ReplacePhase(phase *types.Phase) (*types.Phase, error) {
 	if err != nil {
 		return nil, err
 	}
-	_, err = d.Client.Update(newPhase.PhaseGroup, newPhase.Type.String())
+	_, err = d.Client.Update(phaseGroup, "Delivery", "Verification", "Deploy")
+	if err != nil {
+		return nil, err
+	}
+
+	// Take the old phase out of the pipeline.
+	phase.PhaseGroup = nil
+	_, err = d.Client.Update(phase, "PhaseGroup")
 	if err != nil {
 		return nil, err
 	}
@@ -813,44 +1350,38 @@ func (d *dataClient) ReplacePhase(phase *types.Phase) (*types.Phase, error) {
 }
 
 func (d *dataClient) createPhaseGroup(train *types.Train) (*types.PhaseGroup, error) {
-	phaseGroup := &types.PhaseGroup{HeadSHA: train.HeadSHA}
-	phaseGroup.AddNewPhase(types.Delivery, train)
-	phaseGroup.AddNewPhase(types.Verification, train)
-	phaseGroup.AddNewPhase(types.Deploy, train)
-
-	_, err := d.Client.Insert(phaseGroup.Delivery)
+	options, err := d.Options()
 	if err != nil {
 		return nil, err
 	}
-	_, err = d.Client.Insert(phaseGroup.Verification)
-	if err != nil {
-		return nil, err
+
+	phaseGroup := &types.PhaseGroup{HeadSHA: train.HeadSHA}
+	for _, config := range options.PhasePipeline() {
+		phaseGroup.AddNewPhase(config.Name, config.PhaseType(), train)
 	}
-	_, err = d.Client.Insert(phaseGroup.Deploy)
-	if err != nil {
-		return nil, err
+
+	// The phase group needs its phases' IDs, so they're inserted first and pointed at it after.
+	for _, phase := range phaseGroup.Pipeline {
+		_, err = d.Client.Insert(phase)
+		if err != nil {
+			return nil, err
+		}
+		err = d.createPhaseJobs(phase)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = d.createPhaseJobs(phaseGroup.Delivery)
-	if err != nil {
-		return nil, err
-	}
-	err = d.createPhaseJobs(phaseGroup.Verification)
-	if err != nil {
-		return nil, err
-	}
-	err = d.createPhaseJobs(phaseGroup.Deploy)
-	if err != nil {
-		return nil, err
-	}
-
-	_, err = d.Client.Insert(phaseGroup)
+	_, err = d.Client.Insert(phaseGroup)
 	if err != nil {
 		return nil, err
 	}
 
-	if err != nil {
-		return nil, err
+	for _, phase := range phaseGroup.Pipeline {
+		_, err = d.Client.Update(phase, "PhaseGroup")
+		if err != nil {
+			return nil, err
+		}
 	}
 	datadog.Info("Created phase group (ID, HeadSHA) %v, %v", phaseGroup.ID, phaseGroup.HeadSHA)
 	return phaseGroup, nil
@@ -905,7 +1436,11 @@ func (d *dataClient) RestartJob(job *types.Job, url string) error {
 }
 
 func (d *dataClient) createPhaseJobs(phase *types.Phase) error {
-	for _, jobName := range types.JobsForPhase(phase.Type) {
+	options, err := d.Options()
+	if err != nil {
+		return err
+	}
+	for _, jobName := range options.TrainJobsForPhase(phase) {
 		_, err := d.CreateJob(phase, jobName)
 		if err != nil {
 			return err
@@ -914,6 +1449,262 @@ func (d *dataClient) createPhaseJobs(phase *types.Phase) error {
 	return nil
 }
 
+/* Rollback */
+
+// Records a rollback to train, along with the deployed train being rolled back from,
+// and starts the phase its job reports to.
+func (d *dataClient) CreateRollback(train *types.Train, user *types.User) (*types.Rollback, error) {
+	err := d.Client.Begin()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	phase := &types.Phase{
+		Name:      types.RollbackPhase.String(),
+		Type:      types.RollbackPhase,
+		StartedAt: types.Time{Value: time.Now()},
+	}
+	_, err = d.Client.Insert(phase)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	job, err := d.CreateJob(phase, types.RollbackJobName)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	phase.Jobs = types.Jobs{job}
+	phase.Train = train
+
+	rollback := types.Rollback{Train: train, User: user, Phase: phase}
+
+	query := d.Client.QueryTable(&types.Train{}).
+		Filter("branch", train.Branch).
+		Filter("deployed_at__isnull", false).
+		Filter("cancelled_at__isnull", true).
+		Exclude("id", train.ID)
+	if train.IsDeployed() {
+		query = query.Filter("deployed_at__gt", train.DeployedAt.Value)
+	}
+	var fromTrain types.Train
+	err = query.OrderBy("-deployed_at").Limit(1).One(&fromTrain)
+	if err == nil {
+		rollback.FromTrain = &fromTrain
+	} else if err != orm.ErrNoRows {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	_, err = d.Client.Insert(&rollback)
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+
+	err = d.Client.Commit()
+	if err != nil {
+		d.Client.Rollback()
+		return nil, err
+	}
+	datadog.Info("Created rollback (ID, TrainID) %v, %v", rollback.ID, train.ID)
+	return &rollback, nil
+}
+
+func (d *dataClient) Rollback(id uint64) (*types.Rollback, error) {
+	rollback := &types.Rollback{ID: id}
+	err := d.Client.Read(rollback)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	err = d.loadRollbackRelated(rollback)
+	if err != nil {
+		return nil, err
+	}
+	return rollback, nil
+}
+
+// Returns the most recent rollbacks, newest first.
+func (d *dataClient) LatestRollbacks(limit int) ([]*types.Rollback, error) {
+	rollbacks := make([]*types.Rollback, 0)
+	_, err := d.Client.QueryTable(&types.Rollback{}).OrderBy("-id").Limit(limit).All(&rollbacks)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	for _, rollback := range rollbacks {
+		err = d.loadRollbackRelated(rollback)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rollbacks, nil
+}
+
+func (d *dataClient) loadRollbackRelated(rollback *types.Rollback) error {
+	_, err := d.Client.LoadRelated(rollback, "Train")
+	if err != nil {
+		return err
+	}
+	if rollback.FromTrain != nil {
+		_, err = d.Client.LoadRelated(rollback, "FromTrain")
+		if err != nil {
+			return err
+		}
+	}
+	if rollback.User != nil {
+		_, err = d.Client.LoadRelated(rollback, "User")
+		if err != nil {
+			return err
+		}
+	}
+	if rollback.Phase != nil {
+		_, err = d.Client.LoadRelated(rollback, "Phase")
+		if err != nil {
+			return err
+		}
+		_, err = d.Client.LoadRelated(rollback.Phase, "Jobs")
+		if err != nil {
+			return err
+		}
+		sort.Sort(types.JobsByID(rollback.Phase.Jobs))
+		for _, job := range rollback.Phase.Jobs {
+			job.Phase = rollback.Phase
+		}
+	}
+	return nil
+}
+
+// Returns the rollback whose job reports to the phase, or nil if it isn't a rollback phase.
+func (d *dataClient) RollbackForPhase(phase *types.Phase) (*types.Rollback, error) {
+	rollback := &types.Rollback{}
+	err := d.Client.QueryTable(rollback).Filter("phase_id", phase.ID).One(rollback)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	err = d.loadRollbackRelated(rollback)
+	if err != nil {
+		return nil, err
+	}
+	return rollback, nil
+}
+
+// Sets whether the rollback is still running, and when it completed if not.
+func (d *dataClient) SetRollbackStatus(rollback *types.Rollback, status types.RollbackStatus, rollbackErr string) error {
+	rollback.Status = status
+	rollback.CompletedAt = types.Time{}
+	if status != types.RollbackStarted {
+		rollback.CompletedAt = types.Time{Value: time.Now()}
+	}
+	rollback.Error = rollbackErr
+	_, err := d.Client.Update(rollback, "Status", "CompletedAt", "Error")
+	if err == nil {
+		datadog.Info("Set rollback status (ID, Status) %v, %v", rollback.ID, status)
+	}
+	return err
+}
+
+// Returns the latest rollback to train, or nil.
+func (d *dataClient) latestRollbackTo(train *types.Train) (*types.Rollback, error) {
+	rollback := &types.Rollback{}
+	err := d.Client.QueryTable(rollback).Filter("train_id", train.ID).OrderBy("-id").One(rollback)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	err = d.loadRollbackRelated(rollback)
+	if err != nil {
+		return nil, err
+	}
+	return rollback, nil
+}
+
+// Returns the most recently deployed trains, newest first, without their related fields.
+func (d *dataClient) DeployedTrains(limit int) ([]*types.Train, error) {
+	trains := make([]*types.Train, 0)
+	_, err := d.Client.QueryTable(&types.Train{}).
+		Filter("deployed_at__isnull", false).
+		Filter("cancelled_at__isnull", true).
+		OrderBy("-deployed_at").
+		Limit(limit).
+		All(&trains)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	return trains, nil
+}
+
+/* Approval */
+func (d *dataClient) SetApprovalRules(train *types.Train, rules []string) error {
+	train.ApprovalRules = strings.Join(rules, ",")
+	_, err := d.Client.Update(train, "ApprovalRules")
+	if err != nil {
+		return err
+	}
+	datadog.Info("Set approval rules for train (ID, Rules) %v, %v", train.ID, train.ApprovalRules)
+
+	options, err := d.Options()
+	if err != nil {
+		return err
+	}
+	return d.loadApprovals(train, options)
+}
+
+func (d *dataClient) CreateApproval(train *types.Train, user *types.User, rule string) (*types.Approval, error) {
+	approval := types.Approval{Train: train, User: user, Rule: rule, HeadSHA: train.HeadSHA}
+	_, err := d.Client.Insert(&approval)
+	if err != nil {
+		return nil, err
+	}
+	datadog.Info("Created approval (ID, TrainID, User, Rule) %v, %v, %v, %v",
+		approval.ID, train.ID, user.Email, rule)
+	return &approval, nil
+}
+
+// Approvals only count for the train's current head, so extending it needs new ones.
+func (d *dataClient) loadApprovals(train *types.Train, options *types.Options) error {
+	train.Approvals = make([]*types.ApprovalStatus, 0)
+	names := train.ApprovalRuleNames()
+	if len(names) == 0 {
+		return nil
+	}
+
+	var approvals []*types.Approval
+	_, err := d.Client.QueryTable(&types.Approval{}).
+		Filter("train_id", train.ID).
+		Filter("head_sha", train.HeadSHA).
+		RelatedSel("User").
+		OrderBy("id").
+		All(&approvals)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		rule := options.Approvals.Rule(name)
+		if rule == nil {
+			// The rule was removed from the options.
+			continue
+		}
+		status := &types.ApprovalStatus{Rule: name, Required: rule.Required, Approvals: []*types.Approval{}}
+		for _, approval := range approvals {
+			if approval.Rule == name {
+				status.Approvals = append(status.Approvals, approval)
+			}
+		}
+		train.Approvals = append(train.Approvals, status)
+	}
+	return nil
+}
+
 /* Commit */
 func (d *dataClient) WriteCommits(commits []*types.Commit) ([]*types.Commit, error) {
 	newCommits := make([]*types.Commit, 0)
@@ -965,7 +1756,7 @@ func (d *dataClient) TrainsByCommit(commit *types.Commit) ([]*types.Train, error
 
 /* User */
 
-func (d *dataClient) WriteToken(newToken, name, email, avatar, codeToken string) error {
+func (d *dataClient) WriteToken(newToken, name, email, avatar string, groups []string) error {
 	user := types.User{
 		Email: email,
 	}
@@ -977,9 +1768,15 @@ func (d *dataClient) WriteToken(newToken, name, email, avatar, codeToken string)
 	}
 
 	auth := types.Auth{
-		User:      &user,
-		Token:     newToken,
-		CodeToken: codeToken,
+		User:  &user,
+		Token: newToken,
+	}
+	if len(groups) > 0 {
+		groupsJSON, err := json.Marshal(groups)
+		if err != nil {
+			return err
+		}
+		auth.Groups = string(groupsJSON)
 	}
 
 	tokens := make([]*types.Auth, 0)
@@ -989,7 +1786,7 @@ func (d *dataClient) WriteToken(newToken, name, email, avatar, codeToken string)
 	if err != nil && err != orm.ErrNoRows {
 		return err
 	} else {
-		// Insert a new token (including the code token).
+		// Insert a new token.
 		_, err = d.Client.Insert(&auth)
 		if err != nil && !(err.Error() == "LastInsertId is not supported by this driver" || err.Error() == "no LastInsertId available") {
 			return err
@@ -1043,7 +1840,29 @@ func (d *dataClient) ReadOrCreateUser(name, email string) (*types.User, error) {
 	return &user, nil
 }
 
+// Returns nil if there is no user with the email.
+func (d *dataClient) UserByEmail(email string) (*types.User, error) {
+	user := types.User{Email: email}
+	err := d.Client.Read(&user, "Email")
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (d *dataClient) UserByToken(token string) (*types.User, error) {
+	auth, err := d.Session(token)
+	if err != nil {
+		return nil, err
+	}
+	return auth.User, nil
+}
+
+// Returns the session for the token, with its user.
+func (d *dataClient) Session(token string) (*types.Auth, error) {
 	auth := types.Auth{Token: token}
 	err := d.Client.Read(&auth)
 	if err != nil {
@@ -1055,8 +1874,296 @@ func (d *dataClient) UserByToken(token string) (*types.User, error) {
 		return nil, err
 	}
 
+	if auth.Groups != "" {
+		err = json.Unmarshal([]byte(auth.Groups), &auth.User.Groups)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	auth.User.Token = token
-	return auth.User, nil
+	return &auth, nil
+}
+
+// Returns the sessions of the user, newest first.
+func (d *dataClient) Sessions(user *types.User) ([]*types.Auth, error) {
+	sessions := make([]*types.Auth, 0)
+	_, err := d.Client.QueryTable(&types.Auth{}).
+		Filter("User", user).
+		OrderBy("-created_at").
+		All(&sessions)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.User = user
+	}
+	return sessions, nil
+}
+
+func (d *dataClient) TouchSession(auth *types.Auth) error {
+	auth.LastUsedAt = types.Time{Value: time.Now()}
+	_, err := d.Client.Update(auth, "LastUsedAt")
+	return err
+}
+
+func (d *dataClient) RevokeSession(auth *types.Auth) error {
+	_, err := d.Client.Delete(auth)
+	if err != nil {
+		return err
+	}
+	datadog.Info("Revoked session %v of user %v", auth.SessionID(), auth.User.Email)
+	return nil
+}
+
+// Deletes sessions that expired, returning how many there were.
+func (d *dataClient) DeleteExpiredSessions(idleTimeout, maxLifetime time.Duration) (int64, error) {
+	now := time.Now()
+	cond := orm.NewCondition()
+	idle := cond.And("last_used_at__isnull", true).And("created_at__lt", now.Add(-idleTimeout))
+	expired := cond.
+		Or("created_at__lt", now.Add(-maxLifetime)).
+		Or("last_used_at__lt", now.Add(-idleTimeout)).
+		OrCond(idle)
+	count, err := d.Client.QueryTable(&types.Auth{}).SetCond(expired).Delete()
+	if err != nil {
+		return 0, err
+	}
+	if count > 0 {
+		datadog.Info("Deleted %d expired sessions", count)
+	}
+	return count, nil
+}
+
+/* API token */
+
+var ErrAPITokenNameTaken = errors.New("An API token with that name already exists")
+
+// Requests with an API token act as a user of the same name, so they can be told apart from people.
+func apiTokenEmail(name string) string {
+	return fmt.Sprintf("%s@api-token.invalid", name)
+}
+
+func (d *dataClient) CreateAPIToken(
+	name, tokenHash string, scopes []types.Scope, expiresAt *time.Time,
+	createdBy *types.User) (*types.APIToken, error) {
+
+	exists := d.Client.QueryTable(&types.APIToken{}).Filter("name", name).Exist()
+	if exists {
+		return nil, ErrAPITokenNameTaken
+	}
+
+	user, err := d.ReadOrCreateUser(name, apiTokenEmail(name))
+	if err != nil {
+		return nil, err
+	}
+
+	token := types.APIToken{
+		Name:      name,
+		TokenHash: tokenHash,
+		Scopes:    types.FormatScopes(scopes),
+		User:      user,
+		CreatedBy: createdBy,
+	}
+	if expiresAt != nil {
+		token.ExpiresAt = types.Time{Value: *expiresAt}
+	}
+	_, err = d.Client.Insert(&token)
+	if err != nil {
+		return nil, err
+	}
+	datadog.Info("Created API token (ID, Name) %v, %v", token.ID, token.Name)
+	return &token, nil
+}
+
+func (d *dataClient) APITokens() ([]*types.APIToken, error) {
+	tokens := make([]*types.APIToken, 0)
+	_, err := d.Client.QueryTable(&types.APIToken{}).OrderBy("-id").All(&tokens)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	for _, token := range tokens {
+		err = d.loadAPITokenRelated(token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// Returns nil if there is no token with the ID.
+func (d *dataClient) APIToken(id uint64) (*types.APIToken, error) {
+	token := types.APIToken{ID: id}
+	err := d.Client.Read(&token)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	err = d.loadAPITokenRelated(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (d *dataClient) APITokenByHash(tokenHash string) (*types.APIToken, error) {
+	token := types.APIToken{TokenHash: tokenHash}
+	err := d.Client.Read(&token, "TokenHash")
+	if err != nil {
+		return nil, err
+	}
+	err = d.loadAPITokenRelated(&token)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (d *dataClient) loadAPITokenRelated(token *types.APIToken) error {
+	_, err := d.Client.LoadRelated(token, "User")
+	if err != nil {
+		return err
+	}
+	_, err = d.Client.LoadRelated(token, "CreatedBy")
+	if err != nil {
+		return err
+	}
+	if token.RevokedBy != nil {
+		_, err = d.Client.LoadRelated(token, "RevokedBy")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dataClient) TouchAPIToken(token *types.APIToken) error {
+	token.LastUsedAt = types.Time{Value: time.Now()}
+	_, err := d.Client.Update(token, "LastUsedAt")
+	return err
+}
+
+func (d *dataClient) RevokeAPIToken(token *types.APIToken, user *types.User) error {
+	token.RevokedAt = types.Time{Value: time.Now()}
+	token.RevokedBy = user
+	_, err := d.Client.Update(token, "RevokedAt", "RevokedBy")
+	if err != nil {
+		return err
+	}
+	datadog.Info("Revoked API token (ID, Name) %v, %v", token.ID, token.Name)
+	return nil
+}
+
+/* Role */
+
+func (d *dataClient) UserRoles() ([]*types.UserRole, error) {
+	roles := make([]*types.UserRole, 0)
+	_, err := d.Client.QueryTable(&types.UserRole{}).OrderBy("-role", "id").All(&roles)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	for _, role := range roles {
+		err = d.loadUserRoleRelated(role)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return roles, nil
+}
+
+// Returns nil if the user hasn't been assigned a role.
+func (d *dataClient) UserRole(user *types.User) (*types.UserRole, error) {
+	role := types.UserRole{User: user}
+	err := d.Client.Read(&role, "User")
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	role.User = user
+	return &role, nil
+}
+
+func (d *dataClient) SetUserRole(user *types.User, role types.Role, assignedBy *types.User) (*types.UserRole, error) {
+	userRole, err := d.UserRole(user)
+	if err != nil {
+		return nil, err
+	}
+	if userRole == nil {
+		userRole = &types.UserRole{User: user, Role: role, AssignedBy: assignedBy}
+		_, err = d.Client.Insert(userRole)
+	} else {
+		userRole.Role = role
+		userRole.AssignedBy = assignedBy
+		_, err = d.Client.Update(userRole, "Role", "AssignedBy", "AssignedAt")
+	}
+	if err != nil {
+		return nil, err
+	}
+	datadog.Info("Assigned role %v to user %v", role, user.Email)
+	return userRole, nil
+}
+
+func (d *dataClient) DeleteUserRole(userRole *types.UserRole) error {
+	_, err := d.Client.Delete(userRole)
+	if err != nil {
+		return err
+	}
+	datadog.Info("Removed role %v from user %v", userRole.Role, userRole.User.ID)
+	return nil
+}
+
+func (d *dataClient) loadUserRoleRelated(role *types.UserRole) error {
+	_, err := d.Client.LoadRelated(role, "User")
+	if err != nil {
+		return err
+	}
+	_, err = d.Client.LoadRelated(role, "AssignedBy")
+	return err
+}
+
+/* Audit */
+
+func (d *dataClient) CreateAuditEvent(event *types.AuditEvent) error {
+	_, err := d.Client.Insert(event)
+	return err
+}
+
+func (d *dataClient) AuditEvents(filter *types.AuditFilter) ([]*types.AuditEvent, error) {
+	query := d.Client.QueryTable(&types.AuditEvent{})
+	if filter.Actor == types.ConductorActor {
+		query = query.Filter("actor__isnull", true)
+	} else if filter.Actor != "" {
+		query = query.Filter("Actor__Email", filter.Actor)
+	}
+	if filter.Action != nil {
+		query = query.Filter("action", *filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Filter("target_type", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Filter("target_id", filter.TargetID)
+	}
+	if filter.After != nil {
+		query = query.Filter("created_at__gte", *filter.After)
+	}
+	if filter.Before != nil {
+		query = query.Filter("created_at__lt", *filter.Before)
+	}
+	if filter.Cursor != 0 {
+		query = query.Filter("id__lt", filter.Cursor)
+	}
+
+	events := make([]*types.AuditEvent, 0)
+	_, err := query.OrderBy("-id").Limit(filter.Limit).RelatedSel("Actor").All(&events)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	return events, nil
 }
 
 /* Ticket */
@@ -1105,6 +2212,19 @@ func (d *dataClient) UpdateTickets(tickets []*types.Ticket) error {
 	return nil
 }
 
+// Removes the ticket, so that its commits get a new one when the train is next delivered.
+func (d *dataClient) DeleteTicket(ticket *types.Ticket) error {
+	_, err := d.Client.QueryM2M(ticket, "Commits").Clear()
+	if err != nil {
+		return err
+	}
+	_, err = d.Client.Delete(ticket)
+	if err == nil {
+		datadog.Info("Deleted ticket (ID, Key) %v, %v", ticket.ID, ticket.Key)
+	}
+	return err
+}
+
 /* Metadata */
 
 var ErrNoSuchNamespaceOrKey = errors.New("No such namespace or key")
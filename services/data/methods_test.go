@@ -338,7 +338,7 @@ func TestTokens(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Test can get user by token.
-	err = data.WriteToken("a", user1.Name, user1.Email, "", "")
+	err = data.WriteToken("a", user1.Name, user1.Email, "", nil)
 	assert.NoError(t, err)
 	fetchedUser, err := data.UserByToken("a")
 	assert.NoError(t, err)
@@ -346,7 +346,7 @@ func TestTokens(t *testing.T) {
 	assert.Equal(t, "a", fetchedUser.Token)
 
 	// Test can get user by token for a different user and token.
-	err = data.WriteToken("b", user2.Name, user2.Email, "", "")
+	err = data.WriteToken("b", user2.Name, user2.Email, "", nil)
 	assert.NoError(t, err)
 	fetchedUser, err = data.UserByToken("b")
 	assert.NoError(t, err)
@@ -378,11 +378,11 @@ func TestTokens(t *testing.T) {
 	assert.Nil(t, fetchedUser)
 
 	// Test multiple tokens for same user.
-	err = data.WriteToken("a", user1.Name, user1.Email, "", "")
+	err = data.WriteToken("a", user1.Name, user1.Email, "", nil)
 	assert.NoError(t, err)
-	err = data.WriteToken("b", user1.Name, user1.Email, "", "")
+	err = data.WriteToken("b", user1.Name, user1.Email, "", nil)
 	assert.NoError(t, err)
-	err = data.WriteToken("c", user1.Name, user1.Email, "", "")
+	err = data.WriteToken("c", user1.Name, user1.Email, "", nil)
 	assert.NoError(t, err)
 
 	// Test gets correct token.
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/Nextdoor/conductor/shared/datadog"
+)
+
+// Schema changes syncdb can't make, each run once, in order.
+// A migration's name is recorded when it succeeds, so don't rename or reorder them.
+type migration struct {
+	name string
+	run  func(orm.Ormer) error
+}
+
+var migrations = []migration{
+	{name: "search_indexes", run: createSearchIndexes},
+	{name: "drop_code_tokens", run: dropCodeTokens},
+}
+
+func runMigrations(client orm.Ormer) error {
+	_, err := client.Raw(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %smigration (
+		name text PRIMARY KEY,
+		applied_at timestamp with time zone NOT NULL DEFAULT now())`, tablePrefix)).Exec()
+	if err != nil {
+		return fmt.Errorf("Error creating migration table: %v", err)
+	}
+
+	for _, m := range migrations {
+		var applied int
+		err = client.Raw(fmt.Sprintf(`SELECT count(*) FROM %smigration WHERE name = ?`, tablePrefix), m.name).
+			QueryRow(&applied)
+		if err != nil {
+			return fmt.Errorf("Error checking migration %s: %v", m.name, err)
+		}
+		if applied > 0 {
+			continue
+		}
+
+		err = m.run(client)
+		if err != nil {
+			return fmt.Errorf("Migration %s failed: %v", m.name, err)
+		}
+		_, err = client.Raw(fmt.Sprintf(
+			`INSERT INTO %smigration (name) VALUES (?) ON CONFLICT DO NOTHING`, tablePrefix), m.name).Exec()
+		if err != nil {
+			return fmt.Errorf("Error recording migration %s: %v", m.name, err)
+		}
+		datadog.Info("Ran migration %s", m.name)
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+const defaultSearchLimit = 50
+
+// Indexes backing search.
+// pg_trgm makes ILIKE substring matches on names, emails and messages use an index,
+// and the tsvector index backs full-text matches on commit messages.
+type searchIndex struct {
+	name       string
+	definition string
+}
+
+var searchIndexes = []searchIndex{
+	{"commit_sha_prefix_idx", "%[1]scommit (sha text_pattern_ops)"},
+	{"commit_message_fts_idx", "%[1]scommit USING gin (to_tsvector('english', message))"},
+	{"commit_message_trgm_idx", "%[1]scommit USING gin (message gin_trgm_ops)"},
+	{"commit_author_name_trgm_idx", "%[1]scommit USING gin (author_name gin_trgm_ops)"},
+	{"commit_author_email_trgm_idx", "%[1]scommit USING gin (author_email gin_trgm_ops)"},
+	{"user_name_trgm_idx", "%[1]suser USING gin (name gin_trgm_ops)"},
+	{"user_email_trgm_idx", "%[1]suser USING gin (email gin_trgm_ops)"},
+	{"ticket_key_upper_idx", "%[1]sticket (upper(key))"},
+}
+
+// Indexes are built concurrently so writes to the tables aren't blocked while they're built.
+// A concurrent build that fails leaves an invalid index behind, which IF NOT EXISTS would skip,
+// so invalid indexes are dropped and built again, and the migration errors until they're all valid.
+func createSearchIndexes(client orm.Ormer) error {
+	err := requireTrigramExtension(client)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range searchIndexes {
+		name := tablePrefix + index.name
+		valid, err := indexValidity(client, name)
+		if err != nil {
+			return err
+		}
+		if valid != nil && !*valid {
+			_, err = client.Raw(fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS %s`, name)).Exec()
+			if err != nil {
+				return fmt.Errorf("Error dropping invalid search index %s: %v", name, err)
+			}
+		}
+
+		_, err = client.Raw(fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS %[2]s ON `+index.definition,
+			tablePrefix, name)).Exec()
+		if err != nil {
+			return fmt.Errorf("Error creating search index %s: %v", name, err)
+		}
+	}
+
+	for _, index := range searchIndexes {
+		name := tablePrefix + index.name
+		valid, err := indexValidity(client, name)
+		if err != nil {
+			return err
+		}
+		if valid == nil || !*valid {
+			return fmt.Errorf("Search index %s wasn't built", name)
+		}
+	}
+	return nil
+}
+
+// Whether the index is valid, or nil if it doesn't exist.
+func indexValidity(client orm.Ormer, name string) (*bool, error) {
+	var count, validCount int
+	err := client.Raw(`SELECT count(*), count(nullif(i.indisvalid, false))
+		FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE c.relname = ?`, name).QueryRow(&count, &validCount)
+	if err != nil {
+		return nil, fmt.Errorf("Error checking search index %s: %v", name, err)
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	valid := validCount == count
+	return &valid, nil
+}
+
+// Creating pg_trgm needs superuser on most Postgres setups,
+// so it's only created if it's missing, and a missing extension says what to do about it.
+func requireTrigramExtension(client orm.Ormer) error {
+	var installed int
+	err := client.Raw(`SELECT count(*) FROM pg_extension WHERE extname = 'pg_trgm'`).QueryRow(&installed)
+	if err != nil {
+		return err
+	}
+	if installed > 0 {
+		return nil
+	}
+
+	_, err = client.Raw(`CREATE EXTENSION IF NOT EXISTS pg_trgm`).Exec()
+	if err != nil {
+		return fmt.Errorf(
+			"The pg_trgm extension is needed for search and couldn't be created (%v). "+
+				"Have a superuser run CREATE EXTENSION pg_trgm in the conductor database, then restart.", err)
+	}
+	return nil
+}
+
+// Escapes LIKE wildcards so user input is matched literally.
+func likeEscape(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `%`, `\%`, -1)
+	s = strings.Replace(s, `_`, `\_`, -1)
+	return s
+}
+
+// A SQL condition and the score it contributes when matched.
+// The score expression takes the same arguments as the condition.
+type searchClause struct {
+	condition string
+	score     string
+	args      []interface{}
+}
+
+func shaClause(sha string) searchClause {
+	sha = strings.ToLower(sha)
+	prefix := likeEscape(sha) + "%"
+	return searchClause{
+		condition: `c.sha LIKE ?`,
+		score:     `(CASE WHEN c.sha LIKE ? THEN 4 ELSE 0 END)`,
+		args:      []interface{}{prefix},
+	}
+}
+
+func messageClause(message string) searchClause {
+	substring := "%" + likeEscape(message) + "%"
+	return searchClause{
+		condition: `(to_tsvector('english', c.message) @@ plainto_tsquery('english', ?) OR c.message ILIKE ?)`,
+		score: `(ts_rank(to_tsvector('english', c.message), plainto_tsquery('english', ?)) +
+			(CASE WHEN c.message ILIKE ? THEN 1 ELSE 0 END))`,
+		args: []interface{}{message, substring},
+	}
+}
+
+func authorClause(author string) searchClause {
+	substring := "%" + likeEscape(author) + "%"
+	return searchClause{
+		condition: `(c.author_name ILIKE ? OR c.author_email ILIKE ? OR lower(c.author_email) = lower(?))`,
+		score:     `(CASE WHEN c.author_name ILIKE ? OR c.author_email ILIKE ? THEN 1 ELSE 0 END + CASE WHEN lower(c.author_email) = lower(?) THEN 1 ELSE 0 END)`,
+		args:      []interface{}{substring, substring, author},
+	}
+}
+
+func ticketClause(key string) searchClause {
+	exists := fmt.Sprintf(`EXISTS (
+		SELECT 1 FROM %[1]sticket_%[1]scommits tc
+		JOIN %[1]sticket tk ON tk.id = tc.%[1]sticket_id
+		WHERE tc.%[1]scommit_id = c.id AND upper(tk.key) = upper(?))`, tablePrefix)
+	return searchClause{
+		condition: exists,
+		score:     fmt.Sprintf(`(CASE WHEN %s THEN 3 ELSE 0 END)`, exists),
+		args:      []interface{}{key},
+	}
+}
+
+type searchRank struct {
+	ID   uint64  `orm:"column(id)"`
+	Rank float64 `orm:"column(rank)"`
+}
+
+// Returns commit IDs with their relevance rank, most relevant first.
+func (d *dataClient) searchCommitRanks(query *types.SearchQuery) ([]searchRank, error) {
+	var required []searchClause
+	if query.SHA != "" {
+		required = append(required, shaClause(query.SHA))
+	}
+	if query.Message != "" {
+		required = append(required, messageClause(query.Message))
+	}
+	if query.Author != "" {
+		required = append(required, authorClause(query.Author))
+	}
+	if query.TicketKey != "" {
+		required = append(required, ticketClause(query.TicketKey))
+	}
+
+	var anyOf []searchClause
+	if query.Text != "" {
+		anyOf = []searchClause{
+			shaClause(query.Text),
+			messageClause(query.Text),
+			authorClause(query.Text),
+			ticketClause(query.Text),
+		}
+	}
+
+	var conditions []string
+	var conditionArgs []interface{}
+	var scores []string
+	var scoreArgs []interface{}
+	for _, clause := range required {
+		conditions = append(conditions, clause.condition)
+		conditionArgs = append(conditionArgs, clause.args...)
+		scores = append(scores, clause.score)
+		scoreArgs = append(scoreArgs, clause.args...)
+	}
+	if len(anyOf) > 0 {
+		var anyConditions []string
+		for _, clause := range anyOf {
+			anyConditions = append(anyConditions, clause.condition)
+			conditionArgs = append(conditionArgs, clause.args...)
+			scores = append(scores, clause.score)
+			scoreArgs = append(scoreArgs, clause.args...)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(anyConditions, " OR ")))
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT c.id AS id, (%s)::float8 AS rank
+		FROM %scommit c
+		WHERE %s
+		ORDER BY rank DESC, c.id DESC
+		LIMIT ?`,
+		strings.Join(scores, " + "),
+		tablePrefix,
+		strings.Join(conditions, " AND "))
+
+	args := append(scoreArgs, conditionArgs...)
+	args = append(args, query.Limit)
+
+	ranks := make([]searchRank, 0)
+	_, err := d.Client.Raw(sql, args...).QueryRows(&ranks)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+// Returns train IDs with a matching engineer, with their relevance rank.
+func (d *dataClient) searchEngineerTrainRanks(engineer string, limit int) ([]searchRank, error) {
+	substring := "%" + likeEscape(engineer) + "%"
+	sql := fmt.Sprintf(`
+		SELECT t.id AS id,
+			(CASE WHEN lower(u.email) = lower(?) OR lower(u.name) = lower(?) THEN 2 ELSE 1 END)::float8 AS rank
+		FROM %[1]strain t
+		JOIN %[1]suser u ON u.id = t.engineer_id
+		WHERE u.name ILIKE ? OR u.email ILIKE ?
+		ORDER BY rank DESC, t.id DESC
+		LIMIT ?`,
+		tablePrefix)
+
+	ranks := make([]searchRank, 0)
+	_, err := d.Client.Raw(sql, engineer, engineer, substring, substring, limit).QueryRows(&ranks)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	return ranks, nil
+}
+
+type trainCommit struct {
+	TrainID  uint64 `orm:"column(train_id)"`
+	CommitID uint64 `orm:"column(commit_id)"`
+}
+
+func (d *dataClient) trainsForCommits(commitIDs []uint64) ([]trainCommit, error) {
+	if len(commitIDs) == 0 {
+		return []trainCommit{}, nil
+	}
+	placeholders := make([]string, len(commitIDs))
+	args := make([]interface{}, len(commitIDs))
+	for i, id := range commitIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	sql := fmt.Sprintf(`
+		SELECT %[1]strain_id AS train_id, %[1]scommit_id AS commit_id
+		FROM %[1]strain_%[1]scommits
+		WHERE %[1]scommit_id IN (%[2]s)`,
+		tablePrefix, strings.Join(placeholders, ", "))
+
+	rows := make([]trainCommit, 0)
+	_, err := d.Client.Raw(sql, args...).QueryRows(&rows)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (d *dataClient) Search(query *types.SearchQuery) (*types.SearchResults, error) {
+	if query.Limit <= 0 {
+		query.Limit = defaultSearchLimit
+	}
+
+	results := &types.SearchResults{
+		Trains:  []*types.TrainSummary{},
+		Commits: []*types.CommitMatch{},
+	}
+
+	// Train ID -> rank.
+	trainRanks := make(map[uint64]float64)
+
+	var commitMatches []*types.CommitMatch
+	if query.HasCommitCriteria() {
+		commitRanks, err := d.searchCommitRanks(query)
+		if err != nil {
+			return nil, err
+		}
+
+		commitIDs := make([]uint64, len(commitRanks))
+		commitRankByID := make(map[uint64]float64)
+		for i, commitRank := range commitRanks {
+			commitIDs[i] = commitRank.ID
+			commitRankByID[commitRank.ID] = commitRank.Rank
+		}
+
+		if len(commitIDs) > 0 {
+			commits := make([]*types.Commit, 0)
+			_, err = d.Client.QueryTable(&types.Commit{}).Filter("id__in", commitIDs).All(&commits)
+			if err != nil && err != orm.ErrNoRows {
+				return nil, err
+			}
+			for _, commit := range commits {
+				commitMatches = append(commitMatches, &types.CommitMatch{
+					Commit:   commit,
+					TrainIDs: []string{},
+					Rank:     commitRankByID[commit.ID],
+				})
+			}
+		}
+
+		trainCommits, err := d.trainsForCommits(commitIDs)
+		if err != nil {
+			return nil, err
+		}
+		matchByCommitID := make(map[uint64]*types.CommitMatch)
+		for _, match := range commitMatches {
+			matchByCommitID[match.ID] = match
+		}
+		for _, trainCommit := range trainCommits {
+			match := matchByCommitID[trainCommit.CommitID]
+			if match == nil {
+				continue
+			}
+			match.TrainIDs = append(match.TrainIDs, strconv.FormatUint(trainCommit.TrainID, 10))
+			// A train is as relevant as its most relevant commit.
+			if match.Rank > trainRanks[trainCommit.TrainID] {
+				trainRanks[trainCommit.TrainID] = match.Rank
+			}
+		}
+	}
+
+	engineer := query.Engineer
+	if engineer == "" {
+		engineer = query.Text
+	}
+	if engineer != "" {
+		engineerRanks, err := d.searchEngineerTrainRanks(engineer, query.Limit)
+		if err != nil {
+			return nil, err
+		}
+		engineerTrainRanks := make(map[uint64]float64)
+		for _, engineerRank := range engineerRanks {
+			engineerTrainRanks[engineerRank.ID] = engineerRank.Rank
+		}
+
+		if query.Engineer != "" && query.HasCommitCriteria() {
+			// Both are required, so only keep trains matching both.
+			for trainID := range trainRanks {
+				if rank, ok := engineerTrainRanks[trainID]; ok {
+					trainRanks[trainID] += rank
+				} else {
+					delete(trainRanks, trainID)
+				}
+			}
+			filteredMatches := make([]*types.CommitMatch, 0)
+			for _, match := range commitMatches {
+				for _, trainID := range match.TrainIDs {
+					id, _ := strconv.ParseUint(trainID, 10, 64)
+					if _, ok := trainRanks[id]; ok {
+						filteredMatches = append(filteredMatches, match)
+						break
+					}
+				}
+			}
+			commitMatches = filteredMatches
+		} else {
+			for trainID, rank := range engineerTrainRanks {
+				trainRanks[trainID] += rank
+			}
+		}
+	}
+
+	sort.SliceStable(commitMatches, func(i, j int) bool {
+		if commitMatches[i].Rank != commitMatches[j].Rank {
+			return commitMatches[i].Rank > commitMatches[j].Rank
+		}
+		return commitMatches[i].ID > commitMatches[j].ID
+	})
+	if commitMatches != nil {
+		results.Commits = commitMatches
+	}
+
+	if len(trainRanks) == 0 {
+		return results, nil
+	}
+
+	trainIDs := make([]uint64, 0, len(trainRanks))
+	for trainID := range trainRanks {
+		trainIDs = append(trainIDs, trainID)
+	}
+	trains := make([]*types.Train, 0)
+	_, err := d.Client.QueryTable(&types.Train{}).Filter("id__in", trainIDs).All(&trains)
+	if err != nil && err != orm.ErrNoRows {
+		return nil, err
+	}
+	sort.SliceStable(trains, func(i, j int) bool {
+		rankI, rankJ := trainRanks[trains[i].ID], trainRanks[trains[j].ID]
+		if rankI != rankJ {
+			return rankI > rankJ
+		}
+		return trains[i].ID > trains[j].ID
+	})
+	if len(trains) > query.Limit {
+		trains = trains[:query.Limit]
+	}
+
+	results.Trains, err = d.summarizeTrains(trains)
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"sort"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+// Trains deployed or cancelled between start and end, with commits and active phases loaded.
+// An empty branch matches all branches.
+func (d *dataClient) ReleaseTrains(branch string, start, end time.Time) ([]*types.Train, error) {
+	cond := orm.NewCondition()
+	deployed := cond.And("deployed_at__gte", start).And("deployed_at__lt", end)
+	cancelled := cond.And("cancelled_at__gte", start).And("cancelled_at__lt", end)
+
+	query := d.Client.QueryTable(&types.Train{}).SetCond(cond.AndCond(deployed).OrCond(cancelled))
+	if branch != "" {
+		query = query.Filter("branch", branch)
+	}
+
+	trains := make([]*types.Train, 0)
+	_, err := query.OrderBy("id").All(&trains)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return trains, nil
+		}
+		return nil, err
+	}
+
+	for _, train := range trains {
+		_, err = d.Client.LoadRelated(train, "Commits")
+		if err != nil {
+			return nil, err
+		}
+		sort.Sort(types.CommitsByID(train.Commits))
+
+		_, err = d.Client.LoadRelated(train, "ActivePhases", 1)
+		if err != nil {
+			return nil, err
+		}
+		err = d.loadPipeline(train.ActivePhases, train)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return trains, nil
+}
+
+// Rollbacks initiated between start and end, with the train rolled back from loaded.
+// An empty branch matches all branches.
+func (d *dataClient) Rollbacks(branch string, start, end time.Time) ([]*types.Rollback, error) {
+	query := d.Client.QueryTable(&types.Rollback{}).
+		Filter("created_at__gte", start).
+		Filter("created_at__lt", end)
+	if branch != "" {
+		query = query.Filter("Train__Branch", branch)
+	}
+
+	rollbacks := make([]*types.Rollback, 0)
+	_, err := query.OrderBy("id").All(&rollbacks)
+	if err != nil {
+		if err == orm.ErrNoRows {
+			return rollbacks, nil
+		}
+		return nil, err
+	}
+
+	for _, rollback := range rollbacks {
+		if rollback.FromTrain == nil {
+			continue
+		}
+		_, err = d.Client.LoadRelated(rollback, "FromTrain")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rollbacks, nil
+}
@@ -0,0 +1,71 @@
+/* Handles polling the health checks that gate phases, like a canary's error rate. */
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+var (
+	implementationFlag = flags.EnvString("HEALTH_IMPL", "fake")
+)
+
+type Service interface {
+	// Returns an error only if the check couldn't be run, e.g. Prometheus is down.
+	Check(ctx context.Context, check types.HealthCheck) (*Result, error)
+}
+
+type Result struct {
+	Healthy bool
+	// What the check saw, like "503 Service Unavailable" or "0.07".
+	Detail string
+}
+
+// Describes a failed check, like "Health check error-rate failed: 0.07 is over 0.05".
+func (r *Result) Breach(check types.HealthCheck) string {
+	if check.Query != "" {
+		return fmt.Sprintf("Health check %s failed: %s is over %v", check.Name, r.Detail, check.Max)
+	}
+	return fmt.Sprintf("Health check %s failed: %s", check.Name, r.Detail)
+}
+
+var (
+	service Service
+	getOnce sync.Once
+)
+
+func GetService() Service {
+	getOnce.Do(func() {
+		service = newService()
+	})
+	return service
+}
+
+func newService() Service {
+	logger.Info("Using %s implementation for Health service", implementationFlag)
+	var service Service
+	switch implementationFlag {
+	case "fake":
+		service = newFake()
+	case "http":
+		service = newHTTP()
+	default:
+		panic(fmt.Errorf("Unknown Health Implementation: %s", implementationFlag))
+	}
+	return service
+}
+
+type fake struct{}
+
+func newFake() *fake {
+	return &fake{}
+}
+
+func (h *fake) Check(ctx context.Context, check types.HealthCheck) (*Result, error) {
+	return &Result{Healthy: true}, nil
+}
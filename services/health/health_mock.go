@@ -0,0 +1,18 @@
+package health
+
+import (
+	"context"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+type HealthServiceMock struct {
+	CheckMock func(ctx context.Context, check types.HealthCheck) (*Result, error)
+}
+
+func (m *HealthServiceMock) Check(ctx context.Context, check types.HealthCheck) (*Result, error) {
+	if m.CheckMock == nil {
+		return &Result{Healthy: true}, nil
+	}
+	return m.CheckMock(ctx, check)
+}
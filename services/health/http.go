@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/tracing"
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+var (
+	// Where health check queries are run, like http://prometheus:9090.
+	prometheusURL = flags.EnvString("PROMETHEUS_URL", "")
+)
+
+type httpHealth struct {
+	PrometheusURL string
+}
+
+func newHTTP() *httpHealth {
+	return &httpHealth{PrometheusURL: prometheusURL}
+}
+
+func (h *httpHealth) Check(ctx context.Context, check types.HealthCheck) (*Result, error) {
+	if check.Query != "" {
+		return h.checkQuery(ctx, check)
+	}
+	return h.checkURL(ctx, check)
+}
+
+func (h *httpHealth) checkURL(ctx context.Context, check types.HealthCheck) (*Result, error) {
+	req, err := http.NewRequest("GET", check.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	healthy := resp.StatusCode >= 200 && resp.StatusCode < 300
+	return &Result{Healthy: healthy, Detail: resp.Status}, nil
+}
+
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// Passes if the query's value, or the highest value of any of its series, is at most check.Max.
+func (h *httpHealth) checkQuery(ctx context.Context, check types.HealthCheck) (*Result, error) {
+	if h.PrometheusURL == "" {
+		return nil, fmt.Errorf("PROMETHEUS_URL must be set for health check %s", check.Name)
+	}
+
+	queryURL, err := url.Parse(h.PrometheusURL + "/api/v1/query")
+	if err != nil {
+		return nil, err
+	}
+	queryURL.RawQuery = url.Values{"query": []string{check.Query}}.Encode()
+
+	req, err := http.NewRequest("GET", queryURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var body prometheusResponse
+	err = json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading Prometheus response: %s, %v", resp.Status, err)
+	}
+	if body.Status != "success" {
+		return nil, fmt.Errorf("Error running health check %s: %s", check.Name, body.Error)
+	}
+
+	values, err := queryValues(body.Data.ResultType, body.Data.Result)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		// Nothing to measure, like an error rate with no errors.
+		return &Result{Healthy: true, Detail: "no data"}, nil
+	}
+
+	max := values[0]
+	for _, value := range values[1:] {
+		if value > max {
+			max = value
+		}
+	}
+	return &Result{Healthy: max <= check.Max, Detail: strconv.FormatFloat(max, 'g', -1, 64)}, nil
+}
+
+// Values are [timestamp, "value"] pairs, either one for a scalar or one per series of a vector.
+func queryValues(resultType string, result json.RawMessage) ([]float64, error) {
+	var samples [][]interface{}
+	switch resultType {
+	case "scalar":
+		var sample []interface{}
+		err := json.Unmarshal(result, &sample)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	case "vector":
+		var series []struct {
+			Value []interface{} `json:"value"`
+		}
+		err := json.Unmarshal(result, &series)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			samples = append(samples, s.Value)
+		}
+	default:
+		return nil, fmt.Errorf("Health check queries must return a scalar or vector, not %s", resultType)
+	}
+
+	values := make([]float64, 0, len(samples))
+	for _, sample := range samples {
+		if len(sample) != 2 {
+			return nil, fmt.Errorf("Bad Prometheus sample: %v", sample)
+		}
+		str, ok := sample[1].(string)
+		if !ok {
+			return nil, fmt.Errorf("Bad Prometheus sample: %v", sample)
+		}
+		value, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (h *httpHealth) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	client := &http.Client{
+		Timeout:   time.Second * 15,
+		Transport: tracing.Transport(nil),
+	}
+	return client.Do(req.WithContext(tracing.Detach(ctx)))
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
+
+func TestCheckURL(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	h := &httpHealth{}
+	check := types.HealthCheck{Name: "status", URL: server.URL}
+
+	result, err := h.Check(context.Background(), check)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+
+	status = http.StatusServiceUnavailable
+	result, err = h.Check(context.Background(), check)
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, "Health check status failed: 503 Service Unavailable", result.Breach(check))
+}
+
+func TestCheckQuery(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		assert.Equal(t, "error_rate", r.URL.Query().Get("query"))
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	h := &httpHealth{PrometheusURL: server.URL}
+	check := types.HealthCheck{Name: "errors", Query: "error_rate", Max: 0.05}
+
+	body = `{"status": "success", "data": {"resultType": "vector", "result": [
+		{"metric": {"pod": "a"}, "value": [1700000000, "0.01"]},
+		{"metric": {"pod": "b"}, "value": [1700000000, "0.07"]}]}}`
+	result, err := h.Check(context.Background(), check)
+	assert.NoError(t, err)
+	assert.False(t, result.Healthy)
+	assert.Equal(t, "Health check errors failed: 0.07 is over 0.05", result.Breach(check))
+
+	body = `{"status": "success", "data": {"resultType": "scalar", "result": [1700000000, "0.02"]}}`
+	result, err = h.Check(context.Background(), check)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+
+	body = `{"status": "success", "data": {"resultType": "vector", "result": []}}`
+	result, err = h.Check(context.Background(), check)
+	assert.NoError(t, err)
+	assert.True(t, result.Healthy)
+
+	body = `{"status": "error", "error": "bad query"}`
+	_, err = h.Check(context.Background(), check)
+	assert.Error(t, err)
+
+	_, err = (&httpHealth{}).Check(context.Background(), check)
+	assert.Error(t, err)
+}
@@ -1,6 +1,7 @@
 package messaging
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/Nextdoor/conductor/shared/logger"
@@ -8,11 +9,11 @@ import (
 )
 
 type EngineMock struct {
-	SendMock                        func(string)
-	SendDirectMock                  func(string, string, string)
-	FormatUserMock                  func(*types.User) string
+	SendMock                        func(context.Context, string)
+	SendDirectMock                  func(context.Context, string, string, string)
+	FormatUserMock                  func(context.Context, *types.User) string
 	FormatNameEmailMock             func(string, string) string
-	FormatNameEmailNotificationMock func(string, string) string
+	FormatNameEmailNotificationMock func(context.Context, string, string) string
 	FormatLinkMock                  func(string, string) string
 	FormatBoldMock                  func(string) string
 	FormatMonospacedMock            func(string) string
@@ -20,23 +21,23 @@ type EngineMock struct {
 	Escape                          func(string) string
 }
 
-func (m *EngineMock) send(text string) {
+func (m *EngineMock) send(ctx context.Context, text string) {
 	if m.SendMock != nil {
-		m.SendMock(text)
+		m.SendMock(ctx, text)
 	}
 	logger.Info("%s", text)
 }
 
-func (m *EngineMock) sendDirect(name, email, text string) {
+func (m *EngineMock) sendDirect(ctx context.Context, name, email, text string) {
 	if m.SendDirectMock != nil {
-		m.SendDirectMock(name, email, text)
+		m.SendDirectMock(ctx, name, email, text)
 	}
 	logger.Info("%s: %s", name, text)
 }
 
-func (m *EngineMock) formatUser(user *types.User) string {
+func (m *EngineMock) formatUser(ctx context.Context, user *types.User) string {
 	if m.FormatUserMock != nil {
-		return m.FormatUserMock(user)
+		return m.FormatUserMock(ctx, user)
 	}
 	return user.Name
 }
@@ -48,9 +49,9 @@ func (m *EngineMock) formatNameEmail(name, email string) string {
 	return name
 }
 
-func (m *EngineMock) formatNameEmailNotification(name, email string) string {
+func (m *EngineMock) formatNameEmailNotification(ctx context.Context, name, email string) string {
 	if m.FormatNameEmailNotificationMock != nil {
-		return m.FormatNameEmailNotificationMock(name, email)
+		return m.FormatNameEmailNotificationMock(ctx, name, email)
 	}
 	return name
 }
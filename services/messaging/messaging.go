@@ -3,6 +3,7 @@ package messaging
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -18,23 +19,30 @@ var (
 )
 
 type Service interface {
-	TrainCreation(*types.Train, []*types.Commit)
-	TrainExtension(*types.Train, []*types.Commit, *types.User)
-	TrainDuplication(*types.Train, *types.Train, []*types.Commit)
-	TrainDelivered(*types.Train, []*types.Commit, []*types.Ticket)
-	TrainVerified(*types.Train)
-	TrainUnverified(*types.Train)
-	TrainDeploying()
-	TrainDeployed(*types.Train)
-	TrainClosed(*types.Train, *types.User)
-	TrainOpened(*types.Train, *types.User)
-	TrainBlocked(*types.Train, *types.User)
-	TrainUnblocked(*types.Train, *types.User)
-	TrainCancelled(*types.Train, *types.User)
-	EngineerChanged(*types.Train, *types.User)
-	RollbackInitiated(*types.Train, *types.User)
-	RollbackInfo(*types.User)
-	JobFailed(*types.Job)
+	TrainCreation(context.Context, *types.Train, []*types.Commit)
+	TrainExtension(context.Context, *types.Train, []*types.Commit, *types.User)
+	TrainDuplication(context.Context, *types.Train, *types.Train, []*types.Commit)
+	TrainDelivered(context.Context, *types.Train, []*types.Commit, []*types.Ticket)
+	TrainVerified(context.Context, *types.Train)
+	TrainUnverified(context.Context, *types.Train)
+	TrainDeploying(context.Context)
+	TrainDeployed(context.Context, *types.Train)
+	TrainClosed(context.Context, *types.Train, *types.User)
+	TrainOpened(context.Context, *types.Train, *types.User)
+	TrainBlocked(context.Context, *types.Train, *types.User)
+	TrainUnblocked(context.Context, *types.Train, *types.User)
+	TrainCancelled(context.Context, *types.Train, *types.User)
+	EngineerChanged(context.Context, *types.Train, *types.User)
+	RollbackInitiated(context.Context, *types.Train, *types.User)
+	RollbackInfo(context.Context, *types.User)
+	JobFailed(context.Context, *types.Job)
+	HealthCheckFailed(context.Context, *types.Phase, string)
+	DeployRolledBack(context.Context, *types.Job, *types.Train)
+	RollbackCompleted(context.Context, *types.Rollback)
+	RollbackFailed(context.Context, *types.Rollback, *types.Job)
+	CommitExcluded(context.Context, *types.Train, *types.Commit, *types.User)
+	TrainSplit(context.Context, *types.Train, *types.Train, []*types.Commit, *types.User)
+	CommitReleased(context.Context, *types.Train, *types.Commit, *types.User)
 }
 
 type Messenger struct {
@@ -42,11 +50,11 @@ type Messenger struct {
 }
 
 type Engine interface {
-	send(text string)
-	sendDirect(name, email, text string)
-	formatUser(*types.User) string
+	send(ctx context.Context, text string)
+	sendDirect(ctx context.Context, name, email, text string)
+	formatUser(context.Context, *types.User) string
 	formatNameEmail(name, email string) string
-	formatNameEmailNotification(name, email string) string
+	formatNameEmailNotification(ctx context.Context, name, email string) string
 	formatLink(url, text string) string
 	formatBold(text string) string
 	formatMonospaced(text string) string
@@ -64,28 +72,28 @@ const (
 
 // On train creation, send a link to the train to the slack channel,
 // and send direct messages to all committers on the train.
-func (m Messenger) TrainCreation(train *types.Train, commits []*types.Commit) {
-	m.Engine.send(m.Engine.formatBold(
+func (m Messenger) TrainCreation(ctx context.Context, train *types.Train, commits []*types.Commit) {
+	m.Engine.send(ctx, m.Engine.formatBold(
 		fmt.Sprintf("%s going to staging.", m.formatTrainLink(train, "New train"))))
 
 	if train.Engineer != nil {
-		m.Engine.send(m.Engine.formatBold(
+		m.Engine.send(ctx, m.Engine.formatBold(
 			fmt.Sprintf("%s is the engineer.",
-				m.Engine.formatUser(train.Engineer))))
+				m.Engine.formatUser(ctx, train.Engineer))))
 
-		m.Engine.sendDirect(train.Engineer.Name, train.Engineer.Email, m.Engine.formatBold(
+		m.Engine.sendDirect(ctx, train.Engineer.Name, train.Engineer.Email, m.Engine.formatBold(
 			fmt.Sprintf("You are the engineer for the %s.",
 				m.formatTrainLink(train, fmt.Sprintf("train %d", train.ID)))))
 	}
 
 	commitSets := m.commitSetsFromCommits(commits, true)
 
-	m.sendCommitSetsDirectly(
+	m.sendCommitSetsDirectly(ctx,
 		fmt.Sprintf("Your changes are %s", m.formatTrainLink(train, "going to staging")),
 		commitSets)
 }
 
-func (m Messenger) TrainExtension(train *types.Train, commits []*types.Commit, user *types.User) {
+func (m Messenger) TrainExtension(ctx context.Context, train *types.Train, commits []*types.Commit, user *types.User) {
 	commitSets := m.commitSetsFromCommits(commits, true)
 	// Note: We used to abort early if there are no commit sets to notify for.
 	// Even if no commit sets, send train extension message for manual extensions.
@@ -95,51 +103,73 @@ func (m Messenger) TrainExtension(train *types.Train, commits []*types.Commit, u
 	if user != nil {
 		// Only send this for manual extensions.
 		// Noisy when train is opened.
-		m.Engine.send(m.Engine.formatBold(
+		m.Engine.send(ctx, m.Engine.formatBold(
 			fmt.Sprintf("%s, new changes going to staging.",
-				fmt.Sprintf("%s by %s", trainLink, m.Engine.formatUser(user)))))
+				fmt.Sprintf("%s by %s", trainLink, m.Engine.formatUser(ctx, user)))))
 	}
 
-	m.sendCommitSetsDirectly(
+	m.sendCommitSetsDirectly(ctx,
 		fmt.Sprintf("Your changes are %s", m.formatTrainLink(train, "going to staging")),
 		commitSets)
 }
 
-func (m Messenger) TrainDuplication(train *types.Train, trainFrom *types.Train, commits []*types.Commit) {
+func (m Messenger) TrainDuplication(ctx context.Context, train *types.Train, trainFrom *types.Train, commits []*types.Commit) {
 	// Same message as create for now.
-	m.TrainCreation(train, commits)
+	m.TrainCreation(ctx, train, commits)
 }
 
-func (m Messenger) TrainDelivered(train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
+// The moved commits' authors hear where their changes went, since they go to staging after the train deploys.
+func (m Messenger) TrainSplit(ctx context.Context, train *types.Train, newTrain *types.Train, commits []*types.Commit, user *types.User) {
+	m.Engine.send(ctx, m.Engine.formatBold(
+		fmt.Sprintf("%s split by %s. It deploys up to %s, and %d commits moved to %s.",
+			m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
+			m.Engine.formatUser(ctx, user),
+			m.Engine.formatMonospaced(train.HeadSHA),
+			len(commits),
+			m.formatTrainLink(newTrain, fmt.Sprintf("train %d", newTrain.ID)))))
+
+	if newTrain.Engineer != nil {
+		m.Engine.sendDirect(ctx, newTrain.Engineer.Name, newTrain.Engineer.Email, m.Engine.formatBold(
+			fmt.Sprintf("You are the engineer for the %s.",
+				m.formatTrainLink(newTrain, fmt.Sprintf("train %d", newTrain.ID)))))
+	}
+
+	m.sendCommitSetsDirectly(ctx,
+		fmt.Sprintf("Your changes moved to %s, which goes to staging once train %d is deploying",
+			m.formatTrainLink(newTrain, fmt.Sprintf("train %d", newTrain.ID)), train.ID),
+		m.commitSetsFromCommits(commits, true))
+}
+
+func (m Messenger) TrainDelivered(ctx context.Context, train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
 	ticketedCommitSets, unticketedCommitSets := m.commitSetsFromCommitsAndTickets(commits, tickets)
 
 	if len(ticketedCommitSets) > 0 {
-		m.Engine.send(m.Engine.formatBold(
+		m.Engine.send(ctx, m.Engine.formatBold(
 			fmt.Sprintf("%s delivered to staging.", m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)))))
-		m.Engine.send(m.formatCommitSets("Changes with tickets", PlainText, ticketedCommitSets))
+		m.Engine.send(ctx, m.formatCommitSets(ctx, "Changes with tickets", PlainText, ticketedCommitSets))
 	}
 
-	m.sendCommitSetsDirectly(
+	m.sendCommitSetsDirectly(ctx,
 		fmt.Sprintf("Your [no-verify] changes have %s",
 			m.formatTrainLink(train, "arrived on staging")),
 		unticketedCommitSets)
-	m.sendCommitSetsDirectly(
+	m.sendCommitSetsDirectly(ctx,
 		fmt.Sprintf("Your changes have %s and need verification",
 			m.formatTrainLink(train, "arrived on staging")),
 		ticketedCommitSets)
 }
 
-func (m Messenger) TrainVerified(train *types.Train) {
+func (m Messenger) TrainVerified(ctx context.Context, train *types.Train) {
 	if !train.Closed {
 		// No message if verified but opened, because it's not yet actionable.
 		return
 	}
 
-	m.Engine.send(m.Engine.formatBold(
+	m.Engine.send(ctx, m.Engine.formatBold(
 		fmt.Sprintf("%s fully verified.", m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)))))
 }
 
-func (m Messenger) TrainUnverified(train *types.Train) {
+func (m Messenger) TrainUnverified(ctx context.Context, train *types.Train) {
 	if !train.Closed {
 		// No message if unverified but opened, because it's not yet actionable.
 		return
@@ -147,128 +177,128 @@ func (m Messenger) TrainUnverified(train *types.Train) {
 
 	message := m.Engine.formatBold(
 		fmt.Sprintf("%s no longer fully verified.", m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
-	m.Engine.send(message)
+	m.Engine.send(ctx, message)
 
 	if train.Engineer != nil {
-		m.Engine.sendDirect(train.Engineer.Name, train.Engineer.Email, message)
+		m.Engine.sendDirect(ctx, train.Engineer.Name, train.Engineer.Email, message)
 	}
 }
 
-func (m Messenger) TrainDeploying() {
-	m.Engine.send(m.Engine.formatBold("Deploy started."))
+func (m Messenger) TrainDeploying(ctx context.Context) {
+	m.Engine.send(ctx, m.Engine.formatBold("Deploy started."))
 }
 
-func (m Messenger) TrainDeployed(train *types.Train) {
+func (m Messenger) TrainDeployed(ctx context.Context, train *types.Train) {
 	commitSets := m.commitSetsFromCommits(train.Commits, false)
 
-	m.Engine.send(m.Engine.formatBold(fmt.Sprintf(
+	m.Engine.send(ctx, m.Engine.formatBold(fmt.Sprintf(
 		"Deployed %s to production.",
 		m.formatTrainLink(train,
 			fmt.Sprintf("Train %d", train.ID)))))
 
-	m.sendCommitSetsDirectly(
+	m.sendCommitSetsDirectly(ctx,
 		fmt.Sprintf("Your changes were %s",
 			m.formatTrainLink(train, "deployed to production")),
 		commitSets)
 }
 
-func (m Messenger) TrainClosed(train *types.Train, user *types.User) {
+func (m Messenger) TrainClosed(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s closed by %s.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s closed.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) TrainOpened(train *types.Train, user *types.User) {
+func (m Messenger) TrainOpened(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s opened by %s.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s opened.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) TrainBlocked(train *types.Train, user *types.User) {
+func (m Messenger) TrainBlocked(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s blocked by %s.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s blocked.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) TrainUnblocked(train *types.Train, user *types.User) {
+func (m Messenger) TrainUnblocked(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s unblocked by %s.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s unblocked.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) TrainCancelled(train *types.Train, user *types.User) {
+func (m Messenger) TrainCancelled(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s cancelled by %s. All commits will move to the next train.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("%s cancelled. All commits will move to the next train.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID))))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) EngineerChanged(train *types.Train, user *types.User) {
+func (m Messenger) EngineerChanged(ctx context.Context, train *types.Train, user *types.User) {
 	var text = m.Engine.formatBold(
 		fmt.Sprintf("%s is claimed by new engineer %s.",
 			m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
-			m.Engine.formatUser(user)))
+			m.Engine.formatUser(ctx, user)))
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) RollbackInitiated(train *types.Train, user *types.User) {
+func (m Messenger) RollbackInitiated(ctx context.Context, train *types.Train, user *types.User) {
 	var text string
 	if user != nil {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("Rollback to %s %d initiated by %s.",
 				m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
 				train.ID,
-				m.Engine.formatUser(user)))
+				m.Engine.formatUser(ctx, user)))
 	} else {
 		text = m.Engine.formatBold(
 			fmt.Sprintf("Rollback to %s %d initiated.",
@@ -276,19 +306,19 @@ func (m Messenger) RollbackInitiated(train *types.Train, user *types.User) {
 				train.ID))
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) RollbackInfo(user *types.User) {
+func (m Messenger) RollbackInfo(ctx context.Context, user *types.User) {
 	text := "Make sure to extend the latest train with the fix / revert and unblock when ready."
 	if user != nil {
-		text = fmt.Sprintf("%s: %s", m.Engine.formatUser(user), text)
+		text = fmt.Sprintf("%s: %s", m.Engine.formatUser(ctx, user), text)
 	}
 
-	m.Engine.send(text)
+	m.Engine.send(ctx, text)
 }
 
-func (m Messenger) JobFailed(job *types.Job) {
+func (m Messenger) JobFailed(ctx context.Context, job *types.Job) {
 	if job.Phase.Train.Done || !job.Phase.IsInActivePhaseGroup() {
 		// Don't notify if the train is done or if the job is not for the active phase group.
 		return
@@ -309,10 +339,80 @@ func (m Messenger) JobFailed(job *types.Job) {
 	if engineer != nil && job.Phase.Train.Closed {
 		// Add @mention for the train engineer if the train is closed.
 		message = fmt.Sprintf("%s: %s",
-			m.Engine.formatNameEmailNotification(engineer.Name, engineer.Email),
+			m.Engine.formatNameEmailNotification(ctx, engineer.Name, engineer.Email),
 			message)
 	}
-	m.Engine.send(m.Engine.formatBold(message))
+	m.Engine.send(ctx, m.Engine.formatBold(message))
+}
+
+func (m Messenger) HealthCheckFailed(ctx context.Context, phase *types.Phase, breach string) {
+	m.Engine.send(ctx, m.Engine.formatBold(
+		fmt.Sprintf("%s stopped in phase %s. %s.",
+			m.formatTrainLink(phase.Train, fmt.Sprintf("Train %d", phase.Train.ID)),
+			phase.Name,
+			breach)))
+}
+
+// Pages the engineer, since the deploy stopped partway and needs someone to look at it.
+func (m Messenger) DeployRolledBack(ctx context.Context, job *types.Job, rolledBackTo *types.Train) {
+	train := job.Phase.Train
+	text := fmt.Sprintf("%s failed to deploy in %s, so production is rolling back to %s.",
+		m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
+		m.Engine.formatMonospaced(job.Name),
+		m.formatTrainLink(rolledBackTo, fmt.Sprintf("train %d", rolledBackTo.ID)))
+
+	engineer := train.Engineer
+	if engineer != nil {
+		m.Engine.send(ctx, m.Engine.formatBold(fmt.Sprintf("%s: %s",
+			m.Engine.formatNameEmailNotification(ctx, engineer.Name, engineer.Email), text)))
+		m.Engine.sendDirect(ctx, engineer.Name, engineer.Email, m.Engine.formatBold(text))
+	} else {
+		m.Engine.send(ctx, m.Engine.formatBold(text))
+	}
+}
+
+func (m Messenger) RollbackCompleted(ctx context.Context, rollback *types.Rollback) {
+	m.Engine.send(ctx, m.Engine.formatBold(
+		fmt.Sprintf("Rollback to %s finished. Production is on %s.",
+			m.formatTrainLink(rollback.Train, fmt.Sprintf("Train %d", rollback.Train.ID)),
+			m.Engine.formatMonospaced(rollback.Train.HeadSHA))))
+}
+
+// Mentions whoever asked for the rollback, since production is still on the bad train.
+func (m Messenger) RollbackFailed(ctx context.Context, rollback *types.Rollback, job *types.Job) {
+	jobFailedText := fmt.Sprintf("%s job failed", m.Engine.formatMonospaced(job.Name))
+	if job.URL != nil {
+		jobFailedText = m.Engine.formatLink(*job.URL, jobFailedText)
+	}
+	text := fmt.Sprintf("Rollback to %s failed: %s. Check failure and consider restarting the job.",
+		m.formatTrainLink(rollback.Train, fmt.Sprintf("Train %d", rollback.Train.ID)),
+		jobFailedText)
+	if rollback.User != nil {
+		text = fmt.Sprintf("%s: %s",
+			m.Engine.formatNameEmailNotification(ctx, rollback.User.Name, rollback.User.Email),
+			text)
+	}
+	m.Engine.send(ctx, m.Engine.formatBold(text))
+}
+
+// Tells the channel and the commit's author, whose verification ticket went with it.
+func (m Messenger) CommitExcluded(ctx context.Context, train *types.Train, commit *types.Commit, user *types.User) {
+	text := fmt.Sprintf("%s by %s was taken off %s by %s.",
+		m.Engine.formatMonospaced(commit.ShortSHA()),
+		m.Engine.formatNameEmail(commit.AuthorName, commit.AuthorEmail),
+		m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
+		m.Engine.formatUser(ctx, user))
+	m.Engine.send(ctx, m.Engine.formatBold(text))
+	m.Engine.sendDirect(ctx, commit.AuthorName, commit.AuthorEmail, text)
+}
+
+func (m Messenger) CommitReleased(ctx context.Context, train *types.Train, commit *types.Commit, user *types.User) {
+	text := fmt.Sprintf("%s by %s is no longer holding %s, released by %s.",
+		m.Engine.formatMonospaced(commit.ShortSHA()),
+		m.Engine.formatNameEmail(commit.AuthorName, commit.AuthorEmail),
+		m.formatTrainLink(train, fmt.Sprintf("Train %d", train.ID)),
+		m.Engine.formatUser(ctx, user))
+	m.Engine.send(ctx, text)
 }
 
 func (m Messenger) formatTrainLink(train *types.Train, text string) string {
@@ -326,13 +426,13 @@ type commitSet struct {
 	Extra          *string
 }
 
-func (m Messenger) formatCommitSets(header string, nameFormatting nameFormat, commitSets []commitSet) string {
+func (m Messenger) formatCommitSets(ctx context.Context, header string, nameFormatting nameFormat, commitSets []commitSet) string {
 	var text bytes.Buffer
 	text.WriteString(m.Engine.formatBold(fmt.Sprintf("%s:", header)))
 	text.WriteString("\n")
 
 	for _, commitSet := range commitSets {
-		nameHeader := m.formatNameHeader(commitSet.CommitterName, commitSet.CommitterEmail, commitSet.Extra, nameFormatting)
+		nameHeader := m.formatNameHeader(ctx, commitSet.CommitterName, commitSet.CommitterEmail, commitSet.Extra, nameFormatting)
 		if nameHeader != nil {
 			text.WriteString(fmt.Sprintf("%s\n", *nameHeader))
 		}
@@ -353,14 +453,14 @@ func (m Messenger) formatCommitSets(header string, nameFormatting nameFormat, co
 	return text.String()
 }
 
-func (m Messenger) formatNameHeader(name, email string, extra *string, format nameFormat) *string {
+func (m Messenger) formatNameHeader(ctx context.Context, name, email string, extra *string, format nameFormat) *string {
 	if format == None {
 		return extra
 	}
 	var header string
 	switch format {
 	case Notify:
-		header = m.Engine.formatNameEmailNotification(name, email)
+		header = m.Engine.formatNameEmailNotification(ctx, name, email)
 	case PlainText:
 		header = m.Engine.formatNameEmail(name, email)
 	}
@@ -461,10 +561,10 @@ func (m Messenger) commitSetsFromCommitsAndTickets(
 	return ticketedCommitSets, unticketedCommitSets
 }
 
-func (m Messenger) sendCommitSetsDirectly(header string, commitSets []commitSet) {
+func (m Messenger) sendCommitSetsDirectly(ctx context.Context, header string, commitSets []commitSet) {
 	for _, set := range commitSets {
-		m.Engine.sendDirect(set.CommitterName, set.CommitterEmail,
-			m.formatCommitSets(header, None, []commitSet{set}))
+		m.Engine.sendDirect(ctx, set.CommitterName, set.CommitterEmail,
+			m.formatCommitSets(ctx, header, None, []commitSet{set}))
 	}
 }
 
@@ -502,15 +602,15 @@ func newFakeEngine() *Messenger {
 	}
 }
 
-func (e fakeEngine) send(text string) {
+func (e fakeEngine) send(ctx context.Context, text string) {
 	logger.Info("%s", text)
 }
 
-func (e fakeEngine) sendDirect(name, email, text string) {
+func (e fakeEngine) sendDirect(ctx context.Context, name, email, text string) {
 	logger.Info("%s: %s", name, text)
 }
 
-func (e fakeEngine) formatUser(user *types.User) string {
+func (e fakeEngine) formatUser(ctx context.Context, user *types.User) string {
 	return user.Name
 }
 
@@ -518,7 +618,7 @@ func (e fakeEngine) formatNameEmail(name, email string) string {
 	return name
 }
 
-func (e fakeEngine) formatNameEmailNotification(name, email string) string {
+func (e fakeEngine) formatNameEmailNotification(ctx context.Context, name, email string) string {
 	return name
 }
 
@@ -1,121 +1,180 @@
 package messaging
 
 import (
+	"context"
+
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 type MessagingServiceMock struct {
 	Engine                Engine
-	TrainCreationMock     func(*types.Train, []*types.Commit)
-	TrainExtensionMock    func(*types.Train, []*types.Commit, *types.User)
-	TrainDuplicationMock  func(*types.Train, *types.Train, []*types.Commit)
-	TrainDeliveredMock    func(*types.Train, []*types.Commit, []*types.Ticket)
-	TrainVerifiedMock     func(*types.Train)
-	TrainUnverifiedMock   func(*types.Train)
-	TrainDeployingMock    func()
-	TrainDeployedMock     func(*types.Train)
-	TrainClosedMock       func(*types.Train, *types.User)
-	TrainOpenedMock       func(*types.Train, *types.User)
-	TrainBlockedMock      func(*types.Train, *types.User)
-	TrainUnblockedMock    func(*types.Train, *types.User)
-	TrainCancelledMock    func(*types.Train, *types.User)
-	RollbackInitiatedMock func(*types.Train, *types.User)
-	RollbackInfoMock      func(*types.User)
-	JobFailedMock         func(*types.Job)
-}
-
-func (m MessagingServiceMock) TrainCreation(train *types.Train, commits []*types.Commit) {
+	TrainCreationMock     func(context.Context, *types.Train, []*types.Commit)
+	TrainExtensionMock    func(context.Context, *types.Train, []*types.Commit, *types.User)
+	TrainDuplicationMock  func(context.Context, *types.Train, *types.Train, []*types.Commit)
+	TrainDeliveredMock    func(context.Context, *types.Train, []*types.Commit, []*types.Ticket)
+	TrainVerifiedMock     func(context.Context, *types.Train)
+	TrainUnverifiedMock   func(context.Context, *types.Train)
+	TrainDeployingMock    func(context.Context)
+	TrainDeployedMock     func(context.Context, *types.Train)
+	TrainClosedMock       func(context.Context, *types.Train, *types.User)
+	TrainOpenedMock       func(context.Context, *types.Train, *types.User)
+	TrainBlockedMock      func(context.Context, *types.Train, *types.User)
+	TrainUnblockedMock    func(context.Context, *types.Train, *types.User)
+	TrainCancelledMock    func(context.Context, *types.Train, *types.User)
+	EngineerChangedMock   func(context.Context, *types.Train, *types.User)
+	RollbackInitiatedMock func(context.Context, *types.Train, *types.User)
+	RollbackInfoMock      func(context.Context, *types.User)
+	JobFailedMock         func(context.Context, *types.Job)
+	HealthCheckFailedMock func(context.Context, *types.Phase, string)
+	DeployRolledBackMock  func(context.Context, *types.Job, *types.Train)
+	RollbackCompletedMock func(context.Context, *types.Rollback)
+	RollbackFailedMock    func(context.Context, *types.Rollback, *types.Job)
+	CommitExcludedMock    func(context.Context, *types.Train, *types.Commit, *types.User)
+	TrainSplitMock        func(context.Context, *types.Train, *types.Train, []*types.Commit, *types.User)
+	CommitReleasedMock    func(context.Context, *types.Train, *types.Commit, *types.User)
+}
+
+func (m MessagingServiceMock) TrainCreation(ctx context.Context, train *types.Train, commits []*types.Commit) {
 	if m.TrainCreationMock != nil {
-		m.TrainCreationMock(train, commits)
+		m.TrainCreationMock(ctx, train, commits)
 	}
 }
 
-func (m MessagingServiceMock) TrainExtension(train *types.Train, commits []*types.Commit, user *types.User) {
+func (m MessagingServiceMock) TrainExtension(ctx context.Context, train *types.Train, commits []*types.Commit, user *types.User) {
 	if m.TrainExtensionMock != nil {
-		m.TrainExtensionMock(train, commits, user)
+		m.TrainExtensionMock(ctx, train, commits, user)
 	}
 }
 
-func (m MessagingServiceMock) TrainDuplication(train *types.Train, trainFrom *types.Train, commits []*types.Commit) {
+func (m MessagingServiceMock) TrainDuplication(ctx context.Context, train *types.Train, trainFrom *types.Train, commits []*types.Commit) {
 	if m.TrainDuplicationMock != nil {
-		m.TrainDuplicationMock(train, trainFrom, commits)
+		m.TrainDuplicationMock(ctx, train, trainFrom, commits)
 	}
 }
 
-func (m MessagingServiceMock) TrainDelivered(train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
+func (m MessagingServiceMock) TrainDelivered(ctx context.Context, train *types.Train, commits []*types.Commit, tickets []*types.Ticket) {
 	if m.TrainDeliveredMock != nil {
-		m.TrainDeliveredMock(train, commits, tickets)
+		m.TrainDeliveredMock(ctx, train, commits, tickets)
 	}
 }
 
-func (m MessagingServiceMock) TrainVerified(train *types.Train) {
+func (m MessagingServiceMock) TrainVerified(ctx context.Context, train *types.Train) {
 	if m.TrainVerifiedMock != nil {
-		m.TrainVerifiedMock(train)
+		m.TrainVerifiedMock(ctx, train)
 	}
 }
 
-func (m MessagingServiceMock) TrainUnverified(train *types.Train) {
+func (m MessagingServiceMock) TrainUnverified(ctx context.Context, train *types.Train) {
 	if m.TrainUnverifiedMock != nil {
-		m.TrainUnverifiedMock(train)
+		m.TrainUnverifiedMock(ctx, train)
 	}
 }
 
-func (m MessagingServiceMock) TrainDeploying() {
+func (m MessagingServiceMock) TrainDeploying(ctx context.Context) {
 	if m.TrainDeployingMock != nil {
-		m.TrainDeployingMock()
+		m.TrainDeployingMock(ctx)
 	}
 }
 
-func (m MessagingServiceMock) TrainDeployed(train *types.Train) {
+func (m MessagingServiceMock) TrainDeployed(ctx context.Context, train *types.Train) {
 	if m.TrainDeployedMock != nil {
-		m.TrainDeployedMock(train)
+		m.TrainDeployedMock(ctx, train)
 	}
 }
 
-func (m MessagingServiceMock) TrainClosed(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) TrainClosed(ctx context.Context, train *types.Train, user *types.User) {
 	if m.TrainClosedMock != nil {
-		m.TrainClosedMock(train, user)
+		m.TrainClosedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) TrainOpened(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) TrainOpened(ctx context.Context, train *types.Train, user *types.User) {
 	if m.TrainOpenedMock != nil {
-		m.TrainOpenedMock(train, user)
+		m.TrainOpenedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) TrainBlocked(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) TrainBlocked(ctx context.Context, train *types.Train, user *types.User) {
 	if m.TrainBlockedMock != nil {
-		m.TrainBlockedMock(train, user)
+		m.TrainBlockedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) TrainUnblocked(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) TrainUnblocked(ctx context.Context, train *types.Train, user *types.User) {
 	if m.TrainUnblockedMock != nil {
-		m.TrainUnblockedMock(train, user)
+		m.TrainUnblockedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) TrainCancelled(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) TrainCancelled(ctx context.Context, train *types.Train, user *types.User) {
 	if m.TrainCancelledMock != nil {
-		m.TrainCancelledMock(train, user)
+		m.TrainCancelledMock(ctx, train, user)
+	}
+}
+
+func (m MessagingServiceMock) EngineerChanged(ctx context.Context, train *types.Train, user *types.User) {
+	if m.EngineerChangedMock != nil {
+		m.EngineerChangedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) RollbackInitiated(train *types.Train, user *types.User) {
+func (m MessagingServiceMock) RollbackInitiated(ctx context.Context, train *types.Train, user *types.User) {
 	if m.RollbackInitiatedMock != nil {
-		m.RollbackInitiatedMock(train, user)
+		m.RollbackInitiatedMock(ctx, train, user)
 	}
 }
 
-func (m MessagingServiceMock) RollbackInfo(user *types.User) {
+func (m MessagingServiceMock) RollbackInfo(ctx context.Context, user *types.User) {
 	if m.RollbackInfoMock != nil {
-		m.RollbackInfoMock(user)
+		m.RollbackInfoMock(ctx, user)
 	}
 }
 
-func (m MessagingServiceMock) JobFailed(job *types.Job) {
+func (m MessagingServiceMock) JobFailed(ctx context.Context, job *types.Job) {
 	if m.JobFailedMock != nil {
-		m.JobFailedMock(job)
+		m.JobFailedMock(ctx, job)
+	}
+}
+
+func (m MessagingServiceMock) HealthCheckFailed(ctx context.Context, phase *types.Phase, breach string) {
+	if m.HealthCheckFailedMock != nil {
+		m.HealthCheckFailedMock(ctx, phase, breach)
+	}
+}
+
+func (m MessagingServiceMock) DeployRolledBack(ctx context.Context, job *types.Job, rolledBackTo *types.Train) {
+	if m.DeployRolledBackMock != nil {
+		m.DeployRolledBackMock(ctx, job, rolledBackTo)
+	}
+}
+
+func (m MessagingServiceMock) RollbackCompleted(ctx context.Context, rollback *types.Rollback) {
+	if m.RollbackCompletedMock != nil {
+		m.RollbackCompletedMock(ctx, rollback)
+	}
+}
+
+func (m MessagingServiceMock) RollbackFailed(ctx context.Context, rollback *types.Rollback, job *types.Job) {
+	if m.RollbackFailedMock != nil {
+		m.RollbackFailedMock(ctx, rollback, job)
+	}
+}
+
+func (m MessagingServiceMock) CommitExcluded(ctx context.Context, train *types.Train, commit *types.Commit, user *types.User) {
+	if m.CommitExcludedMock != nil {
+		m.CommitExcludedMock(ctx, train, commit, user)
+	}
+}
+
+func (m MessagingServiceMock) TrainSplit(ctx context.Context,
+	train *types.Train, newTrain *types.Train, commits []*types.Commit, user *types.User) {
+	if m.TrainSplitMock != nil {
+		m.TrainSplitMock(ctx, train, newTrain, commits, user)
+	}
+}
+
+func (m MessagingServiceMock) CommitReleased(ctx context.Context, train *types.Train, commit *types.Commit, user *types.User) {
+	if m.CommitReleasedMock != nil {
+		m.CommitReleasedMock(ctx, train, commit, user)
 	}
 }
@@ -2,8 +2,10 @@
 package messaging
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -11,6 +13,7 @@ import (
 
 	"github.com/Nextdoor/conductor/shared/flags"
 	"github.com/Nextdoor/conductor/shared/logger"
+	"github.com/Nextdoor/conductor/shared/tracing"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
@@ -34,28 +37,29 @@ func newSlackEngine() *Messenger {
 	if slackToken == "" {
 		panic(errors.New("slack_token flag must be set."))
 	}
-	api := slack.New(slackToken)
+	api := slack.New(slackToken,
+		slack.OptionHTTPClient(&http.Client{Transport: tracing.Transport(nil)}))
 	return &Messenger{
 		Engine: &slackEngine{api},
 	}
 }
 
-func (e *slackEngine) send(text string) {
-	e.sendToSlack(slackChannel, text)
+func (e *slackEngine) send(ctx context.Context, text string) {
+	e.sendToSlack(ctx, slackChannel, text)
 }
 
-func (e *slackEngine) sendDirect(name, email, text string) {
-	slackUser, err := e.emailToSlackUser(email)
+func (e *slackEngine) sendDirect(ctx context.Context, name, email, text string) {
+	slackUser, err := e.emailToSlackUser(ctx, email)
 	if err != nil {
 		logger.Error("Error looking up slack user for email %s", email)
 		return
 	}
-	e.sendToSlack(fmt.Sprintf("@%s", slackUser.Name), text)
+	e.sendToSlack(ctx, fmt.Sprintf("@%s", slackUser.Name), text)
 }
 
-func (e *slackEngine) sendToSlack(destination, text string) {
+func (e *slackEngine) sendToSlack(ctx context.Context, destination, text string) {
 	logger.Info("%s", text)
-	_, _, err := e.api.PostMessage(destination,
+	_, _, err := e.api.PostMessageContext(ctx, destination,
 		slack.MsgOptionText(text, false),
 		slack.MsgOptionAsUser(true))
 	if err != nil {
@@ -63,16 +67,16 @@ func (e *slackEngine) sendToSlack(destination, text string) {
 	}
 }
 
-func (e *slackEngine) formatUser(user *types.User) string {
-	return e.formatNameEmailNotification(user.Name, user.Email)
+func (e *slackEngine) formatUser(ctx context.Context, user *types.User) string {
+	return e.formatNameEmailNotification(ctx, user.Name, user.Email)
 }
 
 func (e *slackEngine) formatNameEmail(name, email string) string {
 	return name
 }
 
-func (e *slackEngine) formatNameEmailNotification(name, email string) string {
-	slackUser, err := e.emailToSlackUser(email)
+func (e *slackEngine) formatNameEmailNotification(ctx context.Context, name, email string) string {
+	slackUser, err := e.emailToSlackUser(ctx, email)
 	if err != nil {
 		logger.Error("Error looking up slack user for email %s", email)
 		return name
@@ -104,7 +108,7 @@ func (e *slackEngine) escape(text string) string {
 	return text
 }
 
-func (e *slackEngine) cacheSlackUsers() (map[string]*slack.User, error) {
+func (e *slackEngine) cacheSlackUsers(ctx context.Context) (map[string]*slack.User, error) {
 	// We maintain a cache of email address to Slack user.
 	// This is required to map a commit author to a Slack user we can @-mention.
 	// Since Slack users can change their handles, we only keep the cache for SLACK_CACHE_TTL seconds.
@@ -113,7 +117,7 @@ func (e *slackEngine) cacheSlackUsers() (map[string]*slack.User, error) {
 	if slackEmailUserCacheUnixTime == 0 || now.Unix()-slackEmailUserCacheUnixTime > SlackCacheTtl {
 		slackEmailUserCache = make(map[string]*slack.User, 200)
 
-		users, err := e.api.GetUsers()
+		users, err := e.api.GetUsersContext(ctx)
 		if err != nil {
 			logger.Error("Could not fetch Slack users list: %v", err)
 			if slackEmailUserCacheUnixTime == 0 {
@@ -138,8 +142,8 @@ func (e *slackEngine) cacheSlackUsers() (map[string]*slack.User, error) {
 	return slackEmailUserCache, nil
 }
 
-func (e *slackEngine) emailToSlackUser(email string) (*slack.User, error) {
-	users, err := e.cacheSlackUsers()
+func (e *slackEngine) emailToSlackUser(ctx context.Context, email string) (*slack.User, error) {
+	users, err := e.cacheSlackUsers(ctx)
 	if err != nil {
 		return nil, err
 	}
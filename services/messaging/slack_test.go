@@ -3,6 +3,7 @@
 package messaging
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -16,20 +17,20 @@ func TestEmailToSlackUser(t *testing.T) {
 	now := time.Now()
 	// Test the cache TTL. We should get back the currently cached version, which is an empty map.
 	slackEmailUserCacheUnixTime = now.Unix()
-	users, err := slackEngine.cacheSlackUsers()
+	users, err := slackEngine.cacheSlackUsers(context.Background())
 	assert.NoError(t, err)
 	assert.Empty(t, users)
 
 	// Reset the cache timestamp to force a lookup.
 	slackEmailUserCacheUnixTime = 0
 
-	users, err = slackEngine.cacheSlackUsers()
+	users, err = slackEngine.cacheSlackUsers(context.Background())
 	assert.NoError(t, err)
 	assert.NotEmpty(t, users)
 
 	// Pick first email in the cache
 	for email, _ := range users {
-		user, err := slackEngine.emailToSlackUser(email)
+		user, err := slackEngine.emailToSlackUser(context.Background(), email)
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
 		return
@@ -1,11 +1,14 @@
 package phase
 
 import (
+	"context"
 	"strconv"
+	"strings"
 
 	"github.com/Nextdoor/conductor/services/build"
 	"github.com/Nextdoor/conductor/shared/flags"
 	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/tracing"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
@@ -22,23 +25,31 @@ func newJenkins() *jenkinsPhase {
 	return &jenkinsPhase{}
 }
 
-func (p *jenkinsPhase) Start(phaseType types.PhaseType, trainID,
-	deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string,
+func (p *jenkinsPhase) Start(ctx context.Context, phaseType types.PhaseType, phaseName string, phaseID, trainID,
+	deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string, excludedSHAs []string,
 	buildUser *types.User) error {
 
 	params := make(map[string]string)
 	params["TRAIN_ID"] = strconv.FormatUint(trainID, 10)
+	// Pipelines can have several phases of a type, which share its job.
+	params["PHASE_NAME"] = phaseName
+	params["PHASE_ID"] = strconv.FormatUint(phaseID, 10)
 	params["DELIVERY_PHASE_ID"] = strconv.FormatUint(deliveryPhaseID, 10)
 	params["VERIFICATION_PHASE_ID"] = strconv.FormatUint(verificationPhaseID, 10)
 	params["DEPLOY_PHASE_ID"] = strconv.FormatUint(deployPhaseID, 10)
 	params["BRANCH"] = branch
 	params["SHA"] = sha
+	params["EXCLUDED_SHAS"] = strings.Join(excludedSHAs, ",")
 	params["CONDUCTOR_HOSTNAME"] = settings.GetHostname()
 	if buildUser != nil {
 		params["BUILD_USER"] = buildUser.Name
 	} else {
 		params["BUILD_USER"] = "Conductor"
 	}
+	// Jobs can send this back as the traceparent header to join the trace.
+	if traceParent := tracing.TraceParent(ctx); traceParent != "" {
+		params["TRACEPARENT"] = traceParent
+	}
 
 	var job string
 	switch phaseType {
@@ -54,5 +65,5 @@ func (p *jenkinsPhase) Start(phaseType types.PhaseType, trainID,
 		return nil
 	}
 
-	return build.Jenkins().TriggerJob(job, params)
+	return build.Jenkins().TriggerJob(ctx, job, params)
 }
@@ -3,8 +3,6 @@ package phase
 import (
 	"sort"
 	"time"
-
-	"github.com/Nextdoor/conductor/shared/types"
 )
 
 const (
@@ -15,9 +13,7 @@ const (
 	MaxJobRuntime = time.Minute * 30
 )
 
-func AllJobsComplete(phaseType types.PhaseType, completedJobs []string) bool {
-	expectedJobs := types.JobsForPhase(phaseType)
-
+func AllJobsComplete(expectedJobs []string, completedJobs []string) bool {
 	if completedJobs == nil && expectedJobs == nil {
 		return true
 	}
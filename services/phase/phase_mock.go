@@ -1,26 +1,31 @@
 package phase
 
 import (
+	"context"
+
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
 type PhaseServiceMock struct {
 	StartMock func(
-		phaseType types.PhaseType, trainID,
-		deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string,
+		ctx context.Context, phaseType types.PhaseType, phaseName string, phaseID, trainID,
+		deliveryPhaseID, verificationPhaseID, deployPhaseID uint64, branch, sha string, excludedSHAs []string,
 		buildUser *types.User) error
 }
 
 func (m *PhaseServiceMock) Start(
+	ctx context.Context,
 	phaseType types.PhaseType,
-	trainID, deliveryPhaseID, verificationPhaseID, deployPhaseID uint64,
+	phaseName string,
+	phaseID, trainID, deliveryPhaseID, verificationPhaseID, deployPhaseID uint64,
 	branch, sha string,
+	excludedSHAs []string,
 	buildUser *types.User) error {
 
 	if m.StartMock == nil {
 		return nil
 	}
 	return m.StartMock(
-		phaseType, trainID, deliveryPhaseID, verificationPhaseID, deployPhaseID,
-		branch, sha, buildUser)
+		ctx, phaseType, phaseName, phaseID, trainID, deliveryPhaseID, verificationPhaseID, deployPhaseID,
+		branch, sha, excludedSHAs, buildUser)
 }
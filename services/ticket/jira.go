@@ -1,6 +1,7 @@
 package ticket
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 	"github.com/Nextdoor/conductor/shared/flags"
 	"github.com/Nextdoor/conductor/shared/logger"
 	"github.com/Nextdoor/conductor/shared/settings"
+	"github.com/Nextdoor/conductor/shared/tracing"
 	"github.com/Nextdoor/conductor/shared/types"
 )
 
@@ -61,15 +63,10 @@ func newJIRA() *JIRA {
 		panic(errors.New("jira_issue_type flag must be set."))
 	}
 
-	tp := jira.BasicAuthTransport{
-		Username: jiraUsername,
-		Password: jiraApiToken,
-	}
-	
 	logger.Info("All JIRA flags are set. Creating the JIRA client...")
 
 	var err error
-	jiraClient, err = jira.NewClient(tp.Client(), jiraURL)
+	jiraClient, err = newJIRAClient(context.Background())
 	if err != nil {
 		panic(err)
 	}
@@ -78,6 +75,17 @@ func newJIRA() *JIRA {
 	return &JIRA{}
 }
 
+// The go-jira client doesn't take a context per call,
+// so each call gets a client whose requests continue the trace in ctx.
+func newJIRAClient(ctx context.Context) (*jira.Client, error) {
+	tp := jira.BasicAuthTransport{
+		Username:  jiraUsername,
+		Password:  jiraApiToken,
+		Transport: tracing.ContextTransport(ctx, nil),
+	}
+	return jira.NewClient(tp.Client(), jiraURL)
+}
+
 func parentIssueQuery(jiraProject, summary string) string {
 	return fmt.Sprintf(parentIssueQueryJQL, jiraProject, summary)
 }
@@ -86,42 +94,75 @@ func ticketsQuery(jiraProject, parentIssueKey, jiraIssueType string) string {
 	return fmt.Sprintf(ticketsQueryJQL, jiraProject, parentIssueKey, jiraIssueType)
 }
 
-func (t *JIRA) CreateTickets(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
-	tickets, err := ticketsFromCommits(train, commits)
+func (t *JIRA) CreateTickets(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+	client, err := newJIRAClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets, err := ticketsFromCommits(client, train, commits)
 	if err != nil {
 		return nil, err
 	}
 	return tickets, nil
 }
 
-func (t *JIRA) CloseTickets(tickets []*types.Ticket) error {
+func (t *JIRA) CloseTickets(ctx context.Context, tickets []*types.Ticket) error {
+	client, err := newJIRAClient(ctx)
+	if err != nil {
+		return err
+	}
+
 	keys := make([]string, len(tickets))
 	for i := range tickets {
 		keys[i] = tickets[i].Key
 	}
-	err := t.closeIssuesByKeys(keys)
+	err = t.closeIssuesByKeys(client, keys)
 	return err
 }
 
-func (t *JIRA) DeleteTickets(train *types.Train) error {
-	parentIssue, err := getParentIssue(train)
+func (t *JIRA) DeleteTickets(ctx context.Context, train *types.Train) error {
+	client, err := newJIRAClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	parentIssue, err := getParentIssue(client, train)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Issue.Delete(parentIssue.Key)
+	if err != nil {
+		return parseBodyError(resp, err)
+	}
+	return nil
+}
+
+func (t *JIRA) DeleteTicket(ctx context.Context, ticket *types.Ticket) error {
+	client, err := newJIRAClient(ctx)
 	if err != nil {
 		return err
 	}
-	resp, err := jiraClient.Issue.Delete(parentIssue.Key)
+
+	resp, err := client.Issue.Delete(ticket.Key)
 	if err != nil {
 		return parseBodyError(resp, err)
 	}
 	return nil
 }
 
-func (t *JIRA) SyncTickets(train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
-	parentIssue, err := getParentIssue(train)
+func (t *JIRA) SyncTickets(ctx context.Context, train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
+	client, err := newJIRAClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parentIssue, err := getParentIssue(client, train)
 	if err != nil {
 		return nil, nil, err
 	}
 	jql := ticketsQuery(jiraProject, parentIssue.Key, jiraIssueType)
-	issues, resp, err := jiraClient.Issue.Search(jql, nil)
+	issues, resp, err := client.Issue.Search(jql, nil)
 	if err != nil {
 		return nil, nil, parseBodyError(resp, err)
 	}
@@ -209,14 +250,19 @@ func (t *JIRA) SyncTickets(train *types.Train) ([]*types.Ticket, []*types.Ticket
 	return newTickets, updatedTickets, nil
 }
 
-func (t *JIRA) CloseTrainTickets(train *types.Train) error {
+func (t *JIRA) CloseTrainTickets(ctx context.Context, train *types.Train) error {
+	client, err := newJIRAClient(ctx)
+	if err != nil {
+		return err
+	}
+
 	// Close all the train's issues: children and the parent.
-	parentIssue, err := getParentIssue(train)
+	parentIssue, err := getParentIssue(client, train)
 	if err != nil {
 		return err
 	}
 	jql := ticketsQuery(jiraProject, parentIssue.Key, jiraIssueType)
-	issues, resp, err := jiraClient.Issue.Search(jql, nil)
+	issues, resp, err := client.Issue.Search(jql, nil)
 	if err != nil {
 		return parseBodyError(resp, err)
 	}
@@ -231,7 +277,7 @@ func (t *JIRA) CloseTrainTickets(train *types.Train) error {
 
 	// TODO: Need system that properly moves tickets that were closed by Conductor to a new train
 	// in the case of branch switching.
-	err = t.closeIssuesByKeys(keys)
+	err = t.closeIssuesByKeys(client, keys)
 	if err != nil {
 		return err
 	}
@@ -239,14 +285,14 @@ func (t *JIRA) CloseTrainTickets(train *types.Train) error {
 	return nil
 }
 
-func (t *JIRA) closeIssuesByKeys(keys []string) error {
+func (t *JIRA) closeIssuesByKeys(client *jira.Client, keys []string) error {
 	if len(keys) == 0 {
 		return nil
 	}
 
 	// Just fetch the transitions for the first issue. The API request is
 	// slow and they should change exceedingly rarely.
-	transitions, resp, err := jiraClient.Issue.GetTransitions(keys[0])
+	transitions, resp, err := client.Issue.GetTransitions(keys[0])
 	if err != nil {
 		return parseBodyError(resp, err)
 	}
@@ -264,7 +310,7 @@ func (t *JIRA) closeIssuesByKeys(keys []string) error {
 	}
 	closed := make([]string, 0)
 	for i := range keys {
-		resp, err := jiraClient.Issue.DoTransition(keys[i], doneTransitionID)
+		resp, err := client.Issue.DoTransition(keys[i], doneTransitionID)
 		if err != nil {
 			return parseBodyError(resp, err)
 		}
@@ -291,7 +337,7 @@ func parentSummary(train *types.Train) string {
 	return fmt.Sprintf("Train %d", train.ID)
 }
 
-func createParentIssue(train *types.Train) (*jira.Issue, error) {
+func createParentIssue(client *jira.Client, train *types.Train) (*jira.Issue, error) {
 	// Create parent issue.
 	// Individual tickets are linked to it as a sub-task.
 	i := jira.Issue{
@@ -314,12 +360,12 @@ func createParentIssue(train *types.Train) (*jira.Issue, error) {
 		},
 	}
 	// Create just returns a minimal issue struct
-	parentIssue, resp, err := jiraClient.Issue.Create(&i)
+	parentIssue, resp, err := client.Issue.Create(&i)
 	if err != nil {
 		return nil, parseBodyError(resp, err)
 	}
 	// Call Get on the minimal issue to get the fully-populated struct
-	parentIssue, resp, err = jiraClient.Issue.Get(parentIssue.ID, nil)
+	parentIssue, resp, err = client.Issue.Get(parentIssue.ID, nil)
 	if err != nil {
 		return nil, parseBodyError(resp, err)
 	}
@@ -327,10 +373,10 @@ func createParentIssue(train *types.Train) (*jira.Issue, error) {
 	return parentIssue, nil
 }
 
-func getParentIssue(train *types.Train) (*jira.Issue, error) {
+func getParentIssue(client *jira.Client, train *types.Train) (*jira.Issue, error) {
 	logger.Info("Looking for parent issue for Train %v", train.ID)
 	jql := parentIssueQuery(jiraProject, parentSummary(train))
-	issues, resp, err := jiraClient.Issue.Search(jql, nil)
+	issues, resp, err := client.Issue.Search(jql, nil)
 	if err != nil {
 		return nil, parseBodyError(resp, err)
 	}
@@ -344,7 +390,7 @@ func getParentIssue(train *types.Train) (*jira.Issue, error) {
 	return &issues[0], nil
 }
 
-func createSubIssue(parentIssue *jira.Issue, username string, commits []*types.Commit) (*jira.Issue, error) {
+func createSubIssue(client *jira.Client, parentIssue *jira.Issue, username string, commits []*types.Commit) (*jira.Issue, error) {
 	desc, err := descriptionFromCommits(commits)
 	if err != nil {
 		logger.Error("Error generating descriptionFromCommits: %v", err)
@@ -373,12 +419,12 @@ func createSubIssue(parentIssue *jira.Issue, username string, commits []*types.C
 		},
 	}
 	// Create just returns a minimal issue struct.
-	issue, resp, err := jiraClient.Issue.Create(issue)
+	issue, resp, err := client.Issue.Create(issue)
 	if err != nil {
 		return nil, parseBodyError(resp, err)
 	}
 	// We need to make another request to get the full issue object.
-	issue, resp, err = jiraClient.Issue.Get(issue.ID, nil)
+	issue, resp, err = client.Issue.Get(issue.ID, nil)
 	if err != nil {
 		return nil, parseBodyError(resp, err)
 	}
@@ -386,17 +432,17 @@ func createSubIssue(parentIssue *jira.Issue, username string, commits []*types.C
 	return issue, nil
 }
 
-func ticketsFromCommits(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+func ticketsFromCommits(client *jira.Client, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 	if len(commits) == 0 {
 		return nil, fmt.Errorf("No commits passed to ticketsFromCommits")
 	}
 
-	parentIssue, err := getParentIssue(train)
+	parentIssue, err := getParentIssue(client, train)
 	if err != nil && err != ErrIssueNotFound {
 		return nil, err
 	}
 	if parentIssue == nil {
-		parentIssue, err = createParentIssue(train)
+		parentIssue, err = createParentIssue(client, train)
 		if err != nil {
 			return nil, err
 		}
@@ -407,8 +453,8 @@ func ticketsFromCommits(train *types.Train, commits []*types.Commit) ([]*types.T
 	i := 0
 	tickets := make([]*types.Ticket, len(commitsMap))
 	for email, commits := range commitsMap {
-		username := emailToUsernameInJIRA(email)
-		subissue, err := createSubIssue(parentIssue, username, commits)
+		username := emailToUsernameInJIRA(client, email)
+		subissue, err := createSubIssue(client, parentIssue, username, commits)
 		if err != nil {
 			return nil, err
 		}
@@ -436,8 +482,8 @@ func commitsByEmail(commits []*types.Commit) map[string][]*types.Commit {
 }
 
 // If not found, returns DefaultAccountID.
-func emailToUsernameInJIRA(email string) string {
-	users, resp, err := jiraClient.User.Find(email)
+func emailToUsernameInJIRA(client *jira.Client, email string) string {
+	users, resp, err := client.User.Find(email)
 	if err != nil {
 		err = parseBodyError(resp, err)
 		logger.Error("Error finding JIRA user for email %s: %v", email, err)
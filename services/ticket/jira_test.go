@@ -3,6 +3,7 @@
 package ticket
 
 import (
+	"context"
 	"testing"
 
 	"github.com/Nextdoor/go-jira"
@@ -24,35 +25,35 @@ func TestJIRATickets(t *testing.T) {
 	}
 
 	// Test that closed is detected.
-	newTickets, err := jiraService.CreateTickets(train, testCommits)
+	newTickets, err := jiraService.CreateTickets(context.Background(), train, testCommits)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 1)
 	assert.False(t, newTickets[0].ClosedAt.HasValue())
 
-	err = jiraService.CloseTickets(newTickets)
+	err = jiraService.CloseTickets(context.Background(), newTickets)
 	assert.NoError(t, err)
 
 	train.Tickets = newTickets
 
-	newTickets, updatedTickets, err := jiraService.SyncTickets(train)
+	newTickets, updatedTickets, err := jiraService.SyncTickets(context.Background(), train)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 0)
 	assert.Len(t, updatedTickets, 1)
 	assert.True(t, updatedTickets[0].ClosedAt.HasValue())
 
-	parentIssue, err := getParentIssue(train)
+	parentIssue, err := getParentIssue(jiraClient, train)
 	assert.NoError(t, err)
 
 	newCommits := []*types.Commit{
 		{AuthorEmail: email2, Message: message3, AuthorName: jiraUsername, SHA: sha3}}
-	newTickets, err = jiraService.CreateTickets(train, newCommits)
+	newTickets, err = jiraService.CreateTickets(context.Background(), train, newCommits)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 1)
 
 	train.Tickets = append(train.Tickets, newTickets[0])
 
 	// Test that the new tickets are appeneded to the same parent issue.
-	newParentIssue, err := getParentIssue(train)
+	newParentIssue, err := getParentIssue(jiraClient, train)
 	assert.NoError(t, err)
 	assert.Equal(t, parentIssue.Key, newParentIssue.Key)
 
@@ -86,7 +87,7 @@ func TestJIRATickets(t *testing.T) {
 	issue, _, err = jiraClient.Issue.Get(issue.ID, nil)
 	assert.NoError(t, err)
 
-	newTickets, updatedTickets, err = jiraService.SyncTickets(train)
+	newTickets, updatedTickets, err = jiraService.SyncTickets(context.Background(), train)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 1)
 	assert.Len(t, updatedTickets, 0)
@@ -99,7 +100,7 @@ func TestJIRATickets(t *testing.T) {
 	_, err = jiraClient.Issue.Delete(train.Tickets[1].Key)
 	assert.NoError(t, err)
 
-	newTickets, updatedTickets, err = jiraService.SyncTickets(train)
+	newTickets, updatedTickets, err = jiraService.SyncTickets(context.Background(), train)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 0)
 	assert.Len(t, updatedTickets, 2)
@@ -112,10 +113,10 @@ func TestJIRATickets(t *testing.T) {
 	assert.True(t, updatedTickets[0].Key != updatedTickets[1].Key)
 
 	// Clean up
-	err = jiraService.DeleteTickets(train)
+	err = jiraService.DeleteTickets(context.Background(), train)
 	assert.NoError(t, err)
 
-	_, err = getParentIssue(train)
+	_, err = getParentIssue(jiraClient, train)
 	assert.Equal(t, ErrIssueNotFound, err)
 }
 
@@ -131,36 +132,36 @@ func TestCloseTrainTickets(t *testing.T) {
 		Branch: "branch",
 	}
 
-	newTickets, err := jiraService.CreateTickets(train, testCommits)
+	newTickets, err := jiraService.CreateTickets(context.Background(), train, testCommits)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 1)
 	assert.False(t, newTickets[0].ClosedAt.HasValue())
 
 	train.Tickets = newTickets
 
-	err = jiraService.CloseTrainTickets(train)
+	err = jiraService.CloseTrainTickets(context.Background(), train)
 	assert.NoError(t, err)
 
 	// Test that children issues are closed by CloseTrainTickets.
 	assert.Len(t, newTickets, 1)
 	assert.False(t, newTickets[0].ClosedAt.HasValue())
 
-	newTickets, updatedTickets, err := jiraService.SyncTickets(train)
+	newTickets, updatedTickets, err := jiraService.SyncTickets(context.Background(), train)
 	assert.NoError(t, err)
 	assert.Len(t, newTickets, 0)
 	assert.Len(t, updatedTickets, 1)
 	assert.True(t, updatedTickets[0].ClosedAt.HasValue())
 
 	// Test that parent issue is closed by CloseTrainTickets.
-	parentIssue, err := getParentIssue(train)
+	parentIssue, err := getParentIssue(jiraClient, train)
 	assert.NoError(t, err)
 
 	assert.Equal(t, doneTransition, parentIssue.Fields.Status.Name)
 
 	// Clean up
-	err = jiraService.DeleteTickets(train)
+	err = jiraService.DeleteTickets(context.Background(), train)
 	assert.NoError(t, err)
 
-	_, err = getParentIssue(train)
+	_, err = getParentIssue(jiraClient, train)
 	assert.Equal(t, ErrIssueNotFound, err)
 }
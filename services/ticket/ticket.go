@@ -3,6 +3,7 @@ package ticket
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"sync"
@@ -22,12 +23,15 @@ const descriptionTemplate = `{{ .AuthorName }}
 {{ range .Commits }}	- http://c/{{ .ShortSHA }} - {{ index (Split .Message "\n") 0 }}
 {{ end }}`
 
+// ctx carries the trace to continue in calls to the ticket tracker.
 type Service interface {
-	CreateTickets(*types.Train, []*types.Commit) ([]*types.Ticket, error)
-	CloseTickets([]*types.Ticket) error
-	DeleteTickets(*types.Train) error
-	SyncTickets(*types.Train) ([]*types.Ticket, []*types.Ticket, error)
-	CloseTrainTickets(*types.Train) error
+	CreateTickets(context.Context, *types.Train, []*types.Commit) ([]*types.Ticket, error)
+	CloseTickets(context.Context, []*types.Ticket) error
+	DeleteTickets(context.Context, *types.Train) error
+	// Deletes one of the train's tickets, like when its author's commit is taken off the train.
+	DeleteTicket(context.Context, *types.Ticket) error
+	SyncTickets(context.Context, *types.Train) ([]*types.Ticket, []*types.Ticket, error)
+	CloseTrainTickets(context.Context, *types.Train) error
 }
 
 var (
@@ -63,23 +67,27 @@ func newFake() *fake {
 	return &fake{}
 }
 
-func (t *fake) CreateTickets(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+func (t *fake) CreateTickets(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 	return nil, nil
 }
 
-func (t *fake) CloseTickets(tickets []*types.Ticket) error {
+func (t *fake) CloseTickets(ctx context.Context, tickets []*types.Ticket) error {
 	return nil
 }
 
-func (t *fake) DeleteTickets(train *types.Train) error {
+func (t *fake) DeleteTickets(ctx context.Context, train *types.Train) error {
 	return nil
 }
 
-func (t *fake) SyncTickets(train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
+func (t *fake) DeleteTicket(ctx context.Context, ticket *types.Ticket) error {
+	return nil
+}
+
+func (t *fake) SyncTickets(ctx context.Context, train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
 	return nil, nil, nil
 }
 
-func (t *fake) CloseTrainTickets(train *types.Train) error {
+func (t *fake) CloseTrainTickets(ctx context.Context, train *types.Train) error {
 	return nil
 }
 
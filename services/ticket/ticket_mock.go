@@ -1,46 +1,58 @@
 package ticket
 
-import "github.com/Nextdoor/conductor/shared/types"
+import (
+	"context"
+
+	"github.com/Nextdoor/conductor/shared/types"
+)
 
 type TicketServiceMock struct {
-	CreateTicketsMock     func(*types.Train, []*types.Commit) ([]*types.Ticket, error)
-	CloseTicketsMock      func([]*types.Ticket) error
-	DeleteTicketsMock     func(*types.Train) error
-	SyncTicketsMock       func(*types.Train) ([]*types.Ticket, []*types.Ticket, error)
-	CloseTrainTicketsMock func(*types.Train) error
+	CreateTicketsMock     func(context.Context, *types.Train, []*types.Commit) ([]*types.Ticket, error)
+	CloseTicketsMock      func(context.Context, []*types.Ticket) error
+	DeleteTicketsMock     func(context.Context, *types.Train) error
+	DeleteTicketMock      func(context.Context, *types.Ticket) error
+	SyncTicketsMock       func(context.Context, *types.Train) ([]*types.Ticket, []*types.Ticket, error)
+	CloseTrainTicketsMock func(context.Context, *types.Train) error
 }
 
-func (m *TicketServiceMock) CreateTickets(train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
+func (m *TicketServiceMock) CreateTickets(ctx context.Context, train *types.Train, commits []*types.Commit) ([]*types.Ticket, error) {
 	if m.CreateTicketsMock == nil {
 		return nil, nil
 	}
-	return m.CreateTicketsMock(train, commits)
+	return m.CreateTicketsMock(ctx, train, commits)
 }
 
-func (m *TicketServiceMock) CloseTickets(tickets []*types.Ticket) error {
+func (m *TicketServiceMock) CloseTickets(ctx context.Context, tickets []*types.Ticket) error {
 	if m.CloseTicketsMock == nil {
 		return nil
 	}
-	return m.CloseTicketsMock(tickets)
+	return m.CloseTicketsMock(ctx, tickets)
 }
 
-func (m *TicketServiceMock) DeleteTickets(train *types.Train) error {
+func (m *TicketServiceMock) DeleteTickets(ctx context.Context, train *types.Train) error {
 	if m.DeleteTicketsMock == nil {
 		return nil
 	}
-	return m.DeleteTicketsMock(train)
+	return m.DeleteTicketsMock(ctx, train)
+}
+
+func (m *TicketServiceMock) DeleteTicket(ctx context.Context, ticket *types.Ticket) error {
+	if m.DeleteTicketMock == nil {
+		return nil
+	}
+	return m.DeleteTicketMock(ctx, ticket)
 }
 
-func (m *TicketServiceMock) SyncTickets(train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
+func (m *TicketServiceMock) SyncTickets(ctx context.Context, train *types.Train) ([]*types.Ticket, []*types.Ticket, error) {
 	if m.SyncTicketsMock == nil {
 		return nil, nil, nil
 	}
-	return m.SyncTicketsMock(train)
+	return m.SyncTicketsMock(ctx, train)
 }
 
-func (m *TicketServiceMock) CloseTrainTickets(train *types.Train) error {
+func (m *TicketServiceMock) CloseTrainTickets(ctx context.Context, train *types.Train) error {
 	if m.CloseTrainTicketsMock == nil {
 		return nil
 	}
-	return m.CloseTrainTicketsMock(train)
+	return m.CloseTrainTicketsMock(ctx, train)
 }
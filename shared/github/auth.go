@@ -1,6 +1,7 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,13 +9,15 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+
+	"github.com/Nextdoor/conductor/shared/tracing"
 )
 
 type Auth interface {
 	AuthorizeURL() string
 	AccessTokenURL() string
-	AccessToken(code string) (string, error)
-	UserInfo(string) (string, string, string, error)
+	AccessToken(ctx context.Context, code string) (string, error)
+	UserInfo(ctx context.Context, accessToken string) (string, string, string, error)
 }
 
 type auth struct {
@@ -37,17 +40,22 @@ func (g *auth) AccessTokenURL() string {
 	return fmt.Sprintf("%s/login/oauth/access_token", githubHost)
 }
 
-func (g *auth) AccessToken(code string) (string, error) {
+func (g *auth) AccessToken(ctx context.Context, code string) (string, error) {
 	data := url.Values{
 		"client_id":     []string{g.clientID},
 		"client_secret": []string{g.clientSecret},
 		"code":          []string{code},
 	}
 	req, err := http.NewRequest("POST", g.AccessTokenURL(), strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	client := &http.Client{Transport: tracing.Transport(nil)}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -79,8 +87,8 @@ type githubLoginResponse struct {
 	Error       string `json:"error_description"`
 }
 
-func (g *auth) UserInfo(accessToken string) (string, string, string, error) {
-	client, err := newClient(accessToken)
+func (g *auth) UserInfo(ctx context.Context, accessToken string) (string, string, string, error) {
+	client, err := newClient(ctx, accessToken)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -1,6 +1,7 @@
 package github
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"reflect"
@@ -14,22 +15,23 @@ import (
 const paginationMax = 100
 
 type Code interface {
-	CommitsOnBranch(string, int) ([]*github.RepositoryCommit, error)
-	CommitsOnBranchAfter(string, string) ([]*github.RepositoryCommit, error)
-	CompareRefs(string, string) ([]*github.RepositoryCommit, error)
-	Revert(sha1, branch string) error
+	CommitsOnBranch(context.Context, string, int) ([]*github.RepositoryCommit, error)
+	CommitsOnBranchAfter(context.Context, string, string) ([]*github.RepositoryCommit, error)
+	CompareRefs(context.Context, string, string) ([]*github.RepositoryCommit, error)
+	ChangedFiles(context.Context, string, string) ([]string, error)
+	Merge(ctx context.Context, head, branch string) (*github.RepositoryCommit, error)
 	ParseWebhookForBranch(*http.Request, *regexp.Regexp) (string, error)
 }
 
 type code struct {
-	client        *github.Client
+	codeToken     string
 	repoOwner     string
 	repo          string
 	webhookSecret string
 }
 
 func NewCode(codeToken, repoOwner, repo, webhookURL, webhookSecret string) Code {
-	client, err := newClient(codeToken)
+	client, err := newClient(context.Background(), codeToken)
 	if err != nil {
 		panic(err)
 	}
@@ -57,15 +59,23 @@ func NewCode(codeToken, repoOwner, repo, webhookURL, webhookSecret string) Code
 	}
 
 	return &code{
-		client:        client,
+		codeToken:     codeToken,
 		repoOwner:     repoOwner,
 		repo:          repo,
 		webhookSecret: webhookSecret,
 	}
 }
 
-func (g *code) CommitsOnBranch(branch string, max int) ([]*github.RepositoryCommit, error) {
-	iterator := newCommitIterator(g, branch)
+// A client for one call, which continues the trace in ctx.
+func (g *code) client(ctx context.Context) (*github.Client, error) {
+	return newClient(ctx, g.codeToken)
+}
+
+func (g *code) CommitsOnBranch(ctx context.Context, branch string, max int) ([]*github.RepositoryCommit, error) {
+	iterator, err := newCommitIterator(ctx, g, branch)
+	if err != nil {
+		return nil, err
+	}
 	commits := make([]*github.RepositoryCommit, 0)
 	for {
 		newCommits, next, err := iterator.next()
@@ -86,8 +96,11 @@ func (g *code) CommitsOnBranch(branch string, max int) ([]*github.RepositoryComm
 	return reverse(commits), nil
 }
 
-func (g *code) CommitsOnBranchAfter(branch, sha string) ([]*github.RepositoryCommit, error) {
-	iterator := newCommitIterator(g, branch)
+func (g *code) CommitsOnBranchAfter(ctx context.Context, branch, sha string) ([]*github.RepositoryCommit, error) {
+	iterator, err := newCommitIterator(ctx, g, branch)
+	if err != nil {
+		return nil, err
+	}
 	commits := make([]*github.RepositoryCommit, 0)
 	for {
 		newCommits, next, err := iterator.next()
@@ -116,13 +129,17 @@ func (g *code) CommitsOnBranchAfter(branch, sha string) ([]*github.RepositoryCom
 }
 
 // Gets all commits between oldRef and newRef, in order from [newest, ..., oldest]
-func (g *code) CompareRefs(oldRef, newRef string) ([]*github.RepositoryCommit, error) {
+func (g *code) CompareRefs(ctx context.Context, oldRef, newRef string) ([]*github.RepositoryCommit, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
+	}
 	commits := make([]*github.RepositoryCommit, 0)
 
 	skipLast := false
 	for {
 		// Do a comparison between oldRef <-> newRef.
-		comparison, _, err := g.client.Repositories.CompareCommits(g.repoOwner, g.repo, oldRef, newRef)
+		comparison, _, err := client.Repositories.CompareCommits(g.repoOwner, g.repo, oldRef, newRef)
 		if err != nil {
 			return nil, err
 		}
@@ -136,6 +153,10 @@ func (g *code) CompareRefs(oldRef, newRef string) ([]*github.RepositoryCommit, e
 		}
 		commits = append(newCommits, commits...)
 
+		if len(comparison.Commits) == 0 {
+			// newRef has nothing oldRef doesn't, e.g. it's an ancestor of oldRef.
+			break
+		}
 		oldestRefFound := *comparison.Commits[0].SHA
 		if len(comparison.Commits) == *comparison.TotalCommits {
 			// We're done - No commits left behind.
@@ -153,8 +174,54 @@ func (g *code) CompareRefs(oldRef, newRef string) ([]*github.RepositoryCommit, e
 	return commits, nil
 }
 
-func (g *code) Revert(sha1, branch string) error {
-	return nil
+// GitHub lists at most this many files in a comparison.
+const maxComparisonFiles = 300
+
+// Gets the paths of the files changed between oldRef and newRef.
+// Errors if GitHub's list may be missing some, because it's at its limit.
+func (g *code) ChangedFiles(ctx context.Context, oldRef, newRef string) ([]string, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	comparison, _, err := client.Repositories.CompareCommits(g.repoOwner, g.repo, oldRef, newRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(comparison.Files) >= maxComparisonFiles {
+		return nil, fmt.Errorf("GitHub listed %d files changed in %s..%s, its limit, so some may be missing",
+			len(comparison.Files), oldRef, newRef)
+	}
+
+	files := make([]string, 0, len(comparison.Files))
+	for _, file := range comparison.Files {
+		files = append(files, *file.Filename)
+	}
+	return files, nil
+}
+
+// Merges head into branch, returning the merge commit, or nil if branch already has head.
+func (g *code) Merge(ctx context.Context, head, branch string) (*github.RepositoryCommit, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Merge %s into %s", head, branch)
+	commit, resp, err := client.Repositories.Merge(g.repoOwner, g.repo, &github.RepositoryMergeRequest{
+		Base:          &branch,
+		Head:          &head,
+		CommitMessage: &message,
+	})
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusConflict {
+			return nil, fmt.Errorf("%s doesn't merge cleanly into %s", head, branch)
+		}
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return commit, nil
 }
 
 func (g *code) ParseWebhookForBranch(r *http.Request, branchPattern *regexp.Regexp) (string, error) {
@@ -210,18 +277,24 @@ func (g *code) ParseWebhookForBranch(r *http.Request, branchPattern *regexp.Rege
 }
 
 type commitIterator struct {
-	sha string
-	g   *code
+	sha    string
+	g      *code
+	client *github.Client
 
 	page int
 }
 
-func newCommitIterator(g *code, startRef string) *commitIterator {
-	return &commitIterator{
-		sha:  startRef,
-		g:    g,
-		page: 1,
+func newCommitIterator(ctx context.Context, g *code, startRef string) (*commitIterator, error) {
+	client, err := g.client(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return &commitIterator{
+		sha:    startRef,
+		g:      g,
+		client: client,
+		page:   1,
+	}, nil
 }
 
 func (i *commitIterator) next() ([]*github.RepositoryCommit, bool, error) {
@@ -232,7 +305,7 @@ func (i *commitIterator) next() ([]*github.RepositoryCommit, bool, error) {
 	}
 	options.Page = i.page
 	options.PerPage = paginationMax
-	commits, resp, err := i.g.client.Repositories.ListCommits(i.g.repoOwner, i.g.repo, &options)
+	commits, resp, err := i.client.Repositories.ListCommits(i.g.repoOwner, i.g.repo, &options)
 	// Note: Commits returned from newest to oldest.
 	if err != nil {
 		return nil, false, err
@@ -1,14 +1,17 @@
 package github
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 
 	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/tracing"
 )
 
 var (
@@ -16,7 +19,8 @@ var (
 	githubHost = flags.EnvString("GITHUB_HOST", "")
 )
 
-func newClient(accessToken string) (*github.Client, error) {
+// The client's requests continue the trace in ctx.
+func newClient(ctx context.Context, accessToken string) (*github.Client, error) {
 	if githubHost == "" {
 		return nil, errors.New("github_host flag must be set")
 	}
@@ -28,7 +32,10 @@ func newClient(accessToken string) (*github.Client, error) {
 	tokenSource := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: accessToken},
 	)
-	tokenClient := oauth2.NewClient(oauth2.NoContext, tokenSource)
+	// Use a traced client for the requests underneath the token transport.
+	clientCtx := context.WithValue(oauth2.NoContext, oauth2.HTTPClient,
+		&http.Client{Transport: tracing.ContextTransport(ctx, nil)})
+	tokenClient := oauth2.NewClient(clientCtx, tokenSource)
 
 	client := github.NewClient(tokenClient)
 	client.BaseURL = githubURL
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/Nextdoor/conductor/shared/datadog"
+)
+
+type datadogSink struct{}
+
+func (datadogSink) Incr(name string, tags []string) {
+	datadog.Incr(name, tags)
+}
+
+func (datadogSink) Count(name string, count int, tags []string) {
+	datadog.Count(name, count, tags)
+}
+
+func (datadogSink) Gauge(name string, value float64, tags []string) {
+	datadog.Gauge(name, value, tags)
+}
+
+// Durations have always been sent to datadog as gauges; keep them that way so dashboards keep working.
+func (datadogSink) Histogram(name string, value float64, tags []string) {
+	datadog.Gauge(name, value, tags)
+}
@@ -0,0 +1,88 @@
+// Package metrics sends Conductor metrics to every configured sink.
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/logger"
+)
+
+// Comma-separated list of sinks to send metrics to: datadog, prometheus.
+var sinksFlag = flags.EnvString("METRICS_SINKS", "datadog")
+
+// Tags are datadog-style "key:value" strings.
+type Sink interface {
+	Incr(name string, tags []string)
+	Count(name string, count int, tags []string)
+	Gauge(name string, value float64, tags []string)
+	// For durations and other distributions, in seconds.
+	Histogram(name string, value float64, tags []string)
+}
+
+var (
+	sinks   []Sink
+	getOnce sync.Once
+)
+
+func getSinks() []Sink {
+	getOnce.Do(func() {
+		sinks = newSinks(sinksFlag)
+	})
+	return sinks
+}
+
+func newSinks(names string) []Sink {
+	var sinks []Sink
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "datadog":
+			sinks = append(sinks, datadogSink{})
+		case "prometheus":
+			sinks = append(sinks, Prometheus())
+		default:
+			panic(fmt.Errorf("Unknown metrics sink: %s", name))
+		}
+		logger.Info("Sending metrics to %s", name)
+	}
+	return sinks
+}
+
+// Whether metrics are being collected for the Prometheus /metrics endpoint.
+func PrometheusEnabled() bool {
+	for _, sink := range getSinks() {
+		if _, ok := sink.(*PrometheusSink); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func Incr(name string, tags []string) {
+	for _, sink := range getSinks() {
+		sink.Incr(name, tags)
+	}
+}
+
+func Count(name string, count int, tags []string) {
+	for _, sink := range getSinks() {
+		sink.Count(name, count, tags)
+	}
+}
+
+func Gauge(name string, value float64, tags []string) {
+	for _, sink := range getSinks() {
+		sink.Gauge(name, value, tags)
+	}
+}
+
+func Histogram(name string, value float64, tags []string) {
+	for _, sink := range getSinks() {
+		sink.Histogram(name, value, tags)
+	}
+}
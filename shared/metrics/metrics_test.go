@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSinks(t *testing.T) {
+	sinks := newSinks("datadog, prometheus")
+	assert.Len(t, sinks, 2)
+	assert.Equal(t, datadogSink{}, sinks[0])
+	assert.Equal(t, Prometheus(), sinks[1])
+
+	assert.Empty(t, newSinks(""))
+	assert.Panics(t, func() { newSinks("graphite") })
+}
@@ -0,0 +1,243 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Nextdoor/conductor/shared/logger"
+)
+
+// Prefix for all Prometheus metric names.
+const prometheusNamespace = "conductor_"
+
+// Histogram buckets, in seconds. Phases take minutes, tickets can stay open for days.
+var DefaultBuckets = []float64{
+	1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400, 28800, 86400, 172800, 604800}
+
+// Tags that would make a new time series for every train.
+// The current train is exposed through the train state gauges instead.
+var droppedLabels = map[string]bool{
+	"train_id": true,
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+type metricKind string
+
+const (
+	counterKind   metricKind = "counter"
+	gaugeKind     metricKind = "gauge"
+	histogramKind metricKind = "histogram"
+)
+
+type series struct {
+	labels  []label
+	value   float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+type family struct {
+	name   string
+	kind   metricKind
+	series map[string]*series
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// Keeps metrics in memory and serves them in the Prometheus text format.
+type PrometheusSink struct {
+	mutex    sync.Mutex
+	buckets  []float64
+	families map[string]*family
+}
+
+var (
+	prometheus     *PrometheusSink
+	prometheusOnce sync.Once
+)
+
+func Prometheus() *PrometheusSink {
+	prometheusOnce.Do(func() {
+		prometheus = NewPrometheusSink(DefaultBuckets)
+	})
+	return prometheus
+}
+
+func NewPrometheusSink(buckets []float64) *PrometheusSink {
+	return &PrometheusSink{
+		buckets:  buckets,
+		families: make(map[string]*family),
+	}
+}
+
+func prometheusName(name string) string {
+	return prometheusNamespace + invalidNameChars.ReplaceAllString(name, "_")
+}
+
+func prometheusLabels(tags []string) []label {
+	labels := make([]label, 0, len(tags))
+	for _, tag := range tags {
+		parts := strings.SplitN(tag, ":", 2)
+		name := invalidNameChars.ReplaceAllString(parts[0], "_")
+		if droppedLabels[name] {
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = parts[1]
+		}
+		labels = append(labels, label{name: name, value: value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []label, extra ...label) string {
+	all := append(append([]label{}, labels...), extra...)
+	if len(all) == 0 {
+		return ""
+	}
+	parts := make([]string, len(all))
+	for i, l := range all {
+		parts[i] = fmt.Sprintf(`%s="%s"`, l.name, labelValueEscaper.Replace(l.value))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Returns the series for the metric, creating it if needed.
+// Returns nil if the metric was already used as a different kind.
+// Must hold the lock.
+func (p *PrometheusSink) series(name string, kind metricKind, tags []string) *series {
+	name = prometheusName(name)
+	if kind == counterKind {
+		name += "_total"
+	}
+
+	f, ok := p.families[name]
+	if !ok {
+		f = &family{name: name, kind: kind, series: make(map[string]*series)}
+		p.families[name] = f
+	} else if f.kind != kind {
+		logger.Error("Metric %s is a %s, not a %s", name, f.kind, kind)
+		return nil
+	}
+
+	labels := prometheusLabels(tags)
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: labels}
+		if kind == histogramKind {
+			s.buckets = make([]uint64, len(p.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (p *PrometheusSink) Incr(name string, tags []string) {
+	p.Count(name, 1, tags)
+}
+
+func (p *PrometheusSink) Count(name string, count int, tags []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s := p.series(name, counterKind, tags); s != nil {
+		s.value += float64(count)
+	}
+}
+
+func (p *PrometheusSink) Gauge(name string, value float64, tags []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s := p.series(name, gaugeKind, tags); s != nil {
+		s.value = value
+	}
+}
+
+func (p *PrometheusSink) Histogram(name string, value float64, tags []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	s := p.series(name, histogramKind, tags)
+	if s == nil {
+		return
+	}
+	for i, bound := range p.buckets {
+		if value <= bound {
+			s.buckets[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Writes all metrics in the Prometheus text exposition format.
+func (p *PrometheusSink) Write(buffer *bytes.Buffer) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	names := make([]string, 0, len(p.families))
+	for name := range p.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := p.families[name]
+		fmt.Fprintf(buffer, "# TYPE %s %s\n", f.name, f.kind)
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			s := f.series[key]
+			if f.kind != histogramKind {
+				fmt.Fprintf(buffer, "%s%s %s\n", f.name, key, formatValue(s.value))
+				continue
+			}
+			for i, bound := range p.buckets {
+				fmt.Fprintf(buffer, "%s_bucket%s %d\n",
+					f.name, formatLabels(s.labels, label{"le", formatValue(bound)}), s.buckets[i])
+			}
+			fmt.Fprintf(buffer, "%s_bucket%s %d\n",
+				f.name, formatLabels(s.labels, label{"le", "+Inf"}), s.count)
+			fmt.Fprintf(buffer, "%s_sum%s %s\n", f.name, key, formatValue(s.sum))
+			fmt.Fprintf(buffer, "%s_count%s %d\n", f.name, key, s.count)
+		}
+	}
+}
+
+func (p *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	buffer := new(bytes.Buffer)
+	p.Write(buffer)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buffer.Bytes())
+}
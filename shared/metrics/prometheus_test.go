@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(p *PrometheusSink) string {
+	buffer := new(bytes.Buffer)
+	p.Write(buffer)
+	return buffer.String()
+}
+
+func TestPrometheusCounter(t *testing.T) {
+	p := NewPrometheusSink(DefaultBuckets)
+	p.Incr("train.deploy", []string{"train_id:1"})
+	p.Incr("train.deploy", []string{"train_id:2"})
+	p.Count("ticket.count", 3, []string{"train_id:2"})
+
+	// train_id is dropped, so both increments land on the same series.
+	assert.Equal(t,
+		"# TYPE conductor_ticket_count_total counter\n"+
+			"conductor_ticket_count_total 3\n"+
+			"# TYPE conductor_train_deploy_total counter\n"+
+			"conductor_train_deploy_total 2\n",
+		render(p))
+}
+
+func TestPrometheusGauge(t *testing.T) {
+	p := NewPrometheusSink(DefaultBuckets)
+	p.Gauge("train.state", 1, []string{"state:open"})
+	p.Gauge("train.state", 0, []string{"state:closed"})
+	p.Gauge("train.state", 0, []string{"state:open"})
+
+	assert.Equal(t,
+		"# TYPE conductor_train_state gauge\n"+
+			"conductor_train_state{state=\"closed\"} 0\n"+
+			"conductor_train_state{state=\"open\"} 0\n",
+		render(p))
+}
+
+func TestPrometheusHistogram(t *testing.T) {
+	p := NewPrometheusSink([]float64{1, 10})
+	tags := []string{"train_id:1", "phase_name:deploy"}
+	p.Histogram("phase.duration", 0.5, tags)
+	p.Histogram("phase.duration", 5, tags)
+	p.Histogram("phase.duration", 50, tags)
+
+	assert.Equal(t,
+		"# TYPE conductor_phase_duration histogram\n"+
+			"conductor_phase_duration_bucket{phase_name=\"deploy\",le=\"1\"} 1\n"+
+			"conductor_phase_duration_bucket{phase_name=\"deploy\",le=\"10\"} 2\n"+
+			"conductor_phase_duration_bucket{phase_name=\"deploy\",le=\"+Inf\"} 3\n"+
+			"conductor_phase_duration_sum{phase_name=\"deploy\"} 55.5\n"+
+			"conductor_phase_duration_count{phase_name=\"deploy\"} 3\n",
+		render(p))
+}
+
+func TestPrometheusKindConflict(t *testing.T) {
+	p := NewPrometheusSink(DefaultBuckets)
+	p.Gauge("job.duration", 1, nil)
+	p.Histogram("job.duration", 1, nil)
+
+	assert.Equal(t,
+		"# TYPE conductor_job_duration gauge\n"+
+			"conductor_job_duration 1\n",
+		render(p))
+}
+
+func TestPrometheusLabelEscaping(t *testing.T) {
+	p := NewPrometheusSink(DefaultBuckets)
+	p.Gauge("ticket.open", 1, []string{`ticket-user:a"b\c`})
+
+	assert.Equal(t,
+		"# TYPE conductor_ticket_open gauge\n"+
+			"conductor_ticket_open{ticket_user=\"a\\\"b\\\\c\"} 1\n",
+		render(p))
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	p := NewPrometheusSink(DefaultBuckets)
+	p.Incr("job.start", nil)
+
+	res := httptest.NewRecorder()
+	p.ServeHTTP(res, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, res.Body.String(), "conductor_job_start_total 1\n")
+}
@@ -19,6 +19,13 @@ var (
 	// Comma-separated list of admin user emails that can deploy and change mode.
 	adminUserFlag = flags.EnvString("ADMIN_USERS", "")
 
+	// Role of users who haven't been assigned one: viewer, committer, engineer, release-manager or admin.
+	defaultRoleFlag = flags.EnvString("DEFAULT_ROLE", "engineer")
+
+	// Comma-separated list of auth provider groups whose members are admins.
+	// Only providers that report group membership, like OIDC, support this.
+	adminGroupFlag = flags.EnvString("ADMIN_GROUPS", "")
+
 	// Comma-separated list of user emails who don't use staging by default.
 	// This list is ignored if noStagingVerification is set.
 	noStagingVerificationUsersFlag = flags.EnvString("NO_STAGING_VERIFICATION_USERS", "")
@@ -29,10 +36,12 @@ var (
 	robotUserFlag = flags.EnvString("ROBOT_USERS", "")
 
 	AdminUsers                 []string
+	AdminGroups                []string
 	RobotUsers                 []string
 	NoStagingVerificationUsers []string
 
 	CustomAdminUsers                 []string
+	CustomAdminGroups                []string
 	CustomRobotUsers                 []string
 	CustomNoStagingVerificationUsers []string
 )
@@ -66,6 +75,7 @@ func init() {
 
 func parseFlags() {
 	AdminUsers = parseListString(adminUserFlag)
+	AdminGroups = parseListString(adminGroupFlag)
 	RobotUsers = parseListString(robotUserFlag)
 	NoStagingVerificationUsers = parseListString(noStagingVerificationUsersFlag)
 
@@ -115,6 +125,11 @@ func CustomizeAdminUsers(adminUsers []string) {
 	CustomAdminUsers = adminUsers
 }
 
+// Should only be used for tests.
+func CustomizeAdminGroups(adminGroups []string) {
+	CustomAdminGroups = adminGroups
+}
+
 // Should only be used for tests.
 func CustomizeRobotUsers(robotUsers []string) {
 	CustomRobotUsers = robotUsers
@@ -124,6 +139,10 @@ func GetHostname() string {
 	return Hostname
 }
 
+func GetDefaultRole() string {
+	return defaultRoleFlag
+}
+
 func GetJenkinsRollbackJob() string {
 	return JenkinsRollbackJob
 }
@@ -135,6 +154,19 @@ func IsAdminUser(email string) bool {
 	return StringInList(email, AdminUsers)
 }
 
+func IsAdminGroupMember(groups []string) bool {
+	adminGroups := AdminGroups
+	if CustomAdminGroups != nil {
+		adminGroups = CustomAdminGroups
+	}
+	for _, group := range groups {
+		if StringInList(group, adminGroups) {
+			return true
+		}
+	}
+	return false
+}
+
 func IsRobotUser(email string) bool {
 	if CustomRobotUsers != nil {
 		return StringInList(email, CustomRobotUsers)
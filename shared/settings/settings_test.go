@@ -8,6 +8,7 @@ import (
 
 func TestParseFlags(t *testing.T) {
 	adminUserFlag = "admin-1, admin-2,admin-3"
+	adminGroupFlag = "group-1, group-2"
 	noStagingVerificationUsersFlag = "no-staging-1,    no-staging-2"
 	robotUserFlag = "robot-1,robot-2"
 	deliveryJobsFlag = "delivery-1"
@@ -22,6 +23,11 @@ func TestParseFlags(t *testing.T) {
 	assert.Equal(t, "admin-2", AdminUsers[1])
 	assert.Equal(t, "admin-3", AdminUsers[2])
 
+	assert.Equal(t, []string{"group-1", "group-2"}, AdminGroups)
+	assert.True(t, IsAdminGroupMember([]string{"other", "group-2"}))
+	assert.False(t, IsAdminGroupMember([]string{"other"}))
+	assert.False(t, IsAdminGroupMember(nil))
+
 	assert.Equal(t, "no-staging-1", NoStagingVerificationUsers[0])
 	assert.Equal(t, "no-staging-2", NoStagingVerificationUsers[1])
 
@@ -38,6 +44,7 @@ func TestParseFlags(t *testing.T) {
 
 func clearFlags() {
 	adminUserFlag = ""
+	adminGroupFlag = ""
 	noStagingVerificationUsersFlag = ""
 	robotUserFlag = ""
 	deliveryJobsFlag = ""
@@ -0,0 +1,204 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Nextdoor/conductor/shared/flags"
+	"github.com/Nextdoor/conductor/shared/logger"
+)
+
+var (
+	// Base URL of an OTLP/HTTP collector, e.g. http://otel-collector:4318.
+	// Spans are not exported if empty.
+	otlpEndpoint = flags.EnvString("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	serviceName  = flags.EnvString("OTEL_SERVICE_NAME", "conductor")
+)
+
+const (
+	exportBatchSize = 512
+	exportInterval  = time.Second * 5
+	// Spans are dropped rather than blocking when the queue is full.
+	exportQueueSize = 4096
+)
+
+type exporter struct {
+	url    string
+	queue  chan *Span
+	client *http.Client
+}
+
+var (
+	spanExporter *exporter
+	exporterOnce sync.Once
+)
+
+func export(span *Span) {
+	exporterOnce.Do(func() {
+		if otlpEndpoint != "" {
+			spanExporter = newExporter(otlpEndpoint)
+			logger.Info("Exporting traces to %s", spanExporter.url)
+		}
+	})
+	if spanExporter == nil {
+		return
+	}
+
+	select {
+	case spanExporter.queue <- span:
+	default:
+		logger.Error("Trace export queue is full, dropping span %s", span.name)
+	}
+}
+
+func newExporter(endpoint string) *exporter {
+	e := &exporter{
+		url:   strings.TrimRight(endpoint, "/") + "/v1/traces",
+		queue: make(chan *Span, exportQueueSize),
+		// Not traced, or exporting would create more spans to export.
+		client: &http.Client{Timeout: time.Second * 10},
+	}
+	go e.loop()
+	return e
+}
+
+func (e *exporter) loop() {
+	ticker := time.NewTicker(exportInterval)
+	batch := make([]*Span, 0, exportBatchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) < exportBatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		err := e.send(batch)
+		if err != nil {
+			logger.Error("Error exporting %d spans: %v", len(batch), err)
+		}
+		batch = make([]*Span, 0, exportBatchSize)
+	}
+}
+
+func (e *exporter) send(spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Collector responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP/HTTP JSON encoding. IDs are hex and timestamps are strings of nanoseconds.
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+func newAttribute(key, value string) otlpAttribute {
+	attribute := otlpAttribute{Key: key}
+	attribute.Value.StringValue = value
+	return attribute
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	span := otlpSpan{
+		TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+		SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID != [8]byte{} {
+		span.ParentSpanID = hex.EncodeToString(s.parentID[:])
+	}
+
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		span.Attributes = append(span.Attributes, newAttribute(key, s.attributes[key]))
+	}
+
+	if s.errMessage != "" {
+		span.Status = &otlpStatus{Code: 2, Message: s.errMessage}
+	}
+	return span
+}
+
+func otlpRequest(spans []*Span) otlpTraces {
+	scopeSpans := otlpScopeSpans{Spans: make([]otlpSpan, len(spans))}
+	scopeSpans.Scope.Name = "github.com/Nextdoor/conductor/shared/tracing"
+	for i, span := range spans {
+		scopeSpans.Spans[i] = span.otlp()
+	}
+
+	resourceSpans := otlpResourceSpans{ScopeSpans: []otlpScopeSpans{scopeSpans}}
+	resourceSpans.Resource.Attributes = []otlpAttribute{newAttribute("service.name", serviceName)}
+	return otlpTraces{ResourceSpans: []otlpResourceSpans{resourceSpans}}
+}
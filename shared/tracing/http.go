@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+type transport struct {
+	base http.RoundTripper
+	// Trace to continue for requests that don't carry one.
+	ctx context.Context
+}
+
+// Wraps base (or http.DefaultTransport if nil) to record a client span for each request
+// and send the traceparent header. Requests without a span in their context start a new trace.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+// Like Transport, for clients whose calls don't take a context, like the JIRA and GitHub clients:
+// requests continue the trace in ctx rather than starting a new one.
+func ContextTransport(ctx context.Context, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, ctx: ctx}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	parent := FromContext(req.Context())
+	if parent == nil && t.ctx != nil {
+		parent = FromContext(t.ctx)
+	}
+	ctx, span := startSpan(req.Context(), fmt.Sprintf("%s %s", req.Method, req.URL.Host),
+		Client, parent.Context())
+	defer span.End()
+	span.SetAttribute("http.method", req.Method)
+	// Leave out credentials and query params.
+	span.SetAttribute("http.url", fmt.Sprintf("%s://%s%s", req.URL.Scheme, req.URL.Host, req.URL.Path))
+
+	// Round trippers must not modify the request they are given.
+	req = req.WithContext(ctx)
+	req.Header = cloneHeader(req.Header)
+	req.Header.Set(TraceParentHeader, span.Context().TraceParent())
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(resp.StatusCode))
+	if resp.StatusCode >= 500 {
+		span.SetError(fmt.Errorf("%s", resp.Status))
+	}
+	return resp, nil
+}
+
+func cloneHeader(header http.Header) http.Header {
+	clone := make(http.Header, len(header))
+	for key, values := range header {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
+}
@@ -0,0 +1,201 @@
+// Package tracing records spans and propagates W3C trace context.
+// Spans are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT is set.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Header used to propagate trace context over HTTP.
+const TraceParentHeader = "traceparent"
+
+type SpanKind int
+
+// Values match the OTLP span kinds.
+const (
+	Internal SpanKind = 1
+	Server   SpanKind = 2
+	Client   SpanKind = 3
+)
+
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Formats the context as a W3C traceparent header value.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s",
+		hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+// Parses a W3C traceparent header value.
+func ParseTraceParent(value string) (SpanContext, error) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("Malformed traceparent: %s", value)
+	}
+	if parts[0] == "ff" {
+		return sc, fmt.Errorf("Invalid traceparent version: %s", parts[0])
+	}
+
+	_, err := hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	if err != nil {
+		return sc, fmt.Errorf("Malformed trace ID: %s", parts[1])
+	}
+	_, err = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	if err != nil {
+		return sc, fmt.Errorf("Malformed span ID: %s", parts[2])
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, fmt.Errorf("Malformed trace flags: %s", parts[3])
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("Invalid traceparent: %s", value)
+	}
+	return sc, nil
+}
+
+// A timed operation within a trace.
+// All methods are safe to call on a nil span.
+type Span struct {
+	mutex sync.Mutex
+
+	context    SpanContext
+	parentID   [8]byte
+	name       string
+	kind       SpanKind
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	errMessage string
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+// Marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.errMessage = err.Error()
+}
+
+// Ends the span and queues it for export. Only the first call has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if !s.end.IsZero() {
+		s.mutex.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.mutex.Unlock()
+
+	if s.context.Sampled {
+		export(s)
+	}
+}
+
+type contextKey struct{}
+
+// Returns the current span, or nil if there is none.
+func FromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(contextKey{}).(*Span)
+	return span
+}
+
+// Returns the traceparent for the current span, or "" if there is none.
+func TraceParent(ctx context.Context) string {
+	span := FromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return span.context.TraceParent()
+}
+
+// Starts a span as a child of the current span, or as a new trace if there is none.
+func Start(ctx context.Context, name string) (context.Context, *Span) {
+	return startSpan(ctx, name, Internal, FromContext(ctx).Context())
+}
+
+// Starts a server span continuing the trace from a traceparent value.
+// Starts a new trace if traceparent is empty or invalid.
+func StartRemote(ctx context.Context, name string, traceparent string) (context.Context, *Span) {
+	parent, _ := ParseTraceParent(traceparent)
+	return startSpan(ctx, name, Server, parent)
+}
+
+func startSpan(ctx context.Context, name string, kind SpanKind, parent SpanContext) (context.Context, *Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]string),
+	}
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parentID = parent.SpanID
+	} else {
+		rand.Read(span.context.TraceID[:])
+		span.context.Sampled = true
+	}
+	rand.Read(span.context.SpanID[:])
+
+	return context.WithValue(ctx, contextKey{}, span), span
+}
+
+// Returns a context with only the current span of ctx.
+// Use it for work that should join the trace but outlive ctx, like goroutines started by a request.
+func Detach(ctx context.Context) context.Context {
+	span := FromContext(ctx)
+	if span == nil {
+		return context.Background()
+	}
+	return context.WithValue(context.Background(), contextKey{}, span)
+}
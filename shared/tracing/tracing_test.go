@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceParent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent(testTraceParent)
+	assert.NoError(t, err)
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, testTraceParent, sc.TraceParent())
+
+	sc, err = ParseTraceParent("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-00")
+	assert.NoError(t, err)
+	assert.False(t, sc.Sampled)
+
+	for _, bad := range []string{
+		"",
+		"garbage",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+		"00-0af7651916cd43dd8448eb211c80319z-b7ad6b7169203331-01",
+	} {
+		_, err = ParseTraceParent(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, root := Start(context.Background(), "root")
+	assert.True(t, root.Context().IsValid())
+	assert.Equal(t, root, FromContext(ctx))
+	assert.Equal(t, root.Context().TraceParent(), TraceParent(ctx))
+
+	_, child := Start(ctx, "child")
+	assert.Equal(t, root.Context().TraceID, child.Context().TraceID)
+	assert.Equal(t, root.Context().SpanID, child.parentID)
+	assert.NotEqual(t, root.Context().SpanID, child.Context().SpanID)
+
+	_, remote := StartRemote(context.Background(), "remote", testTraceParent)
+	parent, _ := ParseTraceParent(testTraceParent)
+	assert.Equal(t, parent.TraceID, remote.Context().TraceID)
+	assert.Equal(t, parent.SpanID, remote.parentID)
+
+	_, fresh := StartRemote(context.Background(), "fresh", "invalid")
+	assert.NotEqual(t, parent.TraceID, fresh.Context().TraceID)
+	assert.Equal(t, [8]byte{}, fresh.parentID)
+}
+
+func TestNilSpan(t *testing.T) {
+	var span *Span
+	span.SetAttribute("key", "value")
+	span.End()
+	assert.False(t, span.Context().IsValid())
+	assert.Equal(t, "", TraceParent(context.Background()))
+}
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx, span := Start(ctx, "request")
+	cancel()
+
+	detached := Detach(ctx)
+	assert.NoError(t, detached.Err())
+	assert.Equal(t, span, FromContext(detached))
+}
+
+func TestTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceParentHeader)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	req, err := http.NewRequest("GET", server.URL, nil)
+	assert.NoError(t, err)
+	client := &http.Client{Transport: Transport(nil)}
+	_, err = client.Do(req.WithContext(ctx))
+	assert.NoError(t, err)
+
+	sc, err := ParseTraceParent(received)
+	assert.NoError(t, err)
+	assert.Equal(t, parent.Context().TraceID, sc.TraceID)
+	assert.NotEqual(t, parent.Context().SpanID, sc.SpanID)
+	// The original request is not modified.
+	assert.Equal(t, "", req.Header.Get(TraceParentHeader))
+}
+
+func TestContextTransport(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceParentHeader)
+	}))
+	defer server.Close()
+
+	ctx, parent := Start(context.Background(), "parent")
+	client := &http.Client{Transport: ContextTransport(ctx, nil)}
+	_, err := client.Get(server.URL)
+	assert.NoError(t, err)
+
+	sc, err := ParseTraceParent(received)
+	assert.NoError(t, err)
+	assert.Equal(t, parent.Context().TraceID, sc.TraceID)
+
+	// A request's own trace wins.
+	requestCtx, requestParent := Start(context.Background(), "request")
+	req, err := http.NewRequest("GET", server.URL, nil)
+	assert.NoError(t, err)
+	_, err = client.Do(req.WithContext(requestCtx))
+	assert.NoError(t, err)
+
+	sc, err = ParseTraceParent(received)
+	assert.NoError(t, err)
+	assert.Equal(t, requestParent.Context().TraceID, sc.TraceID)
+}
+
+func TestOTLPRequest(t *testing.T) {
+	_, parent := StartRemote(context.Background(), "parent", testTraceParent)
+	parent.SetAttribute("train_id", "5")
+	parent.SetError(assert.AnError)
+	parent.End()
+
+	body, err := json.Marshal(otlpRequest([]*Span{parent}))
+	assert.NoError(t, err)
+
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &decoded))
+	resourceSpans := decoded["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	span := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})[0].(map[string]interface{})
+
+	assert.Equal(t, "0af7651916cd43dd8448eb211c80319c", span["traceId"])
+	assert.Equal(t, "b7ad6b7169203331", span["parentSpanId"])
+	assert.Equal(t, "parent", span["name"])
+	assert.Equal(t, float64(Server), span["kind"])
+	assert.Equal(t, float64(2), span["status"].(map[string]interface{})["code"])
+	assert.Equal(t, "train_id", span["attributes"].([]interface{})[0].(map[string]interface{})["key"])
+}
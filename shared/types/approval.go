@@ -0,0 +1,137 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Sign-off that trains need before they deploy, like two release managers for the payments code.
+type ApprovalRule struct {
+	Name string `json:"name"`
+	// How many different people have to approve.
+	Required int `json:"required"`
+	// Who can approve: users with at least Role, the users in Users, by email,
+	// and members of Groups, as reported by the auth provider.
+	Role   string   `json:"role,omitempty"`
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+	// Only trains that change files under one of these paths need the approvals, e.g. "payments/".
+	// Every train needs them when unset.
+	Paths []string `json:"paths,omitempty"`
+}
+
+type ApprovalRules []ApprovalRule
+
+func (rule ApprovalRule) Validate() error {
+	if rule.Role == "" && len(rule.Users) == 0 && len(rule.Groups) == 0 {
+		return fmt.Errorf("Approval rule %s needs a role, users or groups", rule.Name)
+	}
+	if rule.Role != "" {
+		_, err := RoleFromString(rule.Role)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rules ApprovalRules) Validate() error {
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if seen[rule.Name] {
+			return fmt.Errorf("Approval rule %s is in the options twice", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		err := rule.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the rule with the name, or nil.
+func (rules ApprovalRules) Rule(name string) *ApprovalRule {
+	for i := range rules {
+		if rules[i].Name == name {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// Whether the user can approve for the rule on a train with the commits.
+// Authors of the commits can't approve their own changes. The user's role must be loaded.
+func (rule ApprovalRule) CanApprove(user *User, commits []*Commit) bool {
+	for _, commit := range commits {
+		if strings.EqualFold(commit.AuthorEmail, user.Email) {
+			return false
+		}
+	}
+	if rule.Role != "" {
+		role, err := RoleFromString(rule.Role)
+		if err == nil && user.Role.AtLeast(role) {
+			return true
+		}
+	}
+	for _, email := range rule.Users {
+		if strings.EqualFold(email, user.Email) {
+			return true
+		}
+	}
+	for _, group := range rule.Groups {
+		for _, userGroup := range user.Groups {
+			if group == userGroup {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Whether a train that changed the files needs the rule's approvals.
+// Pass nil files when they aren't known, so that every rule applies.
+func (rule ApprovalRule) AppliesTo(files []string) bool {
+	if len(rule.Paths) == 0 || files == nil {
+		return true
+	}
+	for _, file := range files {
+		for _, path := range rule.Paths {
+			if strings.HasPrefix(file, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The approvals a train has for one of the rules it needs.
+type ApprovalStatus struct {
+	Rule      string      `json:"rule"`
+	Required  int         `json:"required"`
+	Approvals []*Approval `json:"approvals"`
+}
+
+// Implements phase.Completeable, so approvals gate verification like tickets do.
+func (s *ApprovalStatus) IsComplete() bool {
+	return len(s.Approvals) >= s.Required
+}
+
+// Names of the approval rules the train needs.
+func (train *Train) ApprovalRuleNames() []string {
+	if train.ApprovalRules == "" {
+		return nil
+	}
+	return strings.Split(train.ApprovalRules, ",")
+}
+
+// Returns the status of the first rule that still needs approvals, or nil.
+func (train *Train) PendingApproval() *ApprovalStatus {
+	for _, status := range train.Approvals {
+		if !status.IsComplete() {
+			return status
+		}
+	}
+	return nil
+}
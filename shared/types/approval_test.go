@@ -0,0 +1,77 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalRule(t *testing.T) {
+	rule := ApprovalRule{
+		Name:     "payments",
+		Required: 2,
+		Role:     "release-manager",
+		Users:    []string{"owner@example.com"},
+		Groups:   []string{"payments-team"},
+		Paths:    []string{"payments/"},
+	}
+	assert.NoError(t, rule.Validate())
+
+	assert.True(t, rule.CanApprove(&User{Email: "someone@example.com", Role: ReleaseManager}, nil))
+	assert.True(t, rule.CanApprove(&User{Email: "someone@example.com", Role: Admin}, nil))
+	assert.True(t, rule.CanApprove(&User{Email: "Owner@example.com", Role: Viewer}, nil))
+	assert.True(t, rule.CanApprove(&User{Email: "someone@example.com", Groups: []string{"payments-team"}}, nil))
+	assert.False(t, rule.CanApprove(&User{Email: "someone@example.com", Role: Engineer, Groups: []string{"web"}}, nil))
+
+	commits := []*Commit{{SHA: "c0mm1t", AuthorEmail: "owner@example.com"}}
+	assert.False(t, rule.CanApprove(&User{Email: "Owner@example.com", Role: Viewer}, commits),
+		"Authors can't approve their own commits")
+	assert.False(t, rule.CanApprove(&User{Email: "owner@example.com", Role: Admin}, commits))
+	assert.True(t, rule.CanApprove(&User{Email: "someone@example.com", Role: ReleaseManager}, commits))
+
+	assert.True(t, rule.AppliesTo([]string{"README.md", "payments/charge.go"}))
+	assert.False(t, rule.AppliesTo([]string{"README.md", "web/payments.js"}))
+	assert.False(t, rule.AppliesTo([]string{}))
+	assert.True(t, rule.AppliesTo(nil), "Unknown files need every approval")
+	assert.True(t, ApprovalRule{Name: "all", Required: 1, Role: "admin"}.AppliesTo([]string{}))
+
+	assert.Error(t, ApprovalRule{Name: "nobody", Required: 1}.Validate())
+	assert.Error(t, ApprovalRule{Name: "typo", Required: 1, Role: "release-managers"}.Validate())
+	assert.Error(t, ApprovalRules{rule, rule}.Validate())
+}
+
+func TestApprovalOptions(t *testing.T) {
+	options := &Options{}
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"approvals": [{"name": "payments", "required": 2, "role": "release-manager", "paths": ["payments/"]}]
+	}`)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, options.Approvals.Rule("payments").Required)
+	assert.Nil(t, options.Approvals.Rule("mobile"))
+
+	invalid := []string{
+		`"approvals": [{"name": "payments", "required": 0, "role": "admin"}]`,
+		`"approvals": [{"name": "payments", "required": 1}]`,
+		`"approvals": [{"name": "payments", "required": 1, "role": "boss"}]`,
+	}
+	for _, option := range invalid {
+		options := &Options{}
+		err := options.FromString(`{
+			"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+			` + option + `
+		}`)
+		assert.Error(t, err, option)
+	}
+}
+
+func TestPendingApproval(t *testing.T) {
+	approved := &ApprovalStatus{Rule: "mobile", Required: 1, Approvals: []*Approval{{Rule: "mobile"}}}
+	pending := &ApprovalStatus{Rule: "payments", Required: 2, Approvals: []*Approval{{Rule: "payments"}}}
+
+	train := &Train{Approvals: []*ApprovalStatus{approved}}
+	assert.Nil(t, train.PendingApproval())
+
+	train.Approvals = append(train.Approvals, pending)
+	assert.Equal(t, pending, train.PendingApproval())
+}
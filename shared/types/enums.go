@@ -47,6 +47,8 @@ const (
 	Delivery PhaseType = iota
 	Verification
 	Deploy
+	// Tracks a rollback's job. Not part of any train's pipeline.
+	RollbackPhase
 )
 
 func (e PhaseType) String() string {
@@ -57,6 +59,8 @@ func (e PhaseType) String() string {
 		return "verification"
 	case Deploy:
 		return "deploy"
+	case RollbackPhase:
+		return "rollback"
 	default:
 		panic(fmt.Errorf("Unknown mode: %d", e))
 	}
@@ -70,6 +74,8 @@ func PhaseTypeFromString(phaseType string) (PhaseType, error) {
 		return Verification, nil
 	case "deploy":
 		return Deploy, nil
+	case "rollback":
+		return RollbackPhase, nil
 	default:
 		return -1, fmt.Errorf("Unknown phase type: %s", phaseType)
 	}
@@ -96,3 +102,301 @@ func (j JobResult) String() string {
 func (j JobResult) IsValid() bool {
 	return j >= Ok && j <= Error
 }
+
+// How far a rollback's job got.
+type RollbackStatus int
+
+const (
+	RollbackStarted RollbackStatus = iota
+	RollbackSucceeded
+	RollbackFailed
+)
+
+var RollbackStatuses = []RollbackStatus{RollbackStarted, RollbackSucceeded, RollbackFailed}
+
+func (s RollbackStatus) String() string {
+	switch s {
+	case RollbackStarted:
+		return "started"
+	case RollbackSucceeded:
+		return "succeeded"
+	case RollbackFailed:
+		return "failed"
+	default:
+		panic(fmt.Errorf("Unknown rollback status: %d", s))
+	}
+}
+
+func (s RollbackStatus) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, s.String())), nil
+}
+
+func RollbackStatusFromString(status string) (RollbackStatus, error) {
+	for _, s := range RollbackStatuses {
+		if s.String() == status {
+			return s, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown rollback status: %s", status)
+}
+
+type TrainState int
+
+const (
+	Open TrainState = iota
+	Closed
+	Blocked
+	Deploying
+	Deployed
+	Cancelled
+)
+
+func (s TrainState) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case Closed:
+		return "closed"
+	case Blocked:
+		return "blocked"
+	case Deploying:
+		return "deploying"
+	case Deployed:
+		return "deployed"
+	case Cancelled:
+		return "cancelled"
+	default:
+		panic(fmt.Errorf("Unknown train state: %d", s))
+	}
+}
+
+func (s TrainState) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, s.String())), nil
+}
+
+func TrainStateFromString(state string) (TrainState, error) {
+	switch state {
+	case "open":
+		return Open, nil
+	case "closed":
+		return Closed, nil
+	case "blocked":
+		return Blocked, nil
+	case "deploying":
+		return Deploying, nil
+	case "deployed":
+		return Deployed, nil
+	case "cancelled":
+		return Cancelled, nil
+	default:
+		return -1, fmt.Errorf("Unknown train state: %s", state)
+	}
+}
+
+// What an API token can access.
+type Scope int
+
+const (
+	TrainRead Scope = iota
+	TrainWrite
+	TrainAdmin
+	JobsWrite
+)
+
+var Scopes = []Scope{TrainRead, TrainWrite, TrainAdmin, JobsWrite}
+
+func (s Scope) String() string {
+	switch s {
+	case TrainRead:
+		return "train:read"
+	case TrainWrite:
+		return "train:write"
+	case TrainAdmin:
+		return "train:admin"
+	case JobsWrite:
+		return "jobs:write"
+	default:
+		panic(fmt.Errorf("Unknown scope: %d", s))
+	}
+}
+
+// Train scopes build on each other: train:admin includes train:write, which includes train:read.
+func (s Scope) Includes(scope Scope) bool {
+	switch s {
+	case TrainAdmin:
+		return scope == TrainAdmin || scope == TrainWrite || scope == TrainRead
+	case TrainWrite:
+		return scope == TrainWrite || scope == TrainRead
+	default:
+		return s == scope
+	}
+}
+
+func ScopeFromString(scope string) (Scope, error) {
+	for _, s := range Scopes {
+		if s.String() == scope {
+			return s, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown scope: %s", scope)
+}
+
+// What a user can do. Each role can do everything the roles before it can.
+type Role int
+
+const (
+	Viewer Role = iota
+	Committer
+	Engineer
+	ReleaseManager
+	Admin
+)
+
+var Roles = []Role{Viewer, Committer, Engineer, ReleaseManager, Admin}
+
+func (r Role) String() string {
+	switch r {
+	case Viewer:
+		return "viewer"
+	case Committer:
+		return "committer"
+	case Engineer:
+		return "engineer"
+	case ReleaseManager:
+		return "release-manager"
+	case Admin:
+		return "admin"
+	default:
+		panic(fmt.Errorf("Unknown role: %d", r))
+	}
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, r.String())), nil
+}
+
+func (r Role) AtLeast(role Role) bool {
+	return r >= role
+}
+
+func RoleFromString(role string) (Role, error) {
+	for _, r := range Roles {
+		if r.String() == role {
+			return r, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown role: %s", role)
+}
+
+// A change recorded in the audit log.
+type AuditAction int
+
+const (
+	TrainCreateAction AuditAction = iota
+	TrainCloseAction
+	TrainOpenAction
+	TrainExtendAction
+	TrainBlockAction
+	TrainUnblockAction
+	TrainCancelAction
+	TrainDeployAction
+	TrainRollbackAction
+	TrainEngineerChangeAction
+	PhaseRestartAction
+	ModeSetAction
+	OptionsSetAction
+	MetadataSetAction
+	MetadataDeleteAction
+	APITokenCreateAction
+	APITokenRevokeAction
+	RoleAssignAction
+	RoleRemoveAction
+	SessionRevokeAction
+	TrainFreezeOverrideAction
+	TrainExpediteAction
+	TrainApproveAction
+	TrainExcludeCommitAction
+	TrainSplitAction
+	TrainReleaseCommitAction
+)
+
+var AuditActions = []AuditAction{
+	TrainCreateAction, TrainCloseAction, TrainOpenAction, TrainExtendAction, TrainBlockAction,
+	TrainUnblockAction, TrainCancelAction, TrainDeployAction, TrainRollbackAction, TrainEngineerChangeAction,
+	PhaseRestartAction, ModeSetAction, OptionsSetAction, MetadataSetAction, MetadataDeleteAction,
+	APITokenCreateAction, APITokenRevokeAction, RoleAssignAction, RoleRemoveAction, SessionRevokeAction,
+	TrainFreezeOverrideAction, TrainExpediteAction, TrainApproveAction, TrainExcludeCommitAction,
+	TrainSplitAction, TrainReleaseCommitAction,
+}
+
+func (a AuditAction) String() string {
+	switch a {
+	case TrainCreateAction:
+		return "train.create"
+	case TrainCloseAction:
+		return "train.close"
+	case TrainOpenAction:
+		return "train.open"
+	case TrainExtendAction:
+		return "train.extend"
+	case TrainBlockAction:
+		return "train.block"
+	case TrainUnblockAction:
+		return "train.unblock"
+	case TrainCancelAction:
+		return "train.cancel"
+	case TrainDeployAction:
+		return "train.deploy"
+	case TrainRollbackAction:
+		return "train.rollback"
+	case TrainEngineerChangeAction:
+		return "train.engineer_change"
+	case PhaseRestartAction:
+		return "phase.restart"
+	case ModeSetAction:
+		return "mode.set"
+	case OptionsSetAction:
+		return "options.set"
+	case MetadataSetAction:
+		return "metadata.set"
+	case MetadataDeleteAction:
+		return "metadata.delete"
+	case APITokenCreateAction:
+		return "api_token.create"
+	case APITokenRevokeAction:
+		return "api_token.revoke"
+	case RoleAssignAction:
+		return "role.assign"
+	case RoleRemoveAction:
+		return "role.remove"
+	case SessionRevokeAction:
+		return "session.revoke"
+	case TrainFreezeOverrideAction:
+		return "train.freeze_override"
+	case TrainExpediteAction:
+		return "train.expedite"
+	case TrainApproveAction:
+		return "train.approve"
+	case TrainExcludeCommitAction:
+		return "train.exclude_commit"
+	case TrainSplitAction:
+		return "train.split"
+	case TrainReleaseCommitAction:
+		return "train.release_commit"
+	default:
+		panic(fmt.Errorf("Unknown audit action: %d", a))
+	}
+}
+
+func (a AuditAction) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%s"`, a.String())), nil
+}
+
+func AuditActionFromString(action string) (AuditAction, error) {
+	for _, a := range AuditActions {
+		if a.String() == action {
+			return a, nil
+		}
+	}
+	return -1, fmt.Errorf("Unknown audit action: %s", action)
+}
@@ -0,0 +1,108 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// A time when deploys can't start, like a holiday or the end of a quarter.
+// Either Start and End are set for a one-off freeze,
+// or Months, FirstDay and LastDay are set for a freeze that recurs every year.
+type FreezeWindow struct {
+	Reason string `json:"reason"`
+
+	// One-off freezes last from Start until End.
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+
+	// Recurring freezes last from FirstDay through LastDay of each of Months, in Timezone.
+	// Negative days count back from the end of the month, e.g. -1 is the last day.
+	Months   []time.Month `json:"months,omitempty"`
+	FirstDay int          `json:"first_day,omitempty"`
+	LastDay  int          `json:"last_day,omitempty"`
+	// IANA timezone of recurring freezes' days. Defaults to the Conductor timezone.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type FreezeWindows []FreezeWindow
+
+func (freeze FreezeWindow) IsRecurring() bool {
+	return len(freeze.Months) > 0
+}
+
+func (freeze FreezeWindow) Validate() error {
+	if freeze.Reason == "" {
+		return fmt.Errorf("Freezes need a reason")
+	}
+	if freeze.IsRecurring() {
+		if freeze.Start != nil || freeze.End != nil {
+			return fmt.Errorf("Freeze %q has both months and start/end times", freeze.Reason)
+		}
+		for _, month := range freeze.Months {
+			if month < time.January || month > time.December {
+				return fmt.Errorf("Freeze %q has a bad month: %d", freeze.Reason, month)
+			}
+		}
+		for _, day := range []int{freeze.FirstDay, freeze.LastDay} {
+			if day == 0 || day < -31 || day > 31 {
+				return fmt.Errorf("Freeze %q has a bad day: %d. Must be 1 to 31, or -1 to -31.", freeze.Reason, day)
+			}
+		}
+		if freeze.Timezone != "" {
+			_, err := time.LoadLocation(freeze.Timezone)
+			if err != nil {
+				return fmt.Errorf("Freeze %q has a bad timezone %s: %v", freeze.Reason, freeze.Timezone, err)
+			}
+		}
+		return nil
+	}
+	if freeze.Timezone != "" {
+		return fmt.Errorf("Freeze %q has a timezone, which only recurring freezes use", freeze.Reason)
+	}
+	if freeze.Start == nil || freeze.End == nil {
+		return fmt.Errorf("Freeze %q needs either months or start and end times", freeze.Reason)
+	}
+	if !freeze.End.After(*freeze.Start) {
+		return fmt.Errorf("Freeze %q ends before it starts", freeze.Reason)
+	}
+	return nil
+}
+
+// Includes checks if deploys are frozen at the given time.
+func (freeze FreezeWindow) Includes(t time.Time) bool {
+	if !freeze.IsRecurring() {
+		return freeze.Start != nil && freeze.End != nil &&
+			!t.Before(*freeze.Start) && t.Before(*freeze.End)
+	}
+
+	location := location(freeze.Timezone)
+	t = t.In(location)
+	year, month, day := t.Date()
+	for _, freezeMonth := range freeze.Months {
+		if freezeMonth != month {
+			continue
+		}
+		// The day after the end of the month is day 1 of the next one.
+		daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, location).Day()
+		return day >= dayOfMonth(freeze.FirstDay, daysInMonth) &&
+			day <= dayOfMonth(freeze.LastDay, daysInMonth)
+	}
+	return false
+}
+
+func dayOfMonth(day, daysInMonth int) int {
+	if day < 0 {
+		return daysInMonth + day + 1
+	}
+	return day
+}
+
+// Returns the first freeze including the given time, or nil if deploys aren't frozen.
+func (freezes FreezeWindows) Active(t time.Time) *FreezeWindow {
+	for i := range freezes {
+		if freezes[i].Includes(t) {
+			return &freezes[i]
+		}
+	}
+	return nil
+}
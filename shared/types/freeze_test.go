@@ -0,0 +1,89 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFreezeIncludesOneOff(t *testing.T) {
+	start := time.Date(2020, time.December, 24, 0, 0, 0, 0, time.Local)
+	end := time.Date(2021, time.January, 2, 0, 0, 0, 0, time.Local)
+	freeze := FreezeWindow{Reason: "the holidays", Start: &start, End: &end}
+
+	assert.False(t, freeze.Includes(start.Add(-time.Minute)))
+	assert.True(t, freeze.Includes(start))
+	assert.True(t, freeze.Includes(time.Date(2020, time.December, 31, 23, 0, 0, 0, time.Local)))
+	assert.False(t, freeze.Includes(end))
+}
+
+func TestFreezeIncludesRecurring(t *testing.T) {
+	// The last week of each quarter.
+	freeze := FreezeWindow{
+		Reason:   "end of quarter",
+		Months:   []time.Month{time.March, time.June, time.September, time.December},
+		FirstDay: -7,
+		LastDay:  -1,
+	}
+
+	assert.False(t, freeze.Includes(time.Date(2021, time.June, 23, 23, 59, 0, 0, time.Local)))
+	assert.True(t, freeze.Includes(time.Date(2021, time.June, 24, 0, 0, 0, 0, time.Local)))
+	assert.True(t, freeze.Includes(time.Date(2021, time.June, 30, 23, 59, 0, 0, time.Local)))
+	assert.False(t, freeze.Includes(time.Date(2021, time.July, 1, 0, 0, 0, 0, time.Local)))
+	assert.True(t, freeze.Includes(time.Date(2021, time.March, 31, 12, 0, 0, 0, time.Local)))
+	assert.False(t, freeze.Includes(time.Date(2021, time.April, 30, 12, 0, 0, 0, time.Local)))
+
+	freezes := FreezeWindows{
+		{Reason: "new year", Months: []time.Month{time.January}, FirstDay: 1, LastDay: 2},
+		freeze,
+	}
+	assert.Equal(t, "new year", freezes.Active(time.Date(2021, time.January, 2, 12, 0, 0, 0, time.Local)).Reason)
+	assert.Equal(t, "end of quarter", freezes.Active(time.Date(2021, time.December, 30, 12, 0, 0, 0, time.Local)).Reason)
+	assert.Nil(t, freezes.Active(time.Date(2021, time.January, 3, 12, 0, 0, 0, time.Local)))
+}
+
+func TestFreezeTimezone(t *testing.T) {
+	freeze := FreezeWindow{
+		Reason:   "new year",
+		Months:   []time.Month{time.January},
+		FirstDay: 1,
+		LastDay:  1,
+		Timezone: "Asia/Kolkata",
+	}
+	// Midnight in India is 18:30 UTC the day before.
+	assert.True(t, freeze.Includes(time.Date(2020, time.December, 31, 18, 30, 0, 0, time.UTC)))
+	assert.False(t, freeze.Includes(time.Date(2020, time.December, 31, 18, 29, 0, 0, time.UTC)))
+	assert.False(t, freeze.Includes(time.Date(2021, time.January, 1, 18, 30, 0, 0, time.UTC)))
+}
+
+func TestOptionsFreezes(t *testing.T) {
+	options := &Options{}
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"freezes": [
+			{"reason": "the holidays", "start": "2020-12-24T00:00:00Z", "end": "2021-01-02T00:00:00Z"},
+			{"reason": "end of quarter", "months": [3, 6, 9, 12], "first_day": -7, "last_day": -1}
+		]
+	}`)
+	assert.NoError(t, err)
+	assert.Len(t, options.Freezes, 2)
+	assert.True(t, options.Freezes[1].IsRecurring())
+
+	invalid := []string{
+		`{"reason": ""}`,
+		`{"reason": "no end", "start": "2020-12-24T00:00:00Z"}`,
+		`{"reason": "backwards", "start": "2021-01-02T00:00:00Z", "end": "2020-12-24T00:00:00Z"}`,
+		`{"reason": "bad month", "months": [13], "first_day": 1, "last_day": 2}`,
+		`{"reason": "bad day", "months": [1], "first_day": 0, "last_day": 2}`,
+		`{"reason": "bad timezone", "months": [1], "first_day": 1, "last_day": 2, "timezone": "Mars/Olympus"}`,
+	}
+	for _, freeze := range invalid {
+		options = &Options{}
+		err = options.FromString(`{
+			"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+			"freezes": [` + freeze + `]
+		}`)
+		assert.Error(t, err, freeze)
+	}
+}
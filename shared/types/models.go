@@ -1,10 +1,14 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"regexp"
 	"strings"
+	"time"
 
-	"github.com/Nextdoor/conductor/shared/datadog"
+	"github.com/Nextdoor/conductor/shared/metrics"
 	"github.com/Nextdoor/conductor/shared/settings"
 )
 
@@ -39,34 +43,86 @@ type Train struct {
 	Tickets          []*Ticket     `orm:"reverse(many)" json:"tickets"` // Who's got a ticket to ride?
 	ActivePhases     *PhaseGroup   `orm:"rel(fk)" json:"active_phases"`
 	AllPhaseGroups   []*PhaseGroup `orm:"reverse(many)" json:"all_phase_groups"`
+	// Set when an admin lets the train deploy during a freeze.
+	FreezeOverrideReason *string `orm:"null" json:"freeze_override_reason"`
+	FreezeOverrideBy     *User   `orm:"rel(fk);null" json:"freeze_override_by"`
+	// Set on hotfix trains that skip the queue.
+	ExpediteReason *string `orm:"null" json:"expedite_reason"`
+	// The train an expedited train blocked, which goes back in line once the expedited train is done.
+	PreemptedID *uint64 `orm:"column(preempted_id);null" json:"preempted_id,string"`
+	// The train a split train was split off, which deploys before it.
+	SplitFromID *uint64 `orm:"column(split_from_id);null" json:"split_from_id,string"`
+	// Comma separated names of the approval rules the train needs, set when it's delivered.
+	ApprovalRules string `json:"-"`
+	// Comma separated SHAs of commits taken off the train.
+	ExcludedSHAs string `orm:"column(excluded_shas)" json:"-"`
+	// Where the train is delivered from when it's redelivered without its excluded commits.
+	GeneratedBranch *string `orm:"null" json:"generated_branch"`
+	// Comma separated SHAs of held commits that were released.
+	ReleasedSHAs string `orm:"column(released_shas)" json:"-"`
 
 	// Computed fields
-	ActivePhase         PhaseType `orm:"-" json:"active_phase"`
-	LastDeliveredSHA    *string   `orm:"-" json:"last_delivered_sha"` // SHA for last successful delivery.
-	PreviousID          *uint64   `orm:"-" json:"previous_id,string"`
-	NextID              *uint64   `orm:"-" json:"next_id,string"`
-	NotDeployableReason *string   `orm:"-" json:"not_deployable_reason"`
-	Done                bool      `orm:"-" json:"done"`
-	PreviousTrainDone   bool      `orm:"-" json:"previous_train_done"`
-	CanRollback         bool      `orm:"-" json:"can_rollback"`
+	ActivePhase         PhaseType         `orm:"-" json:"active_phase"`
+	ActivePhaseName     string            `orm:"-" json:"active_phase_name"`
+	LastDeliveredSHA    *string           `orm:"-" json:"last_delivered_sha"` // SHA for last successful delivery.
+	PreviousID          *uint64           `orm:"-" json:"previous_id,string"`
+	NextID              *uint64           `orm:"-" json:"next_id,string"`
+	NextIsSplitOff      bool              `orm:"-" json:"-"` // Whether the next train was split off this one.
+	NotDeployableReason *string           `orm:"-" json:"not_deployable_reason"`
+	Done                bool              `orm:"-" json:"done"`
+	PreviousTrainDone   bool              `orm:"-" json:"previous_train_done"`
+	CanRollback         bool              `orm:"-" json:"can_rollback"`
+	Rollback            *Rollback         `orm:"-" json:"rollback"`         // The latest rollback to the train, if any.
+	Freeze              *FreezeWindow     `orm:"-" json:"freeze"`           // The freeze deploys are in, if any.
+	NextDeploySlot      *time.Time        `orm:"-" json:"next_deploy_slot"` // Set when deploys wait for a slot.
+	Approvals           []*ApprovalStatus `orm:"-" json:"approvals"`
+	ExcludedCommits     []*Commit         `orm:"-" json:"excluded_commits"`
+	Holds               []*CommitHold     `orm:"-" json:"holds"` // Commits keeping the train from deploying.
+}
+
+// A commit marked [hold] or [deploy-after: time] that's keeping its train from deploying.
+type CommitHold struct {
+	Commit      *Commit    `json:"commit"`
+	Reason      string     `json:"reason"`
+	DeployAfter *time.Time `json:"deploy_after"`
+}
+
+func (hold *CommitHold) String() string {
+	sha := hold.Commit.ShortSHA()
+	_, held := hold.Commit.HoldReason()
+	switch {
+	case hold.Reason != "":
+		return fmt.Sprintf("Commit %s is held: %s.", sha, strings.TrimSuffix(hold.Reason, "."))
+	case !held && hold.DeployAfter != nil:
+		// In the timezone the commit gave it in, which its author will recognize.
+		return fmt.Sprintf("Commit %s is held until %s.", sha,
+			hold.DeployAfter.Format("Mon Jan 2 15:04 MST"))
+	default:
+		return fmt.Sprintf("Commit %s is held.", sha)
+	}
 }
 
 type Phase struct {
-	ID          uint64    `orm:"pk;auto;column(id)" json:"id,string"`
-	StartedAt   Time      `orm:"null" json:"started_at"`
-	CompletedAt Time      `orm:"null" json:"completed_at"`
-	Type        PhaseType `json:"type"` // delivery|verification|deploy
-	Error       string    `orm:"null" json:"error"`
-	Jobs        Jobs      `orm:"reverse(many)" json:"jobs"`
+	ID          uint64      `orm:"pk;auto;column(id)" json:"id,string"`
+	StartedAt   Time        `orm:"null" json:"started_at"`
+	CompletedAt Time        `orm:"null" json:"completed_at"`
+	Name        string      `json:"name"`     // From the pipeline, e.g. canary
+	Position    int         `json:"position"` // Order in the pipeline
+	Type        PhaseType   `json:"type"`     // delivery|verification|deploy
+	Error       string      `orm:"null" json:"error"`
+	Jobs        Jobs        `orm:"reverse(many)" json:"jobs"`
+	PhaseGroup  *PhaseGroup `orm:"rel(fk);null" json:"-"`
 
 	// Computed fields
-	PhaseGroup *PhaseGroup `orm:"-" json:"-"`
-	Train      *Train      `orm:"-" json:"-"`
+	Train *Train `orm:"-" json:"-"`
 }
 
 type PhaseGroup struct {
-	ID           uint64 `orm:"pk;auto;column(id)" json:"id,string"`
-	HeadSHA      string `orm:"column(head_sha)" json:"head_sha"`
+	ID       uint64   `orm:"pk;auto;column(id)" json:"id,string"`
+	HeadSHA  string   `orm:"column(head_sha)" json:"head_sha"`
+	Pipeline []*Phase `orm:"reverse(many)" json:"pipeline"` // Every phase, in order.
+	// The phases after which the train is delivered and verified,
+	// i.e. the last of their type, and the first deploy phase, which starts the deploy.
 	Delivery     *Phase `orm:"rel(fk)" json:"delivery"`
 	Verification *Phase `orm:"rel(fk)" json:"verification"`
 	Deploy       *Phase `orm:"rel(fk)" json:"deploy"`
@@ -85,6 +141,41 @@ type Job struct {
 	Phase       *Phase    `orm:"rel(fk)" json:"-"`
 }
 
+// A rollback of production to the HeadSHA of Train.
+type Rollback struct {
+	ID        uint64 `orm:"pk;auto;column(id)" json:"id,string"`
+	CreatedAt Time   `orm:"auto_now_add" json:"created_at"`
+	Train     *Train `orm:"rel(fk)" json:"train"`           // Train being rolled back to.
+	FromTrain *Train `orm:"rel(fk);null" json:"from_train"` // Train that was in production, if any.
+	User      *User  `orm:"rel(fk);null" json:"user"`       // Nil for automatic rollbacks.
+	// The rollback job reports to this phase through the job endpoints.
+	Phase *Phase `orm:"rel(fk);null" json:"phase"`
+	// Set when the rollback job completes, with the error if it failed.
+	Status      RollbackStatus `json:"status"`
+	CompletedAt Time           `orm:"null" json:"completed_at"`
+	Error       string         `orm:"null" json:"error"`
+}
+
+// A deployed train production can be rolled back to.
+type RollbackTarget struct {
+	TrainID    uint64 `json:"train_id,string"`
+	Branch     string `json:"branch"`
+	HeadSHA    string `json:"head_sha"`
+	DeployedAt Time   `json:"deployed_at"`
+	// Whether the train is what's in production now.
+	Production bool `json:"production"`
+}
+
+// A user's sign-off on the train at HeadSHA, for an approval rule.
+type Approval struct {
+	ID        uint64 `orm:"pk;auto;column(id)" json:"id,string"`
+	CreatedAt Time   `orm:"auto_now_add" json:"created_at"`
+	Train     *Train `orm:"rel(fk)" json:"-"`
+	User      *User  `orm:"rel(fk)" json:"user"`
+	Rule      string `json:"rule"`
+	HeadSHA   string `orm:"column(head_sha)" json:"head_sha"`
+}
+
 type Commit struct {
 	ID          uint64 `orm:"pk;auto;column(id)" json:"id,string"`
 	CreatedAt   Time   `orm:"auto_now_add;null" json:"created_at"`
@@ -118,18 +209,171 @@ type User struct {
 	AvatarURL string `orm:"column(avatar_url)" json:"avatar_url"`
 	Token     string `orm:"-" json:"-"`
 	IsAdmin   bool   `orm:"-" json:"is_admin"`
+	// Auth provider groups from the user's login, if the provider reports them.
+	Groups []string `orm:"-" json:"groups,omitempty"`
+	// Only set for the logged in user.
+	Role Role `orm:"-" json:"-"`
 }
 
+// A role assigned to a user. Users without one have the default role.
+type UserRole struct {
+	ID         uint64 `orm:"pk;auto;column(id)" json:"id,string"`
+	User       *User  `orm:"rel(one)" json:"user"`
+	Role       Role   `json:"role"`
+	AssignedBy *User  `orm:"rel(fk)" json:"assigned_by"`
+	AssignedAt Time   `orm:"auto_now" json:"assigned_at"`
+}
+
+// A login session, identified by the token in the auth cookie.
 type Auth struct {
-	Token     string `orm:"pk;size(36)" json:"token"` // Internal token token.
-	CreatedAt Time   `orm:"auto_now_add" json:"created_at"`
-	User      *User  `orm:"rel(fk)" json:"user"`
-	CodeToken string `orm:"null;size(40)" json:"code_token"` // API Token for Code Service. Can be null if auth doesn't support it.
+	Token      string `orm:"pk;size(36)" json:"-"` // Internal token token.
+	CreatedAt  Time   `orm:"auto_now_add" json:"created_at"`
+	LastUsedAt Time   `orm:"null" json:"last_used_at"`
+	User       *User  `orm:"rel(fk)" json:"user"`
+	Groups     string `orm:"null;type(text)" json:"groups"` // JSON list of auth provider groups at login.
+}
+
+// Identifies the session without revealing its token.
+func (a *Auth) SessionID() string {
+	sum := sha256.Sum256([]byte(a.Token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// When the session expires if it isn't used again:
+// idleTimeout after it was last used, but no later than maxLifetime after it was created.
+func (a *Auth) ExpiresAt(idleTimeout, maxLifetime time.Duration) time.Time {
+	lastUsed := a.CreatedAt.Value
+	if a.LastUsedAt.HasValue() {
+		lastUsed = a.LastUsedAt.Value
+	}
+	expiresAt := lastUsed.Add(idleTimeout)
+	if latest := a.CreatedAt.Value.Add(maxLifetime); latest.Before(expiresAt) {
+		expiresAt = latest
+	}
+	return expiresAt
+}
+
+// A token for machine clients, sent in an `Authorization: Bearer` header.
+// Only a hash of the token is stored; the token itself is shown once, on creation.
+type APIToken struct {
+	ID         uint64 `orm:"pk;auto;column(id)" json:"id,string"`
+	Name       string `orm:"unique" json:"name"`
+	TokenHash  string `orm:"unique;size(64)" json:"-"`
+	Scopes     string `json:"scopes"`             // Space-separated, e.g. "train:read jobs:write".
+	User       *User  `orm:"rel(fk)" json:"user"` // Who requests with the token act as.
+	CreatedBy  *User  `orm:"rel(fk)" json:"created_by"`
+	CreatedAt  Time   `orm:"auto_now_add" json:"created_at"`
+	ExpiresAt  Time   `orm:"null" json:"expires_at"`
+	LastUsedAt Time   `orm:"null" json:"last_used_at"`
+	RevokedAt  Time   `orm:"null" json:"revoked_at"`
+	RevokedBy  *User  `orm:"rel(fk);null" json:"revoked_by"`
+}
+
+// The job that reports a rollback's progress.
+const RollbackJobName = "rollback"
+
+// Who Conductor's own actions are attributed to, as opposed to a user's.
+const ConductorActor = "Conductor"
+
+// A change to a train or to Conductor's settings, and who made it.
+type AuditEvent struct {
+	ID         uint64      `orm:"pk;auto;column(id)" json:"id,string"`
+	CreatedAt  Time        `orm:"auto_now_add" json:"created_at"`
+	Actor      *User       `orm:"rel(fk);null" json:"actor"` // Nil for Conductor's own actions.
+	ActorName  string      `json:"actor_name"`
+	Action     AuditAction `json:"action"`
+	TargetType string      `json:"target_type"` // e.g. train, mode, metadata
+	TargetID   string      `orm:"column(target_id)" json:"target_id"`
+	Before     string      `orm:"null;type(jsonb)" json:"-"`
+	After      string      `orm:"null;type(jsonb)" json:"-"`
+	Reason     string      `orm:"null;type(text)" json:"reason"`
+}
+
+// Filters for listing audit events.
+// Zero values are ignored.
+type AuditFilter struct {
+	Actor      string // Email of the user, or ConductorActor.
+	Action     *AuditAction
+	TargetType string
+	TargetID   string
+	After      *time.Time
+	Before     *time.Time
+
+	// Only return events with an ID lower than the cursor.
+	Cursor uint64
+	Limit  int
 }
 
 type Search struct {
 	Params  map[string]string `json:"params"`
-	Results interface{}       `json:"results"`
+	Results *SearchResults    `json:"results"`
+}
+
+// Criteria for searching commits and trains.
+// Every non-empty field must match, except Text which matches any field.
+type SearchQuery struct {
+	Text      string
+	SHA       string
+	Message   string
+	Author    string
+	TicketKey string
+	Engineer  string
+	Limit     int
+}
+
+// Whether the query has criteria that match against commits.
+func (query *SearchQuery) HasCommitCriteria() bool {
+	return query.Text != "" || query.SHA != "" || query.Message != "" ||
+		query.Author != "" || query.TicketKey != ""
+}
+
+type CommitMatch struct {
+	*Commit
+	TrainIDs []string `json:"train_ids"`
+	Rank     float64  `json:"rank"`
+}
+
+type SearchResults struct {
+	Trains  []*TrainSummary `json:"trains"`
+	Commits []*CommitMatch  `json:"commits"`
+}
+
+// Filters for listing past trains.
+// Zero values are ignored.
+type TrainFilter struct {
+	Branch         string
+	EngineerEmail  string
+	AuthorEmail    string
+	State          *TrainState
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	DeployedAfter  *time.Time
+	DeployedBefore *time.Time
+
+	// Only return trains with an ID lower than the cursor.
+	Cursor uint64
+	Limit  int
+}
+
+// Lightweight view of a train, for listing many trains at once.
+type TrainSummary struct {
+	ID            uint64     `json:"id,string"`
+	Branch        string     `json:"branch"`
+	Engineer      *User      `json:"engineer"`
+	State         TrainState `json:"state"`
+	HeadSHA       string     `json:"head_sha"`
+	TailSHA       string     `json:"tail_sha"`
+	CommitCount   int64      `json:"commit_count"`
+	BlockedReason *string    `json:"blocked_reason"`
+	CreatedAt     Time       `json:"created_at"`
+	DeployedAt    Time       `json:"deployed_at"`
+	CancelledAt   Time       `json:"cancelled_at"`
+}
+
+type TrainHistory struct {
+	Params     map[string]string `json:"params"`
+	Trains     []*TrainSummary   `json:"trains"`
+	NextCursor *string           `json:"next_cursor"`
 }
 
 func (_ *Ticket) TableUnique() [][]string {
@@ -145,6 +389,54 @@ type Metadata struct {
 	Data      string `orm:"type(jsonb)" json:"data"`
 }
 
+// Parses space or comma-separated scopes, as stored in APIToken.Scopes.
+func ParseScopes(scopes string) ([]Scope, error) {
+	fields := strings.FieldsFunc(scopes, func(c rune) bool {
+		return c == ' ' || c == ','
+	})
+	parsed := make([]Scope, 0, len(fields))
+	for _, field := range fields {
+		scope, err := ScopeFromString(field)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, scope)
+	}
+	return parsed, nil
+}
+
+func FormatScopes(scopes []Scope) string {
+	names := make([]string, len(scopes))
+	for i, scope := range scopes {
+		names[i] = scope.String()
+	}
+	return strings.Join(names, " ")
+}
+
+func (token *APIToken) HasScope(scope Scope) bool {
+	scopes, err := ParseScopes(token.Scopes)
+	if err != nil {
+		return false
+	}
+	for _, s := range scopes {
+		if s.Includes(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns why the token can't be used, or nil if it can.
+func (token *APIToken) Validate(now time.Time) error {
+	if token.RevokedAt.HasValue() {
+		return fmt.Errorf("Token %s was revoked", token.Name)
+	}
+	if token.ExpiresAt.HasValue() && !now.Before(token.ExpiresAt.Value) {
+		return fmt.Errorf("Token %s expired", token.Name)
+	}
+	return nil
+}
+
 func (commit *Commit) ShortSHA() string {
 	return ShortSHA(commit.SHA)
 }
@@ -157,47 +449,77 @@ func ShortSHA(sha string) string {
 	return sha[:min]
 }
 
-func (train *Train) Phase(phaseType PhaseType) *Phase {
-	switch phaseType {
-	case Delivery:
-		return train.ActivePhases.Delivery
-	case Verification:
-		return train.ActivePhases.Verification
-	case Deploy:
-		return train.ActivePhases.Deploy
+// Returns the phase of the active phase group with the name, or nil.
+func (train *Train) PhaseNamed(name string) *Phase {
+	for _, phase := range train.ActivePhases.Phases() {
+		if phase.name() == name {
+			return phase
+		}
 	}
 	return nil
 }
 
-func (train *Train) SetActivePhase() {
-	phases := train.ActivePhases
-	if phases.Deploy.StartedAt.HasValue() {
-		train.ActivePhase = Deploy
-	} else if phases.Verification.StartedAt.HasValue() {
-		train.ActivePhase = Verification
-	} else {
-		train.ActivePhase = Delivery
+// Returns the last phase of the active phase group that started, or the first phase.
+func (train *Train) CurrentPhase() *Phase {
+	phases := train.ActivePhases.Phases()
+	current := phases[0]
+	for _, phase := range phases {
+		if phase.StartedAt.HasValue() {
+			current = phase
+		}
 	}
+	return current
+}
+
+func (train *Train) SetActivePhase() {
+	current := train.CurrentPhase()
+	train.ActivePhase = current.Type
+	train.ActivePhaseName = current.name()
+}
+
+// Whether a later train has taken over from this one.
+// A train split off this one doesn't count, since it waits for this one to deploy.
+func (train *Train) IsSuperseded() bool {
+	return train.NextID != nil && !train.NextIsSplitOff
 }
 
 func (train *Train) IsDeployable() bool {
-	return train.NextID == nil &&
+	return !train.IsSuperseded() &&
 		train.PreviousTrainDone &&
 		train.ActivePhase == Verification &&
 		train.ActivePhases.Verification.IsComplete() &&
 		train.Closed &&
 		!train.Blocked &&
+		!train.IsFrozen() &&
+		train.NextDeploySlot == nil &&
+		len(train.Holds) == 0 &&
 		!train.Done
 }
 
+func (train *Train) IsExpedited() bool {
+	return train.ExpediteReason != nil
+}
+
+// Whether a freeze is keeping the train from deploying.
+func (train *Train) IsFrozen() bool {
+	return train.Freeze != nil && train.FreezeOverrideReason == nil
+}
+
 func (train *Train) GetNotDeployableReason() *string {
 	if train.IsDeployable() || train.ActivePhase != Verification || train.Done {
 		return nil
 	}
 
 	var reason string
-	if train.NextID != nil {
+	if train.IsSuperseded() {
 		reason = "Not the latest train."
+	} else if pending := train.PendingApproval(); pending != nil {
+		missing := pending.Required - len(pending.Approvals)
+		if missing == 1 {
+			reason = fmt.Sprintf("Waiting for 1 more %s approval.", pending.Rule)
+		} else {
+			reason = fmt.Sprintf("Waiting for %d more %s approvals.", missing, pending.Rule)
+		}
 	} else if train.ActivePhase == Verification && !train.ActivePhases.Verification.IsComplete() {
 		reason = "Waiting for verification."
 	} else if !train.Closed {
@@ -210,8 +532,20 @@ func (train *Train) GetNotDeployableReason() *string {
 		} else {
 			reason = "Train is blocked."
 		}
+	} else if len(train.Holds) > 0 {
+		reason = train.Holds[0].String()
+		if len(train.Holds) == 2 {
+			reason = fmt.Sprintf("%s 1 more commit is held.", reason)
+		} else if len(train.Holds) > 2 {
+			reason = fmt.Sprintf("%s %d more commits are held.", reason, len(train.Holds)-1)
+		}
 	} else if !train.PreviousTrainDone {
 		reason = "Previous train is still deploying."
+	} else if train.IsFrozen() {
+		reason = fmt.Sprintf("Deploys are frozen for %s.", train.Freeze.Reason)
+	} else if train.NextDeploySlot != nil {
+		reason = fmt.Sprintf("Waiting for the next deploy slot, %s.",
+			train.NextDeploySlot.In(time.Local).Format("Mon 15:04 MST"))
 	}
 
 	if reason == "" {
@@ -244,11 +578,11 @@ func (train *Train) SendCommitCountMetrics() {
 		}
 	}
 
-	datadog.Count("commit.count", commitCount, train.DatadogTags())
-	datadog.Count("commit.robot", robotCommitCount, train.DatadogTags())
-	datadog.Count("commit.human", humanCommitCount, train.DatadogTags())
-	datadog.Count("commit.needs_staging", needsStagingCommits, train.DatadogTags())
-	datadog.Count("commit.no_verify", noVerifyCommits, train.DatadogTags())
+	metrics.Count("commit.count", commitCount, train.DatadogTags())
+	metrics.Count("commit.robot", robotCommitCount, train.DatadogTags())
+	metrics.Count("commit.human", humanCommitCount, train.DatadogTags())
+	metrics.Count("commit.needs_staging", needsStagingCommits, train.DatadogTags())
+	metrics.Count("commit.no_verify", noVerifyCommits, train.DatadogTags())
 }
 
 func DoesCommitNeedTicket(commit *Commit, commitsOnTickets map[string]struct{}, noStagingVerify bool) bool {
@@ -269,6 +603,48 @@ func (commit *Commit) IsNoVerify() bool {
 	return strings.Contains(commit.Message, "[no-verify]")
 }
 
+var (
+	holdPattern        = regexp.MustCompile(`\[hold(?::([^\]]*))?\]`)
+	deployAfterPattern = regexp.MustCompile(`\[deploy-after:([^\]]*)\]`)
+)
+
+// Time in the Conductor timezone, e.g. [deploy-after: 2026-11-01T10:00].
+const DeployAfterLayout = "2006-01-02T15:04"
+
+// Time with an RFC 3339 offset, e.g. [deploy-after: 2026-11-01T10:00-08:00] or [deploy-after: 2026-11-01T18:00Z].
+// Seconds are allowed too.
+const DeployAfterOffsetLayout = "2006-01-02T15:04Z07:00"
+
+// Whether the commit is marked [hold] or [hold: reason], and the reason if it has one.
+func (commit *Commit) HoldReason() (string, bool) {
+	match := holdPattern.FindStringSubmatch(commit.Message)
+	if match == nil {
+		return "", false
+	}
+	return strings.TrimSpace(match[1]), true
+}
+
+// When the commit can deploy, if it's marked [deploy-after: time]; nil if it isn't.
+func (commit *Commit) DeployAfter() (*time.Time, error) {
+	match := deployAfterPattern.FindStringSubmatch(commit.Message)
+	if match == nil {
+		return nil, nil
+	}
+	value := strings.TrimSpace(match[1])
+	for _, layout := range []string{DeployAfterOffsetLayout, time.RFC3339} {
+		deployAfter, err := time.Parse(layout, value)
+		if err == nil {
+			return &deployAfter, nil
+		}
+	}
+	deployAfter, err := time.ParseInLocation(DeployAfterLayout, value, location(""))
+	if err != nil {
+		return nil, fmt.Errorf("Bad deploy-after time %q, must look like %s or %s",
+			value, DeployAfterLayout, DeployAfterOffsetLayout)
+	}
+	return &deployAfter, nil
+}
+
 func (commit *Commit) IsNeedsStaging(noStagingVerify bool) bool {
 	if strings.Contains(commit.Message, "[needs-staging]") {
 		return true
@@ -313,6 +689,28 @@ func (train *Train) CommitsBetween(headSHA string, tailSHA string) []*Commit {
 	return commits
 }
 
+// Commits up to and including headSHA that aren't verified yet:
+// those on open tickets, and those that need a ticket and don't have one.
+func (train *Train) UnverifiedCommits(headSHA string, noStagingVerify bool) []*Commit {
+	commitsOnTickets := make(map[string]struct{})
+	verified := make(map[string]bool)
+	for _, ticket := range train.Tickets {
+		for _, commit := range ticket.Commits {
+			commitsOnTickets[commit.SHA] = struct{}{}
+			verified[commit.SHA] = verified[commit.SHA] || ticket.IsComplete()
+		}
+	}
+
+	unverified := make([]*Commit, 0)
+	for _, commit := range train.CommitsSince(headSHA) {
+		_, ticketed := commitsOnTickets[commit.SHA]
+		if (ticketed && !verified[commit.SHA]) || DoesCommitNeedTicket(commit, commitsOnTickets, noStagingVerify) {
+			unverified = append(unverified, commit)
+		}
+	}
+	return unverified
+}
+
 func (train *Train) NewCommitsNeedingTickets(headSHA string, noStagingVerify bool) []*Commit {
 	newCommits := make([]*Commit, 0)
 
@@ -332,8 +730,59 @@ func (train *Train) NewCommitsNeedingTickets(headSHA string, noStagingVerify boo
 	return newCommits
 }
 
+// Whether the first deploy phase started, and the pipeline hasn't completed.
 func (train *Train) IsDeploying() bool {
-	return train.ActivePhases.Deploy.StartedAt.HasValue() && !train.ActivePhases.Deploy.CompletedAt.HasValue()
+	phases := train.ActivePhases.Phases()
+	return train.ActivePhases.Deploy.StartedAt.HasValue() && !phases[len(phases)-1].CompletedAt.HasValue()
+}
+
+// SHAs of the commits taken off the train.
+func (train *Train) ExcludedSHAList() []string {
+	if train.ExcludedSHAs == "" {
+		return nil
+	}
+	return strings.Split(train.ExcludedSHAs, ",")
+}
+
+// SHAs of the held commits that were released.
+func (train *Train) ReleasedSHAList() []string {
+	if train.ReleasedSHAs == "" {
+		return nil
+	}
+	return strings.Split(train.ReleasedSHAs, ",")
+}
+
+// The commits that keep the train from deploying at now,
+// until their author or the engineer releases them, or their deploy-after time passes.
+func (train *Train) CommitHolds(now time.Time) []*CommitHold {
+	released := make(map[string]bool)
+	for _, sha := range train.ReleasedSHAList() {
+		released[sha] = true
+	}
+
+	holds := make([]*CommitHold, 0)
+	for _, commit := range train.Commits {
+		if released[commit.SHA] {
+			continue
+		}
+		reason, held := commit.HoldReason()
+		deployAfter, err := commit.DeployAfter()
+		if err != nil {
+			// Better to hold the train than to deploy early.
+			holds = append(holds, &CommitHold{Commit: commit, Reason: err.Error()})
+		} else if held || (deployAfter != nil && deployAfter.After(now)) {
+			holds = append(holds, &CommitHold{Commit: commit, Reason: reason, DeployAfter: deployAfter})
+		}
+	}
+	return holds
+}
+
+// The branch the train's phases build from.
+func (train *Train) BuildBranch() string {
+	if train.GeneratedBranch != nil {
+		return *train.GeneratedBranch
+	}
+	return train.Branch
 }
 
 func (train *Train) IsDeployed() bool {
@@ -348,6 +797,38 @@ func (train *Train) IsDone() bool {
 	return train.IsDeployed() || train.IsCancelled()
 }
 
+// Requires ActivePhases to be loaded.
+func (train *Train) State() TrainState {
+	if train.IsCancelled() {
+		return Cancelled
+	} else if train.IsDeployed() {
+		return Deployed
+	} else if train.IsDeploying() {
+		return Deploying
+	} else if train.Blocked {
+		return Blocked
+	} else if train.Closed {
+		return Closed
+	}
+	return Open
+}
+
+func (train *Train) Summary(commitCount int64) *TrainSummary {
+	return &TrainSummary{
+		ID:            train.ID,
+		Branch:        train.Branch,
+		Engineer:      train.Engineer,
+		State:         train.State(),
+		HeadSHA:       train.HeadSHA,
+		TailSHA:       train.TailSHA,
+		CommitCount:   commitCount,
+		BlockedReason: train.BlockedReason,
+		CreatedAt:     train.CreatedAt,
+		DeployedAt:    train.DeployedAt,
+		CancelledAt:   train.CancelledAt,
+	}
+}
+
 func (train *Train) GitReference() string {
 	return fmt.Sprintf("%s-%s", train.Branch, ShortSHA(train.HeadSHA))
 }
@@ -356,16 +837,16 @@ func (phase *Phase) IsComplete() bool {
 	return phase.CompletedAt.HasValue()
 }
 
-func (phase *Phase) Before(phaseType PhaseType) bool {
-	switch phase.Type {
-	case Delivery:
-		return phaseType != Delivery
-	case Verification:
-		return phaseType == Deploy
-	case Deploy:
-		return false
+// Phases from before pipelines have no name, so they go by their type.
+func (phase *Phase) name() string {
+	if phase.Name == "" {
+		return phase.Type.String()
 	}
-	return false
+	return phase.Name
+}
+
+func (phase *Phase) Before(other *Phase) bool {
+	return phase.Position < other.Position
 }
 
 func (phase *Phase) IsInActivePhaseGroup() bool {
@@ -373,20 +854,30 @@ func (phase *Phase) IsInActivePhaseGroup() bool {
 }
 
 func (phase *Phase) EarlierPhasesComplete() bool {
-	switch phase.Type {
-	case Delivery:
-		return true
-	case Verification:
-		return phase.PhaseGroup.Delivery.IsComplete()
-	case Deploy:
-		return phase.PhaseGroup.Delivery.IsComplete() && phase.PhaseGroup.Verification.IsComplete()
+	for _, earlier := range phase.PhaseGroup.Phases() {
+		if !earlier.Before(phase) {
+			break
+		}
+		if !earlier.IsComplete() {
+			return false
+		}
 	}
-	return false
+	return true
+}
+
+// Returns the phase after this one in its phase group, or nil if it's the last.
+func (phase *Phase) Next() *Phase {
+	for _, next := range phase.PhaseGroup.Phases() {
+		if phase.Before(next) {
+			return next
+		}
+	}
+	return nil
 }
 
 func (phase *Phase) DatadogTags() []string {
 	tags := phase.Train.DatadogTags()
-	tags = append(tags, fmt.Sprintf("phase_name:%s", phase.Type.String()))
+	tags = append(tags, fmt.Sprintf("phase_name:%s", phase.name()))
 	return tags
 }
 
@@ -394,40 +885,97 @@ func (phaseGroup *PhaseGroup) IsActivePhaseGroup() bool {
 	return phaseGroup.ID == phaseGroup.Train.ActivePhases.ID
 }
 
-func (phaseGroup *PhaseGroup) AddNewPhase(phaseType PhaseType, train *Train) *Phase {
+// Adds a phase to the end of the pipeline.
+func (phaseGroup *PhaseGroup) AddNewPhase(name string, phaseType PhaseType, train *Train) *Phase {
 	phase := &Phase{
+		Name:       name,
+		Position:   len(phaseGroup.Pipeline),
 		Type:       phaseType,
 		Train:      train,
 		PhaseGroup: phaseGroup,
 	}
-	switch phase.Type {
+	phaseGroup.Pipeline = append(phaseGroup.Pipeline, phase)
+	switch phaseType {
 	case Delivery:
 		phaseGroup.Delivery = phase
 	case Verification:
 		phaseGroup.Verification = phase
 	case Deploy:
-		phaseGroup.Deploy = phase
+		if phaseGroup.Deploy == nil {
+			phaseGroup.Deploy = phase
+		}
+	}
+	return phase
+}
+
+// Puts a new phase in place of the given one.
+func (phaseGroup *PhaseGroup) ReplacePhase(old *Phase) *Phase {
+	phase := &Phase{
+		Name:       old.name(),
+		Position:   old.Position,
+		Type:       old.Type,
+		Train:      old.Train,
+		PhaseGroup: phaseGroup,
 	}
+	for i := range phaseGroup.Pipeline {
+		if phaseGroup.Pipeline[i] == old {
+			phaseGroup.Pipeline[i] = phase
+		}
+	}
+	phaseGroup.link(phase, old.ID)
 	return phase
 }
 
+// Points the delivery, verification or deploy phase with the ID at the given phase.
+func (phaseGroup *PhaseGroup) link(phase *Phase, id uint64) {
+	if phaseGroup.Delivery != nil && phaseGroup.Delivery.ID == id {
+		phaseGroup.Delivery = phase
+	}
+	if phaseGroup.Verification != nil && phaseGroup.Verification.ID == id {
+		phaseGroup.Verification = phase
+	}
+	if phaseGroup.Deploy != nil && phaseGroup.Deploy.ID == id {
+		phaseGroup.Deploy = phase
+	}
+}
+
 func (phaseGroup *PhaseGroup) SetReferences(train *Train) {
 	phaseGroup.Train = train
-	phaseGroup.Delivery.Train = train
-	phaseGroup.Verification.Train = train
-	phaseGroup.Deploy.Train = train
-
-	phaseGroup.Delivery.PhaseGroup = phaseGroup
-	phaseGroup.Verification.PhaseGroup = phaseGroup
-	phaseGroup.Deploy.PhaseGroup = phaseGroup
+	// The pipeline is loaded separately from the delivery, verification and deploy phases.
+	for _, phase := range phaseGroup.Pipeline {
+		phaseGroup.link(phase, phase.ID)
+	}
+	for i, phase := range phaseGroup.Phases() {
+		phase.Name = phase.name()
+		phase.Position = i
+		phase.Train = train
+		phase.PhaseGroup = phaseGroup
+	}
 }
 
+// Returns the phases in pipeline order.
+// Phase groups from before pipelines only have delivery, verification and deploy phases.
 func (phaseGroup *PhaseGroup) Phases() []*Phase {
-	return []*Phase{
-		phaseGroup.Delivery,
-		phaseGroup.Verification,
-		phaseGroup.Deploy,
+	if len(phaseGroup.Pipeline) > 0 {
+		return phaseGroup.Pipeline
+	}
+	var phases []*Phase
+	for _, phase := range []*Phase{phaseGroup.Delivery, phaseGroup.Verification, phaseGroup.Deploy} {
+		if phase != nil {
+			phases = append(phases, phase)
+		}
 	}
+	return phases
+}
+
+// Returns the first phase of the type in the pipeline.
+func (phaseGroup *PhaseGroup) FirstPhase(phaseType PhaseType) *Phase {
+	for _, phase := range phaseGroup.Phases() {
+		if phase.Type == phaseType {
+			return phase
+		}
+	}
+	return nil
 }
 
 func (phaseGroup *PhaseGroup) GitReference() string {
@@ -437,7 +985,7 @@ func (phaseGroup *PhaseGroup) GitReference() string {
 func (job *Job) DatadogTags() []string {
 	tags := job.Phase.Train.DatadogTags()
 	tags = append(tags, fmt.Sprintf("job_name:%s", job.Name))
-	tags = append(tags, fmt.Sprintf("phase_name:%s", job.Phase.Type.String()))
+	tags = append(tags, fmt.Sprintf("phase_name:%s", job.Phase.name()))
 	return tags
 }
 
@@ -519,6 +1067,18 @@ func (s TicketsByID) Less(i, j int) bool {
 	return s[i].ID < s[j].ID
 }
 
+type PhasesByPosition []*Phase
+
+func (s PhasesByPosition) Len() int {
+	return len(s)
+}
+func (s PhasesByPosition) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+func (s PhasesByPosition) Less(i, j int) bool {
+	return s[i].Position < s[j].Position
+}
+
 type JobsByID []*Job
 
 func (s JobsByID) Len() int {
@@ -129,6 +129,13 @@ func TestNotDeployableReason(t *testing.T) {
 	reason = train.GetNotDeployableReason()
 	assert.Equal(t, "Not the latest train.", *reason)
 
+	// A train split off this one waits for it.
+	train.NextIsSplitOff = true
+
+	reason = train.GetNotDeployableReason()
+	assert.Equal(t, "Waiting for verification.", *reason)
+
+	train.NextIsSplitOff = false
 	train.PreviousTrainDone = false
 	train.NextID = nil
 
@@ -162,4 +169,324 @@ func TestNotDeployableReason(t *testing.T) {
 	assert.Equal(t,
 		fmt.Sprintf("Train is blocked due to %s.", blockedReason),
 		*reason)
+
+	train.Blocked = false
+	train.Holds = []*CommitHold{
+		{Commit: &Commit{SHA: "abcdef0123456789"}, Reason: "launch on Monday"},
+		{Commit: &Commit{SHA: "0123456789abcdef", Message: "[hold]"}},
+	}
+
+	reason = train.GetNotDeployableReason()
+	assert.Equal(t, "Commit abcdef0123456789 is held: launch on Monday. 1 more commit is held.", *reason)
+	assert.False(t, train.IsDeployable())
+
+	train.Holds = nil
+	train.Freeze = &FreezeWindow{Reason: "the holidays"}
+
+	reason = train.GetNotDeployableReason()
+	assert.Equal(t, "Deploys are frozen for the holidays.", *reason)
+	assert.False(t, train.IsDeployable())
+
+	overrideReason := "security fix"
+	train.FreezeOverrideReason = &overrideReason
+
+	reason = train.GetNotDeployableReason()
+	assert.Nil(t, reason)
+	assert.True(t, train.IsDeployable())
+
+	nextSlot := time.Date(2019, time.December, 30, 14, 0, 0, 0, time.Local)
+	train.NextDeploySlot = &nextSlot
+
+	reason = train.GetNotDeployableReason()
+	assert.Equal(t,
+		fmt.Sprintf("Waiting for the next deploy slot, %s.", nextSlot.Format("Mon 15:04 MST")),
+		*reason)
+	assert.False(t, train.IsDeployable())
+}
+
+func TestTrainState(t *testing.T) {
+	train := &Train{
+		ActivePhases: &PhaseGroup{
+			Deploy: &Phase{},
+		},
+	}
+	assert.Equal(t, Open, train.State())
+
+	train.Closed = true
+	assert.Equal(t, Closed, train.State())
+
+	train.Blocked = true
+	assert.Equal(t, Blocked, train.State())
+
+	train.ActivePhases.Deploy.StartedAt = Time{time.Now()}
+	assert.Equal(t, Deploying, train.State())
+
+	train.ActivePhases.Deploy.CompletedAt = Time{time.Now()}
+	train.DeployedAt = Time{time.Now()}
+	assert.Equal(t, Deployed, train.State())
+
+	train.CancelledAt = Time{time.Now()}
+	assert.Equal(t, Cancelled, train.State())
+}
+
+func TestPipelinePhaseGroup(t *testing.T) {
+	train := &Train{}
+	phaseGroup := &PhaseGroup{}
+	build := phaseGroup.AddNewPhase("build", Delivery, train)
+	staging := phaseGroup.AddNewPhase("staging", Verification, train)
+	canary := phaseGroup.AddNewPhase("canary", Deploy, train)
+	production := phaseGroup.AddNewPhase("production", Deploy, train)
+	train.ActivePhases = phaseGroup
+	train.SetActivePhase()
+
+	assert.Equal(t, build, phaseGroup.Delivery)
+	assert.Equal(t, staging, phaseGroup.Verification)
+	assert.Equal(t, canary, phaseGroup.Deploy)
+	assert.Equal(t, production, canary.Next())
+	assert.Nil(t, production.Next())
+	assert.Equal(t, "build", train.ActivePhaseName)
+	assert.Equal(t, canary, train.PhaseNamed("canary"))
+	assert.Nil(t, train.PhaseNamed("deploy"))
+
+	build.StartedAt = Time{time.Now()}
+	build.CompletedAt = Time{time.Now()}
+	staging.StartedAt = Time{time.Now()}
+	staging.CompletedAt = Time{time.Now()}
+	assert.True(t, canary.EarlierPhasesComplete())
+	assert.False(t, production.EarlierPhasesComplete())
+	assert.False(t, train.IsDeploying())
+
+	canary.StartedAt = Time{time.Now()}
+	canary.CompletedAt = Time{time.Now()}
+	train.SetActivePhase()
+	assert.Equal(t, Deploy, train.ActivePhase)
+	assert.Equal(t, "canary", train.ActivePhaseName)
+	assert.True(t, train.IsDeploying())
+	assert.True(t, staging.Before(train.CurrentPhase()))
+
+	production.StartedAt = Time{time.Now()}
+	replaced := phaseGroup.ReplacePhase(production)
+	assert.Equal(t, "production", replaced.Name)
+	assert.Equal(t, replaced, canary.Next())
+	assert.Equal(t, "canary", train.CurrentPhase().Name)
+
+	replaced.StartedAt = Time{time.Now()}
+	replaced.CompletedAt = Time{time.Now()}
+	assert.False(t, train.IsDeploying())
+}
+
+func TestLegacyPhaseGroup(t *testing.T) {
+	train := &Train{}
+	phaseGroup := &PhaseGroup{
+		Delivery:     &Phase{ID: 1, Type: Delivery},
+		Verification: &Phase{ID: 2, Type: Verification},
+		Deploy:       &Phase{ID: 3, Type: Deploy},
+	}
+	phaseGroup.SetReferences(train)
+	train.ActivePhases = phaseGroup
+
+	phases := phaseGroup.Phases()
+	assert.Len(t, phases, 3)
+	assert.Equal(t, "verification", phases[1].Name)
+	assert.Equal(t, 2, phases[2].Position)
+	assert.Equal(t, phaseGroup.Deploy, train.PhaseNamed("deploy"))
+	assert.Equal(t, phaseGroup.Verification, phaseGroup.Delivery.Next())
+}
+
+func TestTrainStateFromString(t *testing.T) {
+	for _, state := range []TrainState{Open, Closed, Blocked, Deploying, Deployed, Cancelled} {
+		parsed, err := TrainStateFromString(state.String())
+		assert.NoError(t, err)
+		assert.Equal(t, state, parsed)
+	}
+
+	_, err := TrainStateFromString("derailed")
+	assert.Error(t, err)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("train:read, jobs:write train:admin")
+	assert.NoError(t, err)
+	assert.Equal(t, []Scope{TrainRead, JobsWrite, TrainAdmin}, scopes)
+	assert.Equal(t, "train:read jobs:write train:admin", FormatScopes(scopes))
+
+	scopes, err = ParseScopes("")
+	assert.NoError(t, err)
+	assert.Empty(t, scopes)
+
+	_, err = ParseScopes("train:read train:drive")
+	assert.Error(t, err)
+}
+
+func TestAPITokenHasScope(t *testing.T) {
+	token := &APIToken{Scopes: "train:read jobs:write"}
+	assert.True(t, token.HasScope(TrainRead))
+	assert.True(t, token.HasScope(JobsWrite))
+	assert.False(t, token.HasScope(TrainWrite))
+	assert.False(t, token.HasScope(TrainAdmin))
+
+	token = &APIToken{Scopes: "train:admin"}
+	assert.True(t, token.HasScope(TrainRead))
+	assert.True(t, token.HasScope(TrainWrite))
+	assert.True(t, token.HasScope(TrainAdmin))
+	assert.False(t, token.HasScope(JobsWrite))
+
+	token = &APIToken{Scopes: "train:write"}
+	assert.True(t, token.HasScope(TrainRead))
+	assert.False(t, token.HasScope(TrainAdmin))
+}
+
+func TestAPITokenValidate(t *testing.T) {
+	now := time.Now()
+	token := &APIToken{Name: "jenkins"}
+	assert.NoError(t, token.Validate(now))
+
+	token.ExpiresAt = Time{now.Add(time.Hour)}
+	assert.NoError(t, token.Validate(now))
+	assert.EqualError(t, token.Validate(now.Add(time.Hour)), "Token jenkins expired")
+
+	token.ExpiresAt = Time{}
+	token.RevokedAt = Time{now}
+	assert.EqualError(t, token.Validate(now), "Token jenkins was revoked")
+}
+
+func TestRoleFromString(t *testing.T) {
+	for _, role := range Roles {
+		parsed, err := RoleFromString(role.String())
+		assert.NoError(t, err)
+		assert.Equal(t, role, parsed)
+	}
+	_, err := RoleFromString("superuser")
+	assert.Error(t, err)
+
+	assert.True(t, ReleaseManager.AtLeast(Engineer))
+	assert.True(t, Engineer.AtLeast(Engineer))
+	assert.False(t, Committer.AtLeast(Engineer))
+}
+
+func TestTrainExcludedCommits(t *testing.T) {
+	train := &Train{Branch: "master"}
+	assert.Nil(t, train.ExcludedSHAList())
+	assert.Equal(t, "master", train.BuildBranch())
+
+	branch := "conductor/train-1"
+	train.ExcludedSHAs = "abc,def"
+	train.GeneratedBranch = &branch
+	assert.Equal(t, []string{"abc", "def"}, train.ExcludedSHAList())
+	assert.Equal(t, branch, train.BuildBranch())
+}
+
+func TestTrainUnverifiedCommits(t *testing.T) {
+	verified := &Commit{ID: 1, SHA: "verified"}
+	open := &Commit{ID: 2, SHA: "open"}
+	untracked := &Commit{ID: 3, SHA: "untracked"}
+	needsStaging := &Commit{ID: 4, SHA: "needs-staging", Message: "[needs-staging]"}
+	train := &Train{
+		Commits: []*Commit{verified, open, untracked, needsStaging},
+		Tickets: []*Ticket{
+			{Commits: []*Commit{verified}, ClosedAt: Time{time.Now()}},
+			{Commits: []*Commit{open}},
+		},
+	}
+
+	assert.Empty(t, train.UnverifiedCommits("verified", false))
+	assert.Equal(t, []*Commit{open}, train.UnverifiedCommits("open", false))
+	assert.Equal(t, []*Commit{open, untracked}, train.UnverifiedCommits("untracked", false))
+	assert.Equal(t, []*Commit{open, needsStaging}, train.UnverifiedCommits("needs-staging", true))
+}
+
+func TestCommitHoldMarkers(t *testing.T) {
+	reason, held := (&Commit{Message: "Add launch banner"}).HoldReason()
+	assert.False(t, held)
+	reason, held = (&Commit{Message: "Add launch banner [hold]"}).HoldReason()
+	assert.True(t, held)
+	assert.Empty(t, reason)
+	reason, held = (&Commit{Message: "Add launch banner\n\n[hold: waiting on marketing]"}).HoldReason()
+	assert.True(t, held)
+	assert.Equal(t, "waiting on marketing", reason)
+
+	deployAfter, err := (&Commit{Message: "Add launch banner"}).DeployAfter()
+	assert.NoError(t, err)
+	assert.Nil(t, deployAfter)
+	deployAfter, err = (&Commit{Message: "Add launch banner [deploy-after: 2026-11-01T10:00]"}).DeployAfter()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, time.November, 1, 10, 0, 0, 0, time.Local), *deployAfter)
+	deployAfter, err = (&Commit{Message: "Add launch banner [deploy-after: 2026-11-01T10:00+05:30]"}).DeployAfter()
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2026, time.November, 1, 4, 30, 0, 0, time.UTC).Equal(*deployAfter))
+	deployAfter, err = (&Commit{Message: "Add launch banner [deploy-after: 2026-11-01T10:00:30Z]"}).DeployAfter()
+	assert.NoError(t, err)
+	assert.True(t, time.Date(2026, time.November, 1, 10, 0, 30, 0, time.UTC).Equal(*deployAfter))
+	_, err = (&Commit{Message: "Add launch banner [deploy-after: next week]"}).DeployAfter()
+	assert.Error(t, err)
+}
+
+func TestTrainCommitHolds(t *testing.T) {
+	now := time.Date(2026, time.October, 30, 12, 0, 0, 0, time.Local)
+	plain := &Commit{SHA: "plain", Message: "Fix typo"}
+	held := &Commit{SHA: "held", Message: "Launch [hold: press release]"}
+	later := &Commit{SHA: "later", Message: "Launch [deploy-after: 2026-11-01T10:00]"}
+	earlier := &Commit{SHA: "earlier", Message: "Launch [deploy-after: 2026-10-01T10:00]"}
+	bad := &Commit{SHA: "bad", Message: "Launch [deploy-after: soon]"}
+	train := &Train{Commits: []*Commit{plain, held, later, earlier, bad}}
+
+	holds := train.CommitHolds(now)
+	assert.Len(t, holds, 3)
+	assert.Equal(t, held, holds[0].Commit)
+	assert.Equal(t, "press release", holds[0].Reason)
+	assert.Equal(t, later, holds[1].Commit)
+	assert.Equal(t, "Commit later is held until Sun Nov 1 10:00 "+holds[1].DeployAfter.Format("MST")+".",
+		holds[1].String())
+	assert.Equal(t, bad, holds[2].Commit)
+	assert.NotEmpty(t, holds[2].Reason)
+
+	offset := &Commit{SHA: "offset", Message: "Launch [deploy-after: 2026-11-01T10:00+05:30]"}
+	hold := (&Train{Commits: []*Commit{offset}}).CommitHolds(now)[0]
+	assert.Equal(t, "Commit offset is held until Sun Nov 1 10:00 "+hold.DeployAfter.Format("MST")+".", hold.String())
+
+	train.ReleasedSHAs = "held,bad"
+	holds = train.CommitHolds(now)
+	assert.Len(t, holds, 1)
+	assert.Equal(t, later, holds[0].Commit)
+	assert.Empty(t, train.CommitHolds(now.AddDate(0, 0, 3)))
+}
+
+func TestRollbackStatusFromString(t *testing.T) {
+	for _, status := range RollbackStatuses {
+		parsed, err := RollbackStatusFromString(status.String())
+		assert.NoError(t, err)
+		assert.Equal(t, status, parsed)
+	}
+	_, err := RollbackStatusFromString("reverted")
+	assert.Error(t, err)
+}
+
+func TestAuditActionFromString(t *testing.T) {
+	for _, action := range AuditActions {
+		parsed, err := AuditActionFromString(action.String())
+		assert.NoError(t, err)
+		assert.Equal(t, action, parsed)
+	}
+	_, err := AuditActionFromString("train.explode")
+	assert.Error(t, err)
+}
+
+func TestAuthExpiresAt(t *testing.T) {
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	idle := 24 * time.Hour
+	max := 7 * 24 * time.Hour
+
+	auth := &Auth{Token: "token", CreatedAt: Time{Value: created}}
+	assert.Equal(t, created.Add(idle), auth.ExpiresAt(idle, max))
+
+	// Using the session extends it.
+	auth.LastUsedAt = Time{Value: created.Add(3 * 24 * time.Hour)}
+	assert.Equal(t, created.Add(4*24*time.Hour), auth.ExpiresAt(idle, max))
+
+	// But not past the max lifetime.
+	auth.LastUsedAt = Time{Value: created.Add(6*24*time.Hour + time.Hour)}
+	assert.Equal(t, created.Add(max), auth.ExpiresAt(idle, max))
+
+	assert.Len(t, auth.SessionID(), 16)
+	assert.NotContains(t, auth.SessionID(), "token")
 }
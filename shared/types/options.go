@@ -14,18 +14,123 @@ import (
 type Options struct {
 	// CloseTime is when trains should be automatically closed.
 	// This is defined as an array of TimeIntervals.
-	// Example: M-F 9-5.
-	//  []TimeInterval{
-	//      TimeInterval{
+	// Example: M-F 9-5 in San Francisco, except on Memorial Day 2021.
+	//  RepeatingTimeIntervals{
+	//      RepeatingTimeInterval{
 	//          Every: []time.Weekday{
 	//              time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
 	//          StartTime: Clock{Hour: 9, Minute: 0},
 	//          EndTime: Clock{Hour: 17, Minute: 0},
+	//          Timezone: "America/Los_Angeles",
+	//          Except: []string{"2021-05-31"},
 	//      },
 	//  }
-	CloseTime            RepeatingTimeIntervals `json:"close_time"`
-	ValidationError      error                  `orm:"-" json:"-"`
-	InvalidOptionsString string                 `orm:"-" json:"-"`
+	CloseTime RepeatingTimeIntervals `json:"close_time"`
+
+	// Freezes are when deploys shouldn't start, like holidays.
+	// Trains still close and get verified, and deploy once the freeze ends,
+	// unless an admin overrides the freeze for the train.
+	Freezes FreezeWindows `json:"freezes,omitempty"`
+
+	// DeploySlots hold ready trains until set times, so deploys happen while people are around.
+	// Trains can start deploying for DeploySlotMinutes after each slot's time.
+	// Without slots, trains deploy as soon as they're ready.
+	DeploySlots       DeploySlots `json:"deploy_slots,omitempty"`
+	DeploySlotMinutes int         `json:"deploy_slot_minutes,omitempty"`
+
+	// Pipeline is the phases trains go through, like build, staging, canary and production.
+	// Defaults to delivery, verification and deploy.
+	Pipeline Pipeline `json:"pipeline,omitempty"`
+
+	// Branches overrides the mode, close time and jobs for trains on some branches,
+	// e.g. a hotfix branch in manual mode that only runs a few verification jobs.
+	Branches map[string]BranchOptions `json:"branches,omitempty"`
+
+	// Approvals are sign-offs trains need before they deploy.
+	// They hold the train at the end of verification, like tickets.
+	Approvals ApprovalRules `json:"approvals,omitempty"`
+
+	// Expedite sets how hotfix trains that skip the queue are shipped.
+	Expedite ExpeditePolicy `json:"expedite"`
+
+	// Rollback sets what Conductor does on its own when a deploy goes wrong.
+	Rollback RollbackPolicy `json:"rollback"`
+
+	// ExcludeCommits is how a commit is taken off a train. "redeliver", the default, delivers the train again
+	// with BRANCH set to a generated branch and EXCLUDED_SHAS to the commits taken off; Conductor doesn't
+	// create that branch, so CI must build it from the train's head without EXCLUDED_SHAS.
+	// "revert" pushes a revert of the commit to the branch, which needs a code service that can revert.
+	ExcludeCommits string `json:"exclude_commits,omitempty"`
+
+	ValidationError      error  `orm:"-" json:"-"`
+	InvalidOptionsString string `orm:"-" json:"-"`
+}
+
+// Settings for trains on one branch. Unset fields fall back to the global ones.
+type BranchOptions struct {
+	// "schedule" or "manual".
+	Mode string `json:"mode,omitempty"`
+	// An empty list means the branch's trains are never closed automatically.
+	CloseTime *RepeatingTimeIntervals `json:"close_time,omitempty"`
+	// Expected jobs by phase name, in place of the pipeline's.
+	Jobs map[string][]string `json:"jobs,omitempty"`
+}
+
+func (b BranchOptions) Validate(pipeline Pipeline) error {
+	if b.Mode != "" {
+		_, err := ModeFromString(b.Mode)
+		if err != nil {
+			return err
+		}
+	}
+	if b.CloseTime != nil {
+		for _, interval := range *b.CloseTime {
+			err := interval.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for name := range b.Jobs {
+		if pipeline.Phase(name) == nil {
+			return fmt.Errorf("Unknown phase: %s", name)
+		}
+	}
+	return nil
+}
+
+const DefaultExpediteBranch = "hotfix"
+
+// How hotfix trains that skip the queue are shipped.
+type ExpeditePolicy struct {
+	// The branch hotfix trains are made from. Defaults to hotfix.
+	Branch string `json:"branch,omitempty"`
+	// Whether hotfix trains skip verification jobs and tickets.
+	// Otherwise they run the verification jobs for their branch, which Branches can shorten.
+	SkipVerification bool `json:"skip_verification,omitempty"`
+}
+
+func (p ExpeditePolicy) BranchName() string {
+	if p.Branch == "" {
+		return DefaultExpediteBranch
+	}
+	return p.Branch
+}
+
+// What Conductor does on its own when a deploy goes wrong.
+type RollbackPolicy struct {
+	// Whether a failed deploy job rolls production back to the last deployed train,
+	// cancelling the deploying train and blocking the next one.
+	OnDeployFailure bool `json:"on_deploy_failure,omitempty"`
+}
+
+const (
+	ExcludeByRevert     = "revert"
+	ExcludeByRedelivery = "redeliver"
+)
+
+func (o Options) ExcludesByRedelivery() bool {
+	return o.ExcludeCommits != ExcludeByRevert
 }
 
 // Implement beego Fielder interface to handle serialization and deserialization.
@@ -72,6 +177,46 @@ func (o *Options) FromString(optionsString string) error {
 		return err
 	}
 
+	for _, interval := range o.CloseTime {
+		err = interval.Validate()
+		if err != nil {
+			return fmt.Errorf("Options validation error: %v", err.Error())
+		}
+	}
+
+	for _, slot := range o.DeploySlots {
+		err = slot.Validate()
+		if err != nil {
+			return fmt.Errorf("Options validation error: %v", err.Error())
+		}
+	}
+
+	for _, freeze := range o.Freezes {
+		err = freeze.Validate()
+		if err != nil {
+			return fmt.Errorf("Options validation error: %v", err.Error())
+		}
+	}
+
+	if o.Pipeline != nil {
+		err = o.Pipeline.Validate()
+		if err != nil {
+			return fmt.Errorf("Options validation error: %v", err.Error())
+		}
+	}
+
+	err = o.Approvals.Validate()
+	if err != nil {
+		return fmt.Errorf("Options validation error: %v", err.Error())
+	}
+
+	for branch, branchOptions := range o.Branches {
+		err = branchOptions.Validate(o.PhasePipeline())
+		if err != nil {
+			return fmt.Errorf("Options validation error for branch %s: %v", branch, err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -85,6 +230,110 @@ func (o Options) InCloseTime() bool {
 	return false
 }
 
+// Returns the mode for trains on the branch, given the global mode.
+func (o Options) BranchMode(branch string, mode Mode) Mode {
+	branchMode, err := ModeFromString(o.Branches[branch].Mode)
+	if err != nil {
+		return mode
+	}
+	return branchMode
+}
+
+// Returns when trains on the branch should be closed.
+func (o Options) BranchCloseTime(branch string) RepeatingTimeIntervals {
+	closeTime := o.Branches[branch].CloseTime
+	if closeTime == nil {
+		return o.CloseTime
+	}
+	return *closeTime
+}
+
+func (o Options) InBranchCloseTime(branch string) bool {
+	now := time.Now()
+	for _, interval := range o.BranchCloseTime(branch) {
+		if interval.Includes(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) PhasePipeline() Pipeline {
+	if len(o.Pipeline) == 0 {
+		return DefaultPipeline
+	}
+	return o.Pipeline
+}
+
+// Returns the jobs expected for the named phase on trains on the branch.
+func (o Options) JobsForPhase(name string, branch string) []string {
+	jobs, ok := o.Branches[branch].Jobs[name]
+	if ok {
+		return jobs
+	}
+	config := o.PhasePipeline().Phase(name)
+	if config == nil {
+		// The phase was taken out of the pipeline after the train started.
+		return defaultJobs(name)
+	}
+	return config.jobs()
+}
+
+// Returns the health checks that gate the named phase.
+func (o Options) PhaseChecks(name string) []HealthCheck {
+	config := o.PhasePipeline().Phase(name)
+	if config == nil {
+		return nil
+	}
+	return config.Checks
+}
+
+// Returns how long the named phase must run before it can complete.
+func (o Options) PhaseSoak(name string) time.Duration {
+	config := o.PhasePipeline().Phase(name)
+	if config == nil {
+		return 0
+	}
+	return time.Duration(config.SoakMinutes) * time.Minute
+}
+
+// Whether the train skips verification, as an expedited train.
+func (o Options) SkipsVerification(train *Train) bool {
+	return train.IsExpedited() && o.Expedite.SkipVerification
+}
+
+// Returns the jobs expected for the phase of its train.
+func (o Options) TrainJobsForPhase(phase *Phase) []string {
+	if phase.Type == RollbackPhase {
+		return []string{RollbackJobName}
+	}
+	if phase.Type == Verification && o.SkipsVerification(phase.Train) {
+		return []string{}
+	}
+	return o.JobsForPhase(phase.name(), phase.Train.Branch)
+}
+
+// Returns the freeze deploys are in now, or nil if they aren't frozen.
+func (o Options) ActiveFreeze() *FreezeWindow {
+	return o.Freezes.Active(time.Now())
+}
+
+func (o Options) deploySlotMinutes() int {
+	if o.DeploySlotMinutes == 0 {
+		return DefaultDeploySlotMinutes
+	}
+	return o.DeploySlotMinutes
+}
+
+// Returns when trains can next start deploying, or nil if they can now.
+func (o Options) NextDeploySlot() *time.Time {
+	now := time.Now()
+	if o.DeploySlots.Includes(now, o.deploySlotMinutes()) {
+		return nil
+	}
+	return o.DeploySlots.Next(now)
+}
+
 func (o Options) CloseTimeOverlap(start time.Time, end time.Time) time.Duration {
 	return o.CloseTime.TotalOverlap(start, end)
 }
@@ -118,27 +367,160 @@ const optionsSchema = `
 					"every": {
 						"type": "array",
 						"minItems": 1,
-						"items": { "type": "number" }
+						"items": { "type": "integer", "minimum": 0, "maximum": 6 }
 					},
 					"start_time": {
 						"type": "object",
 						"properties": {
-							"hour": { "type": "number" },
-							"minute": { "type": "number" }
+							"hour": { "type": "integer", "minimum": 0, "maximum": 23 },
+							"minute": { "type": "integer", "minimum": 0, "maximum": 59 }
 						},
 						"required": ["hour", "minute"]
 					},
 					"end_time": {
 						"type": "object",
 						"properties": {
-							"hour": { "type": "number" },
-							"minute": { "type": "number" }
+							"hour": { "type": "integer", "minimum": 0, "maximum": 24 },
+							"minute": { "type": "integer", "minimum": 0, "maximum": 59 }
 						},
 						"required": ["hour", "minute"]
+					},
+					"timezone": { "type": "string" },
+					"except": {
+						"type": "array",
+						"items": { "type": "string", "pattern": "^[0-9]{4}-[0-9]{2}-[0-9]{2}$" }
 					}
 				},
 				"required": ["every", "start_time", "end_time"]
 			}
+		},
+		"deploy_slots": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"every": {
+						"type": "array",
+						"minItems": 1,
+						"items": { "type": "integer", "minimum": 0, "maximum": 6 }
+					},
+					"times": {
+						"type": "array",
+						"minItems": 1,
+						"items": {
+							"type": "object",
+							"properties": {
+								"hour": { "type": "integer", "minimum": 0, "maximum": 23 },
+								"minute": { "type": "integer", "minimum": 0, "maximum": 59 }
+							},
+							"required": ["hour", "minute"]
+						}
+					},
+					"timezone": { "type": "string" }
+				},
+				"required": ["every", "times"]
+			}
+		},
+		"deploy_slot_minutes": { "type": "integer", "minimum": 1, "maximum": 1440 },
+		"pipeline": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"name": { "type": "string", "pattern": "^[a-z0-9_-]+$" },
+					"type": { "type": "string", "enum": ["delivery", "verification", "deploy"] },
+					"jobs": {
+						"type": ["array", "null"],
+						"items": { "type": "string" }
+					},
+					"soak_minutes": { "type": "integer", "minimum": 0 },
+					"checks": {
+						"type": "array",
+						"items": {
+							"type": "object",
+							"properties": {
+								"name": { "type": "string", "minLength": 1 },
+								"url": { "type": "string" },
+								"query": { "type": "string" },
+								"max": { "type": "number" }
+							},
+							"required": ["name"],
+							"additionalProperties": false
+						}
+					}
+				},
+				"required": ["name", "type"],
+				"additionalProperties": false
+			}
+		},
+		"branches": {
+			"type": "object",
+			"additionalProperties": {
+				"type": "object",
+				"properties": {
+					"mode": { "type": "string", "enum": ["schedule", "manual"] },
+					"close_time": {
+						"type": "array",
+						"items": { "$ref": "#/properties/close_time/items" }
+					},
+					"jobs": {
+						"type": "object",
+						"additionalProperties": { "type": "array", "items": { "type": "string" } }
+					}
+				},
+				"additionalProperties": false
+			}
+		},
+		"approvals": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"name": { "type": "string", "pattern": "^[a-z0-9_-]+$" },
+					"required": { "type": "integer", "minimum": 1 },
+					"role": { "type": "string" },
+					"users": { "type": "array", "items": { "type": "string" } },
+					"groups": { "type": "array", "items": { "type": "string" } },
+					"paths": { "type": "array", "items": { "type": "string", "minLength": 1 } }
+				},
+				"required": ["name", "required"],
+				"additionalProperties": false
+			}
+		},
+		"expedite": {
+			"type": "object",
+			"properties": {
+				"branch": { "type": "string", "minLength": 1 },
+				"skip_verification": { "type": "boolean" }
+			},
+			"additionalProperties": false
+		},
+		"rollback": {
+			"type": "object",
+			"properties": {
+				"on_deploy_failure": { "type": "boolean" }
+			},
+			"additionalProperties": false
+		},
+		"exclude_commits": { "type": "string", "enum": ["revert", "redeliver"] },
+		"freezes": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"reason": { "type": "string", "minLength": 1 },
+					"start": { "type": "string", "format": "date-time" },
+					"end": { "type": "string", "format": "date-time" },
+					"months": {
+						"type": "array",
+						"items": { "type": "integer", "minimum": 1, "maximum": 12 }
+					},
+					"first_day": { "type": "integer" },
+					"last_day": { "type": "integer" },
+					"timezone": { "type": "string" }
+				},
+				"required": ["reason"]
+			}
 		}
 	},
 	"required": ["close_time"]
@@ -0,0 +1,137 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsBranches(t *testing.T) {
+	phaseTypes := []PhaseType{Delivery, Verification, Deploy}
+	for _, phaseType := range phaseTypes {
+		CustomizeJobs(phaseType, []string{phaseType.String() + "-1", phaseType.String() + "-2"})
+		defer CustomizeJobs(phaseType, nil)
+	}
+
+	options := &Options{}
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"branches": {
+			"hotfix": {"mode": "manual", "close_time": [], "jobs": {"verification": ["smoke"], "deploy": []}},
+			"release": {"close_time": [
+				{"every": [2], "start_time": {"hour": 10, "minute": 0}, "end_time": {"hour": 11, "minute": 0}}
+			]}
+		}
+	}`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, Manual, options.BranchMode("hotfix", Schedule))
+	assert.Equal(t, Schedule, options.BranchMode("release", Schedule))
+	assert.Equal(t, Manual, options.BranchMode("master", Manual))
+
+	assert.Len(t, options.BranchCloseTime("hotfix"), 0)
+	assert.Equal(t, time.Tuesday, options.BranchCloseTime("release")[0].Every[0])
+	assert.Equal(t, options.CloseTime, options.BranchCloseTime("master"))
+
+	assert.Equal(t, []string{"delivery-1", "delivery-2"}, options.JobsForPhase("delivery", "hotfix"))
+	assert.Equal(t, []string{"smoke"}, options.JobsForPhase("verification", "hotfix"))
+	assert.Empty(t, options.JobsForPhase("deploy", "hotfix"))
+	assert.Equal(t, []string{"deploy-1", "deploy-2"}, options.JobsForPhase("deploy", "master"))
+
+	// Overrides survive being saved and loaded.
+	reloaded := &Options{}
+	assert.NoError(t, reloaded.FromString(options.String()))
+	assert.NotNil(t, reloaded.Branches["hotfix"].CloseTime)
+	assert.Len(t, reloaded.BranchCloseTime("hotfix"), 0)
+	assert.Empty(t, reloaded.JobsForPhase("deploy", "hotfix"))
+
+	invalid := []string{
+		`{"mode": "sometimes"}`,
+		`{"jobs": {"build": ["build-1"]}}`,
+		`{"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}}]}`,
+		`{"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0},
+			"timezone": "Mars/Olympus_Mons"}]}`,
+	}
+	for _, branch := range invalid {
+		options := &Options{}
+		err := options.FromString(`{
+			"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+			"branches": {"hotfix": ` + branch + `}
+		}`)
+		assert.Error(t, err, branch)
+	}
+}
+
+func TestOptionsExpedite(t *testing.T) {
+	CustomizeJobs(Verification, []string{"verification-1"})
+	defer CustomizeJobs(Verification, nil)
+
+	options := &Options{}
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"expedite": {"skip_verification": true}
+	}`)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultExpediteBranch, options.Expedite.BranchName())
+
+	reason := "outage"
+	expedited := &Train{Branch: "hotfix", ExpediteReason: &reason}
+	train := &Train{Branch: "hotfix"}
+	assert.True(t, options.SkipsVerification(expedited))
+	assert.False(t, options.SkipsVerification(train))
+	assert.Empty(t, options.TrainJobsForPhase(&Phase{Type: Verification, Train: expedited}))
+	assert.Equal(t, []string{"verification-1"}, options.TrainJobsForPhase(&Phase{Type: Verification, Train: train}))
+
+	options.Expedite.SkipVerification = false
+	assert.Equal(t, []string{"verification-1"}, options.TrainJobsForPhase(&Phase{Type: Verification, Train: expedited}))
+
+	err = options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"expedite": {"branch": ""}
+	}`)
+	assert.Error(t, err)
+}
+
+func TestOptionsRollback(t *testing.T) {
+	options := &Options{}
+	assert.False(t, options.Rollback.OnDeployFailure)
+
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"rollback": {"on_deploy_failure": true}
+	}`)
+	assert.NoError(t, err)
+	assert.True(t, options.Rollback.OnDeployFailure)
+
+	err = options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"rollback": {"on_failure": true}
+	}`)
+	assert.Error(t, err)
+}
+
+func TestOptionsExcludeCommits(t *testing.T) {
+	options := &Options{}
+	assert.True(t, options.ExcludesByRedelivery(), "Redelivery is the default")
+
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"exclude_commits": "redeliver"
+	}`)
+	assert.NoError(t, err)
+	assert.True(t, options.ExcludesByRedelivery())
+
+	err = options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"exclude_commits": "revert"
+	}`)
+	assert.NoError(t, err)
+	assert.False(t, options.ExcludesByRedelivery())
+
+	err = options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"exclude_commits": "cherry-pick"
+	}`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package types
+
+import (
+	"fmt"
+)
+
+// One phase of the pipeline that every train goes through.
+type PhaseConfig struct {
+	Name string `json:"name"`
+	// "delivery", "verification" or "deploy".
+	// Tickets gate the last verification phase, and a train is deploying from its first deploy phase.
+	Type string `json:"type"`
+	// Jobs expected to complete. When unset, phases named after their type expect the jobs for the type,
+	// e.g. DEPLOY_JOBS, and other phases expect none.
+	Jobs []string `json:"jobs"`
+	// How long the phase must run before it can complete, e.g. to watch a canary.
+	SoakMinutes int `json:"soak_minutes,omitempty"`
+	// Polled while the phase runs, and must pass for it to complete.
+	// A failing check stops the train, and rolls production back from a deploy phase.
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// A check of production's health. Set either URL or Query.
+type HealthCheck struct {
+	Name string `json:"name"`
+	// An HTTP endpoint that must respond with a 2xx status.
+	URL string `json:"url,omitempty"`
+	// A Prometheus query, like an error rate or latency, whose value must not go over Max.
+	Query string  `json:"query,omitempty"`
+	Max   float64 `json:"max,omitempty"`
+}
+
+func (check HealthCheck) Validate() error {
+	if (check.URL == "") == (check.Query == "") {
+		return fmt.Errorf("Health check %s needs either a url or a query", check.Name)
+	}
+	return nil
+}
+
+// Phases in the order trains go through them.
+type Pipeline []PhaseConfig
+
+var DefaultPipeline = Pipeline{
+	{Name: "delivery", Type: "delivery"},
+	{Name: "verification", Type: "verification"},
+	{Name: "deploy", Type: "deploy"},
+}
+
+func (config PhaseConfig) PhaseType() PhaseType {
+	phaseType, err := PhaseTypeFromString(config.Type)
+	if err != nil {
+		// Pipelines are validated when options are loaded.
+		panic(err)
+	}
+	return phaseType
+}
+
+// Returns the phase with the name, or nil.
+func (p Pipeline) Phase(name string) *PhaseConfig {
+	for i := range p {
+		if p[i].Name == name {
+			return &p[i]
+		}
+	}
+	return nil
+}
+
+// Phases must be unique, and go from delivery to verification to deploy, with at least one of each.
+func (p Pipeline) Validate() error {
+	seen := make(map[string]bool)
+	last := Delivery - 1
+	for _, config := range p {
+		if seen[config.Name] {
+			return fmt.Errorf("Phase %s is in the pipeline twice", config.Name)
+		}
+		seen[config.Name] = true
+
+		phaseType, err := PhaseTypeFromString(config.Type)
+		if err != nil {
+			return err
+		}
+		if phaseType == RollbackPhase {
+			return fmt.Errorf("Phase %s can't be a rollback phase, rollbacks make their own", config.Name)
+		}
+		if named, err := PhaseTypeFromString(config.Name); err == nil && named != phaseType {
+			return fmt.Errorf("Phase %s must be a %s phase", config.Name, named)
+		}
+		if phaseType < last {
+			return fmt.Errorf("Phase %s is a %s phase, so it can't come after a %s phase",
+				config.Name, phaseType, last)
+		}
+		if phaseType > last+1 {
+			return fmt.Errorf("The pipeline needs a %s phase before phase %s", last+1, config.Name)
+		}
+		last = phaseType
+
+		for _, check := range config.Checks {
+			err = check.Validate()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if last != Deploy {
+		return fmt.Errorf("The pipeline needs a deploy phase")
+	}
+	return nil
+}
+
+func (config PhaseConfig) jobs() []string {
+	if config.Jobs != nil {
+		return config.Jobs
+	}
+	return defaultJobs(config.Name)
+}
+
+// Phases named after their type default to the jobs for the type.
+func defaultJobs(name string) []string {
+	phaseType, err := PhaseTypeFromString(name)
+	if err != nil {
+		return []string{}
+	}
+	return JobsForPhase(phaseType)
+}
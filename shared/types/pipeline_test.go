@@ -0,0 +1,104 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineValidate(t *testing.T) {
+	assert.NoError(t, DefaultPipeline.Validate())
+
+	canary := Pipeline{
+		{Name: "build", Type: "delivery"},
+		{Name: "staging", Type: "verification"},
+		{Name: "canary", Type: "deploy", SoakMinutes: 30, Checks: []HealthCheck{
+			{Name: "status", URL: "http://canary/health"},
+			{Name: "errors", Query: "sum(rate(errors[5m]))", Max: 0.05},
+		}},
+		{Name: "production", Type: "deploy"},
+		{Name: "post-deploy-checks", Type: "deploy"},
+	}
+	assert.NoError(t, canary.Validate())
+
+	invalid := []Pipeline{
+		{},
+		{{Name: "build", Type: "delivery"}, {Name: "staging", Type: "verification"}},
+		{{Name: "staging", Type: "verification"}, {Name: "production", Type: "deploy"}},
+		{{Name: "build", Type: "delivery"}, {Name: "production", Type: "deploy"}},
+		{{Name: "build", Type: "delivery"}, {Name: "production", Type: "deploy"},
+			{Name: "staging", Type: "verification"}},
+		{{Name: "build", Type: "delivery"}, {Name: "build", Type: "verification"},
+			{Name: "production", Type: "deploy"}},
+		{{Name: "build", Type: "delivery"}, {Name: "deploy", Type: "verification"},
+			{Name: "production", Type: "deploy"}},
+		{{Name: "build", Type: "delivery"}, {Name: "staging", Type: "testing"},
+			{Name: "production", Type: "deploy"}},
+		{{Name: "build", Type: "delivery"}, {Name: "staging", Type: "verification"},
+			{Name: "production", Type: "deploy", Checks: []HealthCheck{{Name: "empty"}}}},
+		{{Name: "build", Type: "delivery"}, {Name: "staging", Type: "verification"},
+			{Name: "production", Type: "deploy", Checks: []HealthCheck{{Name: "both", URL: "http://a", Query: "up"}}}},
+		{{Name: "build", Type: "delivery"}, {Name: "staging", Type: "verification"},
+			{Name: "production", Type: "deploy"}, {Name: "undo", Type: "rollback"}},
+	}
+	for _, pipeline := range invalid {
+		assert.Error(t, pipeline.Validate(), "%+v", pipeline)
+	}
+}
+
+func TestOptionsPipeline(t *testing.T) {
+	CustomizeJobs(Deploy, []string{"deploy-1"})
+	defer CustomizeJobs(Deploy, nil)
+
+	options := &Options{}
+	assert.Equal(t, DefaultPipeline, options.PhasePipeline())
+	assert.Equal(t, []string{"deploy-1"}, options.JobsForPhase("deploy", "master"))
+
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"pipeline": [
+			{"name": "delivery", "type": "delivery"},
+			{"name": "verification", "type": "verification", "jobs": []},
+			{"name": "canary", "type": "deploy", "jobs": ["canary-1"], "soak_minutes": 30,
+				"checks": [{"name": "p99", "query": "latency_p99", "max": 0.5}]},
+			{"name": "deploy", "type": "deploy"},
+			{"name": "post-deploy-checks", "type": "deploy"}
+		],
+		"branches": {"hotfix": {"jobs": {"canary": []}}}
+	}`)
+	assert.NoError(t, err)
+
+	assert.Len(t, options.PhasePipeline(), 5)
+	assert.Empty(t, options.JobsForPhase("verification", "master"))
+	assert.Equal(t, []string{"canary-1"}, options.JobsForPhase("canary", "master"))
+	assert.Empty(t, options.JobsForPhase("canary", "hotfix"))
+	assert.Equal(t, []string{"deploy-1"}, options.JobsForPhase("deploy", "master"))
+	assert.Empty(t, options.JobsForPhase("post-deploy-checks", "master"))
+	assert.Equal(t, 30*time.Minute, options.PhaseSoak("canary"))
+	assert.Equal(t, time.Duration(0), options.PhaseSoak("deploy"))
+	assert.Equal(t, []HealthCheck{{Name: "p99", Query: "latency_p99", Max: 0.5}}, options.PhaseChecks("canary"))
+	assert.Empty(t, options.PhaseChecks("deploy"))
+
+	// Explicitly empty jobs survive being saved and loaded.
+	reloaded := &Options{}
+	assert.NoError(t, reloaded.FromString(options.String()))
+	assert.Empty(t, reloaded.JobsForPhase("verification", "master"))
+	assert.Equal(t, []string{"deploy-1"}, reloaded.JobsForPhase("deploy", "master"))
+
+	invalid := []string{
+		`"pipeline": [{"name": "delivery", "type": "delivery"}, {"name": "deploy", "type": "deploy"}]`,
+		`"pipeline": [{"name": "Build", "type": "delivery"}]`,
+		`"branches": {"hotfix": {"jobs": {"canary": []}}}`,
+		`"pipeline": [{"name": "delivery", "type": "delivery"}, {"name": "verification", "type": "verification"},
+			{"name": "deploy", "type": "deploy", "checks": [{"name": "p99", "max": 0.5}]}]`,
+	}
+	for _, option := range invalid {
+		options := &Options{}
+		err := options.FromString(`{
+			"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+			` + option + `
+		}`)
+		assert.Error(t, err, option)
+	}
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// How long trains can start deploying after a slot's time, unless set in the options.
+const DefaultDeploySlotMinutes = 15
+
+// Times trains start deploying on some days of every week, like 10:00, 14:00 and 16:00 on weekdays.
+type DeploySlot struct {
+	Every []time.Weekday `json:"every"`
+	Times []Clock        `json:"times"`
+	// IANA timezone of the times. Defaults to the Conductor timezone.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type DeploySlots []DeploySlot
+
+// The times trains can start deploying, from each slot's time until minutes after.
+func (slot DeploySlot) intervals(minutes int) RepeatingTimeIntervals {
+	intervals := make(RepeatingTimeIntervals, len(slot.Times))
+	for i, start := range slot.Times {
+		end := (start.minutes() + minutes) % (24 * 60)
+		intervals[i] = RepeatingTimeInterval{
+			Every:     slot.Every,
+			StartTime: start,
+			EndTime:   Clock{Hour: end / 60, Minute: end % 60},
+			Timezone:  slot.Timezone,
+		}
+	}
+	return intervals
+}
+
+func (slot DeploySlot) Validate() error {
+	if len(slot.Every) == 0 || len(slot.Times) == 0 {
+		return fmt.Errorf("Deploy slots need days and times")
+	}
+	for _, interval := range slot.intervals(0) {
+		err := interval.Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Whether trains can start deploying at the given time.
+// With no slots, trains can always deploy.
+func (slots DeploySlots) Includes(t time.Time, minutes int) bool {
+	if len(slots) == 0 {
+		return true
+	}
+	for _, slot := range slots {
+		for _, interval := range slot.intervals(minutes) {
+			if interval.Includes(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the first slot time after the given time, or nil if there are no slots.
+func (slots DeploySlots) Next(t time.Time) *time.Time {
+	var next *time.Time
+	for _, slot := range slots {
+		for _, interval := range slot.intervals(0) {
+			location := interval.Location()
+			year, month, day := t.In(location).Date()
+			// Slots repeat weekly, so one must be within the next week, unless all of its days are skipped.
+			for i := 0; i <= 7; i++ {
+				date := time.Date(year, month, day+i, 0, 0, 0, 0, location)
+				start := interval.StartTime.on(year, month, day+i, location)
+				if !interval.startsOn(date) || !start.After(t) {
+					continue
+				}
+				if next == nil || start.Before(*next) {
+					next = &start
+				}
+				break
+			}
+		}
+	}
+	return next
+}
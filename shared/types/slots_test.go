@@ -0,0 +1,86 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 10:00, 14:00 and 16:00 on weekdays.
+var weekdaySlots = DeploySlots{
+	{
+		Every: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Times: []Clock{{Hour: 10}, {Hour: 14}, {Hour: 16}},
+	},
+}
+
+func TestDeploySlotsIncludes(t *testing.T) {
+	// Monday.
+	assert.False(t, weekdaySlots.Includes(time.Date(2019, time.December, 30, 9, 59, 0, 0, time.Local), 15))
+	assert.True(t, weekdaySlots.Includes(time.Date(2019, time.December, 30, 10, 0, 0, 0, time.Local), 15))
+	assert.True(t, weekdaySlots.Includes(time.Date(2019, time.December, 30, 10, 15, 0, 0, time.Local), 15))
+	assert.False(t, weekdaySlots.Includes(time.Date(2019, time.December, 30, 10, 16, 0, 0, time.Local), 15))
+	assert.True(t, weekdaySlots.Includes(time.Date(2019, time.December, 30, 16, 5, 0, 0, time.Local), 15))
+	// Saturday.
+	assert.False(t, weekdaySlots.Includes(time.Date(2020, time.January, 4, 10, 0, 0, 0, time.Local), 15))
+
+	assert.True(t, DeploySlots{}.Includes(time.Date(2020, time.January, 4, 3, 0, 0, 0, time.Local), 15))
+}
+
+func TestDeploySlotsNext(t *testing.T) {
+	// Monday morning.
+	next := weekdaySlots.Next(time.Date(2019, time.December, 30, 8, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2019, time.December, 30, 10, 0, 0, 0, time.Local), *next)
+
+	next = weekdaySlots.Next(time.Date(2019, time.December, 30, 10, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2019, time.December, 30, 14, 0, 0, 0, time.Local), *next)
+
+	// Friday evening, to Monday morning.
+	next = weekdaySlots.Next(time.Date(2020, time.January, 3, 17, 0, 0, 0, time.Local))
+	assert.Equal(t, time.Date(2020, time.January, 6, 10, 0, 0, 0, time.Local), *next)
+
+	assert.Nil(t, DeploySlots{}.Next(time.Now()))
+}
+
+func TestDeploySlotsTimezone(t *testing.T) {
+	slots := DeploySlots{
+		{Every: []time.Weekday{time.Monday}, Times: []Clock{{Hour: 10}}, Timezone: "Asia/Kolkata"},
+	}
+	// 10:00 in India is 04:30 UTC.
+	assert.True(t, slots.Includes(time.Date(2019, time.December, 30, 4, 30, 0, 0, time.UTC), 15))
+	assert.False(t, slots.Includes(time.Date(2019, time.December, 30, 10, 0, 0, 0, time.UTC), 15))
+
+	next := slots.Next(time.Date(2019, time.December, 30, 0, 0, 0, 0, time.UTC))
+	assert.True(t, time.Date(2019, time.December, 30, 4, 30, 0, 0, time.UTC).Equal(*next))
+}
+
+func TestOptionsDeploySlots(t *testing.T) {
+	options := &Options{}
+	err := options.FromString(`{
+		"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+		"deploy_slots": [{"every": [1, 2, 3, 4, 5], "times": [{"hour": 10, "minute": 0}, {"hour": 14, "minute": 0}]}],
+		"deploy_slot_minutes": 30
+	}`)
+	assert.NoError(t, err)
+	assert.Len(t, options.DeploySlots, 1)
+	assert.Len(t, options.DeploySlots[0].Times, 2)
+	assert.Equal(t, 30, options.deploySlotMinutes())
+	assert.Equal(t, DefaultDeploySlotMinutes, Options{}.deploySlotMinutes())
+	assert.Nil(t, Options{}.NextDeploySlot())
+
+	invalid := []string{
+		`{"every": [], "times": [{"hour": 10, "minute": 0}]}`,
+		`{"every": [1], "times": []}`,
+		`{"every": [1], "times": [{"hour": 24, "minute": 0}]}`,
+		`{"every": [1], "times": [{"hour": 10, "minute": 0}], "timezone": "Mars/Olympus_Mons"}`,
+	}
+	for _, slot := range invalid {
+		options := &Options{}
+		err := options.FromString(`{
+			"close_time": [{"every": [1], "start_time": {"hour": 9, "minute": 0}, "end_time": {"hour": 17, "minute": 0}}],
+			"deploy_slots": [` + slot + `]
+		}`)
+		assert.Error(t, err, slot)
+	}
+}
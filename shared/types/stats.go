@@ -0,0 +1,195 @@
+package types
+
+import (
+	"sort"
+	"time"
+)
+
+// Durations are in seconds.
+// RegularHours and AfterHours split Mean by the CloseTime intervals,
+// so RegularHours + AfterHours == Mean.
+type DurationStats struct {
+	Count        int     `json:"count"`
+	Mean         float64 `json:"mean"`
+	Median       float64 `json:"median"`
+	RegularHours float64 `json:"regular_hours_mean"`
+	AfterHours   float64 `json:"after_hours_mean"`
+}
+
+// DORA-style release metrics over a date range.
+type ReleaseStats struct {
+	Params map[string]string `json:"params"`
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+
+	Deployments             int     `json:"deployments"`
+	RegularHoursDeployments int     `json:"regular_hours_deployments"`
+	AfterHoursDeployments   int     `json:"after_hours_deployments"`
+	DeploymentsPerDay       float64 `json:"deployments_per_day"`
+
+	Cancellations int `json:"cancellations"`
+	Rollbacks     int `json:"rollbacks"`
+	// (rollbacks + cancellations) / (deployments + cancellations).
+	ChangeFailureRate float64 `json:"change_failure_rate"`
+
+	// From when Conductor first saw a commit, its CreatedAt, to when its train deployed.
+	// That's when the commit was pushed and picked up, not when it was authored,
+	// which Conductor doesn't store, so lead time is shorter than it would be measured from git.
+	LeadTime DurationStats `json:"lead_time"`
+	// From train creation to deploy.
+	TrainLifetime DurationStats `json:"train_lifetime"`
+	// From the deploy of a train to the completion of a successful rollback away from it.
+	TimeToRestore DurationStats `json:"time_to_restore"`
+	// Keyed by phase name, for phases of the active phase group.
+	PhaseDurations map[string]DurationStats `json:"phase_durations"`
+}
+
+type durationAccumulator struct {
+	hours        RepeatingTimeIntervals
+	durations    []time.Duration
+	regularHours time.Duration
+}
+
+func (acc *durationAccumulator) add(start, end time.Time) {
+	if end.Before(start) {
+		return
+	}
+	acc.durations = append(acc.durations, end.Sub(start))
+	acc.regularHours += acc.hours.TotalOverlap(start, end)
+}
+
+func (acc *durationAccumulator) stats() DurationStats {
+	count := len(acc.durations)
+	if count == 0 {
+		return DurationStats{}
+	}
+
+	sorted := make([]time.Duration, count)
+	copy(sorted, acc.durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, duration := range sorted {
+		total += duration
+	}
+
+	median := sorted[count/2]
+	if count%2 == 0 {
+		median = (sorted[count/2-1] + sorted[count/2]) / 2
+	}
+
+	mean := total.Seconds() / float64(count)
+	regularHours := acc.regularHours.Seconds() / float64(count)
+	return DurationStats{
+		Count:        count,
+		Mean:         mean,
+		Median:       median.Seconds(),
+		RegularHours: regularHours,
+		AfterHours:   mean - regularHours,
+	}
+}
+
+func inRange(t Time, start, end time.Time) bool {
+	return t.HasValue() && !t.Value.Before(start) && t.Value.Before(end)
+}
+
+// Computes release stats for trains and rollbacks between start and end.
+// Trains are counted by when they were deployed or cancelled, rollbacks by when they were initiated.
+// Trains need Commits and ActivePhases loaded, and rollbacks need FromTrain loaded.
+func ComputeReleaseStats(
+	trains []*Train, rollbacks []*Rollback, hours RepeatingTimeIntervals, start, end time.Time) *ReleaseStats {
+
+	stats := &ReleaseStats{
+		Start:          start,
+		End:            end,
+		PhaseDurations: make(map[string]DurationStats),
+	}
+
+	leadTime := &durationAccumulator{hours: hours}
+	trainLifetime := &durationAccumulator{hours: hours}
+	timeToRestore := &durationAccumulator{hours: hours}
+	phaseDurations := make(map[string]*durationAccumulator)
+
+	seenCommits := make(map[uint64]bool)
+	for _, train := range trains {
+		if train.IsCancelled() {
+			if inRange(train.CancelledAt, start, end) {
+				stats.Cancellations++
+			}
+			continue
+		}
+		if !inRange(train.DeployedAt, start, end) {
+			continue
+		}
+		deployedAt := train.DeployedAt.Value
+
+		stats.Deployments++
+		inRegularHours := false
+		for _, interval := range hours {
+			if interval.Includes(deployedAt) {
+				inRegularHours = true
+			}
+		}
+		if inRegularHours {
+			stats.RegularHoursDeployments++
+		} else {
+			stats.AfterHoursDeployments++
+		}
+
+		trainLifetime.add(train.CreatedAt.Value, deployedAt)
+
+		// A commit can be on several trains, but only deploys once.
+		for _, commit := range train.Commits {
+			if seenCommits[commit.ID] || !commit.CreatedAt.HasValue() {
+				continue
+			}
+			seenCommits[commit.ID] = true
+			leadTime.add(commit.CreatedAt.Value, deployedAt)
+		}
+
+		if train.ActivePhases != nil {
+			for _, phase := range train.ActivePhases.Phases() {
+				if phase == nil || !phase.StartedAt.HasValue() || !phase.CompletedAt.HasValue() {
+					continue
+				}
+				acc, ok := phaseDurations[phase.name()]
+				if !ok {
+					acc = &durationAccumulator{hours: hours}
+					phaseDurations[phase.name()] = acc
+				}
+				acc.add(phase.StartedAt.Value, phase.CompletedAt.Value)
+			}
+		}
+	}
+
+	for _, rollback := range rollbacks {
+		if !inRange(rollback.CreatedAt, start, end) {
+			continue
+		}
+		stats.Rollbacks++
+		// Service is only restored once a rollback succeeds.
+		if rollback.Status == RollbackSucceeded && rollback.CompletedAt.HasValue() &&
+			rollback.FromTrain != nil && rollback.FromTrain.IsDeployed() {
+			timeToRestore.add(rollback.FromTrain.DeployedAt.Value, rollback.CompletedAt.Value)
+		}
+	}
+
+	days := end.Sub(start).Hours() / 24
+	if days > 0 {
+		stats.DeploymentsPerDay = float64(stats.Deployments) / days
+	}
+
+	attempts := stats.Deployments + stats.Cancellations
+	if attempts > 0 {
+		stats.ChangeFailureRate = float64(stats.Rollbacks+stats.Cancellations) / float64(attempts)
+	}
+
+	stats.LeadTime = leadTime.stats()
+	stats.TrainLifetime = trainLifetime.stats()
+	stats.TimeToRestore = timeToRestore.stats()
+	for name, acc := range phaseDurations {
+		stats.PhaseDurations[name] = acc.stats()
+	}
+
+	return stats
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComputeReleaseStats(t *testing.T) {
+	// Monday.
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+	end := start.AddDate(0, 0, 7)
+	at := func(day, hour int) Time {
+		return Time{start.AddDate(0, 0, day).Add(time.Duration(hour) * time.Hour)}
+	}
+
+	sharedCommit := &Commit{ID: 1, CreatedAt: at(0, 9)}
+	lateCommit := &Commit{ID: 2, CreatedAt: at(0, 16)}
+
+	// Deployed during regular hours.
+	train1 := &Train{
+		CreatedAt:  at(0, 10),
+		DeployedAt: at(0, 12),
+		Commits:    []*Commit{sharedCommit},
+		ActivePhases: &PhaseGroup{
+			Deploy: &Phase{Type: Deploy, StartedAt: at(0, 11), CompletedAt: at(0, 12)},
+		},
+	}
+	// Deployed after hours.
+	train2 := &Train{
+		CreatedAt:  at(0, 16),
+		DeployedAt: at(0, 20),
+		Commits:    []*Commit{sharedCommit, lateCommit},
+	}
+	cancelled := &Train{CreatedAt: at(1, 9), CancelledAt: at(1, 10)}
+	outOfRange := &Train{CreatedAt: at(-3, 9), DeployedAt: at(-3, 10)}
+
+	rollbacks := []*Rollback{
+		{CreatedAt: at(0, 21), CompletedAt: at(0, 22), Status: RollbackSucceeded, FromTrain: train2},
+		// Failed rollbacks count, but don't restore service.
+		{CreatedAt: at(0, 23), CompletedAt: at(0, 23), Status: RollbackFailed, FromTrain: train1},
+		{CreatedAt: at(8, 10), FromTrain: train2},
+	}
+
+	stats := ComputeReleaseStats(
+		[]*Train{train1, train2, cancelled, outOfRange}, rollbacks, defaultCloseTime, start, end)
+
+	assert.Equal(t, 2, stats.Deployments)
+	assert.Equal(t, 1, stats.RegularHoursDeployments)
+	assert.Equal(t, 1, stats.AfterHoursDeployments)
+	assert.InDelta(t, 2.0/7, stats.DeploymentsPerDay, 0.0001)
+	assert.Equal(t, 1, stats.Cancellations)
+	assert.Equal(t, 2, stats.Rollbacks)
+	assert.InDelta(t, 3.0/3, stats.ChangeFailureRate, 0.0001)
+
+	hour := time.Hour.Seconds()
+
+	// The shared commit only counts for its first deploy.
+	assert.Equal(t, 2, stats.LeadTime.Count)
+	assert.Equal(t, 3.5*hour, stats.LeadTime.Mean)
+	assert.Equal(t, 3.5*hour, stats.LeadTime.Median)
+	assert.Equal(t, 2*hour, stats.LeadTime.RegularHours)
+	assert.Equal(t, 1.5*hour, stats.LeadTime.AfterHours)
+
+	assert.Equal(t, 2, stats.TrainLifetime.Count)
+	assert.Equal(t, 3*hour, stats.TrainLifetime.Mean)
+	assert.Equal(t, 1.5*hour, stats.TrainLifetime.RegularHours)
+
+	// From the deploy to the rollback completing.
+	assert.Equal(t, 1, stats.TimeToRestore.Count)
+	assert.Equal(t, 2*hour, stats.TimeToRestore.Mean)
+	assert.Equal(t, 2*hour, stats.TimeToRestore.AfterHours)
+
+	assert.Len(t, stats.PhaseDurations, 1)
+	assert.Equal(t, 1, stats.PhaseDurations["deploy"].Count)
+	assert.Equal(t, hour, stats.PhaseDurations["deploy"].Mean)
+}
+
+func TestComputeReleaseStatsEmpty(t *testing.T) {
+	start := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local)
+	stats := ComputeReleaseStats(nil, nil, defaultCloseTime, start, start.AddDate(0, 0, 1))
+
+	assert.Equal(t, 0, stats.Deployments)
+	assert.Equal(t, 0.0, stats.ChangeFailureRate)
+	assert.Equal(t, DurationStats{}, stats.LeadTime)
+	assert.Empty(t, stats.PhaseDurations)
+}
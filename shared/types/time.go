@@ -1,7 +1,9 @@
 package types
 
 import (
+	"fmt"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -9,10 +11,20 @@ type TimeInterval interface {
 	Includes(time.Time) bool
 }
 
+// An interval on some days of every week, like M-F 9-5.
 type RepeatingTimeInterval struct {
+	// The days the interval starts on.
 	Every     []time.Weekday `json:"every"`
 	StartTime Clock          `json:"start_time"`
-	EndTime   Clock          `json:"end_time"`
+	// An end time before the start time ends the interval the next day, e.g. 22:00-06:00.
+	EndTime Clock `json:"end_time"`
+
+	// IANA timezone of the clock times, like America/Los_Angeles.
+	// Defaults to the Conductor timezone.
+	Timezone string `json:"timezone,omitempty"`
+	// Dates to skip the interval on, like holidays, as YYYY-MM-DD in the interval's timezone.
+	// Overnight intervals are skipped if they'd start on one of these dates.
+	Except []string `json:"except,omitempty"`
 }
 
 type RepeatingTimeIntervals []RepeatingTimeInterval
@@ -22,38 +34,112 @@ type Clock struct {
 	Minute int `json:"minute"`
 }
 
-type Moment interface {
-	Weekday() time.Weekday
-	Clock() (hour, minute, second int)
+const dateFormat = "2006-01-02"
+
+func (clock Clock) minutes() int {
+	return clock.Hour*60 + clock.Minute
 }
 
-// Includes checks if the given time is in this RepeatingTimeInterval.
-func (interval RepeatingTimeInterval) Includes(testTime Moment) bool {
+func (clock Clock) on(year int, month time.Month, day int, location *time.Location) time.Time {
+	return time.Date(year, month, day, clock.Hour, clock.Minute, 0, 0, location)
+}
+
+var (
+	locations     = make(map[string]*time.Location)
+	locationsLock sync.Mutex
+)
+
+// The timezone of the interval.
+// Falls back to the Conductor timezone if it can't be loaded, which Validate reports.
+func (interval RepeatingTimeInterval) Location() *time.Location {
+	return location(interval.Timezone)
+}
+
+// Loads an IANA timezone, or the Conductor timezone if it's empty or can't be loaded.
+func location(timezone string) *time.Location {
+	if timezone == "" {
+		return time.Local
+	}
+
+	locationsLock.Lock()
+	defer locationsLock.Unlock()
+
+	location, ok := locations[timezone]
+	if !ok {
+		var err error
+		location, err = time.LoadLocation(timezone)
+		if err != nil {
+			location = time.Local
+		}
+		locations[timezone] = location
+	}
+	return location
+}
+
+func (interval RepeatingTimeInterval) Validate() error {
+	if interval.StartTime.Hour < 0 || interval.StartTime.Hour > 23 ||
+		interval.StartTime.Minute < 0 || interval.StartTime.Minute > 59 {
+		return fmt.Errorf("Bad start time: %02d:%02d", interval.StartTime.Hour, interval.StartTime.Minute)
+	}
+	// 24:00 ends an interval at midnight.
+	if interval.EndTime.minutes() < 0 || interval.EndTime.minutes() > 24*60 ||
+		interval.EndTime.Minute < 0 || interval.EndTime.Minute > 59 {
+		return fmt.Errorf("Bad end time: %02d:%02d", interval.EndTime.Hour, interval.EndTime.Minute)
+	}
+	if interval.Timezone != "" {
+		_, err := time.LoadLocation(interval.Timezone)
+		if err != nil {
+			return fmt.Errorf("Bad timezone %s: %v", interval.Timezone, err)
+		}
+	}
+	for _, date := range interval.Except {
+		_, err := time.Parse(dateFormat, date)
+		if err != nil {
+			return fmt.Errorf("Bad except date %s. Must be YYYY-MM-DD.", date)
+		}
+	}
+	return nil
+}
+
+func (interval RepeatingTimeInterval) isOvernight() bool {
+	return interval.EndTime.minutes() < interval.StartTime.minutes()
+}
+
+// Whether the interval starts on the date of the given time.
+func (interval RepeatingTimeInterval) startsOn(date time.Time) bool {
+	startsOnWeekday := false
 	for _, weekday := range interval.Every {
-		if testTime.Weekday() == weekday {
-			testHour, testMinute, _ := testTime.Clock()
-			if testHour < interval.StartTime.Hour {
-				return false
-			}
-			if testHour < interval.StartTime.Hour || testHour > interval.EndTime.Hour {
-				return false
-			}
-			if testHour == interval.StartTime.Hour {
-				// Same as start hour, test minute.
-				if testMinute < interval.StartTime.Minute {
-					return false
-				}
-			}
-			if testHour == interval.EndTime.Hour {
-				// Same as end hour, test minute.
-				if testMinute > interval.EndTime.Minute {
-					return false
-				}
-			}
-			return true
+		if date.Weekday() == weekday {
+			startsOnWeekday = true
+		}
+	}
+	if !startsOnWeekday {
+		return false
+	}
+
+	formatted := date.Format(dateFormat)
+	for _, except := range interval.Except {
+		if except == formatted {
+			return false
 		}
 	}
-	return false
+	return true
+}
+
+// Includes checks if the given time is in this RepeatingTimeInterval.
+// The end time is included, to the minute.
+func (interval RepeatingTimeInterval) Includes(testTime time.Time) bool {
+	testTime = testTime.In(interval.Location())
+	minute := testTime.Hour()*60 + testTime.Minute()
+	start := interval.StartTime.minutes()
+	end := interval.EndTime.minutes()
+
+	if !interval.isOvernight() {
+		return interval.startsOn(testTime) && minute >= start && minute <= end
+	}
+	// Either the evening of a day the interval starts on, or the morning after.
+	return (interval.startsOn(testTime) && minute >= start) ||
+		(interval.startsOn(testTime.AddDate(0, 0, -1)) && minute <= end)
 }
 
 type Interval struct {
@@ -61,19 +147,6 @@ type Interval struct {
 	end   time.Time
 }
 
-func (interval Interval) WithDate(year int, month time.Month, day int) Interval {
-	return Interval{
-		start: time.Date(
-			year, month, day,
-			interval.start.Hour(), interval.start.Minute(),
-			0, 0, interval.start.Location()),
-		end: time.Date(
-			year, month, day,
-			interval.end.Hour(), interval.end.Minute(),
-			0, 0, interval.end.Location()),
-	}
-}
-
 type Intervals []Interval
 
 func (intervals Intervals) Len() int {
@@ -83,156 +156,70 @@ func (intervals Intervals) Swap(i, j int) {
 	intervals[i], intervals[j] = intervals[j], intervals[i]
 }
 func (intervals Intervals) Less(i, j int) bool {
-	return intervals[i].start.Sub(intervals[j].start) < 0
-}
-
-func (repeatingTimeIntervals RepeatingTimeIntervals) weekdayIntervals() map[time.Weekday][]Interval {
-	// Start by calculating the minimal set of intervals for the weekdays.
-	weekdayToInterval := make(map[time.Weekday][]Interval)
-	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
-		intervals := make([]Interval, 0)
-		for _, interval := range repeatingTimeIntervals {
-			includesWeekday := false
-			for _, every := range interval.Every {
-				if every == weekday {
-					includesWeekday = true
-				}
-			}
-			if !includesWeekday {
-				continue
-			}
+	return intervals[i].start.Before(intervals[j].start)
+}
 
-			intervals = append(intervals, Interval{
-				start: time.Date(0, 0, 0, interval.StartTime.Hour, interval.StartTime.Minute, 0, 0, time.Local),
-				end:   time.Date(0, 0, 0, interval.EndTime.Hour, interval.EndTime.Minute, 0, 0, time.Local),
-			})
-		}
+// Returns each time the interval happens that overlaps start to end, cut to start and end.
+func (interval RepeatingTimeInterval) occurrences(start time.Time, end time.Time) Intervals {
+	location := interval.Location()
+	start = start.In(location)
+	end = end.In(location)
 
-		// Sort and merge intervals for the weekday.
-		// Sort by descending order of start time.
-		sort.Sort(sort.Reverse(Intervals(intervals)))
-
-		// Merge intervals as much as possible.
-		mergedIntervals := make([]Interval, 0)
-		mergedIntervalIndex := 0
-		for i, interval := range intervals {
-			if i == 0 {
-				mergedIntervals = append(mergedIntervals, interval)
-			} else {
-				previousInterval := mergedIntervals[i-1]
-				doesOverlap := interval.end.Sub(previousInterval.start) > 0
-				if doesOverlap {
-					previousEndsEarlier := interval.end.Sub(previousInterval.end) > 0
-					if previousEndsEarlier {
-						mergedIntervals[i-1] = interval
-					} else {
-						mergedIntervals[i-1] = Interval{
-							start: interval.start,
-							end:   previousInterval.end,
-						}
-					}
-				} else {
-					mergedIntervals = append(mergedIntervals, interval)
-					mergedIntervalIndex += 1
-				}
+	occurrences := make(Intervals, 0)
+	// Start the day before, in case an overnight interval runs into the start.
+	startYear, startMonth, startDay := start.Date()
+	day := time.Date(startYear, startMonth, startDay-1, 0, 0, 0, 0, location)
+	for !day.After(end) {
+		if interval.startsOn(day) {
+			year, month, date := day.Date()
+			occurrence := Interval{
+				start: interval.StartTime.on(year, month, date, location),
+				end:   interval.EndTime.on(year, month, date, location),
+			}
+			if interval.isOvernight() {
+				occurrence.end = interval.EndTime.on(year, month, date+1, location)
+			}
+			if occurrence.start.Before(start) {
+				occurrence.start = start
+			}
+			if occurrence.end.After(end) {
+				occurrence.end = end
+			}
+			if occurrence.end.After(occurrence.start) {
+				occurrences = append(occurrences, occurrence)
 			}
 		}
-
-		// Store this set of intervals on the weekday.
-		weekdayToInterval[weekday] = mergedIntervals
-	}
-
-	return weekdayToInterval
-}
-
-// Computes the time overlap between a specified array of intervals and
-// a specified start and end time.
-// Intervals must be sorted in descending order of start time.
-// Start and end times must be on the same date.
-// Start and end time are optional.
-// If start time is nil, start time is assumed the very beginning of the day.
-// If end time is nil, end time is assumed the very end of the day.
-func timeOverlap(intervals []Interval, start *time.Time, end *time.Time) time.Duration {
-	var year int
-	var month time.Month
-	var day int
-
-	if start != nil {
-		year, month, day = start.Date()
-	} else if end != nil {
-		year, month, day = end.Date()
-	}
-
-	var totalOverlap time.Duration = 0
-	for _, interval := range intervals {
-		datedInterval := interval.WithDate(year, month, day)
-		testStart := datedInterval.start
-		if start != nil && datedInterval.start.Sub(*start) < 0 {
-			testStart = *start
-		}
-		testEnd := datedInterval.end
-		if end != nil && datedInterval.end.Sub(*end) > 0 {
-			testEnd = *end
-		}
-		overlap := testEnd.Sub(testStart)
-		if overlap > 0 {
-			totalOverlap += overlap
-		}
+		year, month, date := day.Date()
+		day = time.Date(year, month, date+1, 0, 0, 0, 0, location)
 	}
-
-	return totalOverlap
+	return occurrences
 }
 
 // TotalOverlap calculates the total overlap duration between the specified start and end times.
+// Time covered by more than one interval is only counted once.
 func (repeatingTimeIntervals RepeatingTimeIntervals) TotalOverlap(start time.Time, end time.Time) time.Duration {
-	start = start.In(time.Local)
-	end = end.In(time.Local)
-
 	if end.Sub(start) < 0 {
 		// End is before start, so no overlap.
 		return 0
 	}
 
-	weekdayToInterval := repeatingTimeIntervals.weekdayIntervals()
-	// Iterate through each day between start and end time and calculate totalOverlap, summing it all up.
-	//
-	// If the start time and the end time are the same date, the totalOverlap is:
-	//   `min(interval_end, end_time) - max(interval_start, start_time)` summed for each interval.
-	//
-	// Otherwise, the totalOverlap happens over multiple days.
-	// For the first day, the totalOverlap is `interval_end - max(interval_start, start_time)` summed for each interval.
-	// For the end day, the totalOverlap is `min(interval_end, end_time) - interval_start` summed for each interval.
-	// For all middle days, the totalOverlap is `interval_end - interval_start` summed for each interval.
-	var totalOverlap time.Duration = 0
-
-	startYear, startMonth, startDay := start.Date()
-	endYear, endMonth, endDay := end.Date()
-	if startYear == endYear && startMonth == endMonth && startDay == endDay {
-		// Start and end times are on the same date.
-		// Find the relevant day intervals.
-		totalOverlap += timeOverlap(weekdayToInterval[start.Weekday()], &start, &end)
-	} else {
-		// Start day.
-		totalOverlap += timeOverlap(weekdayToInterval[start.Weekday()], &start, nil)
-
-		// Middle days. Start one day ahead of start day, iterate through each day.
-		middleTime := time.Date(startYear, startMonth, startDay, 0, 0, 0, 0, start.Location())
-		middleTime = middleTime.AddDate(0, 0, 1)
-		for {
-			middleYear, middleMonth, middleDay := middleTime.Date()
-			if middleYear == endYear && middleMonth == endMonth && middleDay == endDay {
-				// On the end day.
-				break
-			}
-
-			totalOverlap += timeOverlap(weekdayToInterval[middleTime.Weekday()], nil, nil)
+	occurrences := make(Intervals, 0)
+	for _, interval := range repeatingTimeIntervals {
+		occurrences = append(occurrences, interval.occurrences(start, end)...)
+	}
+	sort.Sort(occurrences)
 
-			// Go forward one day.
-			middleTime = middleTime.AddDate(0, 0, 1)
+	// Sum the occurrences, merging overlapping ones.
+	var totalOverlap time.Duration = 0
+	var covered time.Time
+	for _, occurrence := range occurrences {
+		if occurrence.start.Before(covered) {
+			occurrence.start = covered
+		}
+		if occurrence.end.After(occurrence.start) {
+			totalOverlap += occurrence.end.Sub(occurrence.start)
+			covered = occurrence.end
 		}
-
-		// End day.
-		totalOverlap += timeOverlap(weekdayToInterval[end.Weekday()], nil, &end)
 	}
 
 	return totalOverlap
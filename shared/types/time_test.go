@@ -13,37 +13,34 @@ type TestTime struct {
 	Minute         int
 }
 
-func (t TestTime) Weekday() time.Weekday {
-	return t.CurrentWeekday
-}
-
-func (t TestTime) Clock() (int, int, int) {
-	return t.Hour, t.Minute, 0
+// The time on the weekday in the week of Sunday, Dec 29th, 2019.
+func (t TestTime) Time() time.Time {
+	return time.Date(2019, 12, 29+int(t.CurrentWeekday), t.Hour, t.Minute, 0, 0, time.Local)
 }
 
 func TestInSameStartHour(t *testing.T) {
 	interval := RepeatingTimeInterval{
-		[]time.Weekday{time.Sunday},
-		Clock{0, 30},
-		Clock{1, 0},
+		Every:     []time.Weekday{time.Sunday},
+		StartTime: Clock{0, 30},
+		EndTime:   Clock{1, 0},
 	}
 
 	var testTime TestTime
 
 	testTime = TestTime{time.Sunday, 0, 0}
-	if interval.Includes(testTime) {
+	if interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 0, 30}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 0, 59}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
@@ -51,21 +48,21 @@ func TestInSameStartHour(t *testing.T) {
 
 func TestInSameEndHour(t *testing.T) {
 	interval := RepeatingTimeInterval{
-		[]time.Weekday{time.Sunday},
-		Clock{0, 30},
-		Clock{1, 0},
+		Every:     []time.Weekday{time.Sunday},
+		StartTime: Clock{0, 30},
+		EndTime:   Clock{1, 0},
 	}
 
 	var testTime TestTime
 
 	testTime = TestTime{time.Sunday, 1, 0}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 1, 30}
-	if interval.Includes(testTime) {
+	if interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was detected as including %v.",
 			interval, testTime)
 	}
@@ -73,33 +70,33 @@ func TestInSameEndHour(t *testing.T) {
 
 func TestInBothHours(t *testing.T) {
 	interval := RepeatingTimeInterval{
-		[]time.Weekday{time.Sunday},
-		Clock{2, 0},
-		Clock{2, 30},
+		Every:     []time.Weekday{time.Sunday},
+		StartTime: Clock{2, 0},
+		EndTime:   Clock{2, 30},
 	}
 
 	var testTime TestTime
 
 	testTime = TestTime{time.Sunday, 2, 0}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 2, 15}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 2, 30}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 
 	testTime = TestTime{time.Sunday, 2, 45}
-	if interval.Includes(testTime) {
+	if interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was detected as including %v.",
 			interval, testTime)
 	}
@@ -107,15 +104,15 @@ func TestInBothHours(t *testing.T) {
 
 func TestDifferentDay(t *testing.T) {
 	interval := RepeatingTimeInterval{
-		[]time.Weekday{time.Sunday},
-		Clock{3, 0},
-		Clock{3, 59},
+		Every:     []time.Weekday{time.Sunday},
+		StartTime: Clock{3, 0},
+		EndTime:   Clock{3, 59},
 	}
 
 	var testTime TestTime
 
 	testTime = TestTime{time.Monday, 3, 0}
-	if interval.Includes(testTime) {
+	if interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was detected as including %v.",
 			interval, testTime)
 	}
@@ -123,198 +120,20 @@ func TestDifferentDay(t *testing.T) {
 
 func TestDifferentDayMatching(t *testing.T) {
 	interval := RepeatingTimeInterval{
-		[]time.Weekday{time.Sunday, time.Monday},
-		Clock{3, 0},
-		Clock{3, 59},
+		Every:     []time.Weekday{time.Sunday, time.Monday},
+		StartTime: Clock{3, 0},
+		EndTime:   Clock{3, 59},
 	}
 
 	var testTime TestTime
 
 	testTime = TestTime{time.Monday, 3, 0}
-	if !interval.Includes(testTime) {
+	if !interval.Includes(testTime.Time()) {
 		t.Fatalf("%v was not detected as including %v.",
 			interval, testTime)
 	}
 }
 
-func TestWeekdayIntervalsSingle(t *testing.T) {
-	intervals := RepeatingTimeIntervals{
-		{
-			[]time.Weekday{time.Monday},
-			Clock{0, 0}, Clock{12, 0},
-		},
-	}
-
-	// Only Monday should have an interval.
-	weekdayToIntervals := intervals.weekdayIntervals()
-	assert.Len(t, weekdayToIntervals[time.Sunday], 0)
-	assert.Len(t, weekdayToIntervals[time.Monday], 1)
-	assert.Len(t, weekdayToIntervals[time.Tuesday], 0)
-	assert.Len(t, weekdayToIntervals[time.Wednesday], 0)
-	assert.Len(t, weekdayToIntervals[time.Thursday], 0)
-	assert.Len(t, weekdayToIntervals[time.Friday], 0)
-	assert.Len(t, weekdayToIntervals[time.Saturday], 0)
-
-	// Monday should be 0-12.
-	assert.Equal(t, Interval{
-		start: time.Date(0, 0, 0, 0, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 12, 0, 0, 0, time.Local),
-	}, weekdayToIntervals[time.Monday][0])
-}
-
-func TestWeekdayIntervalsMultiplePerDay(t *testing.T) {
-	intervals := RepeatingTimeIntervals{
-		{
-			[]time.Weekday{time.Monday, time.Tuesday, time.Friday},
-			Clock{3, 0}, Clock{6, 0},
-		},
-		{
-			[]time.Weekday{time.Tuesday},
-			Clock{4, 0}, Clock{7, 0},
-		},
-		{
-			[]time.Weekday{time.Monday},
-			Clock{8, 0}, Clock{10, 30},
-		},
-	}
-
-	// Only Monday, Tuesday, and Friday should have intervals. Monday has 2.
-	weekdayToIntervals := intervals.weekdayIntervals()
-	assert.Len(t, weekdayToIntervals[time.Sunday], 0)
-	assert.Len(t, weekdayToIntervals[time.Monday], 2)
-	assert.Len(t, weekdayToIntervals[time.Tuesday], 1)
-	assert.Len(t, weekdayToIntervals[time.Wednesday], 0)
-	assert.Len(t, weekdayToIntervals[time.Thursday], 0)
-	assert.Len(t, weekdayToIntervals[time.Friday], 1)
-	assert.Len(t, weekdayToIntervals[time.Saturday], 0)
-
-	// Monday should be 3-6 and 8-10:30.
-	// In descending order of start time, so 8-10:30 first.
-	assert.Equal(t, Interval{
-		start: time.Date(0, 0, 0, 8, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 10, 30, 0, 0, time.Local),
-	}, weekdayToIntervals[time.Monday][0])
-	assert.Equal(t, Interval{
-		start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 6, 0, 0, 0, time.Local),
-	}, weekdayToIntervals[time.Monday][1])
-
-	// Tuesday should be 3-7.
-	assert.Equal(t, Interval{
-		start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 7, 0, 0, 0, time.Local),
-	}, weekdayToIntervals[time.Tuesday][0])
-
-	// Friday should be 3-6.
-	assert.Equal(t, Interval{
-		start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 6, 0, 0, 0, time.Local),
-	}, weekdayToIntervals[time.Friday][0])
-}
-
-func TestWithDate(t *testing.T) {
-	interval := Interval{
-		start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-		end:   time.Date(0, 0, 0, 6, 0, 0, 0, time.Local),
-	}
-
-	var year int
-	var month time.Month
-	var day int
-
-	var withDate Interval
-	withDate = interval.WithDate(2019, 12, 31)
-	year, month, day = withDate.start.Date()
-	assert.Equal(t, 2019, year)
-	assert.Equal(t, time.Month(12), month)
-	assert.Equal(t, 31, day)
-	year, month, day = withDate.end.Date()
-	assert.Equal(t, 2019, year)
-	assert.Equal(t, time.Month(12), month)
-	assert.Equal(t, 31, day)
-
-	// Should stay the same after doing WithDate again.
-	withDate = interval.WithDate(2019, 12, 31)
-	year, month, day = withDate.start.Date()
-	assert.Equal(t, 2019, year)
-	assert.Equal(t, time.Month(12), month)
-	assert.Equal(t, 31, day)
-	year, month, day = withDate.end.Date()
-	assert.Equal(t, 2019, year)
-	assert.Equal(t, time.Month(12), month)
-	assert.Equal(t, 31, day)
-}
-
-func TestTimeOverlapStartEnd(t *testing.T) {
-	// Test with an interval from 3-6:30 and 10-12.
-	intervals := []Interval{
-		{
-			start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 6, 30, 0, 0, time.Local),
-		},
-		{
-			start: time.Date(0, 0, 0, 10, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 12, 0, 0, 0, time.Local),
-		},
-	}
-
-	// Get the overlap between those intervals and 4-11.
-	// Start and end must be at the same date. Let's try Dec 31st, 2019.
-	start := time.Date(2019, 12, 31, 4, 0, 0, 0, time.Local)
-	end := time.Date(2019, 12, 31, 11, 0, 0, 0, time.Local)
-
-	overlap := timeOverlap(intervals, &start, &end)
-	// The expected overlap is 2.5 hours for the first interval,
-	// and 1 hour for the second interval, so 3.5 hours.
-	assert.Equal(t, 3.5, overlap.Hours())
-}
-
-func TestTimeOverlapStart(t *testing.T) {
-	// Test with an interval from 3-6:30 and 10-12.
-	intervals := []Interval{
-		{
-			start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 6, 30, 0, 0, time.Local),
-		},
-		{
-			start: time.Date(0, 0, 0, 10, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 12, 0, 0, 0, time.Local),
-		},
-	}
-
-	// Get the overlap between those intervals and 1 to end of day.
-	// Let's try Dec 31st, 2019.
-	start := time.Date(2019, 12, 31, 1, 0, 0, 0, time.Local)
-
-	overlap := timeOverlap(intervals, &start, nil)
-	// The expected overlap is 3.5 hours for the first interval,
-	// and 2 hours for the second interval, so 5.5 hours.
-	assert.Equal(t, 5.5, overlap.Hours())
-}
-
-func TestTimeOverlapEnd(t *testing.T) {
-	// Test with an interval from 3-6:30 and 10-12.
-	intervals := []Interval{
-		{
-			start: time.Date(0, 0, 0, 3, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 6, 30, 0, 0, time.Local),
-		},
-		{
-			start: time.Date(0, 0, 0, 10, 0, 0, 0, time.Local),
-			end:   time.Date(0, 0, 0, 12, 0, 0, 0, time.Local),
-		},
-	}
-
-	// Get the overlap between those intervals and start of day to 11.
-	// Let's try Dec 31st, 2019.
-	end := time.Date(2019, 12, 31, 11, 0, 0, 0, time.Local)
-
-	overlap := timeOverlap(intervals, nil, &end)
-	// The expected overlap is 3.5 hours for the first interval,
-	// and 1 hour for the second interval, so 4.5 hours.
-	assert.Equal(t, 4.5, overlap.Hours())
-}
-
 func TestTotalOverlapSingleDayNoIntervals(t *testing.T) {
 	intervals := RepeatingTimeIntervals{}
 
@@ -332,12 +151,12 @@ func TestTotalOverlapSingleDayWrongDayOfWeek(t *testing.T) {
 	// 0-3, 6-9 on a Tuesday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Monday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Monday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Monday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Monday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 	}
 
@@ -355,12 +174,12 @@ func TestTotalOverlapSingleDay(t *testing.T) {
 	// 0-3, 6-9 on a Tuesday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 	}
 
@@ -378,12 +197,12 @@ func TestTotalOverlapSingleDayPartial(t *testing.T) {
 	// 0-3, 6-9 on a Tuesday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 	}
 
@@ -403,16 +222,16 @@ func TestTotalOverlapTwoDay(t *testing.T) {
 	// 0-3, 6-9 on a Tuesday, and 12-20:30 on Wednesday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 		{
-			[]time.Weekday{time.Wednesday},
-			Clock{12, 0}, Clock{20, 30},
+			Every:     []time.Weekday{time.Wednesday},
+			StartTime: Clock{12, 0}, EndTime: Clock{20, 30},
 		},
 	}
 
@@ -433,16 +252,16 @@ func TestTotalOverlapTwoDayPartial(t *testing.T) {
 	// 0-3, 6-9 on a Tuesday, and 12-20:30 on Wednesday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 		{
-			[]time.Weekday{time.Wednesday},
-			Clock{12, 0}, Clock{20, 30},
+			Every:     []time.Weekday{time.Wednesday},
+			StartTime: Clock{12, 0}, EndTime: Clock{20, 30},
 		},
 	}
 
@@ -465,24 +284,24 @@ func TestTotalOverlapMultiday(t *testing.T) {
 	// and 12-20:30 on Friday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 		{
-			[]time.Weekday{time.Wednesday},
-			Clock{10, 0}, Clock{22, 0},
+			Every:     []time.Weekday{time.Wednesday},
+			StartTime: Clock{10, 0}, EndTime: Clock{22, 0},
 		},
 		{
-			[]time.Weekday{time.Thursday},
-			Clock{0, 0}, Clock{12, 0},
+			Every:     []time.Weekday{time.Thursday},
+			StartTime: Clock{0, 0}, EndTime: Clock{12, 0},
 		},
 		{
-			[]time.Weekday{time.Friday},
-			Clock{12, 0}, Clock{20, 30},
+			Every:     []time.Weekday{time.Friday},
+			StartTime: Clock{12, 0}, EndTime: Clock{20, 30},
 		},
 	}
 
@@ -506,24 +325,24 @@ func TestTotalOverlapMultimonth(t *testing.T) {
 	// and 12-20:30 on Friday.
 	intervals := RepeatingTimeIntervals{
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{0, 0}, Clock{3, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{0, 0}, EndTime: Clock{3, 0},
 		},
 		{
-			[]time.Weekday{time.Tuesday},
-			Clock{6, 0}, Clock{9, 0},
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{6, 0}, EndTime: Clock{9, 0},
 		},
 		{
-			[]time.Weekday{time.Wednesday},
-			Clock{10, 0}, Clock{22, 0},
+			Every:     []time.Weekday{time.Wednesday},
+			StartTime: Clock{10, 0}, EndTime: Clock{22, 0},
 		},
 		{
-			[]time.Weekday{time.Thursday},
-			Clock{0, 0}, Clock{12, 0},
+			Every:     []time.Weekday{time.Thursday},
+			StartTime: Clock{0, 0}, EndTime: Clock{12, 0},
 		},
 		{
-			[]time.Weekday{time.Friday},
-			Clock{12, 0}, Clock{20, 30},
+			Every:     []time.Weekday{time.Friday},
+			StartTime: Clock{12, 0}, EndTime: Clock{20, 30},
 		},
 	}
 
@@ -550,3 +369,142 @@ func TestTotalOverlapMultimonth(t *testing.T) {
 	overlap := intervals.TotalOverlap(start, end)
 	assert.Equal(t, 379.5, overlap.Hours())
 }
+
+func TestTotalOverlapOverlappingIntervals(t *testing.T) {
+	// 3-6 and 4-7 on Tuesday should only count 3-7 once.
+	intervals := RepeatingTimeIntervals{
+		{
+			Every:     []time.Weekday{time.Monday, time.Tuesday},
+			StartTime: Clock{3, 0}, EndTime: Clock{6, 0},
+		},
+		{
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{4, 0}, EndTime: Clock{7, 0},
+		},
+	}
+
+	// Dec 31st, 2019 is a Tuesday.
+	start := time.Date(2019, 12, 31, 0, 0, 0, 0, time.Local)
+	end := time.Date(2019, 12, 31, 24, 0, 0, 0, time.Local)
+
+	overlap := intervals.TotalOverlap(start, end)
+	assert.Equal(t, 4.0, overlap.Hours())
+}
+
+func TestIncludesOvernight(t *testing.T) {
+	// Friday 22:00 to Saturday 6:00.
+	interval := RepeatingTimeInterval{
+		Every:     []time.Weekday{time.Friday},
+		StartTime: Clock{22, 0},
+		EndTime:   Clock{6, 0},
+	}
+
+	assert.False(t, interval.Includes(TestTime{time.Friday, 21, 59}.Time()))
+	assert.True(t, interval.Includes(TestTime{time.Friday, 22, 0}.Time()))
+	assert.True(t, interval.Includes(TestTime{time.Saturday, 3, 0}.Time()))
+	assert.True(t, interval.Includes(TestTime{time.Saturday, 6, 0}.Time()))
+	assert.False(t, interval.Includes(TestTime{time.Saturday, 6, 1}.Time()))
+	assert.False(t, interval.Includes(TestTime{time.Saturday, 22, 0}.Time()))
+	assert.False(t, interval.Includes(TestTime{time.Friday, 3, 0}.Time()))
+}
+
+func TestTotalOverlapOvernight(t *testing.T) {
+	// 22:00 to 6:00, starting Tuesdays.
+	intervals := RepeatingTimeIntervals{
+		{
+			Every:     []time.Weekday{time.Tuesday},
+			StartTime: Clock{22, 0},
+			EndTime:   Clock{6, 0},
+		},
+	}
+
+	// From Wednesday at 1:00 to Wednesday at noon, the tail of Tuesday's interval.
+	start := time.Date(2020, 1, 1, 1, 0, 0, 0, time.Local)
+	end := time.Date(2020, 1, 1, 12, 0, 0, 0, time.Local)
+	assert.Equal(t, 5.0, intervals.TotalOverlap(start, end).Hours())
+
+	// Across the whole week.
+	start = time.Date(2019, 12, 29, 0, 0, 0, 0, time.Local)
+	end = time.Date(2020, 1, 5, 0, 0, 0, 0, time.Local)
+	assert.Equal(t, 8.0, intervals.TotalOverlap(start, end).Hours())
+}
+
+func TestIncludesDuplicateWeekdays(t *testing.T) {
+	interval := RepeatingTimeInterval{
+		Every:     []time.Weekday{time.Monday, time.Monday, time.Tuesday},
+		StartTime: Clock{9, 0},
+		EndTime:   Clock{17, 0},
+	}
+	assert.True(t, interval.Includes(TestTime{time.Tuesday, 12, 0}.Time()))
+	assert.False(t, interval.Includes(TestTime{time.Tuesday, 8, 0}.Time()))
+}
+
+func TestIncludesTimezone(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	assert.NoError(t, err)
+
+	// 9-17 on Mondays in India.
+	interval := RepeatingTimeInterval{
+		Every:     []time.Weekday{time.Monday},
+		StartTime: Clock{9, 0},
+		EndTime:   Clock{17, 0},
+		Timezone:  "Asia/Kolkata",
+	}
+
+	// Monday 10:00 in India is Sunday 20:30 in California.
+	assert.True(t, interval.Includes(time.Date(2019, 12, 29, 20, 30, 0, 0, losAngeles)))
+	assert.True(t, interval.Includes(time.Date(2019, 12, 30, 10, 0, 0, 0, kolkata)))
+	assert.False(t, interval.Includes(time.Date(2019, 12, 30, 10, 0, 0, 0, losAngeles)))
+
+	// A full Monday in California starts at 13:30 on Monday in India, so it overlaps 13:30-17:00.
+	start := time.Date(2019, 12, 30, 0, 0, 0, 0, losAngeles)
+	end := time.Date(2019, 12, 31, 0, 0, 0, 0, losAngeles)
+	assert.Equal(t, 3.5, RepeatingTimeIntervals{interval}.TotalOverlap(start, end).Hours())
+}
+
+func TestIncludesExcept(t *testing.T) {
+	// Mondays, except Dec 30th, 2019.
+	interval := RepeatingTimeInterval{
+		Every:     []time.Weekday{time.Monday},
+		StartTime: Clock{22, 0},
+		EndTime:   Clock{2, 0},
+		Except:    []string{"2019-12-30"},
+	}
+
+	assert.False(t, interval.Includes(time.Date(2019, 12, 30, 23, 0, 0, 0, time.Local)))
+	assert.False(t, interval.Includes(time.Date(2019, 12, 31, 1, 0, 0, 0, time.Local)))
+	assert.True(t, interval.Includes(time.Date(2020, 1, 6, 23, 0, 0, 0, time.Local)))
+
+	start := time.Date(2019, 12, 23, 0, 0, 0, 0, time.Local)
+	end := time.Date(2020, 1, 13, 0, 0, 0, 0, time.Local)
+	assert.Equal(t, 8.0, RepeatingTimeIntervals{interval}.TotalOverlap(start, end).Hours())
+}
+
+func TestRepeatingTimeIntervalValidate(t *testing.T) {
+	valid := RepeatingTimeInterval{
+		Every:     []time.Weekday{time.Monday},
+		StartTime: Clock{9, 0},
+		EndTime:   Clock{24, 0},
+		Timezone:  "Asia/Kolkata",
+		Except:    []string{"2019-12-30"},
+	}
+	assert.NoError(t, valid.Validate())
+
+	invalid := valid
+	invalid.Timezone = "Mars/Olympus_Mons"
+	assert.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.Except = []string{"next monday"}
+	assert.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.StartTime = Clock{24, 0}
+	assert.Error(t, invalid.Validate())
+
+	invalid = valid
+	invalid.EndTime = Clock{24, 30}
+	assert.Error(t, invalid.Validate())
+}